@@ -6,8 +6,8 @@ import (
 	"cutrix-backend/internal/handlers"
 	"cutrix-backend/internal/repositories"
 	"cutrix-backend/internal/services"
+	"cutrix-backend/pkg/auth"
 	"cutrix-backend/pkg/database"
-	"cutrix-backend/pkg/middleware"
 	"log"
 	"net/http"
 	"os"
@@ -32,14 +32,10 @@ func main() {
 	}
 	defer db.Close()
 
-	// ... (设置 Gin 模式和中间件部分不变)
+	// ... (设置 Gin 模式部分不变)
 	if cfg.Environment == "production" {
 		gin.SetMode(gin.ReleaseMode)
 	}
-	r := gin.Default()
-	r.Use(middleware.CORS())
-	r.Use(middleware.Logger())
-	r.Use(middleware.Recovery())
 
 	// ======== 统一初始化所有仓库 (Repositories) ========
 	styleRepo := repositories.NewStyleRepository(db)
@@ -48,89 +44,59 @@ func main() {
 	workerRepo := repositories.NewWorkerRepository(db)
 	orderRepo := repositories.NewProductionOrderRepository(db)
 	planRepo := repositories.NewProductionPlanRepository(db)
+	sessionRepo := repositories.NewSessionRepository(db)
+	loginEventRepo := repositories.NewLoginEventRepository(db)
+	apiKeyRepo := repositories.NewAPIKeyRepository(db)
+	auditRepo := repositories.NewAuditRepository(db)
+	roleRepo := repositories.NewRoleRepository(db)
+	settingsRepo := repositories.NewSettingsRepository(db)
+	customerRepo := repositories.NewCustomerRepository(db)
+	recycleBinRepo := repositories.NewRecycleBinRepository(db)
 
 	// ======== 统一初始化所有服务 (Services) ========
-	authService := services.NewAuthService(workerRepo)
-	styleService := services.NewStyleService(styleRepo)
+	tokenManager := auth.NewTokenManager(cfg.JWTSecret, cfg.AccessTokenTTL)
+	authService := services.NewAuthService(db, workerRepo, sessionRepo, loginEventRepo, tokenManager, services.AuthSettings{
+		RefreshTTL:             cfg.RefreshTokenTTL,
+		MaxFailedAttempts:      cfg.LoginMaxAttempts,
+		MaxFailedAttemptsPerIP: cfg.LoginMaxAttemptsPerIP,
+		LockoutDuration:        cfg.LoginLockoutDuration,
+		KioskTTL:               cfg.KioskTokenTTL,
+	})
+	styleService := services.NewStyleService(db, styleRepo, recycleBinRepo, auditRepo)
 	taskService := services.NewTaskService(taskRepo, styleRepo)
-	logService := services.NewLogService(logRepo)
-	orderService := services.NewProductionOrderService(db, orderRepo, styleRepo)
-	planService := services.NewProductionPlanService(db, planRepo)
-	workerService := services.NewWorkerService(workerRepo)
+	logService := services.NewLogService(db, logRepo, orderRepo, auditRepo)
+	orderService := services.NewProductionOrderService(db, orderRepo, styleRepo, planRepo, customerRepo, settingsRepo, recycleBinRepo, auditRepo)
+	planService := services.NewProductionPlanService(db, planRepo, orderRepo, styleRepo, recycleBinRepo, auditRepo)
+	workerService := services.NewWorkerService(db, workerRepo, sessionRepo, roleRepo, recycleBinRepo, auditRepo)
+	apiKeyService := services.NewAPIKeyService(apiKeyRepo)
+	auditService := services.NewAuditService(auditRepo)
+	roleService := services.NewRoleService(db, roleRepo, auditRepo)
+	settingsService := services.NewSettingsService(db, settingsRepo, auditRepo)
+	exportService := services.NewExportService(orderRepo, planRepo, logRepo)
+	customerService := services.NewCustomerService(db, customerRepo, auditRepo)
+	recycleBinService := services.NewRecycleBinService(db, recycleBinRepo, orderRepo, planRepo, styleRepo, workerRepo, roleRepo, auditRepo)
 
 	// ======== 统一初始化所有处理器 (Handlers) ========
-	authHandler := handlers.NewAuthHandler(authService)
-	styleHandler := handlers.NewStyleHandler(styleService)
-	taskHandler := handlers.NewTaskHandler(taskService)
-	logHandler := handlers.NewLogHandler(logService)
-	orderHandler := handlers.NewProductionOrderHandler(orderService)
-	planHandler := handlers.NewProductionPlanHandler(planService)
-	workerHandler := handlers.NewWorkerHandler(workerService)
-
-	// ... (API 路由组部分不变)
-	api := r.Group("/api")
-	{
-		// 认证
-		auth := api.Group("/auth")
-		{
-			auth.POST("/login", authHandler.Login)
-		}
-
-		// 款号管理
-		styles := api.Group("/styles")
-		{
-			styles.POST("", styleHandler.CreateStyle)
-			styles.GET("", styleHandler.GetStyles)
-			styles.GET("/:id", styleHandler.GetStyle)
-		}
-
-		// 生产订单管理 (新)
-		orders := api.Group("/production-orders")
-		{
-			orders.POST("", orderHandler.CreateOrder)
-			orders.GET("", orderHandler.GetOrders)
-			orders.GET("/unplanned", orderHandler.GetUnplannedOrders)
-			orders.GET("/:id", orderHandler.GetOrder)
-			orders.DELETE("/:id", orderHandler.DeleteOrder)
-		}
-
-		// 生产计划管理 (新)
-		plans := api.Group("/production-plans")
-		{
-			plans.POST("", planHandler.CreatePlan)
-			plans.GET("", planHandler.GetPlans)
-			plans.GET("/:id", planHandler.GetPlan)
-			plans.PUT("/:id", planHandler.UpdatePlan)
-			plans.DELETE("/:id", planHandler.DeletePlan)
-			plans.GET("/by-order/:order_id", planHandler.GetPlanByOrderID)
-		}
-		// 生产任务管理
-		tasks := api.Group("/tasks")
-		{
-			tasks.GET("", taskHandler.GetTasks)
-			tasks.GET("/:id", taskHandler.GetTask)
-			tasks.GET("/progress", taskHandler.GetTaskProgress)
-		}
-
-		// 生产记录
-		logs := api.Group("/production-logs")
-		{
-			logs.POST("", logHandler.CreateProductionLog)
-			logs.GET("/task/:taskID", logHandler.GetLogsByTaskID)
-		}
-
-		// 员工管理
-		workers := api.Group("/workers")
-		{
-			workers.GET("", workerHandler.GetWorkers)
-			workers.POST("", workerHandler.CreateWorker)
-			workers.GET("/:id", workerHandler.GetWorker)
-			workers.PUT("/:id", workerHandler.UpdateWorker)
-			workers.DELETE("/:id", workerHandler.DeleteWorker)
-			workers.GET("/:id/tasks", workerHandler.GetWorkerTasks)
-			workers.PUT("/:id/password", workerHandler.UpdateWorkerPassword)
-			workers.GET("/:id/task-groups", workerHandler.GetWorkerTaskGroups) // <-- 在这里添加新路由
-		}
+	r := newRouter(routeHandlers{
+		auth:       handlers.NewAuthHandler(authService),
+		style:      handlers.NewStyleHandler(styleService),
+		task:       handlers.NewTaskHandler(taskService),
+		log:        handlers.NewLogHandler(logService),
+		order:      handlers.NewProductionOrderHandler(orderService),
+		plan:       handlers.NewProductionPlanHandler(planService),
+		worker:     handlers.NewWorkerHandler(workerService),
+		apiKey:     handlers.NewAPIKeyHandler(apiKeyService),
+		audit:      handlers.NewAuditHandler(auditService),
+		role:       handlers.NewRoleHandler(roleService),
+		settings:   handlers.NewSettingsHandler(settingsService),
+		export:     handlers.NewExportHandler(exportService),
+		customer:   handlers.NewCustomerHandler(customerService),
+		recycleBin: handlers.NewRecycleBinHandler(recycleBinService),
+	}, tokenManager, authService, apiKeyService, roleService)
+
+	// 确保每条 API 路由都登记了访问规则
+	if missing, stale := routePolicy.Uncovered(r.Routes()); len(missing) > 0 || len(stale) > 0 {
+		log.Fatalf("Route policy out of sync: missing=%v stale=%v", missing, stale)
 	}
 
 	// ... (静态文件服务和服务器启动部分不变)
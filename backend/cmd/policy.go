@@ -0,0 +1,114 @@
+package main
+
+import (
+	"cutrix-backend/pkg/auth"
+	"cutrix-backend/pkg/middleware"
+)
+
+// routePolicy 定义每条 API 路由所需的权限，以及 API 密钥访问所需的权限范围。
+// 角色拥有哪些权限由管理员在角色管理中维护。
+// 新增路由时必须在此登记，否则服务启动时会报错退出。
+var routePolicy = middleware.Policy{
+	// 认证
+	"POST /api/auth/login":       {Public: true},
+	"POST /api/auth/kiosk-login": {Public: true},
+	"POST /api/auth/refresh":     {Public: true},
+	"POST /api/auth/logout":      {Public: true},
+	"PUT /api/auth/password":     {Authenticated: true},
+	"GET /api/auth/login-events": {Permission: auth.PermSecurityView},
+
+	// 角色与权限
+	"GET /api/roles":        {Permission: auth.PermRoleManage},
+	"GET /api/roles/:id":    {Permission: auth.PermRoleManage},
+	"POST /api/roles":       {Permission: auth.PermRoleManage},
+	"PUT /api/roles/:id":    {Permission: auth.PermRoleManage},
+	"DELETE /api/roles/:id": {Permission: auth.PermRoleManage},
+	"GET /api/permissions":  {Permission: auth.PermRoleManage},
+
+	// 系统设置
+	"GET /api/settings/order-number": {Permission: auth.PermSettingsManage},
+	"PUT /api/settings/order-number": {Permission: auth.PermSettingsManage},
+
+	// 系统集成 API 密钥
+	"GET /api/api-keys":        {Permission: auth.PermAPIKeyManage},
+	"POST /api/api-keys":       {Permission: auth.PermAPIKeyManage},
+	"DELETE /api/api-keys/:id": {Permission: auth.PermAPIKeyManage},
+
+	// 审计日志
+	"GET /api/audit": {Permission: auth.PermAuditView},
+
+	// 回收站
+	"GET /api/recycle-bin":                           {Permission: auth.PermRecycleBinManage},
+	"POST /api/recycle-bin/:entity_type/:id/restore": {Permission: auth.PermRecycleBinManage},
+	"DELETE /api/recycle-bin/:entity_type/:id":       {Permission: auth.PermRecycleBinManage},
+
+	// 款号管理
+	"POST /api/styles":       {Permission: auth.PermStyleManage, APIScope: auth.APIScopeStylesWrite},
+	"GET /api/styles":        {Permission: auth.PermStyleView, APIScope: auth.APIScopeStylesRead},
+	"GET /api/styles/:id":    {Permission: auth.PermStyleView, APIScope: auth.APIScopeStylesRead},
+	"PUT /api/styles/:id":    {Permission: auth.PermStyleManage, APIScope: auth.APIScopeStylesWrite},
+	"DELETE /api/styles/:id": {Permission: auth.PermStyleManage, APIScope: auth.APIScopeStylesWrite},
+
+	// 客户管理
+	"GET /api/customers":        {Permission: auth.PermCustomerView, APIScope: auth.APIScopeCustomersRead},
+	"POST /api/customers":       {Permission: auth.PermCustomerManage, APIScope: auth.APIScopeCustomersWrite},
+	"GET /api/customers/:id":    {Permission: auth.PermCustomerView, APIScope: auth.APIScopeCustomersRead},
+	"PUT /api/customers/:id":    {Permission: auth.PermCustomerManage, APIScope: auth.APIScopeCustomersWrite},
+	"DELETE /api/customers/:id": {Permission: auth.PermCustomerManage, APIScope: auth.APIScopeCustomersWrite},
+
+	// 生产订单管理
+	"POST /api/production-orders":                   {Permission: auth.PermOrderCreate, APIScope: auth.APIScopeOrdersWrite},
+	"POST /api/production-orders/import":            {Permission: auth.PermOrderCreate, APIScope: auth.APIScopeOrdersWrite},
+	"GET /api/production-orders":                    {Permission: auth.PermOrderView, APIScope: auth.APIScopeOrdersRead},
+	"GET /api/production-orders/unplanned":          {Permission: auth.PermOrderView, APIScope: auth.APIScopeOrdersRead},
+	"GET /api/production-orders/at-risk":            {Permission: auth.PermOrderView, APIScope: auth.APIScopeOrdersRead},
+	"GET /api/production-orders/:id":                {Permission: auth.PermOrderView, APIScope: auth.APIScopeOrdersRead},
+	"PUT /api/production-orders/:id":                {Permission: auth.PermOrderEdit, APIScope: auth.APIScopeOrdersWrite},
+	"PUT /api/production-orders/:id/details":        {Permission: auth.PermOrderEdit, APIScope: auth.APIScopeOrdersWrite},
+	"POST /api/production-orders/:id/confirm":       {Permission: auth.PermOrderTransition, APIScope: auth.APIScopeOrdersWrite},
+	"POST /api/production-orders/:id/cancel":        {Permission: auth.PermOrderTransition, APIScope: auth.APIScopeOrdersWrite},
+	"POST /api/production-orders/:id/handover":      {Permission: auth.PermOrderTransition, APIScope: auth.APIScopeOrdersWrite},
+	"GET /api/production-orders/:id/status-history": {Permission: auth.PermOrderView, APIScope: auth.APIScopeOrdersRead},
+	"GET /api/production-orders/:id/fulfillment":    {Permission: auth.PermOrderView, APIScope: auth.APIScopeOrdersRead},
+	"GET /api/production-orders/:id/plans":          {Permission: auth.PermPlanList, APIScope: auth.APIScopePlansRead},
+	"DELETE /api/production-orders/:id":             {Permission: auth.PermOrderDelete, APIScope: auth.APIScopeOrdersWrite},
+
+	// 生产计划管理 (工人需要查看计划详情来执行任务)
+	"POST /api/production-plans":                   {Permission: auth.PermPlanCreate, APIScope: auth.APIScopePlansWrite},
+	"POST /api/production-plans/cut-plan":          {Permission: auth.PermPlanCreate, APIScope: auth.APIScopePlansRead},
+	"GET /api/production-plans":                    {Permission: auth.PermPlanList, APIScope: auth.APIScopePlansRead},
+	"GET /api/production-plans/:id":                {Permission: auth.PermPlanView, APIScope: auth.APIScopePlansRead},
+	"PUT /api/production-plans/:id":                {Permission: auth.PermPlanEdit, APIScope: auth.APIScopePlansWrite},
+	"DELETE /api/production-plans/:id":             {Permission: auth.PermPlanDelete, APIScope: auth.APIScopePlansWrite},
+	"GET /api/production-plans/by-order/:order_id": {Permission: auth.PermPlanList, APIScope: auth.APIScopePlansRead},
+
+	// 生产任务
+	"GET /api/tasks":          {Permission: auth.PermTaskView, APIScope: auth.APIScopeTasksRead},
+	"GET /api/tasks/:id":      {Permission: auth.PermTaskView, APIScope: auth.APIScopeTasksRead},
+	"GET /api/tasks/progress": {Permission: auth.PermTaskView, APIScope: auth.APIScopeTasksRead},
+
+	// 生产记录 (代他人提交需要 log.create_for_others 权限，由 LogHandler 校验；
+	// 通过 API 密钥提交的记录会标记来源集成)
+	"POST /api/production-logs":             {Permission: auth.PermLogCreate, Kiosk: true, APIScope: auth.APIScopeLogsWrite},
+	"GET /api/production-logs/task/:taskID": {Permission: auth.PermLogView, APIScope: auth.APIScopeLogsRead},
+
+	// 导出
+	"GET /api/exports/orders":          {Permission: auth.PermOrderView, APIScope: auth.APIScopeOrdersRead},
+	"GET /api/exports/plans":           {Permission: auth.PermPlanList, APIScope: auth.APIScopePlansRead},
+	"GET /api/exports/production-logs": {Permission: auth.PermLogView, APIScope: auth.APIScopeLogsRead},
+
+	// 员工管理
+	"GET /api/workers":                 {Permission: auth.PermWorkerView},
+	"POST /api/workers":                {Permission: auth.PermWorkerManage},
+	"GET /api/workers/:id":             {Permission: auth.PermWorkerView, Self: true},
+	"PUT /api/workers/:id":             {Permission: auth.PermWorkerManage},
+	"DELETE /api/workers/:id":          {Permission: auth.PermWorkerManage},
+	"GET /api/workers/:id/tasks":       {Permission: auth.PermWorkerView, Self: true},
+	"PUT /api/workers/:id/password":    {Permission: auth.PermWorkerManage},
+	"POST /api/workers/:id/unlock":     {Permission: auth.PermWorkerUnlock},
+	"PUT /api/workers/:id/pin":         {Permission: auth.PermWorkerManage},
+	"DELETE /api/workers/:id/pin":      {Permission: auth.PermWorkerManage},
+	"PUT /api/workers/:id/badge":       {Permission: auth.PermWorkerManage},
+	"DELETE /api/workers/:id/badge":    {Permission: auth.PermWorkerManage},
+	"GET /api/workers/:id/task-groups": {Permission: auth.PermWorkerView, Self: true, Kiosk: true},
+}
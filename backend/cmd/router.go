@@ -0,0 +1,190 @@
+package main
+
+import (
+	"cutrix-backend/internal/handlers"
+	"cutrix-backend/pkg/auth"
+	"cutrix-backend/pkg/middleware"
+
+	"github.com/gin-gonic/gin"
+)
+
+// routeHandlers 是注册 API 路由所需的全部处理器
+type routeHandlers struct {
+	auth       *handlers.AuthHandler
+	style      *handlers.StyleHandler
+	task       *handlers.TaskHandler
+	log        *handlers.LogHandler
+	order      *handlers.ProductionOrderHandler
+	plan       *handlers.ProductionPlanHandler
+	worker     *handlers.WorkerHandler
+	apiKey     *handlers.APIKeyHandler
+	audit      *handlers.AuditHandler
+	role       *handlers.RoleHandler
+	settings   *handlers.SettingsHandler
+	export     *handlers.ExportHandler
+	customer   *handlers.CustomerHandler
+	recycleBin *handlers.RecycleBinHandler
+}
+
+// newRouter 创建 Gin 引擎并注册全部 API 路由。除登录等公开接口外，每条路由都先经 AuthRequired 认证，
+// 再按 routePolicy 授权；静态文件和前端页面由 main 注册
+func newRouter(h routeHandlers, tokens *auth.TokenManager, sessions middleware.SessionChecker, apiKeys middleware.APIKeyChecker, permissions middleware.PermissionResolver) *gin.Engine {
+	r := gin.Default()
+	r.Use(middleware.CORS())
+	r.Use(middleware.Logger())
+	r.Use(middleware.Recovery())
+
+	api := r.Group("/api")
+	{
+		// 认证 (无需令牌)
+		authGroup := api.Group("/auth")
+		{
+			authGroup.POST("/login", h.auth.Login)
+			authGroup.POST("/kiosk-login", h.auth.KioskLogin)
+			authGroup.POST("/refresh", h.auth.Refresh)
+			authGroup.POST("/logout", h.auth.Logout)
+		}
+	}
+
+	// 以下路由均需携带有效的访问令牌，并按 routePolicy 进行角色授权
+	api = r.Group("/api", middleware.AuthRequired(tokens, sessions, apiKeys), middleware.Authorize(routePolicy, permissions))
+	{
+		// 当前登录员工的账户操作
+		account := api.Group("/auth")
+		{
+			account.PUT("/password", h.auth.ChangePassword)
+			account.GET("/login-events", h.auth.GetLoginEvents)
+		}
+
+		// 角色与权限管理
+		roles := api.Group("/roles")
+		{
+			roles.GET("", h.role.GetRoles)
+			roles.POST("", h.role.CreateRole)
+			roles.GET("/:id", h.role.GetRole)
+			roles.PUT("/:id", h.role.UpdateRole)
+			roles.DELETE("/:id", h.role.DeleteRole)
+		}
+		api.GET("/permissions", h.role.GetPermissions)
+
+		// 系统设置
+		settings := api.Group("/settings")
+		{
+			settings.GET("/order-number", h.settings.GetOrderNumberSettings)
+			settings.PUT("/order-number", h.settings.UpdateOrderNumberSettings)
+		}
+
+		// 系统集成 API 密钥管理
+		apiKeys := api.Group("/api-keys")
+		{
+			apiKeys.GET("", h.apiKey.GetAPIKeys)
+			apiKeys.POST("", h.apiKey.CreateAPIKey)
+			apiKeys.DELETE("/:id", h.apiKey.RevokeAPIKey)
+		}
+
+		// 审计日志
+		api.GET("/audit", h.audit.GetAuditLogs)
+
+		// 回收站
+		recycleBin := api.Group("/recycle-bin")
+		{
+			recycleBin.GET("", h.recycleBin.GetArchived)
+			recycleBin.POST("/:entity_type/:id/restore", h.recycleBin.RestoreArchived)
+			recycleBin.DELETE("/:entity_type/:id", h.recycleBin.PurgeArchived)
+		}
+
+		// 款号管理
+		styles := api.Group("/styles")
+		{
+			styles.POST("", h.style.CreateStyle)
+			styles.GET("", h.style.GetStyles)
+			styles.GET("/:id", h.style.GetStyle)
+			styles.PUT("/:id", h.style.UpdateStyle)
+			styles.DELETE("/:id", h.style.DeleteStyle)
+		}
+
+		// 客户管理
+		customers := api.Group("/customers")
+		{
+			customers.GET("", h.customer.GetCustomers)
+			customers.POST("", h.customer.CreateCustomer)
+			customers.GET("/:id", h.customer.GetCustomer)
+			customers.PUT("/:id", h.customer.UpdateCustomer)
+			customers.DELETE("/:id", h.customer.DeleteCustomer)
+		}
+
+		// 生产订单管理 (新)
+		orders := api.Group("/production-orders")
+		{
+			orders.POST("", h.order.CreateOrder)
+			orders.GET("", h.order.GetOrders)
+			orders.GET("/unplanned", h.order.GetUnplannedOrders)
+			orders.GET("/at-risk", h.order.GetAtRiskOrders)
+			orders.POST("/import", h.order.ImportOrders)
+			orders.GET("/:id", h.order.GetOrder)
+			orders.PUT("/:id", h.order.UpdateOrder)
+			orders.PUT("/:id/details", h.order.UpdateOrderDetails)
+			orders.POST("/:id/confirm", h.order.ConfirmOrder)
+			orders.POST("/:id/cancel", h.order.CancelOrder)
+			orders.POST("/:id/handover", h.order.HandOverOrder)
+			orders.GET("/:id/status-history", h.order.GetOrderStatusHistory)
+			orders.GET("/:id/fulfillment", h.order.GetOrderFulfillment)
+			orders.GET("/:id/plans", h.plan.GetPlansByOrderID)
+			orders.DELETE("/:id", h.order.DeleteOrder)
+		}
+
+		// 生产计划管理 (新)
+		plans := api.Group("/production-plans")
+		{
+			plans.POST("", h.plan.CreatePlan)
+			plans.POST("/cut-plan", h.plan.ProposeCutPlan)
+			plans.GET("", h.plan.GetPlans)
+			plans.GET("/:id", h.plan.GetPlan)
+			plans.PUT("/:id", h.plan.UpdatePlan)
+			plans.DELETE("/:id", h.plan.DeletePlan)
+			plans.GET("/by-order/:order_id", h.plan.GetPlanByOrderID)
+		}
+		// 生产任务管理
+		tasks := api.Group("/tasks")
+		{
+			tasks.GET("", h.task.GetTasks)
+			tasks.GET("/:id", h.task.GetTask)
+			tasks.GET("/progress", h.task.GetTaskProgress)
+		}
+
+		// 生产记录
+		logs := api.Group("/production-logs")
+		{
+			logs.POST("", h.log.CreateProductionLog)
+			logs.GET("/task/:taskID", h.log.GetLogsByTaskID)
+		}
+
+		// 导出 (XLSX / CSV)
+		exports := api.Group("/exports")
+		{
+			exports.GET("/orders", h.export.ExportOrders)
+			exports.GET("/plans", h.export.ExportPlans)
+			exports.GET("/production-logs", h.export.ExportLogs)
+		}
+
+		// 员工管理
+		workers := api.Group("/workers")
+		{
+			workers.GET("", h.worker.GetWorkers)
+			workers.POST("", h.worker.CreateWorker)
+			workers.GET("/:id", h.worker.GetWorker)
+			workers.PUT("/:id", h.worker.UpdateWorker)
+			workers.DELETE("/:id", h.worker.DeleteWorker)
+			workers.GET("/:id/tasks", h.worker.GetWorkerTasks)
+			workers.PUT("/:id/password", h.worker.UpdateWorkerPassword)
+			workers.POST("/:id/unlock", h.worker.UnlockWorker)
+			workers.PUT("/:id/pin", h.worker.UpdateWorkerPin)
+			workers.DELETE("/:id/pin", h.worker.ClearWorkerPin)
+			workers.PUT("/:id/badge", h.worker.UpdateWorkerBadge)
+			workers.DELETE("/:id/badge", h.worker.ClearWorkerBadge)
+			workers.GET("/:id/task-groups", h.worker.GetWorkerTaskGroups) // <-- 在这里添加新路由
+		}
+	}
+
+	return r
+}
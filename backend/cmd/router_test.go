@@ -0,0 +1,444 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"cutrix-backend/internal/handlers"
+	"cutrix-backend/internal/models"
+	"cutrix-backend/pkg/auth"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 测试中调用者的员工ID；路径参数 :id 使用 otherWorkerID，即访问的不是调用者本人
+const (
+	callerWorkerID = 7
+	otherWorkerID  = 99
+)
+
+// rolePermissions 是迁移脚本为内置角色写入的默认权限
+var rolePermissions = map[string][]string{
+	"admin": {
+		auth.PermStyleView, auth.PermStyleManage, auth.PermCustomerView, auth.PermCustomerManage,
+		auth.PermOrderView, auth.PermOrderCreate, auth.PermOrderEdit, auth.PermOrderTransition, auth.PermOrderDelete,
+		auth.PermPlanList, auth.PermPlanView, auth.PermPlanCreate, auth.PermPlanEdit, auth.PermPlanDelete,
+		auth.PermTaskView, auth.PermLogView, auth.PermLogCreate, auth.PermLogCreateForOthers, auth.PermLogVoid,
+		auth.PermWorkerView, auth.PermWorkerManage, auth.PermWorkerUnlock, auth.PermSecurityView, auth.PermAuditView,
+		auth.PermAPIKeyManage, auth.PermRoleManage, auth.PermSettingsManage, auth.PermRecycleBinManage,
+	},
+	"manager": {
+		auth.PermStyleView, auth.PermStyleManage, auth.PermCustomerView, auth.PermCustomerManage,
+		auth.PermOrderView, auth.PermOrderCreate, auth.PermOrderEdit, auth.PermOrderTransition, auth.PermOrderDelete,
+		auth.PermPlanList, auth.PermPlanView, auth.PermPlanCreate, auth.PermPlanEdit, auth.PermPlanDelete,
+		auth.PermTaskView, auth.PermLogView, auth.PermLogCreate, auth.PermLogCreateForOthers, auth.PermLogVoid,
+		auth.PermWorkerView, auth.PermWorkerManage, auth.PermSecurityView,
+	},
+	"pattern_maker": {
+		auth.PermStyleView, auth.PermCustomerView, auth.PermOrderView, auth.PermPlanList, auth.PermPlanView,
+		auth.PermTaskView, auth.PermLogView, auth.PermLogCreate,
+	},
+	"worker": {
+		auth.PermStyleView, auth.PermPlanView, auth.PermTaskView, auth.PermLogView, auth.PermLogCreate,
+	},
+}
+
+var (
+	public    = []string{}
+	everyone  = []string{"admin", "manager", "pattern_maker", "worker"}
+	office    = []string{"admin", "manager", "pattern_maker"}
+	managers  = []string{"admin", "manager"}
+	adminOnly = []string{"admin"}
+)
+
+// expectedAccess 列出每条 API 路由允许访问的内置角色 (public 表示无需登录)。
+// 新增路由时必须在此登记，否则 TestRoutePolicy 失败
+var expectedAccess = map[string][]string{
+	"POST /api/auth/login":       public,
+	"POST /api/auth/kiosk-login": public,
+	"POST /api/auth/refresh":     public,
+	"POST /api/auth/logout":      public,
+	"PUT /api/auth/password":     everyone,
+	"GET /api/auth/login-events": managers,
+
+	"GET /api/roles":        adminOnly,
+	"GET /api/roles/:id":    adminOnly,
+	"POST /api/roles":       adminOnly,
+	"PUT /api/roles/:id":    adminOnly,
+	"DELETE /api/roles/:id": adminOnly,
+	"GET /api/permissions":  adminOnly,
+
+	"GET /api/settings/order-number": adminOnly,
+	"PUT /api/settings/order-number": adminOnly,
+
+	"GET /api/api-keys":        adminOnly,
+	"POST /api/api-keys":       adminOnly,
+	"DELETE /api/api-keys/:id": adminOnly,
+
+	"GET /api/audit": adminOnly,
+
+	"GET /api/recycle-bin":                           adminOnly,
+	"POST /api/recycle-bin/:entity_type/:id/restore": adminOnly,
+	"DELETE /api/recycle-bin/:entity_type/:id":       adminOnly,
+
+	"POST /api/styles":       managers,
+	"GET /api/styles":        everyone,
+	"GET /api/styles/:id":    everyone,
+	"PUT /api/styles/:id":    managers,
+	"DELETE /api/styles/:id": managers,
+
+	"GET /api/customers":        office,
+	"POST /api/customers":       managers,
+	"GET /api/customers/:id":    office,
+	"PUT /api/customers/:id":    managers,
+	"DELETE /api/customers/:id": managers,
+
+	"POST /api/production-orders":                   managers,
+	"POST /api/production-orders/import":            managers,
+	"GET /api/production-orders":                    office,
+	"GET /api/production-orders/unplanned":          office,
+	"GET /api/production-orders/at-risk":            office,
+	"GET /api/production-orders/:id":                office,
+	"PUT /api/production-orders/:id":                managers,
+	"PUT /api/production-orders/:id/details":        managers,
+	"POST /api/production-orders/:id/confirm":       managers,
+	"POST /api/production-orders/:id/cancel":        managers,
+	"POST /api/production-orders/:id/handover":      managers,
+	"GET /api/production-orders/:id/status-history": office,
+	"GET /api/production-orders/:id/fulfillment":    office,
+	"GET /api/production-orders/:id/plans":          office,
+	"DELETE /api/production-orders/:id":             managers,
+
+	"POST /api/production-plans":                   managers,
+	"POST /api/production-plans/cut-plan":          managers,
+	"GET /api/production-plans":                    office,
+	"GET /api/production-plans/:id":                everyone,
+	"PUT /api/production-plans/:id":                managers,
+	"DELETE /api/production-plans/:id":             managers,
+	"GET /api/production-plans/by-order/:order_id": office,
+
+	"GET /api/tasks":          everyone,
+	"GET /api/tasks/:id":      everyone,
+	"GET /api/tasks/progress": everyone,
+
+	"POST /api/production-logs":             everyone,
+	"GET /api/production-logs/task/:taskID": everyone,
+
+	"GET /api/exports/orders":          office,
+	"GET /api/exports/plans":           office,
+	"GET /api/exports/production-logs": everyone,
+
+	"GET /api/workers":                 managers,
+	"POST /api/workers":                managers,
+	"GET /api/workers/:id":             managers,
+	"PUT /api/workers/:id":             managers,
+	"DELETE /api/workers/:id":          managers,
+	"GET /api/workers/:id/tasks":       managers,
+	"PUT /api/workers/:id/password":    managers,
+	"POST /api/workers/:id/unlock":     adminOnly,
+	"PUT /api/workers/:id/pin":         managers,
+	"DELETE /api/workers/:id/pin":      managers,
+	"PUT /api/workers/:id/badge":       managers,
+	"DELETE /api/workers/:id/badge":    managers,
+	"GET /api/workers/:id/task-groups": managers,
+}
+
+type fakePermissions struct{}
+
+func (fakePermissions) PermissionsForRole(roleName string) (map[string]bool, error) {
+	permissions := map[string]bool{}
+	for _, permission := range rolePermissions[roleName] {
+		permissions[permission] = true
+	}
+	return permissions, nil
+}
+
+type fakeSessions struct{}
+
+func (fakeSessions) IsSessionActive(sessionID int64) (bool, error) {
+	return true, nil
+}
+
+// fakeAPIKeys 把请求头中的密钥当作以逗号分隔的权限范围
+type fakeAPIKeys struct{}
+
+func (fakeAPIKeys) AuthenticateAPIKey(key string) (*models.APIKey, error) {
+	return &models.APIKey{APIKeyID: 1, Scopes: strings.Split(key, ",")}, nil
+}
+
+// fakeLogService 记录提交的生产记录，其余方法不会被调用
+type fakeLogService struct {
+	created []int
+}
+
+func (s *fakeLogService) CreateLog(actor models.Actor, log *models.CreateProductionLogRequest) error {
+	s.created = append(s.created, log.WorkerID)
+	return nil
+}
+
+func (s *fakeLogService) GetLogsByTaskID(taskID int) ([]*models.ProductionLog, error) {
+	return []*models.ProductionLog{}, nil
+}
+
+// testRouter 创建与 main 相同的路由。处理器的服务除生产记录外均为 nil，
+// 授权通过的请求在处理器中 panic，由 Recovery 返回 500
+type testRouter struct {
+	engine *gin.Engine
+	tokens *auth.TokenManager
+	logs   *fakeLogService
+}
+
+func newTestRouter(t *testing.T) *testRouter {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	gin.DefaultWriter = io.Discard
+	gin.DefaultErrorWriter = io.Discard
+
+	tr := &testRouter{tokens: auth.NewTokenManager("test-secret", time.Hour), logs: &fakeLogService{}}
+	tr.engine = newRouter(routeHandlers{
+		auth:       handlers.NewAuthHandler(nil),
+		style:      handlers.NewStyleHandler(nil),
+		task:       handlers.NewTaskHandler(nil),
+		log:        handlers.NewLogHandler(tr.logs),
+		order:      handlers.NewProductionOrderHandler(nil),
+		plan:       handlers.NewProductionPlanHandler(nil),
+		worker:     handlers.NewWorkerHandler(nil),
+		apiKey:     handlers.NewAPIKeyHandler(nil),
+		audit:      handlers.NewAuditHandler(nil),
+		role:       handlers.NewRoleHandler(nil),
+		settings:   handlers.NewSettingsHandler(nil),
+		export:     handlers.NewExportHandler(nil),
+		customer:   handlers.NewCustomerHandler(nil),
+		recycleBin: handlers.NewRecycleBinHandler(nil),
+	}, tr.tokens, fakeSessions{}, fakeAPIKeys{}, fakePermissions{})
+	return tr
+}
+
+func (tr *testRouter) token(t *testing.T, role string) string {
+	t.Helper()
+	token, _, err := tr.tokens.Issue(callerWorkerID, role, 1)
+	if err != nil {
+		t.Fatalf("issue token: %v", err)
+	}
+	return "Bearer " + token
+}
+
+func (tr *testRouter) kioskToken(t *testing.T) string {
+	t.Helper()
+	token, _, err := tr.tokens.IssueKiosk(callerWorkerID, "worker", 1, time.Now().Add(time.Hour))
+	if err != nil {
+		t.Fatalf("issue kiosk token: %v", err)
+	}
+	return "Bearer " + token
+}
+
+// do 发送请求；header 为 Authorization 或 X-API-Key 的值，为空时不携带凭证
+func (tr *testRouter) do(method, path, body, headerName, header string) *httptest.ResponseRecorder {
+	if body == "" && (method == http.MethodPost || method == http.MethodPut) {
+		body = "{}"
+	}
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	if header != "" {
+		req.Header.Set(headerName, header)
+	}
+	w := httptest.NewRecorder()
+	tr.engine.ServeHTTP(w, req)
+	return w
+}
+
+// concretePath 把路由中的路径参数替换为具体的值，:id 不是调用者本人
+func concretePath(route string) string {
+	parts := strings.Split(route, "/")
+	for i, part := range parts {
+		switch {
+		case part == ":entity_type":
+			parts[i] = "order"
+		case strings.HasPrefix(part, ":"):
+			parts[i] = fmt.Sprint(otherWorkerID)
+		}
+	}
+	return strings.Join(parts, "/")
+}
+
+// assertForbidden 校验请求被拒绝，且响应体是标准的 APIResponse 格式
+func assertForbidden(t *testing.T, w *httptest.ResponseRecorder, wantError string) {
+	t.Helper()
+	if w.Code != http.StatusForbidden {
+		t.Fatalf("status = %d, want 403 (body %s)", w.Code, w.Body.String())
+	}
+	var body map[string]any
+	if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+		t.Fatalf("403 body is not JSON: %v (%s)", err, w.Body.String())
+	}
+	keys := make([]string, 0, len(body))
+	for key := range body {
+		keys = append(keys, key)
+	}
+	slices.Sort(keys)
+	if !slices.Equal(keys, []string{"error", "message", "success"}) {
+		t.Fatalf("403 body fields = %v, want [error message success]", keys)
+	}
+	if body["success"] != false || body["message"] != "权限不足" {
+		t.Fatalf("403 body = %v, want success=false message=权限不足", body)
+	}
+	if reason, _ := body["error"].(string); reason == "" || (wantError != "" && !strings.Contains(reason, wantError)) {
+		t.Fatalf("403 error = %q, want it to mention %q", reason, wantError)
+	}
+}
+
+// assertAllowed 校验请求通过了认证和授权 (处理器本身的返回不在此校验)
+func assertAllowed(t *testing.T, w *httptest.ResponseRecorder) {
+	t.Helper()
+	if w.Code == http.StatusUnauthorized || w.Code == http.StatusForbidden {
+		t.Fatalf("status = %d, want the request to be authorized (body %s)", w.Code, w.Body.String())
+	}
+}
+
+func TestRoutePolicy(t *testing.T) {
+	tr := newTestRouter(t)
+
+	registered := map[string]bool{}
+	for _, route := range tr.engine.Routes() {
+		if !strings.HasPrefix(route.Path, "/api/") {
+			continue
+		}
+		key := route.Method + " " + route.Path
+		registered[key] = true
+		allowed, ok := expectedAccess[key]
+		if !ok {
+			t.Errorf("%s has no entry in expectedAccess", key)
+			continue
+		}
+		path := concretePath(route.Path)
+		body := ""
+		if key == "POST /api/production-logs" {
+			body = fmt.Sprintf(`{"worker_id": %d}`, callerWorkerID)
+		}
+
+		t.Run(key, func(t *testing.T) {
+			if len(allowed) == 0 {
+				assertAllowed(t, tr.do(route.Method, path, body, "", ""))
+				return
+			}
+			w := tr.do(route.Method, path, body, "", "")
+			if w.Code != http.StatusUnauthorized {
+				t.Fatalf("without token: status = %d, want 401", w.Code)
+			}
+			for role := range rolePermissions {
+				t.Run(role, func(t *testing.T) {
+					w := tr.do(route.Method, path, body, "Authorization", tr.token(t, role))
+					if slices.Contains(allowed, role) {
+						assertAllowed(t, w)
+					} else {
+						assertForbidden(t, w, "")
+					}
+				})
+			}
+		})
+	}
+	for key := range expectedAccess {
+		if !registered[key] {
+			t.Errorf("expectedAccess lists %s but the route is not registered", key)
+		}
+	}
+}
+
+func TestRoutePolicySelf(t *testing.T) {
+	tr := newTestRouter(t)
+	self, other := fmt.Sprint(callerWorkerID), fmt.Sprint(otherWorkerID)
+
+	for _, path := range []string{"/api/workers/%s", "/api/workers/%s/tasks", "/api/workers/%s/task-groups"} {
+		t.Run(path, func(t *testing.T) {
+			worker := tr.token(t, "worker")
+			assertAllowed(t, tr.do(http.MethodGet, fmt.Sprintf(path, self), "", "Authorization", worker))
+			assertForbidden(t, tr.do(http.MethodGet, fmt.Sprintf(path, other), "", "Authorization", worker), auth.PermWorkerView)
+		})
+	}
+	t.Run("PUT /api/workers/:id is not self-service", func(t *testing.T) {
+		assertForbidden(t, tr.do(http.MethodPut, "/api/workers/"+self, "", "Authorization", tr.token(t, "worker")), auth.PermWorkerManage)
+	})
+}
+
+func TestProductionLogOnlyForSelf(t *testing.T) {
+	tr := newTestRouter(t)
+	logFor := func(workerID int) string {
+		return fmt.Sprintf(`{"worker_id": %d, "process_name": "拉布", "task_id": 1, "layers_completed": 5}`, workerID)
+	}
+
+	tests := []struct {
+		name       string
+		headerName string
+		header     string
+		workerID   int
+		allowed    bool
+	}{
+		{"worker logs for self", "Authorization", tr.token(t, "worker"), callerWorkerID, true},
+		{"worker logs for another worker", "Authorization", tr.token(t, "worker"), otherWorkerID, false},
+		{"pattern maker logs for another worker", "Authorization", tr.token(t, "pattern_maker"), otherWorkerID, false},
+		{"manager logs for another worker", "Authorization", tr.token(t, "manager"), otherWorkerID, true},
+		{"kiosk logs for self", "Authorization", tr.kioskToken(t), callerWorkerID, true},
+		{"kiosk logs for another worker", "Authorization", tr.kioskToken(t), otherWorkerID, false},
+		{"api key with logs:write", "X-API-Key", auth.APIScopeLogsWrite, otherWorkerID, true},
+		{"api key without logs:write", "X-API-Key", auth.APIScopeLogsRead, otherWorkerID, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tr.logs.created = nil
+			w := tr.do(http.MethodPost, "/api/production-logs", logFor(tt.workerID), tt.headerName, tt.header)
+			if tt.allowed {
+				if w.Code != http.StatusCreated {
+					t.Fatalf("status = %d, want 201 (body %s)", w.Code, w.Body.String())
+				}
+				if !slices.Equal(tr.logs.created, []int{tt.workerID}) {
+					t.Fatalf("created logs for %v, want [%d]", tr.logs.created, tt.workerID)
+				}
+				return
+			}
+			assertForbidden(t, w, "")
+			if len(tr.logs.created) > 0 {
+				t.Fatalf("log was created for %v although the request was denied", tr.logs.created)
+			}
+		})
+	}
+}
+
+func TestRoutePolicyKioskAndAPIKey(t *testing.T) {
+	tr := newTestRouter(t)
+	kiosk := tr.kioskToken(t)
+
+	tests := []struct {
+		name       string
+		method     string
+		path       string
+		headerName string
+		header     string
+		allowed    bool
+	}{
+		{"kiosk reads own task groups", http.MethodGet, fmt.Sprintf("/api/workers/%d/task-groups", callerWorkerID), "Authorization", kiosk, true},
+		{"kiosk reads another worker's task groups", http.MethodGet, fmt.Sprintf("/api/workers/%d/task-groups", otherWorkerID), "Authorization", kiosk, false},
+		{"kiosk reads own worker record", http.MethodGet, fmt.Sprintf("/api/workers/%d", callerWorkerID), "Authorization", kiosk, false},
+		{"kiosk lists tasks", http.MethodGet, "/api/tasks", "Authorization", kiosk, false},
+		{"api key reads plans", http.MethodGet, "/api/production-plans", "X-API-Key", auth.APIScopePlansRead, true},
+		{"api key without plans:write creates a plan", http.MethodPost, "/api/production-plans", "X-API-Key", auth.APIScopePlansRead, false},
+		{"api key with plans:write creates a plan", http.MethodPost, "/api/production-plans", "X-API-Key", auth.APIScopePlansWrite, true},
+		{"api key lists workers", http.MethodGet, "/api/workers", "X-API-Key", strings.Join(auth.APIScopes, ","), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := tr.do(tt.method, tt.path, "", tt.headerName, tt.header)
+			if tt.allowed {
+				assertAllowed(t, w)
+			} else {
+				assertForbidden(t, w, "")
+			}
+		})
+	}
+}
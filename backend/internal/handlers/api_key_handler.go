@@ -0,0 +1,73 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+
+	"cutrix-backend/internal/models"
+	"cutrix-backend/internal/services"
+	"cutrix-backend/pkg/middleware"
+
+	"github.com/gin-gonic/gin"
+)
+
+// APIKeyHandler 处理 API 密钥的管理请求 (仅管理员)
+type APIKeyHandler struct {
+	apiKeyService services.APIKeyService
+}
+
+// NewAPIKeyHandler 创建新的 API 密钥处理器
+func NewAPIKeyHandler(apiKeyService services.APIKeyService) *APIKeyHandler {
+	return &APIKeyHandler{apiKeyService: apiKeyService}
+}
+
+// CreateAPIKey 创建 API 密钥，明文密钥只在本次响应中返回
+func (h *APIKeyHandler) CreateAPIKey(c *gin.Context) {
+	var req models.CreateAPIKeyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.APIResponse{Success: false, Message: "请求参数无效", Error: err.Error()})
+		return
+	}
+
+	resp, err := h.apiKeyService.Create(middleware.CurrentWorkerID(c), &req)
+	if err != nil {
+		if validationErr, ok := err.(*services.ValidationError); ok {
+			c.JSON(http.StatusBadRequest, models.APIResponse{Success: false, Message: "创建失败", Error: validationErr.Message})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, models.APIResponse{Success: false, Message: "创建API密钥失败", Error: err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, models.APIResponse{Success: true, Message: "API密钥创建成功，请立即保存，密钥不会再次显示", Data: resp})
+}
+
+// GetAPIKeys 获取全部 API 密钥 (不含明文)
+func (h *APIKeyHandler) GetAPIKeys(c *gin.Context) {
+	keys, err := h.apiKeyService.GetAll()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.APIResponse{Success: false, Message: "获取API密钥失败", Error: err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, models.APIResponse{Success: true, Message: "获取API密钥成功", Data: keys})
+}
+
+// RevokeAPIKey 吊销 API 密钥，吊销后立即失效
+func (h *APIKeyHandler) RevokeAPIKey(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.APIResponse{Success: false, Message: "无效的密钥ID", Error: "ID必须是数字"})
+		return
+	}
+
+	if err := h.apiKeyService.Revoke(id); err != nil {
+		if err.Error() == "api key not found" {
+			c.JSON(http.StatusNotFound, models.APIResponse{Success: false, Message: "API密钥不存在或已吊销", Error: err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, models.APIResponse{Success: false, Message: "吊销API密钥失败", Error: err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.APIResponse{Success: true, Message: "API密钥已吊销"})
+}
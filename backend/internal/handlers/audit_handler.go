@@ -0,0 +1,61 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+
+	"cutrix-backend/internal/models"
+	"cutrix-backend/internal/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// AuditHandler 处理审计日志查询请求 (仅管理员)
+type AuditHandler struct {
+	auditService services.AuditService
+}
+
+// NewAuditHandler 创建新的审计日志处理器
+func NewAuditHandler(auditService services.AuditService) *AuditHandler {
+	return &AuditHandler{auditService: auditService}
+}
+
+// GetAuditLogs 分页查询审计日志，支持按对象类型/ID、操作者和时间范围过滤
+func (h *AuditHandler) GetAuditLogs(c *gin.Context) {
+	filter := models.AuditLogFilter{EntityType: c.Query("entity_type")}
+
+	if entityIDStr := c.Query("entity_id"); entityIDStr != "" {
+		entityID, err := strconv.ParseInt(entityIDStr, 10, 64)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, models.APIResponse{Success: false, Message: "无效的查询参数", Error: "entity_id 必须是数字"})
+			return
+		}
+		filter.EntityID = &entityID
+	}
+	if actorIDStr := c.Query("actor_id"); actorIDStr != "" {
+		actorID, err := strconv.Atoi(actorIDStr)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, models.APIResponse{Success: false, Message: "无效的查询参数", Error: "actor_id 必须是数字"})
+			return
+		}
+		filter.ActorWorkerID = &actorID
+	}
+	from, to, err := parseTimeRange(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.APIResponse{Success: false, Message: "无效的查询参数", Error: err.Error()})
+		return
+	}
+	filter.From, filter.To = from, to
+	filter.Page, filter.PageSize, err = parsePagination(c, 20, 100)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.APIResponse{Success: false, Message: "无效的查询参数", Error: err.Error()})
+		return
+	}
+
+	page, err := h.auditService.List(&filter)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.APIResponse{Success: false, Message: "获取审计日志失败", Error: err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, models.APIResponse{Success: true, Message: "获取审计日志成功", Data: page})
+}
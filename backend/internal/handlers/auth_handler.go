@@ -3,7 +3,9 @@ package handlers
 import (
 	"cutrix-backend/internal/models"
 	"cutrix-backend/internal/services"
+	"cutrix-backend/pkg/middleware"
 	"net/http"
+	"strconv"
 
 	"github.com/gin-gonic/gin"
 )
@@ -32,8 +34,17 @@ func (h *AuthHandler) Login(c *gin.Context) {
 		return
 	}
 
-	worker, err := h.authService.Login(&req)
+	resp, err := h.authService.Login(&req, c.ClientIP(), c.Request.UserAgent())
 	if err != nil {
+		if tooMany, ok := err.(*services.TooManyAttemptsError); ok {
+			c.Header("Retry-After", strconv.Itoa(int(tooMany.RetryAfter.Seconds())))
+			c.JSON(http.StatusTooManyRequests, models.APIResponse{
+				Success: false,
+				Message: "登录失败",
+				Error:   tooMany.Message,
+			})
+			return
+		}
 		c.JSON(http.StatusUnauthorized, models.APIResponse{
 			Success: false,
 			Message: "登录失败",
@@ -42,11 +53,186 @@ func (h *AuthHandler) Login(c *gin.Context) {
 		return
 	}
 
-	// 在实际项目中，这里应该生成并返回JWT token
-	// 为了简化，我们直接返回用户信息
 	c.JSON(http.StatusOK, models.APIResponse{
 		Success: true,
 		Message: "登录成功",
-		Data:    worker,
+		Data:    resp,
 	})
 }
+
+// KioskLogin 处理车间终端的 PIN 或工牌登录请求
+func (h *AuthHandler) KioskLogin(c *gin.Context) {
+	var req models.KioskLoginRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.APIResponse{
+			Success: false,
+			Message: "无效的请求",
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	resp, err := h.authService.KioskLogin(&req, c.ClientIP(), c.Request.UserAgent())
+	if err != nil {
+		if validationErr, ok := err.(*services.ValidationError); ok {
+			c.JSON(http.StatusBadRequest, models.APIResponse{
+				Success: false,
+				Message: "无效的请求",
+				Error:   validationErr.Message,
+			})
+			return
+		}
+		if tooMany, ok := err.(*services.TooManyAttemptsError); ok {
+			c.Header("Retry-After", strconv.Itoa(int(tooMany.RetryAfter.Seconds())))
+			c.JSON(http.StatusTooManyRequests, models.APIResponse{
+				Success: false,
+				Message: "登录失败",
+				Error:   tooMany.Message,
+			})
+			return
+		}
+		c.JSON(http.StatusUnauthorized, models.APIResponse{
+			Success: false,
+			Message: "登录失败",
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.APIResponse{
+		Success: true,
+		Message: "登录成功",
+		Data:    resp,
+	})
+}
+
+// Refresh 使用刷新令牌换取新的访问令牌
+func (h *AuthHandler) Refresh(c *gin.Context) {
+	var req models.RefreshRequest
+	if err := c.ShouldBindJSON(&req); err != nil || req.RefreshToken == "" {
+		c.JSON(http.StatusBadRequest, models.APIResponse{
+			Success: false,
+			Message: "无效的请求",
+			Error:   "缺少刷新令牌",
+		})
+		return
+	}
+
+	resp, err := h.authService.Refresh(req.RefreshToken)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, models.APIResponse{
+			Success: false,
+			Message: "刷新令牌失败",
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.APIResponse{
+		Success: true,
+		Message: "刷新令牌成功",
+		Data:    resp,
+	})
+}
+
+// Logout 注销刷新令牌对应的会话
+func (h *AuthHandler) Logout(c *gin.Context) {
+	var req models.RefreshRequest
+	if err := c.ShouldBindJSON(&req); err != nil || req.RefreshToken == "" {
+		c.JSON(http.StatusBadRequest, models.APIResponse{
+			Success: false,
+			Message: "无效的请求",
+			Error:   "缺少刷新令牌",
+		})
+		return
+	}
+
+	if err := h.authService.Logout(req.RefreshToken); err != nil {
+		c.JSON(http.StatusInternalServerError, models.APIResponse{
+			Success: false,
+			Message: "注销失败",
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.APIResponse{
+		Success: true,
+		Message: "注销成功",
+	})
+}
+
+// ChangePassword 处理当前登录员工修改自己密码的请求
+func (h *AuthHandler) ChangePassword(c *gin.Context) {
+	var req models.ChangePasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.APIResponse{
+			Success: false,
+			Message: "无效的请求",
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	err := h.authService.ChangePassword(middleware.CurrentWorkerID(c), &req)
+	if err != nil {
+		if validationErr, ok := err.(*services.ValidationError); ok {
+			c.JSON(http.StatusBadRequest, models.APIResponse{
+				Success: false,
+				Message: "修改密码失败",
+				Error:   validationErr.Message,
+			})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, models.APIResponse{
+			Success: false,
+			Message: "修改密码失败",
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.APIResponse{
+		Success: true,
+		Message: "密码修改成功",
+	})
+}
+
+// GetLoginEvents 查询登录事件，支持按用户名、IP、是否成功和时间范围过滤
+func (h *AuthHandler) GetLoginEvents(c *gin.Context) {
+	filter := models.LoginEventFilter{
+		WorkerName: c.Query("worker_name"),
+		ClientIP:   c.Query("client_ip"),
+		Limit:      100,
+	}
+
+	if successStr := c.Query("success"); successStr != "" {
+		success, err := strconv.ParseBool(successStr)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, models.APIResponse{Success: false, Message: "无效的查询参数", Error: "success 必须是 true 或 false"})
+			return
+		}
+		filter.Success = &success
+	}
+	from, to, err := parseTimeRange(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.APIResponse{Success: false, Message: "无效的查询参数", Error: err.Error()})
+		return
+	}
+	filter.From, filter.To = from, to
+	if limitStr := c.Query("limit"); limitStr != "" {
+		limit, err := strconv.Atoi(limitStr)
+		if err != nil || limit < 1 || limit > 1000 {
+			c.JSON(http.StatusBadRequest, models.APIResponse{Success: false, Message: "无效的查询参数", Error: "limit 必须在 1 到 1000 之间"})
+			return
+		}
+		filter.Limit = limit
+	}
+
+	events, err := h.authService.ListLoginEvents(&filter)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.APIResponse{Success: false, Message: "获取登录记录失败", Error: err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, models.APIResponse{Success: true, Message: "获取登录记录成功", Data: events})
+}
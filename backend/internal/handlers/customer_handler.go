@@ -0,0 +1,123 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+
+	"cutrix-backend/internal/models"
+	"cutrix-backend/internal/services"
+	"cutrix-backend/pkg/middleware"
+
+	"github.com/gin-gonic/gin"
+)
+
+// CustomerHandler 处理客户主数据请求
+type CustomerHandler struct {
+	customerService services.CustomerService
+}
+
+// NewCustomerHandler 创建新的客户处理器
+func NewCustomerHandler(customerService services.CustomerService) *CustomerHandler {
+	return &CustomerHandler{customerService: customerService}
+}
+
+// GetCustomers 获取客户列表，可用 search 按编码或名称搜索
+func (h *CustomerHandler) GetCustomers(c *gin.Context) {
+	customers, err := h.customerService.GetAll(c.Query("search"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.APIResponse{Success: false, Message: "获取客户列表失败", Error: err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, models.APIResponse{Success: true, Message: "获取客户列表成功", Data: customers})
+}
+
+// GetCustomer 获取单个客户
+func (h *CustomerHandler) GetCustomer(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.APIResponse{Success: false, Message: "无效的客户ID", Error: "ID必须是数字"})
+		return
+	}
+	customer, err := h.customerService.GetByID(id)
+	if err != nil {
+		if err.Error() == "customer not found" {
+			c.JSON(http.StatusNotFound, models.APIResponse{Success: false, Message: "客户不存在", Error: err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, models.APIResponse{Success: false, Message: "获取客户失败", Error: err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, models.APIResponse{Success: true, Message: "获取客户成功", Data: customer})
+}
+
+// CreateCustomer 创建客户
+func (h *CustomerHandler) CreateCustomer(c *gin.Context) {
+	var req models.CreateCustomerRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.APIResponse{Success: false, Message: "请求参数无效", Error: err.Error()})
+		return
+	}
+
+	customer, err := h.customerService.Create(middleware.CurrentActor(c), &req)
+	if err != nil {
+		if validationErr, ok := err.(*services.ValidationError); ok {
+			c.JSON(http.StatusBadRequest, models.APIResponse{Success: false, Message: "创建客户失败", Error: validationErr.Message})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, models.APIResponse{Success: false, Message: "创建客户失败", Error: err.Error()})
+		return
+	}
+	c.JSON(http.StatusCreated, models.APIResponse{Success: true, Message: "创建客户成功", Data: customer})
+}
+
+// UpdateCustomer 修改客户信息
+func (h *CustomerHandler) UpdateCustomer(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.APIResponse{Success: false, Message: "无效的客户ID", Error: "ID必须是数字"})
+		return
+	}
+	var req models.UpdateCustomerRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.APIResponse{Success: false, Message: "请求参数无效", Error: err.Error()})
+		return
+	}
+
+	customer, err := h.customerService.Update(middleware.CurrentActor(c), id, &req)
+	if err != nil {
+		if validationErr, ok := err.(*services.ValidationError); ok {
+			c.JSON(http.StatusBadRequest, models.APIResponse{Success: false, Message: "更新客户失败", Error: validationErr.Message})
+			return
+		}
+		if err.Error() == "customer not found" {
+			c.JSON(http.StatusNotFound, models.APIResponse{Success: false, Message: "客户不存在", Error: err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, models.APIResponse{Success: false, Message: "更新客户失败", Error: err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, models.APIResponse{Success: true, Message: "更新客户成功", Data: customer})
+}
+
+// DeleteCustomer 删除没有订单的客户
+func (h *CustomerHandler) DeleteCustomer(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.APIResponse{Success: false, Message: "无效的客户ID", Error: "ID必须是数字"})
+		return
+	}
+
+	if err := h.customerService.Delete(middleware.CurrentActor(c), id); err != nil {
+		if validationErr, ok := err.(*services.ValidationError); ok {
+			c.JSON(http.StatusBadRequest, models.APIResponse{Success: false, Message: "删除客户失败", Error: validationErr.Message})
+			return
+		}
+		if err.Error() == "customer not found" {
+			c.JSON(http.StatusNotFound, models.APIResponse{Success: false, Message: "客户不存在", Error: err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, models.APIResponse{Success: false, Message: "删除客户失败", Error: err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, models.APIResponse{Success: true, Message: "删除客户成功"})
+}
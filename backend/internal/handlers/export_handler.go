@@ -0,0 +1,100 @@
+package handlers
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"cutrix-backend/internal/models"
+	"cutrix-backend/internal/services"
+	"cutrix-backend/pkg/spreadsheet"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ExportHandler 处理 XLSX / CSV 导出请求
+type ExportHandler struct {
+	exportService services.ExportService
+}
+
+// maxLogXLSXRange 生产记录按 XLSX 导出时允许的最大日期范围。XLSX 要在写完全部记录后才开始发送，
+// 范围过大时客户端长时间收不到数据，更大范围的记录只能按 CSV 边查询边发送
+const maxLogXLSXRange = 31 * 24 * time.Hour
+
+// NewExportHandler 创建新的导出处理器
+func NewExportHandler(exportService services.ExportService) *ExportHandler {
+	return &ExportHandler{exportService: exportService}
+}
+
+// ExportOrders 导出订单明细 (颜色×尺码)，可按 style_number 筛选
+func (h *ExportHandler) ExportOrders(c *gin.Context) {
+	styleNumberQuery := c.Query("style_number")
+	h.export(c, exportFormat(c, spreadsheet.FormatXLSX), "订单", "orders", func(w spreadsheet.Writer) error {
+		return h.exportService.ExportOrders(w, styleNumberQuery)
+	})
+}
+
+// ExportPlans 导出生产计划及其排版、尺码配比和颜色任务，可按 q 筛选
+func (h *ExportHandler) ExportPlans(c *gin.Context) {
+	searchQuery := c.Query("q")
+	h.export(c, exportFormat(c, spreadsheet.FormatXLSX), "生产计划", "plans", func(w spreadsheet.Writer) error {
+		return h.exportService.ExportPlans(w, searchQuery)
+	})
+}
+
+// ExportLogs 导出生产记录，按 from/to 筛选日期。生产记录可能很多，默认导出 CSV；
+// format=xlsx 时必须同时指定 from 和 to，且范围不超过 maxLogXLSXRange
+func (h *ExportHandler) ExportLogs(c *gin.Context) {
+	from, to, err := parseTimeRange(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.APIResponse{Success: false, Message: "无效的查询参数", Error: err.Error()})
+		return
+	}
+	format := exportFormat(c, spreadsheet.FormatCSV)
+	if format == spreadsheet.FormatXLSX && (from == nil || to == nil || to.Sub(*from) > maxLogXLSXRange) {
+		c.JSON(http.StatusBadRequest, models.APIResponse{Success: false, Message: "无效的查询参数",
+			Error: fmt.Sprintf("按 XLSX 导出生产记录时必须指定 from 和 to，且不超过 %d 天，更大范围请使用 format=csv", int(maxLogXLSXRange.Hours()/24))})
+		return
+	}
+	h.export(c, format, "生产记录", "production-logs", func(w spreadsheet.Writer) error {
+		return h.exportService.ExportLogs(w, from, to)
+	})
+}
+
+// exportFormat 返回 format 参数 (xlsx 或 csv)，未指定时取 defaultFormat
+func exportFormat(c *gin.Context, defaultFormat string) string {
+	return strings.ToLower(c.DefaultQuery("format", defaultFormat))
+}
+
+// export 按 format 把数据直接写入响应。
+// CSV 边查询边发送；XLSX 在 Close 时才一次写出，此前出错仍可返回错误响应
+func (h *ExportHandler) export(c *gin.Context, format, sheetName, filePrefix string, write func(w spreadsheet.Writer) error) {
+	if !spreadsheet.IsSupportedFormat(format) {
+		c.JSON(http.StatusBadRequest, models.APIResponse{Success: false, Message: "无效的查询参数", Error: "format 只能是 xlsx 或 csv"})
+		return
+	}
+
+	filename := fmt.Sprintf("%s-%s.%s", filePrefix, time.Now().Format("20060102-150405"), format)
+	c.Header("Content-Type", spreadsheet.ContentType(format))
+	c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"`, filename))
+
+	writer, err := spreadsheet.NewWriter(format, c.Writer, sheetName)
+	if err == nil {
+		err = write(writer)
+		if closeErr := writer.Close(); err == nil {
+			err = closeErr
+		}
+	}
+	if err != nil {
+		// 已经开始输出文件内容时无法再返回错误响应，只能中断并记录日志
+		if c.Writer.Written() {
+			log.Printf("Failed to export %s: %v", filePrefix, err)
+			c.Abort()
+			return
+		}
+		c.Writer.Header().Del("Content-Disposition")
+		c.JSON(http.StatusInternalServerError, models.APIResponse{Success: false, Message: "导出失败", Error: err.Error()})
+	}
+}
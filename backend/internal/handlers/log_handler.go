@@ -6,6 +6,8 @@ import (
 
 	"cutrix-backend/internal/models"
 	"cutrix-backend/internal/services"
+	"cutrix-backend/pkg/auth"
+	"cutrix-backend/pkg/middleware"
 
 	"github.com/gin-gonic/gin"
 )
@@ -21,7 +23,7 @@ func NewLogHandler(logService services.LogService) *LogHandler {
 }
 
 func (h *LogHandler) CreateProductionLog(c *gin.Context) {
-	var req models.CreateLogRequest
+	var req models.CreateProductionLogRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
 		c.JSON(http.StatusBadRequest, models.APIResponse{
 			Success: false,
@@ -31,7 +33,28 @@ func (h *LogHandler) CreateProductionLog(c *gin.Context) {
 		return
 	}
 
-	err := h.logService.CreateLog(&req)
+	// 拥有代录权限的员工和系统集成 (API 密钥) 可以代工人录入，
+	// 其他员工和车间终端令牌只能为自己提交记录
+	canLogForOthers := middleware.IsAPIKey(c) || middleware.HasPermission(c, auth.PermLogCreateForOthers)
+	if !canLogForOthers && req.WorkerID != middleware.CurrentWorkerID(c) {
+		c.JSON(http.StatusForbidden, models.APIResponse{
+			Success: false,
+			Message: "权限不足",
+			Error:   "只能为自己提交生产记录",
+		})
+		return
+	}
+
+	if req.WorkerID == 0 {
+		c.JSON(http.StatusBadRequest, models.APIResponse{
+			Success: false,
+			Message: "无效的请求数据",
+			Error:   "worker_id 不能为空",
+		})
+		return
+	}
+
+	err := h.logService.CreateLog(middleware.CurrentActor(c), &req)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, models.APIResponse{
 			Success: false,
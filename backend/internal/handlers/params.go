@@ -0,0 +1,146 @@
+package handlers
+
+import (
+	"cutrix-backend/internal/models"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// parseTimeRange 解析查询参数 from/to，支持 YYYY-MM-DD 和 RFC3339 两种格式。
+// 仅给出日期时，to 包含当天 (即返回次日零点作为开区间上界)。
+func parseTimeRange(c *gin.Context) (from, to *time.Time, err error) {
+	if value := c.Query("from"); value != "" {
+		t, _, err := parseTimeParam(value)
+		if err != nil {
+			return nil, nil, fmt.Errorf("from 必须是 YYYY-MM-DD 或 RFC3339 格式")
+		}
+		from = &t
+	}
+	if value := c.Query("to"); value != "" {
+		t, dateOnly, err := parseTimeParam(value)
+		if err != nil {
+			return nil, nil, fmt.Errorf("to 必须是 YYYY-MM-DD 或 RFC3339 格式")
+		}
+		if dateOnly {
+			t = t.AddDate(0, 0, 1)
+		}
+		to = &t
+	}
+	return from, to, nil
+}
+
+// parseDateQuery 解析 YYYY-MM-DD 格式的日期查询参数，参数为空时返回 nil
+func parseDateQuery(c *gin.Context, name string) (*time.Time, error) {
+	value := c.Query(name)
+	if value == "" {
+		return nil, nil
+	}
+	t, err := time.Parse("2006-01-02", value)
+	if err != nil {
+		return nil, fmt.Errorf("%s 必须是 YYYY-MM-DD 格式", name)
+	}
+	return &t, nil
+}
+
+func parseTimeParam(value string) (t time.Time, dateOnly bool, err error) {
+	if t, err := time.ParseInLocation("2006-01-02", value, time.Local); err == nil {
+		return t, true, nil
+	}
+	t, err = time.Parse(time.RFC3339, value)
+	return t, false, err
+}
+
+// parsePagination 解析查询参数 page/page_size，page 从 1 开始
+func parsePagination(c *gin.Context, defaultPageSize, maxPageSize int) (page, pageSize int, err error) {
+	page, pageSize = 1, defaultPageSize
+	if value := c.Query("page"); value != "" {
+		page, err = strconv.Atoi(value)
+		if err != nil || page < 1 {
+			return 0, 0, fmt.Errorf("page 必须是大于 0 的整数")
+		}
+	}
+	if value := c.Query("page_size"); value != "" {
+		pageSize, err = strconv.Atoi(value)
+		if err != nil || pageSize < 1 || pageSize > maxPageSize {
+			return 0, 0, fmt.Errorf("page_size 必须在 1 到 %d 之间", maxPageSize)
+		}
+	}
+	return page, pageSize, nil
+}
+
+// 列表接口分页时的默认和最大每页条数
+const (
+	defaultListPageSize = 20
+	maxListPageSize     = 200
+)
+
+// listSort 是列表接口允许的排序字段，第一个为默认字段；descFields 中的字段未指定 order 时默认倒序
+type listSort struct {
+	fields     []string
+	descFields []string
+}
+
+// parseListQuery 解析列表接口共用的查询参数 page、page_size、sort、order (asc/desc)。
+// 列表总是分页，未给出 page_size 时每页 defaultListPageSize 条，总数见响应的 meta.total
+func parseListQuery(c *gin.Context, sort listSort) (models.ListQuery, error) {
+	var q models.ListQuery
+	var err error
+	q.Page, q.PageSize, err = parsePagination(c, defaultListPageSize, maxListPageSize)
+	if err != nil {
+		return q, err
+	}
+
+	q.SortBy = sort.fields[0]
+	if value := c.Query("sort"); value != "" {
+		found := false
+		for _, field := range sort.fields {
+			found = found || field == value
+		}
+		if !found {
+			return q, fmt.Errorf("sort 只能是 %s", strings.Join(sort.fields, "、"))
+		}
+		q.SortBy = value
+	}
+	switch c.Query("order") {
+	case "asc":
+	case "desc":
+		q.SortDesc = true
+	case "":
+		for _, field := range sort.descFields {
+			q.SortDesc = q.SortDesc || field == q.SortBy
+		}
+	default:
+		return q, fmt.Errorf("order 只能是 asc 或 desc")
+	}
+	return q, nil
+}
+
+// parseIntQuery 解析整数查询参数，参数为空时返回 nil
+func parseIntQuery(c *gin.Context, name string) (*int, error) {
+	value := c.Query(name)
+	if value == "" {
+		return nil, nil
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil {
+		return nil, fmt.Errorf("%s 必须是数字", name)
+	}
+	return &n, nil
+}
+
+// parseBoolQuery 解析布尔查询参数 (true/false)，参数为空时返回 nil
+func parseBoolQuery(c *gin.Context, name string) (*bool, error) {
+	value := c.Query(name)
+	if value == "" {
+		return nil, nil
+	}
+	b, err := strconv.ParseBool(value)
+	if err != nil {
+		return nil, fmt.Errorf("%s 必须是 true 或 false", name)
+	}
+	return &b, nil
+}
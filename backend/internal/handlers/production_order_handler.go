@@ -3,6 +3,8 @@ package handlers
 import (
 	"cutrix-backend/internal/models"
 	"cutrix-backend/internal/services"
+	"cutrix-backend/pkg/middleware"
+	"cutrix-backend/pkg/spreadsheet"
 	"net/http"
 	"strconv"
 
@@ -40,8 +42,14 @@ func (h *ProductionOrderHandler) CreateOrder(c *gin.Context) {
 		return
 	}
 
-	order, err := h.orderService.CreateOrder(&req)
+	order, err := h.orderService.CreateOrder(middleware.CurrentActor(c), &req)
 	if err != nil {
+		if validationErr, ok := err.(*services.ValidationError); ok {
+			c.JSON(http.StatusBadRequest, models.APIResponse{
+				Success: false, Message: "Failed to create production order", Error: validationErr.Message,
+			})
+			return
+		}
 		c.JSON(http.StatusInternalServerError, models.APIResponse{
 			Success: false, Message: "Failed to create production order", Error: err.Error(),
 		})
@@ -76,11 +84,49 @@ func (h *ProductionOrderHandler) GetOrder(c *gin.Context) {
 	})
 }
 
+// orderListSort 订单列表的排序字段，省略 order 时创建时间和优先级默认倒序，交期和订单号默认正序
+var orderListSort = listSort{
+	fields:     []string{"created_at", "due_date", "priority", "order_number"},
+	descFields: []string{"created_at", "priority"},
+}
+
+// GetOrders 分页查询订单列表，支持按款号、客户、状态、优先级、交期范围 (due_from/due_to)
+// 和创建时间范围 (from/to) 过滤，分页和排序参数见 parseListQuery
 func (h *ProductionOrderHandler) GetOrders(c *gin.Context) {
-	styleNumberQuery := c.Query("style_number")
+	filter := models.ProductionOrderFilter{
+		StyleNumber: c.Query("style_number"),
+		Status:      c.Query("status"),
+		Priority:    c.Query("priority"),
+	}
+	var err error
+	filter.ListQuery, err = parseListQuery(c, orderListSort)
+	if err == nil {
+		filter.CustomerID, err = parseIntQuery(c, "customer_id")
+	}
+	if err == nil {
+		filter.DueFrom, err = parseDateQuery(c, "due_from")
+	}
+	if err == nil {
+		filter.DueTo, err = parseDateQuery(c, "due_to")
+	}
+	if err == nil {
+		filter.CreatedFrom, filter.CreatedTo, err = parseTimeRange(c)
+	}
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.APIResponse{
+			Success: false, Message: "Invalid query parameters", Error: err.Error(),
+		})
+		return
+	}
 
-	orders, err := h.orderService.GetAllOrders(styleNumberQuery)
+	orders, total, err := h.orderService.GetAllOrders(&filter)
 	if err != nil {
+		if validationErr, ok := err.(*services.ValidationError); ok {
+			c.JSON(http.StatusBadRequest, models.APIResponse{
+				Success: false, Message: "Invalid query parameters", Error: validationErr.Message,
+			})
+			return
+		}
 		c.JSON(http.StatusInternalServerError, models.APIResponse{
 			Success: false, Message: "Failed to retrieve orders", Error: err.Error(),
 		})
@@ -88,7 +134,203 @@ func (h *ProductionOrderHandler) GetOrders(c *gin.Context) {
 	}
 
 	c.JSON(http.StatusOK, models.APIResponse{
-		Success: true, Message: "Orders retrieved successfully", Data: orders,
+		Success: true, Message: "Orders retrieved successfully", Data: orders, Meta: models.NewPageMeta(filter.ListQuery, total),
+	})
+}
+
+// GetAtRiskOrders 列出 days 天内到期 (默认7天，含已逾期) 且裁剪进度低于 threshold (默认0.8) 的订单
+func (h *ProductionOrderHandler) GetAtRiskOrders(c *gin.Context) {
+	days, err := strconv.Atoi(c.DefaultQuery("days", "7"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.APIResponse{
+			Success: false, Message: "Invalid query parameters", Error: "days must be a number",
+		})
+		return
+	}
+	threshold, err := strconv.ParseFloat(c.DefaultQuery("threshold", "0.8"), 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.APIResponse{
+			Success: false, Message: "Invalid query parameters", Error: "threshold must be a number",
+		})
+		return
+	}
+
+	orders, err := h.orderService.GetAtRiskOrders(days, threshold)
+	if err != nil {
+		if validationErr, ok := err.(*services.ValidationError); ok {
+			c.JSON(http.StatusBadRequest, models.APIResponse{
+				Success: false, Message: "Invalid query parameters", Error: validationErr.Message,
+			})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, models.APIResponse{
+			Success: false, Message: "Failed to retrieve at-risk orders", Error: err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.APIResponse{
+		Success: true, Message: "At-risk orders retrieved successfully", Data: orders,
+	})
+}
+
+// UpdateOrderDetails 修改订单的客户、交期、优先级和备注
+func (h *ProductionOrderHandler) UpdateOrderDetails(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.APIResponse{
+			Success: false, Message: "Invalid order ID", Error: "order ID must be a number",
+		})
+		return
+	}
+
+	var req models.UpdateOrderDetailsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.APIResponse{
+			Success: false, Message: "Invalid request body", Error: err.Error(),
+		})
+		return
+	}
+
+	order, err := h.orderService.UpdateOrderDetails(middleware.CurrentActor(c), id, &req)
+	if err != nil {
+		if validationErr, ok := err.(*services.ValidationError); ok {
+			c.JSON(http.StatusBadRequest, models.APIResponse{
+				Success: false, Message: "Failed to update order details", Error: validationErr.Message,
+			})
+			return
+		}
+		if err.Error() == "order not found" {
+			c.JSON(http.StatusNotFound, models.APIResponse{
+				Success: false, Message: "Order not found", Error: err.Error(),
+			})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, models.APIResponse{
+			Success: false, Message: "Failed to update order details", Error: err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.APIResponse{
+		Success: true, Message: "Order details updated successfully", Data: order,
+	})
+}
+
+// maxImportFileSize 导入文件大小上限
+const maxImportFileSize = 5 << 20
+
+// ImportOrders 从 CSV / XLSX 表格批量导入订单 (multipart 字段 file)，dry_run=true 时只校验不创建
+func (h *ProductionOrderHandler) ImportOrders(c *gin.Context) {
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxImportFileSize)
+
+	var opts models.ImportOrdersOptions
+	if err := c.ShouldBind(&opts); err != nil {
+		c.JSON(http.StatusBadRequest, models.APIResponse{
+			Success: false, Message: "Invalid import options", Error: err.Error(),
+		})
+		return
+	}
+
+	fileHeader, err := c.FormFile("file")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.APIResponse{
+			Success: false, Message: "Missing import file", Error: err.Error(),
+		})
+		return
+	}
+	file, err := fileHeader.Open()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.APIResponse{
+			Success: false, Message: "Failed to open import file", Error: err.Error(),
+		})
+		return
+	}
+	defer file.Close()
+
+	rows, err := spreadsheet.Read(fileHeader.Filename, file)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.APIResponse{
+			Success: false, Message: "Failed to read import file", Error: err.Error(),
+		})
+		return
+	}
+
+	result, err := h.orderService.ImportOrders(middleware.CurrentActor(c), rows, &opts)
+	if err != nil {
+		if validationErr, ok := err.(*services.ValidationError); ok {
+			c.JSON(http.StatusBadRequest, models.APIResponse{
+				Success: false, Message: "Failed to import orders", Error: validationErr.Message,
+			})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, models.APIResponse{
+			Success: false, Message: "Failed to import orders", Error: err.Error(),
+		})
+		return
+	}
+
+	if len(result.Errors) > 0 {
+		status := http.StatusBadRequest
+		if result.DryRun {
+			status = http.StatusOK
+		}
+		c.JSON(status, models.APIResponse{
+			Success: result.DryRun, Message: "Import file contains errors", Data: result,
+		})
+		return
+	}
+	if result.DryRun {
+		c.JSON(http.StatusOK, models.APIResponse{
+			Success: true, Message: "Import file validated successfully", Data: result,
+		})
+		return
+	}
+	c.JSON(http.StatusCreated, models.APIResponse{
+		Success: true, Message: "Orders imported successfully", Data: result,
+	})
+}
+
+// UpdateOrder 按 颜色+尺码 比对并更新订单明细
+func (h *ProductionOrderHandler) UpdateOrder(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.APIResponse{
+			Success: false, Message: "Invalid order ID", Error: "order ID must be a number",
+		})
+		return
+	}
+
+	var req models.UpdateProductionOrderRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.APIResponse{
+			Success: false, Message: "Invalid request body", Error: err.Error(),
+		})
+		return
+	}
+
+	result, err := h.orderService.UpdateOrder(middleware.CurrentActor(c), id, &req)
+	if err != nil {
+		if validationErr, ok := err.(*services.ValidationError); ok {
+			c.JSON(http.StatusBadRequest, models.APIResponse{
+				Success: false, Message: "Failed to update order", Error: validationErr.Message,
+			})
+			return
+		}
+		if err.Error() == "order not found" {
+			c.JSON(http.StatusNotFound, models.APIResponse{
+				Success: false, Message: "Order not found", Error: err.Error(),
+			})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, models.APIResponse{
+			Success: false, Message: "Failed to update order", Error: err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.APIResponse{
+		Success: true, Message: "Order updated successfully", Data: result,
 	})
 }
 
@@ -102,7 +344,7 @@ func (h *ProductionOrderHandler) DeleteOrder(c *gin.Context) {
 		return
 	}
 
-	err = h.orderService.DeleteOrderByID(id)
+	err = h.orderService.DeleteOrderByID(middleware.CurrentActor(c), id)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, models.APIResponse{
 			Success: false, Message: "Failed to delete order", Error: err.Error(),
@@ -114,3 +356,121 @@ func (h *ProductionOrderHandler) DeleteOrder(c *gin.Context) {
 		Success: true, Message: "Order deleted successfully",
 	})
 }
+
+// ConfirmOrder 确认草稿订单
+func (h *ProductionOrderHandler) ConfirmOrder(c *gin.Context) {
+	h.transitionOrder(c, services.OrderStatusConfirmed, "Order confirmed successfully")
+}
+
+// CancelOrder 取消尚未开始裁剪的订单
+func (h *ProductionOrderHandler) CancelOrder(c *gin.Context) {
+	h.transitionOrder(c, services.OrderStatusCancelled, "Order cancelled successfully")
+}
+
+// HandOverOrder 将裁剪完成的订单交接给下道工序
+func (h *ProductionOrderHandler) HandOverOrder(c *gin.Context) {
+	h.transitionOrder(c, services.OrderStatusHandedOver, "Order handed over successfully")
+}
+
+func (h *ProductionOrderHandler) transitionOrder(c *gin.Context, status string, successMessage string) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.APIResponse{
+			Success: false, Message: "Invalid order ID", Error: "order ID must be a number",
+		})
+		return
+	}
+
+	// 请求体可以省略，只有需要填写原因时才传
+	var req models.OrderTransitionRequest
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, models.APIResponse{
+				Success: false, Message: "Invalid request body", Error: err.Error(),
+			})
+			return
+		}
+	}
+
+	order, err := h.orderService.TransitionOrder(middleware.CurrentActor(c), id, status, req.Reason)
+	if err != nil {
+		if validationErr, ok := err.(*services.ValidationError); ok {
+			c.JSON(http.StatusBadRequest, models.APIResponse{
+				Success: false, Message: "Failed to change order status", Error: validationErr.Message,
+			})
+			return
+		}
+		if err.Error() == "order not found" {
+			c.JSON(http.StatusNotFound, models.APIResponse{
+				Success: false, Message: "Order not found", Error: err.Error(),
+			})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, models.APIResponse{
+			Success: false, Message: "Failed to change order status", Error: err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.APIResponse{
+		Success: true, Message: successMessage, Data: order,
+	})
+}
+
+// GetOrderStatusHistory 获取订单的状态变更历史
+func (h *ProductionOrderHandler) GetOrderStatusHistory(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.APIResponse{
+			Success: false, Message: "Invalid order ID", Error: "order ID must be a number",
+		})
+		return
+	}
+
+	history, err := h.orderService.GetStatusHistory(id)
+	if err != nil {
+		if err.Error() == "order not found" {
+			c.JSON(http.StatusNotFound, models.APIResponse{
+				Success: false, Message: "Order not found", Error: err.Error(),
+			})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, models.APIResponse{
+			Success: false, Message: "Failed to retrieve order status history", Error: err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.APIResponse{
+		Success: true, Message: "Order status history retrieved successfully", Data: history,
+	})
+}
+
+// GetOrderFulfillment 按 颜色+尺码 对比订单数量、计划件数和实际裁剪件数
+func (h *ProductionOrderHandler) GetOrderFulfillment(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.APIResponse{
+			Success: false, Message: "Invalid order ID", Error: "order ID must be a number",
+		})
+		return
+	}
+
+	report, err := h.orderService.GetFulfillment(id)
+	if err != nil {
+		if err.Error() == "order not found" {
+			c.JSON(http.StatusNotFound, models.APIResponse{
+				Success: false, Message: "Order not found", Error: err.Error(),
+			})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, models.APIResponse{
+			Success: false, Message: "Failed to retrieve order fulfillment", Error: err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.APIResponse{
+		Success: true, Message: "Order fulfillment retrieved successfully", Data: report,
+	})
+}
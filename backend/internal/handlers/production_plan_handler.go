@@ -3,6 +3,7 @@ package handlers
 import (
 	"cutrix-backend/internal/models"
 	"cutrix-backend/internal/services"
+	"cutrix-backend/pkg/middleware"
 	"net/http"
 	"strconv"
 
@@ -35,8 +36,20 @@ func (h *ProductionPlanHandler) UpdatePlan(c *gin.Context) {
 		return
 	}
 
-	plan, err := h.planService.UpdatePlan(id, &req)
+	plan, err := h.planService.UpdatePlan(middleware.CurrentActor(c), id, &req)
 	if err != nil {
+		if warningsErr, ok := err.(*services.PlanWarningsError); ok {
+			c.JSON(http.StatusBadRequest, models.APIResponse{
+				Success: false, Message: "Production plan does not match its linked orders", Error: warningsErr.Error(), Data: warningsErr.Warnings,
+			})
+			return
+		}
+		if validationErr, ok := err.(*services.ValidationError); ok {
+			c.JSON(http.StatusBadRequest, models.APIResponse{
+				Success: false, Message: "Failed to update production plan", Error: validationErr.Message,
+			})
+			return
+		}
 		c.JSON(http.StatusInternalServerError, models.APIResponse{
 			Success: false, Message: "Failed to update production plan", Error: err.Error(),
 		})
@@ -59,8 +72,14 @@ func (h *ProductionPlanHandler) DeletePlan(c *gin.Context) {
 		return
 	}
 
-	err = h.planService.DeletePlanByID(id)
+	err = h.planService.DeletePlanByID(middleware.CurrentActor(c), id)
 	if err != nil {
+		if validationErr, ok := err.(*services.ValidationError); ok {
+			c.JSON(http.StatusBadRequest, models.APIResponse{
+				Success: false, Message: "Failed to delete plan", Error: validationErr.Message,
+			})
+			return
+		}
 		c.JSON(http.StatusInternalServerError, models.APIResponse{
 			Success: false, Message: "Failed to delete plan", Error: err.Error(),
 		})
@@ -93,6 +112,29 @@ func (h *ProductionPlanHandler) GetPlanByOrderID(c *gin.Context) {
 		Success: true, Message: "Plan retrieved successfully", Data: plan,
 	})
 }
+
+// GetPlansByOrderID 返回订单关联的全部生产计划
+func (h *ProductionPlanHandler) GetPlansByOrderID(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.APIResponse{
+			Success: false, Message: "Invalid order ID", Error: "order ID must be a number",
+		})
+		return
+	}
+
+	plans, err := h.planService.GetPlansByOrderID(id)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.APIResponse{
+			Success: false, Message: "Failed to retrieve plans for this order", Error: err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.APIResponse{
+		Success: true, Message: "Plans retrieved successfully", Data: plans,
+	})
+}
 func (h *ProductionPlanHandler) CreatePlan(c *gin.Context) {
 	var req models.CreateProductionPlanRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
@@ -102,8 +144,20 @@ func (h *ProductionPlanHandler) CreatePlan(c *gin.Context) {
 		return
 	}
 
-	plan, err := h.planService.CreatePlan(&req)
+	plan, err := h.planService.CreatePlan(middleware.CurrentActor(c), &req)
 	if err != nil {
+		if warningsErr, ok := err.(*services.PlanWarningsError); ok {
+			c.JSON(http.StatusBadRequest, models.APIResponse{
+				Success: false, Message: "Production plan does not match its linked orders", Error: warningsErr.Error(), Data: warningsErr.Warnings,
+			})
+			return
+		}
+		if validationErr, ok := err.(*services.ValidationError); ok {
+			c.JSON(http.StatusBadRequest, models.APIResponse{
+				Success: false, Message: "Failed to create production plan", Error: validationErr.Message,
+			})
+			return
+		}
 		c.JSON(http.StatusInternalServerError, models.APIResponse{
 			Success: false, Message: "Failed to create production plan", Error: err.Error(),
 		})
@@ -114,6 +168,36 @@ func (h *ProductionPlanHandler) CreatePlan(c *gin.Context) {
 		Success: true, Message: "Production plan created successfully", Data: plan,
 	})
 }
+
+// ProposeCutPlan 根据订单数量和约束条件生成裁剪方案，结果不保存
+func (h *ProductionPlanHandler) ProposeCutPlan(c *gin.Context) {
+	var req models.CutPlanRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.APIResponse{
+			Success: false, Message: "Invalid request body", Error: err.Error(),
+		})
+		return
+	}
+
+	proposal, err := h.planService.ProposeCutPlan(&req)
+	if err != nil {
+		if validationErr, ok := err.(*services.ValidationError); ok {
+			c.JSON(http.StatusBadRequest, models.APIResponse{
+				Success: false, Message: "Failed to propose cut plan", Error: validationErr.Message,
+			})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, models.APIResponse{
+			Success: false, Message: "Failed to propose cut plan", Error: err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.APIResponse{
+		Success: true, Message: "Cut plan proposed successfully", Data: proposal,
+	})
+}
+
 func (h *ProductionPlanHandler) GetPlan(c *gin.Context) {
 	idStr := c.Param("id")
 	id, err := strconv.Atoi(idStr)
@@ -136,9 +220,36 @@ func (h *ProductionPlanHandler) GetPlan(c *gin.Context) {
 		Success: true, Message: "Plan retrieved successfully", Data: plan,
 	})
 }
+
+// planListSort 计划列表的排序字段，创建时间默认倒序
+var planListSort = listSort{
+	fields:     []string{"created_at", "plan_name", "plan_id"},
+	descFields: []string{"created_at"},
+}
+
+// GetPlans 分页查询计划列表，q 模糊匹配计划名称或关联订单号，
+// 另外支持按款号 (style_number)、关联订单 (order_id) 和创建时间范围 (from/to) 过滤
 func (h *ProductionPlanHandler) GetPlans(c *gin.Context) {
-	searchQuery := c.Query("q")
-	plans, err := h.planService.GetAllPlans(searchQuery)
+	filter := models.ProductionPlanFilter{
+		Search:      c.Query("q"),
+		StyleNumber: c.Query("style_number"),
+	}
+	var err error
+	filter.ListQuery, err = parseListQuery(c, planListSort)
+	if err == nil {
+		filter.OrderID, err = parseIntQuery(c, "order_id")
+	}
+	if err == nil {
+		filter.CreatedFrom, filter.CreatedTo, err = parseTimeRange(c)
+	}
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.APIResponse{
+			Success: false, Message: "Invalid query parameters", Error: err.Error(),
+		})
+		return
+	}
+
+	plans, total, err := h.planService.GetAllPlans(&filter)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, models.APIResponse{
 			Success: false, Message: "Failed to retrieve plans", Error: err.Error(),
@@ -147,6 +258,6 @@ func (h *ProductionPlanHandler) GetPlans(c *gin.Context) {
 	}
 
 	c.JSON(http.StatusOK, models.APIResponse{
-		Success: true, Message: "Plans retrieved successfully", Data: plans,
+		Success: true, Message: "Plans retrieved successfully", Data: plans, Meta: models.NewPageMeta(filter.ListQuery, total),
 	})
 }
@@ -0,0 +1,84 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+
+	"cutrix-backend/internal/models"
+	"cutrix-backend/internal/services"
+	"cutrix-backend/pkg/middleware"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RecycleBinHandler 处理回收站请求 (仅管理员)
+type RecycleBinHandler struct {
+	recycleBinService services.RecycleBinService
+}
+
+// NewRecycleBinHandler 创建新的回收站处理器
+func NewRecycleBinHandler(recycleBinService services.RecycleBinService) *RecycleBinHandler {
+	return &RecycleBinHandler{recycleBinService: recycleBinService}
+}
+
+var recycleBinListSort = listSort{
+	fields:     []string{"deleted_at", "name"},
+	descFields: []string{"deleted_at"},
+}
+
+// GetArchived 分页列出回收站中的记录，可按 entity_type 过滤
+func (h *RecycleBinHandler) GetArchived(c *gin.Context) {
+	q, err := parseListQuery(c, recycleBinListSort)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.APIResponse{Success: false, Message: "无效的查询参数", Error: err.Error()})
+		return
+	}
+
+	records, total, err := h.recycleBinService.List(c.Query("entity_type"), q)
+	if err != nil {
+		if validationErr, ok := err.(*services.ValidationError); ok {
+			c.JSON(http.StatusBadRequest, models.APIResponse{Success: false, Message: "无效的查询参数", Error: validationErr.Message})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, models.APIResponse{Success: false, Message: "获取回收站记录失败", Error: err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, models.APIResponse{
+		Success: true,
+		Message: "获取回收站记录成功",
+		Data:    records,
+		Meta:    models.NewPageMeta(q, total),
+	})
+}
+
+// RestoreArchived 恢复回收站中的记录
+func (h *RecycleBinHandler) RestoreArchived(c *gin.Context) {
+	h.handleArchived(c, h.recycleBinService.Restore, "恢复记录失败", "恢复记录成功")
+}
+
+// PurgeArchived 彻底删除回收站中没有生产记录的记录
+func (h *RecycleBinHandler) PurgeArchived(c *gin.Context) {
+	h.handleArchived(c, h.recycleBinService.Purge, "彻底删除记录失败", "彻底删除记录成功")
+}
+
+func (h *RecycleBinHandler) handleArchived(c *gin.Context, action func(models.Actor, string, int) error, failMessage, successMessage string) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.APIResponse{Success: false, Message: "无效的记录ID", Error: "记录ID必须是数字"})
+		return
+	}
+
+	if err := action(middleware.CurrentActor(c), c.Param("entity_type"), id); err != nil {
+		if validationErr, ok := err.(*services.ValidationError); ok {
+			c.JSON(http.StatusBadRequest, models.APIResponse{Success: false, Message: failMessage, Error: validationErr.Message})
+			return
+		}
+		if err.Error() == "archived record not found" {
+			c.JSON(http.StatusNotFound, models.APIResponse{Success: false, Message: "回收站中没有该记录", Error: err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, models.APIResponse{Success: false, Message: failMessage, Error: err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, models.APIResponse{Success: true, Message: successMessage})
+}
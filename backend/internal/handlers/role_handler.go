@@ -0,0 +1,129 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+
+	"cutrix-backend/internal/models"
+	"cutrix-backend/internal/services"
+	"cutrix-backend/pkg/middleware"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RoleHandler 处理角色与权限管理请求
+type RoleHandler struct {
+	roleService services.RoleService
+}
+
+// NewRoleHandler 创建新的角色处理器
+func NewRoleHandler(roleService services.RoleService) *RoleHandler {
+	return &RoleHandler{roleService: roleService}
+}
+
+// GetRoles 获取全部角色及其权限
+func (h *RoleHandler) GetRoles(c *gin.Context) {
+	roles, err := h.roleService.GetAll()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.APIResponse{Success: false, Message: "获取角色列表失败", Error: err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, models.APIResponse{Success: true, Message: "获取角色列表成功", Data: roles})
+}
+
+// GetRole 获取单个角色
+func (h *RoleHandler) GetRole(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.APIResponse{Success: false, Message: "无效的角色ID", Error: "ID必须是数字"})
+		return
+	}
+	role, err := h.roleService.GetByID(id)
+	if err != nil {
+		c.JSON(http.StatusNotFound, models.APIResponse{Success: false, Message: "角色不存在", Error: err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, models.APIResponse{Success: true, Message: "获取角色成功", Data: role})
+}
+
+// GetPermissions 获取可分配的全部权限
+func (h *RoleHandler) GetPermissions(c *gin.Context) {
+	permissions, err := h.roleService.GetAllPermissions()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.APIResponse{Success: false, Message: "获取权限列表失败", Error: err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, models.APIResponse{Success: true, Message: "获取权限列表成功", Data: permissions})
+}
+
+// CreateRole 创建自定义角色
+func (h *RoleHandler) CreateRole(c *gin.Context) {
+	var req models.CreateRoleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.APIResponse{Success: false, Message: "请求参数无效", Error: err.Error()})
+		return
+	}
+
+	role, err := h.roleService.Create(middleware.CurrentActor(c), &req)
+	if err != nil {
+		if validationErr, ok := err.(*services.ValidationError); ok {
+			c.JSON(http.StatusBadRequest, models.APIResponse{Success: false, Message: "创建角色失败", Error: validationErr.Message})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, models.APIResponse{Success: false, Message: "创建角色失败", Error: err.Error()})
+		return
+	}
+	c.JSON(http.StatusCreated, models.APIResponse{Success: true, Message: "创建角色成功", Data: role})
+}
+
+// UpdateRole 修改角色名称、说明和权限
+func (h *RoleHandler) UpdateRole(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.APIResponse{Success: false, Message: "无效的角色ID", Error: "ID必须是数字"})
+		return
+	}
+	var req models.UpdateRoleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.APIResponse{Success: false, Message: "请求参数无效", Error: err.Error()})
+		return
+	}
+
+	role, err := h.roleService.Update(middleware.CurrentActor(c), id, &req)
+	if err != nil {
+		if validationErr, ok := err.(*services.ValidationError); ok {
+			c.JSON(http.StatusBadRequest, models.APIResponse{Success: false, Message: "更新角色失败", Error: validationErr.Message})
+			return
+		}
+		if err.Error() == "role not found" {
+			c.JSON(http.StatusNotFound, models.APIResponse{Success: false, Message: "角色不存在", Error: err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, models.APIResponse{Success: false, Message: "更新角色失败", Error: err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, models.APIResponse{Success: true, Message: "更新角色成功", Data: role})
+}
+
+// DeleteRole 删除未被使用的自定义角色
+func (h *RoleHandler) DeleteRole(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.APIResponse{Success: false, Message: "无效的角色ID", Error: "ID必须是数字"})
+		return
+	}
+
+	if err := h.roleService.Delete(middleware.CurrentActor(c), id); err != nil {
+		if validationErr, ok := err.(*services.ValidationError); ok {
+			c.JSON(http.StatusBadRequest, models.APIResponse{Success: false, Message: "删除角色失败", Error: validationErr.Message})
+			return
+		}
+		if err.Error() == "role not found" {
+			c.JSON(http.StatusNotFound, models.APIResponse{Success: false, Message: "角色不存在", Error: err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, models.APIResponse{Success: false, Message: "删除角色失败", Error: err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, models.APIResponse{Success: true, Message: "删除角色成功"})
+}
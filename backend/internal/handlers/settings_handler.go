@@ -0,0 +1,51 @@
+package handlers
+
+import (
+	"net/http"
+
+	"cutrix-backend/internal/models"
+	"cutrix-backend/internal/services"
+	"cutrix-backend/pkg/middleware"
+
+	"github.com/gin-gonic/gin"
+)
+
+// SettingsHandler 处理系统设置请求
+type SettingsHandler struct {
+	settingsService services.SettingsService
+}
+
+// NewSettingsHandler 创建新的系统设置处理器
+func NewSettingsHandler(settingsService services.SettingsService) *SettingsHandler {
+	return &SettingsHandler{settingsService: settingsService}
+}
+
+// GetOrderNumberSettings 获取当前订单号规则
+func (h *SettingsHandler) GetOrderNumberSettings(c *gin.Context) {
+	settings, err := h.settingsService.GetOrderNumberSettings()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.APIResponse{Success: false, Message: "获取订单号规则失败", Error: err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, models.APIResponse{Success: true, Message: "获取订单号规则成功", Data: settings})
+}
+
+// UpdateOrderNumberSettings 修改订单号规则，只影响之后创建的订单
+func (h *SettingsHandler) UpdateOrderNumberSettings(c *gin.Context) {
+	var req models.UpdateOrderNumberSettingsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.APIResponse{Success: false, Message: "请求参数无效", Error: err.Error()})
+		return
+	}
+
+	settings, err := h.settingsService.UpdateOrderNumberSettings(middleware.CurrentActor(c), &req)
+	if err != nil {
+		if validationErr, ok := err.(*services.ValidationError); ok {
+			c.JSON(http.StatusBadRequest, models.APIResponse{Success: false, Message: "修改订单号规则失败", Error: validationErr.Message})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, models.APIResponse{Success: false, Message: "修改订单号规则失败", Error: err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, models.APIResponse{Success: true, Message: "订单号规则已更新", Data: settings})
+}
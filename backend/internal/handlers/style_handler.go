@@ -6,6 +6,7 @@ import (
 
 	"cutrix-backend/internal/models"
 	"cutrix-backend/internal/services"
+	"cutrix-backend/pkg/middleware"
 
 	"github.com/gin-gonic/gin"
 )
@@ -31,8 +32,16 @@ func (h *StyleHandler) CreateStyle(c *gin.Context) {
 		return
 	}
 
-	style, err := h.styleService.CreateStyle(&req)
+	style, err := h.styleService.CreateStyle(middleware.CurrentActor(c), &req)
 	if err != nil {
+		if validationErr, ok := err.(*services.ValidationError); ok {
+			c.JSON(http.StatusBadRequest, models.APIResponse{
+				Success: false,
+				Message: "Failed to create style",
+				Error:   validationErr.Message,
+			})
+			return
+		}
 		c.JSON(http.StatusInternalServerError, models.APIResponse{
 			Success: false,
 			Message: "Failed to create style",
@@ -94,3 +103,99 @@ func (h *StyleHandler) GetStyles(c *gin.Context) {
 		Data:    styles,
 	})
 }
+
+func (h *StyleHandler) UpdateStyle(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.APIResponse{
+			Success: false,
+			Message: "Invalid style ID",
+			Error:   "style ID must be a number",
+		})
+		return
+	}
+
+	var req models.UpdateStyleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.APIResponse{
+			Success: false,
+			Message: "Invalid request body",
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	style, err := h.styleService.UpdateStyle(middleware.CurrentActor(c), id, &req)
+	if err != nil {
+		if validationErr, ok := err.(*services.ValidationError); ok {
+			c.JSON(http.StatusBadRequest, models.APIResponse{
+				Success: false,
+				Message: "Failed to update style",
+				Error:   validationErr.Message,
+			})
+			return
+		}
+		if err.Error() == "style not found" {
+			c.JSON(http.StatusNotFound, models.APIResponse{
+				Success: false,
+				Message: "Style not found",
+				Error:   err.Error(),
+			})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, models.APIResponse{
+			Success: false,
+			Message: "Failed to update style",
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.APIResponse{
+		Success: true,
+		Message: "Style updated successfully",
+		Data:    style,
+	})
+}
+
+func (h *StyleHandler) DeleteStyle(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.APIResponse{
+			Success: false,
+			Message: "Invalid style ID",
+			Error:   "style ID must be a number",
+		})
+		return
+	}
+
+	if err := h.styleService.DeleteStyle(middleware.CurrentActor(c), id); err != nil {
+		if validationErr, ok := err.(*services.ValidationError); ok {
+			c.JSON(http.StatusBadRequest, models.APIResponse{
+				Success: false,
+				Message: "Failed to delete style",
+				Error:   validationErr.Message,
+			})
+			return
+		}
+		if err.Error() == "style not found" {
+			c.JSON(http.StatusNotFound, models.APIResponse{
+				Success: false,
+				Message: "Style not found",
+				Error:   err.Error(),
+			})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, models.APIResponse{
+			Success: false,
+			Message: "Failed to delete style",
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.APIResponse{
+		Success: true,
+		Message: "Style deleted successfully",
+	})
+}
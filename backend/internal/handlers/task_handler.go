@@ -49,9 +49,43 @@ func (h *TaskHandler) GetTask(c *gin.Context) {
 	})
 }
 
+// taskListSort 任务列表的排序字段，省略 order 时均为正序
+var taskListSort = listSort{
+	fields: []string{"task_id", "color", "planned_layers", "progress"},
+}
+
+// GetTasks 分页查询任务列表，支持按款号 (style_number)、计划 (plan_id)、颜色和裁剪状态
+// (status: pending、in_progress、completed) 过滤
 func (h *TaskHandler) GetTasks(c *gin.Context) {
-	tasks, err := h.taskService.GetTasks()
+	filter := models.TaskFilter{
+		StyleNumber: c.Query("style_number"),
+		Color:       c.Query("color"),
+		Status:      c.Query("status"),
+	}
+	var err error
+	filter.ListQuery, err = parseListQuery(c, taskListSort)
+	if err == nil {
+		filter.PlanID, err = parseIntQuery(c, "plan_id")
+	}
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.APIResponse{
+			Success: false,
+			Message: "Invalid query parameters",
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	tasks, total, err := h.taskService.GetTasks(&filter)
 	if err != nil {
+		if validationErr, ok := err.(*services.ValidationError); ok {
+			c.JSON(http.StatusBadRequest, models.APIResponse{
+				Success: false,
+				Message: "Invalid query parameters",
+				Error:   validationErr.Message,
+			})
+			return
+		}
 		c.JSON(http.StatusInternalServerError, models.APIResponse{
 			Success: false,
 			Message: "Failed to retrieve tasks",
@@ -64,6 +98,7 @@ func (h *TaskHandler) GetTasks(c *gin.Context) {
 		Success: true,
 		Message: "Tasks retrieved successfully",
 		Data:    tasks,
+		Meta:    models.NewPageMeta(filter.ListQuery, total),
 	})
 }
 
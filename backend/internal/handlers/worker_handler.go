@@ -3,7 +3,7 @@ package handlers
 import (
 	"cutrix-backend/internal/models"
 	"cutrix-backend/internal/services"
-	"fmt"
+	"cutrix-backend/pkg/middleware"
 	"net/http"
 	"strconv"
 
@@ -20,15 +20,29 @@ func NewWorkerHandler(workerService services.WorkerService) *WorkerHandler {
 	}
 }
 
-// roleMap 用于错误消息的中文转换
-var roleMap = map[string]string{
-	"admin":   "管理员",
-	"manager": "车间主任",
+// workerListSort 员工列表的排序字段，省略 order 时均为正序
+var workerListSort = listSort{
+	fields: []string{"worker_id", "name", "worker_group", "role"},
 }
 
-// GetWorkers ... (此函数及 GetWorker 函数保持不变)
+// GetWorkers 分页查询员工列表，支持按姓名 (q)、班组 (worker_group)、角色 (role) 和是否启用 (is_active) 过滤
 func (h *WorkerHandler) GetWorkers(c *gin.Context) {
-	workers, err := h.workerService.GetAll()
+	filter := models.WorkerFilter{
+		Search:      c.Query("q"),
+		WorkerGroup: c.Query("worker_group"),
+		Role:        c.Query("role"),
+	}
+	var err error
+	filter.ListQuery, err = parseListQuery(c, workerListSort)
+	if err == nil {
+		filter.IsActive, err = parseBoolQuery(c, "is_active")
+	}
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.APIResponse{Success: false, Message: "无效的查询参数", Error: err.Error()})
+		return
+	}
+
+	workers, total, err := h.workerService.GetAll(&filter)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, models.APIResponse{
 			Success: false,
@@ -41,6 +55,7 @@ func (h *WorkerHandler) GetWorkers(c *gin.Context) {
 		Success: true,
 		Message: "获取员工列表成功",
 		Data:    workers,
+		Meta:    models.NewPageMeta(filter.ListQuery, total),
 	})
 }
 
@@ -77,26 +92,7 @@ func (h *WorkerHandler) CreateWorker(c *gin.Context) {
 		return
 	}
 
-	if req.Role == "admin" || req.Role == "manager" {
-		allWorkers, err := h.workerService.GetAll()
-		if err != nil {
-			c.JSON(http.StatusInternalServerError, models.APIResponse{Success: false, Message: "无法验证角色唯一性", Error: err.Error()})
-			return
-		}
-		for _, w := range allWorkers {
-			if w.Role == req.Role {
-				// --- 修改点：使用 roleMap 进行中文提示 ---
-				roleName := roleMap[req.Role]
-				if roleName == "" {
-					roleName = req.Role
-				}
-				c.JSON(http.StatusBadRequest, models.APIResponse{Success: false, Message: "创建失败", Error: fmt.Sprintf("角色 '%s' 已存在，且只能有一个", roleName)})
-				return
-			}
-		}
-	}
-
-	worker, err := h.workerService.Create(&req)
+	worker, err := h.workerService.Create(middleware.CurrentActor(c), &req)
 	if err != nil {
 		if validationErr, ok := err.(*services.ValidationError); ok {
 			c.JSON(http.StatusBadRequest, models.APIResponse{Success: false, Message: "创建员工失败", Error: validationErr.Message})
@@ -122,26 +118,7 @@ func (h *WorkerHandler) UpdateWorker(c *gin.Context) {
 		return
 	}
 
-	if req.Role == "admin" || req.Role == "manager" {
-		allWorkers, err := h.workerService.GetAll()
-		if err != nil {
-			c.JSON(http.StatusInternalServerError, models.APIResponse{Success: false, Message: "无法验证角色唯一性", Error: err.Error()})
-			return
-		}
-		for _, w := range allWorkers {
-			if w.Role == req.Role && w.WorkerID != id {
-				// --- 修改点：使用 roleMap 进行中文提示 ---
-				roleName := roleMap[req.Role]
-				if roleName == "" {
-					roleName = req.Role
-				}
-				c.JSON(http.StatusBadRequest, models.APIResponse{Success: false, Message: "更新失败", Error: fmt.Sprintf("角色 '%s' 已存在，且只能有一个", roleName)})
-				return
-			}
-		}
-	}
-
-	worker, err := h.workerService.Update(id, &req)
+	worker, err := h.workerService.Update(middleware.CurrentActor(c), id, &req)
 	if err != nil {
 		if validationErr, ok := err.(*services.ValidationError); ok {
 			c.JSON(http.StatusBadRequest, models.APIResponse{Success: false, Message: "更新员工失败", Error: validationErr.Message})
@@ -166,7 +143,7 @@ func (h *WorkerHandler) DeleteWorker(c *gin.Context) {
 		return
 	}
 
-	err = h.workerService.Delete(id)
+	err = h.workerService.Delete(middleware.CurrentActor(c), id)
 	if err != nil {
 		if validationErr, ok := err.(*services.ValidationError); ok {
 			c.JSON(http.StatusBadRequest, models.APIResponse{Success: false, Message: "删除员工失败", Error: validationErr.Message})
@@ -208,9 +185,7 @@ func (h *WorkerHandler) UpdateWorkerPassword(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, models.APIResponse{Success: false, Message: "请求参数无效", Error: err.Error()})
 		return
 	}
-	updatingUserID := 1
-	updatingUserRole := "admin"
-	err = h.workerService.UpdatePassword(updatingUserID, updatingUserRole, targetWorkerID, req.Password)
+	err = h.workerService.UpdatePassword(middleware.CurrentActor(c), targetWorkerID, req.Password)
 	if err != nil {
 		if validationErr, ok := err.(*services.ValidationError); ok {
 			c.JSON(http.StatusForbidden, models.APIResponse{Success: false, Message: "操作失败", Error: validationErr.Message})
@@ -222,6 +197,92 @@ func (h *WorkerHandler) UpdateWorkerPassword(c *gin.Context) {
 	c.JSON(http.StatusOK, models.APIResponse{Success: true, Message: "密码更新成功"})
 }
 
+// UpdateWorkerPin 设置员工的车间终端登录PIN
+func (h *WorkerHandler) UpdateWorkerPin(c *gin.Context) {
+	targetWorkerID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.APIResponse{Success: false, Message: "无效的员工ID"})
+		return
+	}
+	var req models.UpdateWorkerPinRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.APIResponse{Success: false, Message: "请求参数无效", Error: err.Error()})
+		return
+	}
+	err = h.workerService.SetPin(middleware.CurrentActor(c), targetWorkerID, req.Pin)
+	h.respondCredentialUpdate(c, err, "PIN设置成功")
+}
+
+// ClearWorkerPin 清除员工的车间终端登录PIN
+func (h *WorkerHandler) ClearWorkerPin(c *gin.Context) {
+	targetWorkerID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.APIResponse{Success: false, Message: "无效的员工ID"})
+		return
+	}
+	err = h.workerService.ClearPin(middleware.CurrentActor(c), targetWorkerID)
+	h.respondCredentialUpdate(c, err, "PIN已清除")
+}
+
+// UpdateWorkerBadge 为员工绑定工牌
+func (h *WorkerHandler) UpdateWorkerBadge(c *gin.Context) {
+	targetWorkerID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.APIResponse{Success: false, Message: "无效的员工ID"})
+		return
+	}
+	var req models.UpdateWorkerBadgeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.APIResponse{Success: false, Message: "请求参数无效", Error: err.Error()})
+		return
+	}
+	err = h.workerService.SetBadge(middleware.CurrentActor(c), targetWorkerID, req.BadgeCode)
+	h.respondCredentialUpdate(c, err, "工牌绑定成功")
+}
+
+// ClearWorkerBadge 解除员工的工牌绑定
+func (h *WorkerHandler) ClearWorkerBadge(c *gin.Context) {
+	targetWorkerID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.APIResponse{Success: false, Message: "无效的员工ID"})
+		return
+	}
+	err = h.workerService.ClearBadge(middleware.CurrentActor(c), targetWorkerID)
+	h.respondCredentialUpdate(c, err, "工牌已解除绑定")
+}
+
+func (h *WorkerHandler) respondCredentialUpdate(c *gin.Context, err error, successMessage string) {
+	if err != nil {
+		if validationErr, ok := err.(*services.ValidationError); ok {
+			c.JSON(http.StatusForbidden, models.APIResponse{Success: false, Message: "操作失败", Error: validationErr.Message})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, models.APIResponse{Success: false, Message: "更新登录凭证失败", Error: err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, models.APIResponse{Success: true, Message: successMessage})
+}
+
+// UnlockWorker 解除员工账户的登录锁定
+func (h *WorkerHandler) UnlockWorker(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.APIResponse{Success: false, Message: "无效的员工ID", Error: "ID必须是数字"})
+		return
+	}
+
+	if err := h.workerService.Unlock(middleware.CurrentActor(c), id); err != nil {
+		if err.Error() == "worker not found" {
+			c.JSON(http.StatusNotFound, models.APIResponse{Success: false, Message: "员工不存在", Error: "找不到指定的员工"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, models.APIResponse{Success: false, Message: "解锁失败", Error: err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.APIResponse{Success: true, Message: "账户已解锁"})
+}
+
 // 在文件末尾添加新的处理器函数
 func (h *WorkerHandler) GetWorkerTaskGroups(c *gin.Context) {
 	id, err := strconv.Atoi(c.Param("id"))
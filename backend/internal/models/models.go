@@ -1,22 +1,173 @@
 package models
 
-import "time"
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// --- 角色 ---
+
+// 系统内置角色，其余角色由管理员在 Roles 表中维护
+
+const (
+	RoleAdmin        = "admin"
+	RoleManager      = "manager"
+	RoleWorker       = "worker"
+	RolePatternMaker = "pattern_maker"
+)
 
 // --- 基础实体模型 ---
 
+// Style 是款号主数据。SizeScale 为尺码体系 (alpha、numeric、kids 或 custom)，
+// Sizes 为按顺序排列的尺码组 (如 XS,S,M,L,XL)，Colors 为允许的颜色，为空时不限制订单明细和排版中的尺码、颜色
 type Style struct {
-	StyleID     int    `json:"style_id" db:"style_id"`
-	StyleNumber string `json:"style_number" db:"style_number" validate:"required"`
+	StyleID      int            `json:"style_id" db:"style_id"`
+	StyleNumber  string         `json:"style_number" db:"style_number" validate:"required"`
+	Description  string         `json:"description" db:"description"`
+	Season       string         `json:"season" db:"season"`
+	CustomerID   *int           `json:"customer_id" db:"customer_id"`
+	CustomerName *string        `json:"customer_name" db:"customer_name"`
+	SizeScale    string         `json:"size_scale" db:"size_scale"`
+	Sizes        pq.StringArray `json:"sizes" db:"sizes"`
+	Colors       pq.StringArray `json:"colors" db:"colors"`
+	MainFabric   string         `json:"main_fabric" db:"main_fabric"`
+	CreatedAt    time.Time      `json:"created_at" db:"created_at"`
+	UpdatedAt    time.Time      `json:"updated_at" db:"updated_at"`
+}
+
+// Customer 是客户主数据，CustomerCode 可用于订单号规则中的 {customer}
+type Customer struct {
+	CustomerID   int       `json:"customer_id" db:"customer_id"`
+	CustomerCode string    `json:"customer_code" db:"customer_code"`
+	Name         string    `json:"name" db:"name"`
+	ContactName  string    `json:"contact_name" db:"contact_name"`
+	Phone        string    `json:"phone" db:"phone"`
+	Remarks      string    `json:"remarks" db:"remarks"`
+	CreatedAt    time.Time `json:"created_at" db:"created_at"`
+	UpdatedAt    time.Time `json:"updated_at" db:"updated_at"`
 }
 
 type Worker struct {
-	WorkerID     int     `json:"worker_id" db:"worker_id"`
-	Name         string  `json:"name" db:"name" validate:"required"`
-	Notes        string  `json:"notes" db:"notes"`
-	PasswordHash string  `json:"-" db:"password_hash"`
-	Role         string  `json:"role" db:"role"`
-	WorkerGroup  *string `json:"worker_group" db:"worker_group"`
-	IsActive     bool    `json:"is_active" db:"is_active"`
+	WorkerID            int        `json:"worker_id" db:"worker_id"`
+	Name                string     `json:"name" db:"name" validate:"required"`
+	Notes               string     `json:"notes" db:"notes"`
+	PasswordHash        string     `json:"-" db:"password_hash"`
+	Role                string     `json:"role" db:"role"`
+	WorkerGroup         *string    `json:"worker_group" db:"worker_group"`
+	IsActive            bool       `json:"is_active" db:"is_active"`
+	FailedLoginAttempts int        `json:"failed_login_attempts" db:"failed_login_attempts"`
+	LockedUntil         *time.Time `json:"locked_until" db:"locked_until"`
+	PinHash             string     `json:"-" db:"pin_hash"`
+	HasPin              bool       `json:"has_pin" db:"has_pin"`
+	HasBadge            bool       `json:"has_badge" db:"has_badge"`
+}
+
+// Session 是一次登录会话，保存刷新令牌的哈希，吊销后该会话签发的令牌全部失效
+type Session struct {
+	SessionID        int64      `json:"session_id" db:"session_id"`
+	WorkerID         int        `json:"worker_id" db:"worker_id"`
+	RefreshTokenHash string     `json:"-" db:"refresh_token_hash"` // 终端会话为空
+	Scope            string     `json:"scope" db:"scope"`
+	ClientIP         string     `json:"client_ip" db:"client_ip"`
+	UserAgent        string     `json:"user_agent" db:"user_agent"`
+	CreatedAt        time.Time  `json:"created_at" db:"created_at"`
+	ExpiresAt        time.Time  `json:"expires_at" db:"expires_at"`
+	RevokedAt        *time.Time `json:"revoked_at" db:"revoked_at"`
+	RotatedAt        *time.Time `json:"rotated_at" db:"rotated_at"` // 最近一次轮换刷新令牌的时间
+}
+
+// LoginEvent 记录一次登录尝试，用于安全审计和暴力破解防护
+type LoginEvent struct {
+	EventID    int64     `json:"event_id" db:"event_id"`
+	WorkerID   *int      `json:"worker_id" db:"worker_id"`
+	WorkerName string    `json:"worker_name" db:"worker_name"`
+	ClientIP   string    `json:"client_ip" db:"client_ip"`
+	UserAgent  string    `json:"user_agent" db:"user_agent"`
+	Method     string    `json:"method" db:"method"` // password, pin, badge
+	Success    bool      `json:"success" db:"success"`
+	Reason     string    `json:"reason" db:"reason"`
+	CreatedAt  time.Time `json:"created_at" db:"created_at"`
+}
+
+// APIKey 是供自动裁床、ERP 等系统集成使用的访问密钥，明文只在创建时返回一次
+type APIKey struct {
+	APIKeyID   int            `json:"api_key_id" db:"api_key_id"`
+	Name       string         `json:"name" db:"name"`
+	KeyPrefix  string         `json:"key_prefix" db:"key_prefix"`
+	Scopes     pq.StringArray `json:"scopes" db:"scopes"`
+	CreatedBy  *int           `json:"created_by" db:"created_by"`
+	CreatedAt  time.Time      `json:"created_at" db:"created_at"`
+	ExpiresAt  *time.Time     `json:"expires_at" db:"expires_at"`
+	LastUsedAt *time.Time     `json:"last_used_at" db:"last_used_at"`
+	RevokedAt  *time.Time     `json:"revoked_at" db:"revoked_at"`
+}
+
+// Actor 表示发起写操作的调用者 (员工或系统集成)，由处理器从认证信息构造并传入服务层
+type Actor struct {
+	WorkerID    *int
+	APIKeyID    *int
+	Role        string
+	Permissions map[string]bool
+	ClientIP    string
+}
+
+// Can 判断调用者是否拥有指定权限
+func (a Actor) Can(permission string) bool {
+	return a.Permissions[permission]
+}
+
+// Role 是存储在数据库中的角色及其权限
+type Role struct {
+	RoleID      int            `json:"role_id" db:"role_id"`
+	Name        string         `json:"name" db:"name"`
+	DisplayName string         `json:"display_name" db:"display_name"`
+	Description string         `json:"description" db:"description"`
+	IsSystem    bool           `json:"is_system" db:"is_system"`
+	MaxPerGroup *int           `json:"max_per_group" db:"max_per_group"` // 每个班组内的人数上限，nil 表示不限
+	Permissions pq.StringArray `json:"permissions" db:"permissions"`
+	WorkerCount int            `json:"worker_count" db:"worker_count"`
+	CreatedAt   time.Time      `json:"created_at" db:"created_at"`
+}
+
+// Permission 是可分配给角色的权限
+type Permission struct {
+	Code        string `json:"code" db:"permission_code"`
+	Description string `json:"description" db:"description"`
+}
+
+// AuditLog 是一条写操作审计记录，Before/After 为变更前后对象的 JSON 快照
+type AuditLog struct {
+	AuditID       int64           `json:"audit_id" db:"audit_id"`
+	ActorWorkerID *int            `json:"actor_worker_id" db:"actor_worker_id"`
+	ActorName     string          `json:"actor_name" db:"actor_name"`
+	ActorAPIKeyID *int            `json:"actor_api_key_id" db:"actor_api_key_id"`
+	ActorRole     string          `json:"actor_role" db:"actor_role"`
+	ClientIP      string          `json:"client_ip" db:"client_ip"`
+	Action        string          `json:"action" db:"action"`
+	EntityType    string          `json:"entity_type" db:"entity_type"`
+	EntityID      int64           `json:"entity_id" db:"entity_id"`
+	Before        json.RawMessage `json:"before" db:"before_data"`
+	After         json.RawMessage `json:"after" db:"after_data"`
+	CreatedAt     time.Time       `json:"created_at" db:"created_at"`
+}
+
+// SystemSetting 是一项由管理员维护的系统设置
+type SystemSetting struct {
+	SettingID int       `json:"setting_id" db:"setting_id"`
+	Key       string    `json:"key" db:"setting_key"`
+	Value     string    `json:"value" db:"setting_value"`
+	UpdatedBy *int      `json:"updated_by" db:"updated_by"`
+	UpdatedAt time.Time `json:"updated_at" db:"updated_at"`
+}
+
+// OrderNumberSettings 订单号规则及按该规则生成的示例
+type OrderNumberSettings struct {
+	Template  string    `json:"template"`
+	Preview   string    `json:"preview"`
+	UpdatedBy *int      `json:"updated_by"`
+	UpdatedAt time.Time `json:"updated_at"`
 }
 
 type ProductionLog struct {
@@ -27,16 +178,143 @@ type ProductionLog struct {
 	ProcessName     string    `json:"process_name" db:"process_name" validate:"required,oneof=放料 拉布 裁剪 打包"`
 	LayersCompleted *int      `json:"layers_completed" db:"layers_completed"`
 	LogTime         time.Time `json:"log_time" db:"log_time"`
+	APIKeyID        *int      `json:"api_key_id,omitempty" db:"api_key_id"` // 通过 API 密钥提交时记录来源集成
 }
 
 // --- 订单、计划、任务模型 (新/重构) ---
 
 type ProductionOrder struct {
-	OrderID     int         `json:"order_id" db:"order_id"`
-	OrderNumber string      `json:"order_number" db:"order_number"`
-	StyleID     int         `json:"style_id" db:"style_id"`
-	CreatedAt   time.Time   `json:"created_at" db:"created_at"`
-	Items       []OrderItem `json:"items,omitempty"` // 用于API响应，数据库中无此字段
+	OrderID         int         `json:"order_id" db:"order_id"`
+	OrderNumber     string      `json:"order_number" db:"order_number"`
+	StyleID         int         `json:"style_id" db:"style_id"`
+	CustomerID      *int        `json:"customer_id" db:"customer_id"`
+	CustomerName    *string     `json:"customer_name,omitempty" db:"customer_name"` // 查询时关联客户表得到
+	DueDate         *time.Time  `json:"due_date" db:"due_date"`
+	Priority        string      `json:"priority" db:"priority"`
+	Remarks         string      `json:"remarks" db:"remarks"`
+	Status          string      `json:"status" db:"status"`
+	StatusUpdatedAt time.Time   `json:"status_updated_at" db:"status_updated_at"`
+	CreatedAt       time.Time   `json:"created_at" db:"created_at"`
+	Items           []OrderItem `json:"items,omitempty"`                                  // 用于API响应，数据库中无此字段
+	UnplannedPieces *int        `json:"unplanned_pieces,omitempty" db:"unplanned_pieces"` // 仅未排计划订单列表：尚未分配到计划的件数
+}
+
+// OrderFulfillmentLine 是订单一个 颜色+尺码 (或合计) 的执行情况。
+// Diff 为正表示多于订单数量，为负表示不足；订单数量为 0 时百分比为 null
+type OrderFulfillmentLine struct {
+	Color           string   `json:"color,omitempty"`
+	Size            string   `json:"size,omitempty"`
+	OrderedQuantity int      `json:"ordered_quantity"`
+	PlannedPieces   int      `json:"planned_pieces"`
+	CutPieces       int      `json:"cut_pieces"`
+	PlannedDiff     int      `json:"planned_diff"`
+	CutDiff         int      `json:"cut_diff"`
+	PlannedPercent  *float64 `json:"planned_percent"`
+	CutPercent      *float64 `json:"cut_percent"`
+}
+
+// OrderFulfillment 是订单的执行报表：订单数量 vs 计划件数 vs 实际裁剪件数
+type OrderFulfillment struct {
+	OrderID     int                    `json:"order_id"`
+	OrderNumber string                 `json:"order_number"`
+	PlanIDs     []int                  `json:"plan_ids"`
+	Lines       []OrderFulfillmentLine `json:"lines"`
+	Total       OrderFulfillmentLine   `json:"total"`
+}
+
+// 订单优先级
+const (
+	OrderPriorityLow    = "low"
+	OrderPriorityNormal = "normal"
+	OrderPriorityHigh   = "high"
+	OrderPriorityUrgent = "urgent"
+)
+
+// ProductionOrderFilter 是查询订单列表的过滤和排序条件，零值字段表示不过滤
+type ProductionOrderFilter struct {
+	ListQuery   // 排序字段：created_at (默认)、due_date、priority、order_number
+	StyleNumber string
+	CustomerID  *int
+	Status      string
+	Priority    string
+	DueFrom     *time.Time
+	DueTo       *time.Time // 包含当天
+	CreatedFrom *time.Time
+	CreatedTo   *time.Time // 开区间
+}
+
+// ProductionPlanFilter 是查询计划列表的过滤条件，零值字段表示不过滤
+type ProductionPlanFilter struct {
+	ListQuery          // 排序字段：created_at (默认)、plan_name、plan_id
+	Search      string // 模糊匹配计划名称或关联订单号
+	StyleNumber string
+	OrderID     *int
+	CreatedFrom *time.Time
+	CreatedTo   *time.Time // 开区间
+}
+
+// 任务裁剪状态 (按完成层数计算)
+const (
+	TaskStatusPending    = "pending"
+	TaskStatusInProgress = "in_progress"
+	TaskStatusCompleted  = "completed"
+)
+
+// TaskFilter 是查询任务列表的过滤条件，零值字段表示不过滤
+type TaskFilter struct {
+	ListQuery   // 排序字段：task_id (默认)、color、planned_layers、progress
+	StyleNumber string
+	PlanID      *int
+	Color       string
+	Status      string // pending、in_progress、completed
+}
+
+// WorkerFilter 是查询员工列表的过滤条件，零值字段表示不过滤
+type WorkerFilter struct {
+	ListQuery          // 排序字段：worker_id (默认)、name、worker_group、role
+	Search      string // 模糊匹配姓名
+	WorkerGroup string
+	Role        string
+	IsActive    *bool
+}
+
+// AtRiskOrder 是交期临近但裁剪进度落后的订单。进度按关联计划分配给该订单的件数计算：
+// 已裁剪件数 / 计划件数，没有分配到计划件数的订单进度为 0
+type AtRiskOrder struct {
+	OrderID         int       `json:"order_id" db:"order_id"`
+	OrderNumber     string    `json:"order_number" db:"order_number"`
+	StyleNumber     string    `json:"style_number" db:"style_number"`
+	CustomerID      *int      `json:"customer_id" db:"customer_id"`
+	CustomerName    *string   `json:"customer_name" db:"customer_name"`
+	DueDate         time.Time `json:"due_date" db:"due_date"`
+	DaysRemaining   int       `json:"days_remaining" db:"days_remaining"` // 负数表示已逾期
+	Priority        string    `json:"priority" db:"priority"`
+	Status          string    `json:"status" db:"status"`
+	OrderedQuantity int       `json:"ordered_quantity" db:"ordered_quantity"`
+	PlannedPieces   int       `json:"planned_pieces" db:"planned_pieces"`
+	CutPieces       int       `json:"cut_pieces" db:"cut_pieces"`
+	Progress        float64   `json:"progress" db:"-"`
+}
+
+// OrderTaskProgress 是订单关联计划下全部裁剪任务的进度汇总
+type OrderTaskProgress struct {
+	Tasks           int `db:"tasks"`
+	CompletedTasks  int `db:"completed_tasks"`
+	CompletedLayers int `db:"completed_layers"`
+}
+
+// OrderStatusChange 是一条订单状态变更记录，FromStatus 为空表示订单创建时的初始状态
+type OrderStatusChange struct {
+	HistoryID     int64     `json:"history_id" db:"history_id"`
+	OrderID       int       `json:"order_id" db:"order_id"`
+	FromStatus    *string   `json:"from_status" db:"from_status"`
+	ToStatus      string    `json:"to_status" db:"to_status"`
+	ActorWorkerID *int      `json:"actor_worker_id" db:"actor_worker_id"`
+	ActorName     string    `json:"actor_name" db:"actor_name"`
+	ActorAPIKeyID *int      `json:"actor_api_key_id" db:"actor_api_key_id"`
+	Automatic     bool      `json:"automatic" db:"automatic"`
+	Reason        string    `json:"reason" db:"reason"`
+	CreatedAt     time.Time `json:"created_at" db:"created_at"`
 }
 
 type OrderItem struct {
@@ -51,9 +329,55 @@ type ProductionPlan struct {
 	PlanID        int             `json:"plan_id" db:"plan_id"`
 	PlanName      string          `json:"plan_name" db:"plan_name"`
 	StyleID       int             `json:"style_id" db:"style_id"`
-	LinkedOrderID *int            `json:"linked_order_id" db:"linked_order_id"`
+	LinkedOrderID *int            `json:"linked_order_id" db:"linked_order_id"` // 兼容旧接口：关联订单中编号最小的一个
 	CreatedAt     time.Time       `json:"created_at" db:"created_at"`
-	Layouts       []CuttingLayout `json:"layouts,omitempty"` // 用于API响应，数据库中无此字段
+	Layouts       []CuttingLayout `json:"layouts,omitempty"`  // 用于API响应，数据库中无此字段
+	Orders        []PlanOrder     `json:"orders,omitempty"`   // 关联的订单及计划件数分配，用于API响应
+	Warnings      []PlanWarning   `json:"warnings,omitempty"` // 保存时计划件数与关联订单不一致的警告，用于API响应
+}
+
+// 计划校验模式：strict 有警告时拒绝保存，lenient 照常保存并记录警告
+const (
+	PlanValidationStrict  = "strict"
+	PlanValidationLenient = "lenient"
+)
+
+// 计划警告类型
+const (
+	PlanWarningUnderCut     = "under_cut"     // 计划件数少于订单未排数量
+	PlanWarningOverCut      = "over_cut"      // 计划件数超出订单未排数量且超过允许的超裁比例
+	PlanWarningUnknownColor = "unknown_color" // 颜色不在关联订单中
+	PlanWarningUnknownSize  = "unknown_size"  // 尺码不在关联订单中
+	PlanWarningUnknownItem  = "unknown_item"  // 颜色和尺码都在订单中，但订单没有这个 颜色+尺码
+)
+
+// PlanWarning 是计划件数与关联订单的一处不一致。OrderedQuantity 为关联订单尚未被其他计划覆盖的数量，
+// 颜色或尺码级别的警告 Size 或 Color 为空
+type PlanWarning struct {
+	Type            string `json:"type" db:"warning_type"`
+	Color           string `json:"color,omitempty" db:"color"`
+	Size            string `json:"size,omitempty" db:"size"`
+	OrderedQuantity int    `json:"ordered_quantity" db:"ordered_quantity"`
+	PlannedPieces   int    `json:"planned_pieces" db:"planned_pieces"`
+	Message         string `json:"message" db:"message"`
+}
+
+// PlanOrder 是计划关联的一个订单，按交期 (空交期在后)、订单ID排序，
+// 实际裁剪件数按这个顺序依次分给各订单
+type PlanOrder struct {
+	OrderID     int                   `json:"order_id" db:"order_id"`
+	OrderNumber string                `json:"order_number" db:"order_number"`
+	DueDate     *time.Time            `json:"due_date" db:"due_date"`
+	Allocations []PlanOrderAllocation `json:"allocations"`
+}
+
+// PlanOrderAllocation 是计划分配给某个订单的某个 颜色+尺码 的件数
+type PlanOrderAllocation struct {
+	PlanID   int    `json:"plan_id" db:"plan_id"`
+	OrderID  int    `json:"order_id" db:"order_id"`
+	Color    string `json:"color" db:"color"`
+	Size     string `json:"size" db:"size"`
+	Quantity int    `json:"quantity" db:"quantity"`
 }
 
 type CuttingLayout struct {
@@ -82,40 +406,225 @@ type ProductionTask struct {
 	CompletedLayers int    `json:"completed_layers" db:"completed_layers"`
 }
 
+// --- 导出模型 (逐行从数据库读取，不一次性加载) ---
+
+// OrderExportRow 是订单明细导出的一行 (一个订单的一个 颜色+尺码)
+type OrderExportRow struct {
+	OrderID     int       `db:"order_id"`
+	OrderNumber string    `db:"order_number"`
+	StyleNumber string    `db:"style_number"`
+	CreatedAt   time.Time `db:"created_at"`
+	Color       string    `db:"color"`
+	Size        string    `db:"size"`
+	Quantity    int       `db:"quantity"`
+}
+
+// PlanExportRow 是生产计划导出的一行 (一个排版下的一个颜色任务)
+type PlanExportRow struct {
+	PlanID          int       `db:"plan_id"`
+	PlanName        string    `db:"plan_name"`
+	StyleNumber     string    `db:"style_number"`
+	OrderNumber     *string   `db:"order_number"`
+	CreatedAt       time.Time `db:"created_at"`
+	LayoutName      *string   `db:"layout_name"`
+	Description     *string   `db:"description"`
+	Ratios          *string   `db:"ratios"`
+	PiecesPerLayer  *int      `db:"pieces_per_layer"`
+	Color           *string   `db:"color"`
+	PlannedLayers   *int      `db:"planned_layers"`
+	CompletedLayers *int      `db:"completed_layers"`
+}
+
+// LogExportRow 是生产记录导出的一行
+type LogExportRow struct {
+	LogID           int64     `db:"log_id"`
+	LogTime         time.Time `db:"log_time"`
+	WorkerName      string    `db:"worker_name"`
+	WorkerGroup     *string   `db:"worker_group"`
+	ProcessName     string    `db:"process_name"`
+	LayersCompleted *int      `db:"layers_completed"`
+	TaskID          *int      `db:"task_id"`
+	StyleNumber     *string   `db:"style_number"`
+	PlanName        *string   `db:"plan_name"`
+	LayoutName      *string   `db:"layout_name"`
+	Color           *string   `db:"color"`
+	ParentLogID     *int64    `db:"parent_log_id"`
+	APIKeyName      *string   `db:"api_key_name"`
+}
+
 // --- API 请求/响应模型 ---
 
 type APIResponse struct {
 	Success bool        `json:"success"`
 	Message string      `json:"message"`
 	Data    interface{} `json:"data,omitempty"`
+	Meta    *PageMeta   `json:"meta,omitempty"` // 仅列表接口
 	Error   string      `json:"error,omitempty"`
 }
 
+// ListQuery 是列表接口共用的分页和排序参数 (查询参数 page、page_size、sort、order)。
+// PageSize 为 0 表示不分页，返回全部结果 (仅供内部调用，列表接口总是分页)
+type ListQuery struct {
+	Page     int
+	PageSize int
+	SortBy   string
+	SortDesc bool
+}
+
+// Offset 返回当前页第一条记录的偏移量
+func (q ListQuery) Offset() int {
+	return (q.Page - 1) * q.PageSize
+}
+
+// PageMeta 是列表响应的分页信息，Total 为满足过滤条件的总数
+type PageMeta struct {
+	Total      int `json:"total"`
+	Page       int `json:"page"`
+	PageSize   int `json:"page_size"`
+	TotalPages int `json:"total_pages"`
+}
+
+// NewPageMeta 根据查询参数和总数生成分页信息，不分页时整个结果视为一页
+func NewPageMeta(q ListQuery, total int) *PageMeta {
+	if q.PageSize <= 0 {
+		return &PageMeta{Total: total, Page: 1, PageSize: total, TotalPages: 1}
+	}
+	return &PageMeta{
+		Total:      total,
+		Page:       q.Page,
+		PageSize:   q.PageSize,
+		TotalPages: (total + q.PageSize - 1) / q.PageSize,
+	}
+}
+
 // 登录
 type LoginRequest struct {
 	Name     string `json:"name" validate:"required"`
 	Password string `json:"password"`
 }
 
+type LoginResponse struct {
+	Token            string    `json:"token"`
+	ExpiresAt        time.Time `json:"expires_at"`
+	RefreshToken     string    `json:"refresh_token"`
+	RefreshExpiresAt time.Time `json:"refresh_expires_at"`
+	Worker           *Worker   `json:"worker"`
+}
+
+// KioskLoginRequest 用于车间终端快捷登录，提供 姓名+PIN 或 工牌码 之一
+type KioskLoginRequest struct {
+	Name      string `json:"name"`
+	Pin       string `json:"pin"`
+	BadgeCode string `json:"badge_code"`
+}
+
+// KioskLoginResponse 终端登录只返回短期受限令牌，不提供刷新令牌
+type KioskLoginResponse struct {
+	Token     string    `json:"token"`
+	ExpiresAt time.Time `json:"expires_at"`
+	Worker    *Worker   `json:"worker"`
+}
+
+// LoginEventFilter 是查询登录事件的过滤条件，零值字段表示不过滤
+type LoginEventFilter struct {
+	WorkerName string
+	ClientIP   string
+	Success    *bool
+	From       *time.Time
+	To         *time.Time
+	Limit      int
+}
+
+// AuditLogFilter 是分页查询审计日志的过滤条件，零值字段表示不过滤
+type AuditLogFilter struct {
+	EntityType    string
+	EntityID      *int64
+	ActorWorkerID *int
+	From          *time.Time
+	To            *time.Time
+	Page          int
+	PageSize      int
+}
+
+// AuditLogPage 是审计日志的一页查询结果
+type AuditLogPage struct {
+	Items    []AuditLog `json:"items"`
+	Total    int        `json:"total"`
+	Page     int        `json:"page"`
+	PageSize int        `json:"page_size"`
+}
+
+// ArchivedRecord 是回收站中的一条已删除记录，EntityType 与审计日志的对象类型一致
+// (production_order、production_plan、style、worker)
+type ArchivedRecord struct {
+	EntityType    string    `json:"entity_type" db:"entity_type"`
+	EntityID      int       `json:"entity_id" db:"entity_id"`
+	Name          string    `json:"name" db:"name"` // 订单号、计划名称、款号或员工姓名
+	DeletedAt     time.Time `json:"deleted_at" db:"deleted_at"`
+	DeletedBy     *int      `json:"deleted_by" db:"deleted_by"`
+	DeletedByName *string   `json:"deleted_by_name" db:"deleted_by_name"`
+	HasHistory    bool      `json:"has_history" db:"has_history"` // 有生产记录或被其他数据引用，不能彻底删除
+}
+
+// RefreshRequest 用于刷新访问令牌或注销会话
+type RefreshRequest struct {
+	RefreshToken string `json:"refresh_token" validate:"required"`
+}
+
 // 款号
 type CreateStyleRequest struct {
-	StyleNumber string `json:"style_number" validate:"required"`
+	StyleNumber string   `json:"style_number" validate:"required,max=50"`
+	Description string   `json:"description"`
+	Season      string   `json:"season" validate:"max=20"`
+	CustomerID  *int     `json:"customer_id"`
+	SizeScale   string   `json:"size_scale"` // 为空时为 custom
+	Sizes       []string `json:"sizes"`
+	Colors      []string `json:"colors"`
+	MainFabric  string   `json:"main_fabric" validate:"max=100"`
+}
+
+type UpdateStyleRequest struct {
+	StyleNumber string   `json:"style_number" validate:"required,max=50"`
+	Description string   `json:"description"`
+	Season      string   `json:"season" validate:"max=20"`
+	CustomerID  *int     `json:"customer_id"`
+	SizeScale   string   `json:"size_scale"` // 为空时为 custom
+	Sizes       []string `json:"sizes"`
+	Colors      []string `json:"colors"`
+	MainFabric  string   `json:"main_fabric" validate:"max=100"`
+}
+
+// 客户
+type CreateCustomerRequest struct {
+	CustomerCode string `json:"customer_code" validate:"required,max=20"`
+	Name         string `json:"name" validate:"required,max=100"`
+	ContactName  string `json:"contact_name" validate:"max=50"`
+	Phone        string `json:"phone" validate:"max=30"`
+	Remarks      string `json:"remarks"`
+}
+
+type UpdateCustomerRequest struct {
+	CustomerCode string `json:"customer_code" validate:"required,max=20"`
+	Name         string `json:"name" validate:"required,max=100"`
+	ContactName  string `json:"contact_name" validate:"max=50"`
+	Phone        string `json:"phone" validate:"max=30"`
+	Remarks      string `json:"remarks"`
 }
 
 // 员工
 type CreateWorkerRequest struct {
 	Name        string  `json:"name" validate:"required"`
 	Notes       string  `json:"notes"`
-	Role        string  `json:"role" validate:"required,oneof=admin manager worker pattern_maker"` // 修改
-	WorkerGroup *string `json:"worker_group"`                                                      // 新增
+	Role        string  `json:"role" validate:"required"`
+	WorkerGroup *string `json:"worker_group"` // 新增
 	IsActive    bool    `json:"is_active"`
 }
 
 type UpdateWorkerRequest struct {
 	Name        string  `json:"name" validate:"required"`
 	Notes       string  `json:"notes"`
-	Role        string  `json:"role" validate:"required,oneof=admin manager worker pattern_maker"` // 修改
-	WorkerGroup *string `json:"worker_group"`                                                      // 新增
+	Role        string  `json:"role" validate:"required"`
+	WorkerGroup *string `json:"worker_group"` // 新增
 	IsActive    bool    `json:"is_active"`
 }
 
@@ -133,6 +642,18 @@ type CreateProductionOrderRequest struct {
 	OrderNumber string            `json:"order_number" validate:"required"`
 	StyleNumber string            `json:"style_number" validate:"required"`
 	Items       []CreateOrderItem `json:"items" validate:"required,min=1"`
+	CustomerID  *int              `json:"customer_id"`
+	DueDate     string            `json:"due_date"` // YYYY-MM-DD，可为空
+	Priority    string            `json:"priority" validate:"omitempty,oneof=low normal high urgent"`
+	Remarks     string            `json:"remarks"`
+}
+
+// UpdateOrderDetailsRequest 修改订单的客户、交期、优先级和备注 (整体替换，customer_id/due_date 为空表示清除)
+type UpdateOrderDetailsRequest struct {
+	CustomerID *int   `json:"customer_id"`
+	DueDate    string `json:"due_date"`
+	Priority   string `json:"priority" validate:"required,oneof=low normal high urgent"`
+	Remarks    string `json:"remarks"`
 }
 
 type CreateOrderItem struct {
@@ -141,14 +662,135 @@ type CreateOrderItem struct {
 	Quantity int    `json:"quantity" validate:"required,min=1"`
 }
 
+// UpdateProductionOrderRequest 给出订单修改后的完整明细，服务端按 颜色+尺码 与现有明细比对
+type UpdateProductionOrderRequest struct {
+	Items []CreateOrderItem `json:"items" validate:"required,min=1,dive"`
+}
+
+// OrderItemChange 描述一次订单修改中单个 颜色+尺码 行的变化
+type OrderItemChange struct {
+	Color       string `json:"color"`
+	Size        string `json:"size"`
+	Action      string `json:"action"` // added / updated / removed
+	OldQuantity int    `json:"old_quantity"`
+	NewQuantity int    `json:"new_quantity"`
+}
+
+type UpdateProductionOrderResponse struct {
+	Order    *ProductionOrder  `json:"order"`
+	Changes  []OrderItemChange `json:"changes"`
+	Warnings []string          `json:"warnings"`
+}
+
+// OrderTransitionRequest 手动变更订单状态 (确认、取消、交接) 的请求
+type OrderTransitionRequest struct {
+	Reason string `json:"reason" validate:"max=255"`
+}
+
+// 订单导入的表格布局
+const (
+	OrderImportLayoutAuto   = "auto"   // 根据表头自动识别
+	OrderImportLayoutMatrix = "matrix" // 颜色为行、尺码为列
+	OrderImportLayoutLong   = "long"   // 每行一条 颜色/尺码/数量
+)
+
+// ImportOrdersOptions 订单导入参数，StyleNumber 用于表格中没有款号列的情况
+type ImportOrdersOptions struct {
+	Layout      string `form:"layout"`
+	StyleNumber string `form:"style_number"`
+	CustomerID  *int   `form:"customer_id"` // 导入的订单都属于该客户
+	DueDate     string `form:"due_date"`    // 导入的订单共用的交期 YYYY-MM-DD
+	DryRun      bool   `form:"dry_run"`
+}
+
+// OrderImportError 指出表格中出错的位置，Row 为表格中的行号 (从1开始)
+type OrderImportError struct {
+	Row     int    `json:"row"`
+	Column  string `json:"column,omitempty"`
+	Message string `json:"message"`
+}
+
+// ImportedOrder 是表格中识别出的一张订单 (同一款号的所有行合并为一张订单)
+type ImportedOrder struct {
+	StyleNumber   string            `json:"style_number"`
+	NewStyle      bool              `json:"new_style"`
+	OrderID       int               `json:"order_id,omitempty"`
+	OrderNumber   string            `json:"order_number,omitempty"`
+	Items         []CreateOrderItem `json:"items"`
+	TotalQuantity int               `json:"total_quantity"`
+}
+
+type ImportOrdersResult struct {
+	DryRun bool               `json:"dry_run"`
+	Layout string             `json:"layout"`
+	Orders []ImportedOrder    `json:"orders"`
+	Errors []OrderImportError `json:"errors"`
+}
+
 // 生产计划 (新)
 type CreateProductionPlanRequest struct {
-	PlanName      string         `json:"plan_name" validate:"required"`
-	StyleID       int            `json:"style_id" validate:"required"`
-	LinkedOrderID *int           `json:"linked_order_id"`
-	Layouts       []CreateLayout `json:"layouts" validate:"required,min=1,dive"`
+	PlanName      string             `json:"plan_name" validate:"required"`
+	StyleID       int                `json:"style_id" validate:"required"`
+	LinkedOrderID *int               `json:"linked_order_id"` // 只关联一个订单时的简写，与 orders 同时给出时合并
+	Orders        []PlanOrderRequest `json:"orders"`          // 更新计划时不传则沿用已关联的订单，传空数组表示解除全部关联
+	Layouts       []CreateLayout     `json:"layouts" validate:"required,min=1,dive"`
+	// ValidationMode 为 strict 时计划与关联订单不一致则拒绝保存，为空或 lenient 时保存并记录警告
+	ValidationMode   string   `json:"validation_mode"`
+	OvercutTolerance *float64 `json:"overcut_tolerance"` // 允许超裁的百分比，为空时使用默认值
+	// LogReassignments 更新计划时删除已有生产记录的任务，需要指定把生产记录转移到哪个任务
+	LogReassignments []TaskLogReassignment `json:"log_reassignments"`
+}
+
+// TaskLogReassignment 把被删除任务的生产记录和已完成层数转移到更新后计划中的另一个任务。
+// 目标任务用 ToTaskID 指定，或用排版名称和颜色指定 (可以是本次新增的任务)
+type TaskLogReassignment struct {
+	FromTaskID int    `json:"from_task_id"`
+	ToTaskID   *int   `json:"to_task_id"`
+	LayoutName string `json:"layout_name"`
+	Color      string `json:"color"`
 }
+
+// PlanOrderRequest 指定计划关联的订单。Allocations 为空时按交期顺序自动分配计划件数
+type PlanOrderRequest struct {
+	OrderID     int               `json:"order_id" validate:"required"`
+	Allocations []CreateOrderItem `json:"allocations"`
+}
+
+// CutPlanRequest 是自动排版的请求：为订单尚未排入计划的数量生成裁剪方案
+type CutPlanRequest struct {
+	OrderID              int     `json:"order_id"`
+	PlanName             string  `json:"plan_name"`               // 为空时使用订单号
+	MaxPlies             int     `json:"max_plies"`               // 每床最多拉布层数
+	MaxGarmentsPerMarker int     `json:"max_garments_per_marker"` // 每个排版最多排的件数 (尺码配比之和)
+	MaxMarkers           int     `json:"max_markers"`             // 最多排版数
+	OvercutPercent       float64 `json:"overcut_percent"`         // 每个 颜色+尺码 允许超裁的百分比
+}
+
+// CutPlanLine 是自动排版方案中一个 颜色+尺码 的需求件数、计划件数和超裁件数
+type CutPlanLine struct {
+	Color         string `json:"color"`
+	Size          string `json:"size"`
+	Quantity      int    `json:"quantity"`
+	PlannedPieces int    `json:"planned_pieces"`
+	OvercutPieces int    `json:"overcut_pieces"`
+}
+
+// CutPlanProposal 是自动排版的结果。Plan 未保存，核对后可直接提交给创建计划接口；
+// 每个任务是一床，层数不超过每床最多层数
+type CutPlanProposal struct {
+	Plan          CreateProductionPlanRequest `json:"plan"`
+	MarkerCount   int                         `json:"marker_count"`
+	SpreadCount   int                         `json:"spread_count"`
+	Quantity      int                         `json:"quantity"`
+	PlannedPieces int                         `json:"planned_pieces"`
+	OvercutPieces int                         `json:"overcut_pieces"`
+	Lines         []CutPlanLine               `json:"lines"`
+}
+
+// CreateLayout 是计划中的一个排版。更新计划时 LayoutID 指定要修改的已有排版，
+// 为空时按排版名称匹配，匹配不到的为新增排版
 type CreateLayout struct {
+	LayoutID    *int                `json:"layout_id,omitempty"`
 	LayoutName  string              `json:"layout_name" validate:"required"`
 	Description string              `json:"description"`
 	Ratios      []CreateRatio       `json:"ratios" validate:"required,min=1,dive"`
@@ -158,7 +800,11 @@ type CreateRatio struct {
 	Size  string `json:"size" validate:"required"`
 	Ratio int    `json:"ratio" validate:"required,min=1"`
 }
+
+// CreateTaskForPlan 是排版中一个颜色的任务。更新计划时 TaskID 指定要修改的已有任务，
+// 为空时按颜色匹配排版中的已有任务，匹配不到的为新增任务
 type CreateTaskForPlan struct {
+	TaskID        *int   `json:"task_id,omitempty"`
 	Color         string `json:"color" validate:"required"`
 	PlannedLayers int    `json:"planned_layers" validate:"required,min=1"`
 }
@@ -187,6 +833,55 @@ type UpdateWorkerPasswordRequest struct {
 	Password string `json:"password" validate:"required,min=6"`
 }
 
+// CreateRoleRequest 创建自定义角色的请求
+type CreateRoleRequest struct {
+	Name        string   `json:"name" validate:"required,max=50"`
+	DisplayName string   `json:"display_name" validate:"required,max=100"`
+	Description string   `json:"description"`
+	MaxPerGroup *int     `json:"max_per_group"`
+	Permissions []string `json:"permissions"`
+}
+
+// UpdateRoleRequest 修改角色的请求 (角色标识 name 创建后不可修改)
+type UpdateRoleRequest struct {
+	DisplayName string   `json:"display_name" validate:"required,max=100"`
+	Description string   `json:"description"`
+	MaxPerGroup *int     `json:"max_per_group"`
+	Permissions []string `json:"permissions"`
+}
+
+// UpdateOrderNumberSettingsRequest 修改订单号规则的请求
+type UpdateOrderNumberSettingsRequest struct {
+	Template string `json:"template" validate:"required"`
+}
+
+// CreateAPIKeyRequest 创建 API 密钥的请求
+type CreateAPIKeyRequest struct {
+	Name      string     `json:"name" validate:"required,max=100"`
+	Scopes    []string   `json:"scopes" validate:"required,min=1"`
+	ExpiresAt *time.Time `json:"expires_at"`
+}
+
+// CreateAPIKeyResponse 创建 API 密钥的响应，Key 为明文密钥，只返回这一次
+type CreateAPIKeyResponse struct {
+	Key    string  `json:"key"`
+	APIKey *APIKey `json:"api_key"`
+}
+
+type UpdateWorkerPinRequest struct {
+	Pin string `json:"pin" validate:"required,numeric,min=4,max=8"`
+}
+
+type UpdateWorkerBadgeRequest struct {
+	BadgeCode string `json:"badge_code" validate:"required,min=4,max=64"`
+}
+
+// ChangePasswordRequest 用于员工修改自己的密码
+type ChangePasswordRequest struct {
+	OldPassword string `json:"old_password" validate:"required"`
+	NewPassword string `json:"new_password" validate:"required,min=6"`
+}
+
 // WorkerTaskGroup 是为工人工作台定制的视图模型
 type WorkerTaskGroup struct {
 	PlanID         int              `json:"plan_id" db:"plan_id"`
@@ -0,0 +1,91 @@
+package repositories
+
+import (
+	"database/sql"
+	"fmt"
+
+	"cutrix-backend/internal/models"
+
+	"github.com/jmoiron/sqlx"
+)
+
+type APIKeyRepository interface {
+	Create(key *models.APIKey, keyHash string) error
+	GetAll() ([]*models.APIKey, error)
+	Authenticate(keyHash string) (*models.APIKey, error)
+	Revoke(id int) error
+}
+
+type apiKeyRepository struct {
+	db *sqlx.DB
+}
+
+func NewAPIKeyRepository(db *sqlx.DB) APIKeyRepository {
+	return &apiKeyRepository{db: db}
+}
+
+const apiKeyQueryFields = `
+    api_key_id,
+    name,
+    key_prefix,
+    scopes,
+    created_by,
+    created_at,
+    expires_at,
+    last_used_at,
+    revoked_at
+`
+
+func (r *apiKeyRepository) Create(key *models.APIKey, keyHash string) error {
+	query := `INSERT INTO API_Keys (name, key_prefix, key_hash, scopes, created_by, expires_at)
+	          VALUES ($1, $2, $3, $4, $5, $6) RETURNING api_key_id, created_at`
+	err := r.db.QueryRow(query, key.Name, key.KeyPrefix, keyHash, key.Scopes, key.CreatedBy, key.ExpiresAt).
+		Scan(&key.APIKeyID, &key.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to create api key: %w", err)
+	}
+	return nil
+}
+
+func (r *apiKeyRepository) GetAll() ([]*models.APIKey, error) {
+	var keys []*models.APIKey
+	query := fmt.Sprintf("SELECT %s FROM API_Keys ORDER BY created_at DESC", apiKeyQueryFields)
+	if err := r.db.Select(&keys, query); err != nil {
+		return nil, fmt.Errorf("failed to get api keys: %w", err)
+	}
+	return keys, nil
+}
+
+// Authenticate 查找未吊销且未过期的密钥并同时更新其最后使用时间。
+// 密钥无效时返回 nil, nil。
+func (r *apiKeyRepository) Authenticate(keyHash string) (*models.APIKey, error) {
+	var key models.APIKey
+	query := fmt.Sprintf(`UPDATE API_Keys SET last_used_at = CURRENT_TIMESTAMP
+	          WHERE key_hash = $1 AND revoked_at IS NULL
+	            AND (expires_at IS NULL OR expires_at > CURRENT_TIMESTAMP)
+	          RETURNING %s`, apiKeyQueryFields)
+	err := r.db.Get(&key, query, keyHash)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to authenticate api key: %w", err)
+	}
+	return &key, nil
+}
+
+func (r *apiKeyRepository) Revoke(id int) error {
+	query := `UPDATE API_Keys SET revoked_at = CURRENT_TIMESTAMP WHERE api_key_id = $1 AND revoked_at IS NULL`
+	result, err := r.db.Exec(query, id)
+	if err != nil {
+		return fmt.Errorf("failed to revoke api key: %w", err)
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+	if rowsAffected == 0 {
+		return fmt.Errorf("api key not found")
+	}
+	return nil
+}
@@ -0,0 +1,92 @@
+package repositories
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"cutrix-backend/internal/models"
+
+	"github.com/jmoiron/sqlx"
+)
+
+type AuditRepository interface {
+	Create(tx *sqlx.Tx, entry *models.AuditLog) error
+	List(filter *models.AuditLogFilter) ([]models.AuditLog, int, error)
+}
+
+type auditRepository struct {
+	db *sqlx.DB
+}
+
+func NewAuditRepository(db *sqlx.DB) AuditRepository {
+	return &auditRepository{db: db}
+}
+
+// Create 在业务操作的事务中写入审计记录，与被记录的修改一起提交或回滚
+func (r *auditRepository) Create(tx *sqlx.Tx, entry *models.AuditLog) error {
+	query := `INSERT INTO Audit_Logs (actor_worker_id, actor_api_key_id, actor_role, client_ip, action, entity_type, entity_id, before_data, after_data)
+	          VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9) RETURNING audit_id, created_at`
+	err := tx.QueryRow(query, entry.ActorWorkerID, entry.ActorAPIKeyID, entry.ActorRole, entry.ClientIP,
+		entry.Action, entry.EntityType, entry.EntityID, nullableJSON(entry.Before), nullableJSON(entry.After)).
+		Scan(&entry.AuditID, &entry.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to create audit log: %w", err)
+	}
+	return nil
+}
+
+// List 按过滤条件分页查询审计日志 (按时间倒序)，同时返回满足条件的总数
+func (r *auditRepository) List(filter *models.AuditLogFilter) ([]models.AuditLog, int, error) {
+	where := " WHERE 1=1"
+	args := []interface{}{}
+
+	if filter.EntityType != "" {
+		args = append(args, filter.EntityType)
+		where += fmt.Sprintf(" AND a.entity_type = $%d", len(args))
+	}
+	if filter.EntityID != nil {
+		args = append(args, *filter.EntityID)
+		where += fmt.Sprintf(" AND a.entity_id = $%d", len(args))
+	}
+	if filter.ActorWorkerID != nil {
+		args = append(args, *filter.ActorWorkerID)
+		where += fmt.Sprintf(" AND a.actor_worker_id = $%d", len(args))
+	}
+	if filter.From != nil {
+		args = append(args, *filter.From)
+		where += fmt.Sprintf(" AND a.created_at >= $%d", len(args))
+	}
+	if filter.To != nil {
+		args = append(args, *filter.To)
+		where += fmt.Sprintf(" AND a.created_at < $%d", len(args))
+	}
+
+	var total int
+	if err := r.db.Get(&total, "SELECT COUNT(*) FROM Audit_Logs a"+where, args...); err != nil {
+		return nil, 0, fmt.Errorf("failed to count audit logs: %w", err)
+	}
+
+	query := `SELECT a.audit_id, a.actor_worker_id, COALESCE(w.name, '') as actor_name, a.actor_api_key_id,
+	                 COALESCE(a.actor_role, '') as actor_role, COALESCE(a.client_ip, '') as client_ip,
+	                 a.action, a.entity_type, a.entity_id,
+	                 COALESCE(a.before_data, 'null'::jsonb) as before_data,
+	                 COALESCE(a.after_data, 'null'::jsonb) as after_data, a.created_at
+	          FROM Audit_Logs a
+	          LEFT JOIN Workers w ON a.actor_worker_id = w.worker_id` + where
+	args = append(args, filter.PageSize, (filter.Page-1)*filter.PageSize)
+	query += fmt.Sprintf(" ORDER BY a.created_at DESC, a.audit_id DESC LIMIT $%d OFFSET $%d", len(args)-1, len(args))
+
+	entries := []models.AuditLog{}
+	if err := r.db.Select(&entries, query, args...); err != nil {
+		return nil, 0, fmt.Errorf("failed to list audit logs: %w", err)
+	}
+	return entries, total, nil
+}
+
+// nullableJSON 将空快照写为 NULL，避免向 JSONB 列写入空字符串
+func nullableJSON(data json.RawMessage) interface{} {
+	if len(data) == 0 {
+		return nil
+	}
+	return string(data)
+}
@@ -0,0 +1,106 @@
+package repositories
+
+import (
+	"database/sql"
+	"fmt"
+
+	"cutrix-backend/internal/models"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+)
+
+type CustomerRepository interface {
+	GetAll(search string) ([]models.Customer, error)
+	GetByID(id int) (*models.Customer, error)
+	Create(tx *sqlx.Tx, customer *models.Customer) error
+	Update(tx *sqlx.Tx, customer *models.Customer) error
+	Delete(tx *sqlx.Tx, id int) error
+}
+
+type customerRepository struct {
+	db *sqlx.DB
+}
+
+func NewCustomerRepository(db *sqlx.DB) CustomerRepository {
+	return &customerRepository{db: db}
+}
+
+const customerColumns = `customer_id, customer_code, name, contact_name, phone, remarks, created_at, updated_at`
+
+// GetAll 按编码排序返回客户，search 不为空时按编码或名称模糊匹配
+func (r *customerRepository) GetAll(search string) ([]models.Customer, error) {
+	customers := []models.Customer{}
+	query := `SELECT ` + customerColumns + ` FROM Customers`
+	args := []interface{}{}
+	if search != "" {
+		query += ` WHERE customer_code ILIKE $1 OR name ILIKE $1`
+		args = append(args, "%"+search+"%")
+	}
+	query += ` ORDER BY customer_code`
+	if err := r.db.Select(&customers, query, args...); err != nil {
+		return nil, fmt.Errorf("failed to get customers: %w", err)
+	}
+	return customers, nil
+}
+
+func (r *customerRepository) GetByID(id int) (*models.Customer, error) {
+	var customer models.Customer
+	err := r.db.Get(&customer, `SELECT `+customerColumns+` FROM Customers WHERE customer_id = $1`, id)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("customer not found")
+		}
+		return nil, fmt.Errorf("failed to get customer: %w", err)
+	}
+	return &customer, nil
+}
+
+func (r *customerRepository) Create(tx *sqlx.Tx, customer *models.Customer) error {
+	query := `INSERT INTO Customers (customer_code, name, contact_name, phone, remarks) VALUES ($1, $2, $3, $4, $5)
+	          RETURNING ` + customerColumns
+	err := tx.QueryRowx(query, customer.CustomerCode, customer.Name, customer.ContactName, customer.Phone, customer.Remarks).
+		StructScan(customer)
+	if err != nil {
+		if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == "23505" {
+			return fmt.Errorf("customer already exists")
+		}
+		return fmt.Errorf("failed to create customer: %w", err)
+	}
+	return nil
+}
+
+func (r *customerRepository) Update(tx *sqlx.Tx, customer *models.Customer) error {
+	query := `UPDATE Customers SET customer_code = $1, name = $2, contact_name = $3, phone = $4, remarks = $5, updated_at = CURRENT_TIMESTAMP
+	          WHERE customer_id = $6 RETURNING ` + customerColumns
+	err := tx.QueryRowx(query, customer.CustomerCode, customer.Name, customer.ContactName, customer.Phone, customer.Remarks, customer.CustomerID).
+		StructScan(customer)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return fmt.Errorf("customer not found")
+		}
+		if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == "23505" {
+			return fmt.Errorf("customer already exists")
+		}
+		return fmt.Errorf("failed to update customer: %w", err)
+	}
+	return nil
+}
+
+func (r *customerRepository) Delete(tx *sqlx.Tx, id int) error {
+	result, err := tx.Exec(`DELETE FROM Customers WHERE customer_id = $1`, id)
+	if err != nil {
+		if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == "23503" {
+			return fmt.Errorf("customer is still referenced")
+		}
+		return fmt.Errorf("failed to delete customer: %w", err)
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+	if rowsAffected == 0 {
+		return fmt.Errorf("customer not found")
+	}
+	return nil
+}
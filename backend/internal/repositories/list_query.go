@@ -0,0 +1,26 @@
+package repositories
+
+import (
+	"cutrix-backend/internal/models"
+	"fmt"
+)
+
+// listClauses 根据列表查询参数生成 ORDER BY 和 LIMIT/OFFSET 子句，分页参数追加到 args。
+// sortColumns 为排序字段到 SQL 表达式的映射，未知字段按 defaultSort 排序；
+// tieBreaker 必须是唯一列，保证翻页时顺序稳定。空值总是排在最后
+func listClauses(q models.ListQuery, sortColumns map[string]string, defaultSort, tieBreaker string, args []interface{}) (string, []interface{}) {
+	sortColumn, ok := sortColumns[q.SortBy]
+	if !ok {
+		sortColumn = sortColumns[defaultSort]
+	}
+	direction := "ASC"
+	if q.SortDesc {
+		direction = "DESC"
+	}
+	clauses := fmt.Sprintf(" ORDER BY %s %s NULLS LAST, %s %s", sortColumn, direction, tieBreaker, direction)
+	if q.PageSize > 0 {
+		args = append(args, q.PageSize, q.Offset())
+		clauses += fmt.Sprintf(" LIMIT $%d OFFSET $%d", len(args)-1, len(args))
+	}
+	return clauses, args
+}
@@ -3,6 +3,7 @@ package repositories
 import (
 	"database/sql"
 	"fmt"
+	"time"
 
 	"cutrix-backend/internal/models"
 
@@ -10,7 +11,7 @@ import (
 )
 
 type LogRepository interface {
-	Create(log *models.ProductionLog) error
+	Create(tx *sqlx.Tx, log *models.ProductionLog) error
 	GetByID(id int64) (*models.ProductionLog, error)
 	GetByTaskID(taskID int) ([]*models.ProductionLog, error)
 	GetByWorkerID(workerID int) ([]*models.ProductionLog, error)
@@ -19,6 +20,7 @@ type LogRepository interface {
 	GetAll() ([]*models.ProductionLog, error)
 	GetSpreadingLogs() ([]*models.ProductionLog, error)
 	GetUnprocessedSpreadingLogs() ([]*models.ProductionLog, error)
+	StreamForExport(from, to *time.Time, fn func(row *models.LogExportRow) error) error
 }
 
 type logRepository struct {
@@ -29,11 +31,12 @@ func NewLogRepository(db *sqlx.DB) LogRepository {
 	return &logRepository{db: db}
 }
 
-func (r *logRepository) Create(log *models.ProductionLog) error {
-	query := `INSERT INTO Production_Logs (task_id, parent_log_id, worker_id, process_name, layers_completed, log_time) 
-	          VALUES ($1, $2, $3, $4, $5, $6) RETURNING log_id`
+// Create 在事务中保存生产记录，调用方可在同一事务中同步订单状态
+func (r *logRepository) Create(tx *sqlx.Tx, log *models.ProductionLog) error {
+	query := `INSERT INTO Production_Logs (task_id, parent_log_id, worker_id, process_name, layers_completed, log_time, api_key_id) 
+	          VALUES ($1, $2, $3, $4, $5, $6, $7) RETURNING log_id`
 
-	err := r.db.QueryRow(query, log.TaskID, log.ParentLogID, log.WorkerID, log.ProcessName, log.LayersCompleted, log.LogTime).Scan(&log.LogID)
+	err := tx.QueryRow(query, log.TaskID, log.ParentLogID, log.WorkerID, log.ProcessName, log.LayersCompleted, log.LogTime, log.APIKeyID).Scan(&log.LogID)
 	if err != nil {
 		return fmt.Errorf("failed to create production log: %w", err)
 	}
@@ -43,7 +46,7 @@ func (r *logRepository) Create(log *models.ProductionLog) error {
 
 func (r *logRepository) GetByID(id int64) (*models.ProductionLog, error) {
 	var log models.ProductionLog
-	query := `SELECT log_id, task_id, parent_log_id, worker_id, process_name, layers_completed, log_time 
+	query := `SELECT log_id, task_id, parent_log_id, worker_id, process_name, layers_completed, log_time, api_key_id 
 	          FROM Production_Logs WHERE log_id = $1`
 
 	err := r.db.Get(&log, query, id)
@@ -59,7 +62,7 @@ func (r *logRepository) GetByID(id int64) (*models.ProductionLog, error) {
 
 func (r *logRepository) GetByTaskID(taskID int) ([]*models.ProductionLog, error) {
 	var logs []*models.ProductionLog
-	query := `SELECT log_id, task_id, parent_log_id, worker_id, process_name, layers_completed, log_time 
+	query := `SELECT log_id, task_id, parent_log_id, worker_id, process_name, layers_completed, log_time, api_key_id 
 	          FROM Production_Logs WHERE task_id = $1 ORDER BY log_time`
 
 	err := r.db.Select(&logs, query, taskID)
@@ -72,7 +75,7 @@ func (r *logRepository) GetByTaskID(taskID int) ([]*models.ProductionLog, error)
 
 func (r *logRepository) GetByWorkerID(workerID int) ([]*models.ProductionLog, error) {
 	var logs []*models.ProductionLog
-	query := `SELECT log_id, task_id, parent_log_id, worker_id, process_name, layers_completed, log_time 
+	query := `SELECT log_id, task_id, parent_log_id, worker_id, process_name, layers_completed, log_time, api_key_id 
 	          FROM Production_Logs WHERE worker_id = $1 ORDER BY log_time`
 
 	err := r.db.Select(&logs, query, workerID)
@@ -85,7 +88,7 @@ func (r *logRepository) GetByWorkerID(workerID int) ([]*models.ProductionLog, er
 
 func (r *logRepository) GetByProcessName(processName string) ([]*models.ProductionLog, error) {
 	var logs []*models.ProductionLog
-	query := `SELECT log_id, task_id, parent_log_id, worker_id, process_name, layers_completed, log_time 
+	query := `SELECT log_id, task_id, parent_log_id, worker_id, process_name, layers_completed, log_time, api_key_id 
 	          FROM Production_Logs WHERE process_name = $1 ORDER BY log_time`
 
 	err := r.db.Select(&logs, query, processName)
@@ -98,7 +101,7 @@ func (r *logRepository) GetByProcessName(processName string) ([]*models.Producti
 
 func (r *logRepository) GetByParentLogID(parentLogID int64) ([]*models.ProductionLog, error) {
 	var logs []*models.ProductionLog
-	query := `SELECT log_id, task_id, parent_log_id, worker_id, process_name, layers_completed, log_time 
+	query := `SELECT log_id, task_id, parent_log_id, worker_id, process_name, layers_completed, log_time, api_key_id 
 	          FROM Production_Logs WHERE parent_log_id = $1 ORDER BY log_time`
 
 	err := r.db.Select(&logs, query, parentLogID)
@@ -111,7 +114,7 @@ func (r *logRepository) GetByParentLogID(parentLogID int64) ([]*models.Productio
 
 func (r *logRepository) GetAll() ([]*models.ProductionLog, error) {
 	var logs []*models.ProductionLog
-	query := `SELECT log_id, task_id, parent_log_id, worker_id, process_name, layers_completed, log_time 
+	query := `SELECT log_id, task_id, parent_log_id, worker_id, process_name, layers_completed, log_time, api_key_id 
 	          FROM Production_Logs ORDER BY log_time`
 
 	err := r.db.Select(&logs, query)
@@ -124,7 +127,7 @@ func (r *logRepository) GetAll() ([]*models.ProductionLog, error) {
 
 func (r *logRepository) GetSpreadingLogs() ([]*models.ProductionLog, error) {
 	var logs []*models.ProductionLog
-	query := `SELECT log_id, task_id, parent_log_id, worker_id, process_name, layers_completed, log_time 
+	query := `SELECT log_id, task_id, parent_log_id, worker_id, process_name, layers_completed, log_time, api_key_id 
 	          FROM Production_Logs WHERE process_name = '拉布' ORDER BY log_time`
 
 	err := r.db.Select(&logs, query)
@@ -137,7 +140,7 @@ func (r *logRepository) GetSpreadingLogs() ([]*models.ProductionLog, error) {
 
 func (r *logRepository) GetUnprocessedSpreadingLogs() ([]*models.ProductionLog, error) {
 	var logs []*models.ProductionLog
-	query := `SELECT l.log_id, l.task_id, l.parent_log_id, l.worker_id, l.process_name, l.layers_completed, l.log_time
+	query := `SELECT l.log_id, l.task_id, l.parent_log_id, l.worker_id, l.process_name, l.layers_completed, l.log_time, l.api_key_id
 	          FROM Production_Logs l
 	          WHERE l.process_name = '拉布' 
 	          AND NOT EXISTS (
@@ -153,3 +156,45 @@ func (r *logRepository) GetUnprocessedSpreadingLogs() ([]*models.ProductionLog,
 
 	return logs, nil
 }
+
+// StreamForExport 按时间顺序逐行读取 [from, to) 范围内的生产记录及其关联的员工、任务和计划
+func (r *logRepository) StreamForExport(from, to *time.Time, fn func(row *models.LogExportRow) error) error {
+	query := `
+        SELECT pl.log_id, pl.log_time, w.name AS worker_name, w.worker_group, pl.process_name, pl.layers_completed,
+               pl.task_id, s.style_number, pp.plan_name, pt.layout_name, pt.color, pl.parent_log_id, ak.name AS api_key_name
+        FROM Production_Logs pl
+        JOIN Workers w ON w.worker_id = pl.worker_id
+        LEFT JOIN Production_Tasks pt ON pt.task_id = pl.task_id
+        LEFT JOIN Styles s ON s.style_id = pt.style_id
+        LEFT JOIN Cutting_Layouts cl ON cl.layout_id = pt.layout_id
+        LEFT JOIN Production_Plans pp ON pp.plan_id = cl.plan_id
+        LEFT JOIN API_Keys ak ON ak.api_key_id = pl.api_key_id
+        WHERE 1=1`
+	args := []interface{}{}
+	if from != nil {
+		args = append(args, *from)
+		query += fmt.Sprintf(" AND pl.log_time >= $%d", len(args))
+	}
+	if to != nil {
+		args = append(args, *to)
+		query += fmt.Sprintf(" AND pl.log_time < $%d", len(args))
+	}
+	query += " ORDER BY pl.log_time, pl.log_id"
+
+	rows, err := r.db.Queryx(query, args...)
+	if err != nil {
+		return fmt.Errorf("failed to query production logs for export: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var row models.LogExportRow
+		if err := rows.StructScan(&row); err != nil {
+			return fmt.Errorf("failed to scan production log for export: %w", err)
+		}
+		if err := fn(&row); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
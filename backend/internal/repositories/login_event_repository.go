@@ -0,0 +1,86 @@
+package repositories
+
+import (
+	"fmt"
+	"time"
+
+	"cutrix-backend/internal/models"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+)
+
+type LoginEventRepository interface {
+	Create(event *models.LoginEvent) error
+	CountRecentFailuresByIP(clientIP string, window time.Duration, excludeReasons []string) (int, error)
+	List(filter *models.LoginEventFilter) ([]models.LoginEvent, error)
+}
+
+type loginEventRepository struct {
+	db *sqlx.DB
+}
+
+func NewLoginEventRepository(db *sqlx.DB) LoginEventRepository {
+	return &loginEventRepository{db: db}
+}
+
+func (r *loginEventRepository) Create(event *models.LoginEvent) error {
+	query := `INSERT INTO Login_Events (worker_id, worker_name, client_ip, user_agent, method, success, reason)
+	          VALUES ($1, $2, $3, $4, $5, $6, $7) RETURNING event_id, created_at`
+	err := r.db.QueryRow(query, event.WorkerID, event.WorkerName, event.ClientIP, event.UserAgent, event.Method, event.Success, event.Reason).
+		Scan(&event.EventID, &event.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to create login event: %w", err)
+	}
+	return nil
+}
+
+// CountRecentFailuresByIP 统计指定IP在时间窗口内的登录失败次数，不计失败原因在 excludeReasons 中的记录
+func (r *loginEventRepository) CountRecentFailuresByIP(clientIP string, window time.Duration, excludeReasons []string) (int, error) {
+	var count int
+	query := `SELECT COUNT(*) FROM Login_Events
+	          WHERE client_ip = $1 AND success = false
+	          AND created_at > CURRENT_TIMESTAMP - make_interval(secs => $2)
+	          AND NOT (COALESCE(reason, '') = ANY($3))`
+	if err := r.db.Get(&count, query, clientIP, window.Seconds(), pq.Array(excludeReasons)); err != nil {
+		return 0, fmt.Errorf("failed to count login failures: %w", err)
+	}
+	return count, nil
+}
+
+func (r *loginEventRepository) List(filter *models.LoginEventFilter) ([]models.LoginEvent, error) {
+	query := `SELECT event_id, worker_id, worker_name, client_ip, COALESCE(user_agent, '') as user_agent,
+	                 method, success, COALESCE(reason, '') as reason, created_at
+	          FROM Login_Events WHERE 1=1`
+	args := []interface{}{}
+
+	if filter.WorkerName != "" {
+		args = append(args, filter.WorkerName)
+		query += fmt.Sprintf(" AND worker_name = $%d", len(args))
+	}
+	if filter.ClientIP != "" {
+		args = append(args, filter.ClientIP)
+		query += fmt.Sprintf(" AND client_ip = $%d", len(args))
+	}
+	if filter.Success != nil {
+		args = append(args, *filter.Success)
+		query += fmt.Sprintf(" AND success = $%d", len(args))
+	}
+	if filter.From != nil {
+		args = append(args, *filter.From)
+		query += fmt.Sprintf(" AND created_at >= $%d", len(args))
+	}
+	if filter.To != nil {
+		args = append(args, *filter.To)
+		query += fmt.Sprintf(" AND created_at < $%d", len(args))
+	}
+
+	args = append(args, filter.Limit)
+	query += fmt.Sprintf(" ORDER BY created_at DESC LIMIT $%d", len(args))
+
+	events := []models.LoginEvent{}
+	if err := r.db.Select(&events, query, args...); err != nil {
+		return nil, fmt.Errorf("failed to list login events: %w", err)
+	}
+	return events, nil
+}
@@ -2,20 +2,41 @@ package repositories
 
 import (
 	"cutrix-backend/internal/models"
+	"cutrix-backend/pkg/sizes"
 	"database/sql"
 	"fmt"
-	"time"
 
 	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
 )
 
 type ProductionOrderRepository interface {
-	CreateOrder(tx *sqlx.Tx, orderNumber string, styleID int, items []models.CreateOrderItem) (*models.ProductionOrder, error)
+	CreateOrder(tx *sqlx.Tx, order *models.ProductionOrder, items []models.CreateOrderItem) error
 	GetOrderWithItems(orderID int) (*models.ProductionOrder, error)
-	GetAllOrders(styleNumberQuery string) ([]models.ProductionOrder, error)
+	GetOrderWithItemsInTx(tx *sqlx.Tx, orderID int) (*models.ProductionOrder, error)
+	GetAllOrders(filter *models.ProductionOrderFilter) ([]models.ProductionOrder, int, error)
+	UpdateOrderDetails(tx *sqlx.Tx, order *models.ProductionOrder) error
+	GetOpenOrdersDueWithin(days int) ([]models.AtRiskOrder, error)
 	GetAllUnplannedOrders() ([]models.ProductionOrder, error) // <-- 新增
-	CountOrdersByStyleAndDate(styleID int, date string) (int, error)
+	NextOrderSequence(tx *sqlx.Tx, sequenceKey string) (int, error)
+	OrderNumberExists(tx *sqlx.Tx, orderNumber string) (bool, error)
 	DeleteOrder(tx *sqlx.Tx, orderID int) error
+	// 订单明细修改
+	GetOrderItemsForUpdate(tx *sqlx.Tx, orderID int) ([]models.OrderItem, error)
+	AddOrderItem(tx *sqlx.Tx, orderID int, item models.CreateOrderItem) error
+	UpdateOrderItemQuantity(tx *sqlx.Tx, itemID int, quantity int) error
+	DeleteOrderItem(tx *sqlx.Tx, itemID int) error
+	// 订单状态
+	LockOrderStatus(tx *sqlx.Tx, orderID int) (string, error)
+	UpdateOrderStatus(tx *sqlx.Tx, orderID int, status string) error
+	CreateStatusChange(tx *sqlx.Tx, change *models.OrderStatusChange) error
+	GetStatusHistory(orderID int) ([]models.OrderStatusChange, error)
+	FindOrderIDsByTask(tx *sqlx.Tx, taskID int) ([]int, error)
+	CountLinkedPlans(tx *sqlx.Tx, orderID int) (int, error)
+	GetTaskProgress(tx *sqlx.Tx, orderID int) (*models.OrderTaskProgress, error)
+	// 导出
+	GetExportSizes(styleNumberQuery string) ([]string, error)
+	StreamOrderItems(styleNumberQuery string, fn func(row *models.OrderExportRow) error) error
 }
 
 type productionOrderRepository struct {
@@ -26,15 +47,34 @@ func NewProductionOrderRepository(db *sqlx.DB) ProductionOrderRepository {
 	return &productionOrderRepository{db: db}
 }
 
-// GetAllUnplannedOrders retrieves all orders that do not have a linked production plan yet.
+// orderColumns 是查询订单时的列，需要 LEFT JOIN Customers c 以带出客户名称
+const orderColumns = `po.order_id, po.order_number, po.style_id, po.customer_id, c.name AS customer_name, po.due_date,
+        po.priority, po.remarks, po.status, po.status_updated_at, po.created_at`
+
+// GetAllUnplannedOrders retrieves all open orders that still have pieces not allocated to any production plan.
+// 部分件数已分配到计划的订单也会列出，unplanned_pieces 为尚未分配的件数。
 func (r *productionOrderRepository) GetAllUnplannedOrders() ([]models.ProductionOrder, error) {
 	var orders []models.ProductionOrder
 	query := `
-        SELECT po.order_id, po.order_number, po.style_id, po.created_at
-        FROM Production_Orders po
-        WHERE NOT EXISTS (
-            SELECT 1 FROM Production_Plans pp WHERE pp.linked_order_id = po.order_id
+        WITH unplanned AS (
+            SELECT oi.order_id,
+                   SUM(GREATEST(oi.quantity - COALESCE(a.allocated, 0), 0))::int AS unplanned_pieces
+            FROM Order_Items oi
+            LEFT JOIN (
+                SELECT a.order_id, a.color, a.size, SUM(a.quantity) AS allocated
+                FROM Plan_Order_Allocations a
+                JOIN Production_Plans pp ON pp.plan_id = a.plan_id AND pp.deleted_at IS NULL
+                GROUP BY a.order_id, a.color, a.size
+            ) a ON a.order_id = oi.order_id AND a.color = oi.color AND a.size = oi.size
+            GROUP BY oi.order_id
         )
+        SELECT ` + orderColumns + `, u.unplanned_pieces
+        FROM Production_Orders po
+        JOIN unplanned u ON u.order_id = po.order_id
+        LEFT JOIN Customers c ON po.customer_id = c.customer_id
+        WHERE po.deleted_at IS NULL
+          AND po.status IN ('draft', 'confirmed', 'planned', 'cutting')
+          AND u.unplanned_pieces > 0
         ORDER BY po.created_at DESC`
 	err := r.db.Select(&orders, query)
 	if err != nil {
@@ -44,35 +84,50 @@ func (r *productionOrderRepository) GetAllUnplannedOrders() ([]models.Production
 }
 
 // ... (其他函数保持不变)
-func (r *productionOrderRepository) CreateOrder(tx *sqlx.Tx, orderNumber string, styleID int, items []models.CreateOrderItem) (*models.ProductionOrder, error) {
-	orderQuery := `INSERT INTO Production_Orders (order_number, style_id) VALUES ($1, $2) RETURNING order_id, order_number, style_id, created_at`
-	var order models.ProductionOrder
-	err := tx.QueryRowx(orderQuery, orderNumber, styleID).StructScan(&order)
+// CreateOrder 插入订单及其明细，order 中的订单号、款号、客户、交期、优先级和备注会被写入，其余字段由数据库生成
+func (r *productionOrderRepository) CreateOrder(tx *sqlx.Tx, order *models.ProductionOrder, items []models.CreateOrderItem) error {
+	orderQuery := `INSERT INTO Production_Orders (order_number, style_id, customer_id, due_date, priority, remarks)
+	               VALUES ($1, $2, $3, $4, $5, $6) RETURNING order_id, status, status_updated_at, created_at`
+	err := tx.QueryRowx(orderQuery, order.OrderNumber, order.StyleID, order.CustomerID, order.DueDate, order.Priority, order.Remarks).
+		Scan(&order.OrderID, &order.Status, &order.StatusUpdatedAt, &order.CreatedAt)
 	if err != nil {
-		return nil, fmt.Errorf("failed to insert production order: %w", err)
+		if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == "23503" {
+			return fmt.Errorf("customer not found")
+		}
+		return fmt.Errorf("failed to insert production order: %w", err)
 	}
 
 	itemQuery := `INSERT INTO Order_Items (order_id, color, size, quantity) VALUES ($1, $2, $3, $4)`
 	stmt, err := tx.Preparex(itemQuery)
 	if err != nil {
-		return nil, fmt.Errorf("failed to prepare order item statement: %w", err)
+		return fmt.Errorf("failed to prepare order item statement: %w", err)
 	}
 	defer stmt.Close()
 
 	for _, item := range items {
 		_, err := stmt.Exec(order.OrderID, item.Color, item.Size, item.Quantity)
 		if err != nil {
-			return nil, fmt.Errorf("failed to insert order item: %w", err)
+			return fmt.Errorf("failed to insert order item: %w", err)
 		}
 	}
 
-	return &order, nil
+	return nil
 }
 
 func (r *productionOrderRepository) GetOrderWithItems(orderID int) (*models.ProductionOrder, error) {
+	return queryOrderWithItems(r.db, orderID)
+}
+
+// GetOrderWithItemsInTx 在事务中读取订单及明细，可以看到本事务中尚未提交的修改
+func (r *productionOrderRepository) GetOrderWithItemsInTx(tx *sqlx.Tx, orderID int) (*models.ProductionOrder, error) {
+	return queryOrderWithItems(tx, orderID)
+}
+
+func queryOrderWithItems(db sqlx.Queryer, orderID int) (*models.ProductionOrder, error) {
 	var order models.ProductionOrder
-	orderQuery := `SELECT order_id, order_number, style_id, created_at FROM Production_Orders WHERE order_id = $1`
-	err := r.db.Get(&order, orderQuery, orderID)
+	orderQuery := `SELECT ` + orderColumns + ` FROM Production_Orders po
+	               LEFT JOIN Customers c ON po.customer_id = c.customer_id WHERE po.order_id = $1 AND po.deleted_at IS NULL`
+	err := sqlx.Get(db, &order, orderQuery, orderID)
 	if err != nil {
 		if err == sql.ErrNoRows {
 			return nil, fmt.Errorf("order not found")
@@ -81,60 +136,208 @@ func (r *productionOrderRepository) GetOrderWithItems(orderID int) (*models.Prod
 	}
 
 	var items []models.OrderItem
-	itemsQuery := `SELECT item_id, order_id, color, size, quantity FROM Order_Items WHERE order_id = $1`
-	err = r.db.Select(&items, itemsQuery, orderID)
+	itemsQuery := `SELECT item_id, order_id, color, size, quantity FROM Order_Items WHERE order_id = $1 ORDER BY item_id`
+	err = sqlx.Select(db, &items, itemsQuery, orderID)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get order items: %w", err)
 	}
 
+	// 明细按颜色分组，组内按款号尺码组的顺序排列
+	run, err := queryStyleRun(db, `SELECT size_scale, sizes FROM Styles WHERE style_id = $1`, order.StyleID)
+	if err != nil {
+		return nil, err
+	}
+	sizes.SortByColor(run, items, func(item models.OrderItem) (string, string) { return item.Color, item.Size })
+
 	order.Items = items
 	return &order, nil
 }
 
-func (r *productionOrderRepository) GetAllOrders(styleNumberQuery string) ([]models.ProductionOrder, error) {
-	var orders []models.ProductionOrder
+// orderSortColumns 是订单列表允许的排序字段，priority 按紧急程度排序，空交期总是排在最后
+var orderSortColumns = map[string]string{
+	"created_at":   "po.created_at",
+	"due_date":     "po.due_date",
+	"order_number": "po.order_number",
+	"priority":     "CASE po.priority WHEN 'urgent' THEN 4 WHEN 'high' THEN 3 WHEN 'normal' THEN 2 ELSE 1 END",
+}
 
-	baseQuery := `
-        SELECT po.order_id, po.order_number, po.style_id, po.created_at
+// GetAllOrders 按过滤条件分页查询订单，同时返回满足条件的总数
+func (r *productionOrderRepository) GetAllOrders(filter *models.ProductionOrderFilter) ([]models.ProductionOrder, int, error) {
+	from := `
         FROM Production_Orders po
         LEFT JOIN Styles s ON po.style_id = s.style_id
-    `
+        LEFT JOIN Customers c ON po.customer_id = c.customer_id
+        WHERE po.deleted_at IS NULL`
 	args := []interface{}{}
 
-	if styleNumberQuery != "" {
-		baseQuery += " WHERE s.style_number ILIKE $1" // ILIKE for case-insensitive search
-		args = append(args, "%"+styleNumberQuery+"%")
+	if filter.StyleNumber != "" {
+		args = append(args, "%"+filter.StyleNumber+"%")
+		from += fmt.Sprintf(" AND s.style_number ILIKE $%d", len(args)) // ILIKE for case-insensitive search
+	}
+	if filter.CustomerID != nil {
+		args = append(args, *filter.CustomerID)
+		from += fmt.Sprintf(" AND po.customer_id = $%d", len(args))
+	}
+	if filter.Status != "" {
+		args = append(args, filter.Status)
+		from += fmt.Sprintf(" AND po.status = $%d", len(args))
+	}
+	if filter.Priority != "" {
+		args = append(args, filter.Priority)
+		from += fmt.Sprintf(" AND po.priority = $%d", len(args))
+	}
+	if filter.DueFrom != nil {
+		args = append(args, *filter.DueFrom)
+		from += fmt.Sprintf(" AND po.due_date >= $%d::date", len(args))
+	}
+	if filter.DueTo != nil {
+		args = append(args, *filter.DueTo)
+		from += fmt.Sprintf(" AND po.due_date <= $%d::date", len(args))
+	}
+	if filter.CreatedFrom != nil {
+		args = append(args, *filter.CreatedFrom)
+		from += fmt.Sprintf(" AND po.created_at >= $%d", len(args))
+	}
+	if filter.CreatedTo != nil {
+		args = append(args, *filter.CreatedTo)
+		from += fmt.Sprintf(" AND po.created_at < $%d", len(args))
 	}
 
-	baseQuery += " ORDER BY po.created_at DESC"
+	var total int
+	if err := r.db.Get(&total, "SELECT COUNT(*)"+from, args...); err != nil {
+		return nil, 0, fmt.Errorf("failed to count orders: %w", err)
+	}
+
+	clauses, args := listClauses(filter.ListQuery, orderSortColumns, "created_at", "po.order_id", args)
+	orders := []models.ProductionOrder{}
+	if err := r.db.Select(&orders, "SELECT "+orderColumns+from+clauses, args...); err != nil {
+		return nil, 0, fmt.Errorf("failed to get all orders: %w", err)
+	}
+	return orders, total, nil
+}
 
-	err := r.db.Select(&orders, baseQuery, args...)
+// UpdateOrderDetails 修改订单的客户、交期、优先级和备注
+func (r *productionOrderRepository) UpdateOrderDetails(tx *sqlx.Tx, order *models.ProductionOrder) error {
+	query := `UPDATE Production_Orders SET customer_id = $1, due_date = $2, priority = $3, remarks = $4 WHERE order_id = $5`
+	result, err := tx.Exec(query, order.CustomerID, order.DueDate, order.Priority, order.Remarks, order.OrderID)
+	if err != nil {
+		if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == "23503" {
+			return fmt.Errorf("customer not found")
+		}
+		return fmt.Errorf("failed to update order details: %w", err)
+	}
+	rowsAffected, err := result.RowsAffected()
 	if err != nil {
-		return nil, fmt.Errorf("failed to get all orders: %w", err)
+		return fmt.Errorf("failed to check rows affected: %w", err)
+	}
+	if rowsAffected == 0 {
+		return fmt.Errorf("order not found")
+	}
+	return nil
+}
+
+// GetOpenOrdersDueWithin 返回交期不晚于今天之后 days 天 (含已逾期) 且尚未裁剪完成的订单，按交期、优先级排序，
+// 并在同一个查询中汇总关联计划分配给订单的计划件数和其中已裁剪的件数。
+// 计划每个 颜色+尺码 已裁剪的件数 (尺码配比 × 完成层数) 按交期顺序依次填满各订单的分配数量，
+// 超出全部分配的部分记在最后一个订单上；每个 颜色+尺码 计入的裁剪件数不超过分配给订单的计划件数。
+// 截止日期和剩余天数都按数据库的 CURRENT_DATE 计算，不受应用服务器时区影响
+func (r *productionOrderRepository) GetOpenOrdersDueWithin(days int) ([]models.AtRiskOrder, error) {
+	query := `
+        WITH candidates AS (
+            SELECT po.order_id, po.order_number, s.style_number, po.customer_id, c.name AS customer_name,
+                   po.due_date, (po.due_date - CURRENT_DATE) AS days_remaining, po.priority, po.status,
+                   COALESCE((SELECT SUM(oi.quantity) FROM Order_Items oi WHERE oi.order_id = po.order_id), 0) AS ordered_quantity
+            FROM Production_Orders po
+            JOIN Styles s ON po.style_id = s.style_id
+            LEFT JOIN Customers c ON po.customer_id = c.customer_id
+            WHERE po.deleted_at IS NULL
+              AND po.due_date IS NOT NULL
+              AND po.due_date <= CURRENT_DATE + $1::int
+              AND po.status IN ('draft', 'confirmed', 'planned', 'cutting')
+        ),
+        plans AS (
+            SELECT DISTINCT plo.plan_id
+            FROM Plan_Orders plo
+            JOIN candidates ON candidates.order_id = plo.order_id
+            JOIN Production_Plans pp ON pp.plan_id = plo.plan_id AND pp.deleted_at IS NULL
+        ),
+        cut AS (
+            SELECT cl.plan_id, pt.color, lsr.size, SUM(lsr.ratio * pt.completed_layers) AS pieces
+            FROM plans
+            JOIN Cutting_Layouts cl ON cl.plan_id = plans.plan_id
+            JOIN Production_Tasks pt ON pt.layout_id = cl.layout_id
+            JOIN Layout_Size_Ratios lsr ON lsr.layout_id = cl.layout_id
+            GROUP BY cl.plan_id, pt.color, lsr.size
+        ),
+        shares AS (
+            SELECT a.order_id, a.color, a.size, a.quantity,
+                   COALESCE(cut.pieces, 0) - (SUM(a.quantity) OVER earlier - a.quantity) AS remaining,
+                   ROW_NUMBER() OVER (PARTITION BY a.plan_id, a.color, a.size
+                                      ORDER BY o.due_date DESC NULLS FIRST, o.order_id DESC) = 1 AS is_last
+            FROM plans
+            JOIN Plan_Order_Allocations a ON a.plan_id = plans.plan_id
+            JOIN Production_Orders o ON o.order_id = a.order_id
+            LEFT JOIN cut ON cut.plan_id = a.plan_id AND cut.color = a.color AND cut.size = a.size
+            WINDOW earlier AS (PARTITION BY a.plan_id, a.color, a.size ORDER BY o.due_date NULLS LAST, o.order_id)
+        ),
+        lines AS (
+            SELECT order_id, SUM(quantity) AS planned,
+                   SUM(CASE WHEN is_last THEN GREATEST(remaining, 0)
+                            ELSE LEAST(quantity, GREATEST(remaining, 0)) END) AS cut
+            FROM shares
+            GROUP BY order_id, color, size
+        ),
+        progress AS (
+            SELECT order_id, SUM(planned) AS planned_pieces, SUM(LEAST(cut, planned)) AS cut_pieces
+            FROM lines
+            GROUP BY order_id
+        )
+        SELECT candidates.*,
+               COALESCE(progress.planned_pieces, 0)::int AS planned_pieces,
+               COALESCE(progress.cut_pieces, 0)::int AS cut_pieces
+        FROM candidates
+        LEFT JOIN progress ON progress.order_id = candidates.order_id
+        ORDER BY candidates.due_date,
+                 CASE candidates.priority WHEN 'urgent' THEN 4 WHEN 'high' THEN 3 WHEN 'normal' THEN 2 ELSE 1 END DESC,
+                 candidates.order_id`
+	orders := []models.AtRiskOrder{}
+	if err := r.db.Select(&orders, query, days); err != nil {
+		return nil, fmt.Errorf("failed to get orders due soon: %w", err)
 	}
 	return orders, nil
 }
 
-func (r *productionOrderRepository) CountOrdersByStyleAndDate(styleID int, date string) (int, error) {
-	var count int
-	startTime, err := time.Parse("20060102", date)
-	if err != nil {
-		return 0, err
+// NextOrderSequence 在事务中原子地递增并返回订单号计数器的值。
+// 计数器行在事务提交前保持锁定，因此并发创建的订单会依次拿到不同的序号。
+func (r *productionOrderRepository) NextOrderSequence(tx *sqlx.Tx, sequenceKey string) (int, error) {
+	query := `
+        INSERT INTO Order_Number_Sequences (sequence_key, last_value) VALUES ($1, 1)
+        ON CONFLICT (sequence_key) DO UPDATE
+        SET last_value = Order_Number_Sequences.last_value + 1, updated_at = CURRENT_TIMESTAMP
+        RETURNING last_value`
+	var value int
+	if err := tx.Get(&value, query, sequenceKey); err != nil {
+		return 0, fmt.Errorf("failed to allocate order sequence: %w", err)
 	}
-	endTime := startTime.Add(24 * time.Hour)
+	return value, nil
+}
 
-	query := `SELECT COUNT(*) FROM Production_Orders WHERE style_id = $1 AND created_at >= $2 AND created_at < $3`
-	err = r.db.Get(&count, query, styleID, startTime, endTime)
+func (r *productionOrderRepository) OrderNumberExists(tx *sqlx.Tx, orderNumber string) (bool, error) {
+	var exists bool
+	err := tx.Get(&exists, `SELECT EXISTS(SELECT 1 FROM Production_Orders WHERE order_number = $1)`, orderNumber)
 	if err != nil {
-		return 0, fmt.Errorf("failed to count orders: %w", err)
+		return false, fmt.Errorf("failed to check order number: %w", err)
 	}
-	return count, nil
+	return exists, nil
 }
 
 func (r *productionOrderRepository) DeleteOrder(tx *sqlx.Tx, orderID int) error {
 	query := `DELETE FROM Production_Orders WHERE order_id = $1`
 	result, err := tx.Exec(query, orderID)
 	if err != nil {
+		if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == "23503" {
+			return fmt.Errorf("order is still referenced")
+		}
 		return fmt.Errorf("failed to delete order: %w", err)
 	}
 	rowsAffected, err := result.RowsAffected()
@@ -146,3 +349,192 @@ func (r *productionOrderRepository) DeleteOrder(tx *sqlx.Tx, orderID int) error
 	}
 	return nil
 }
+
+// GetOrderItemsForUpdate 锁定订单及其明细，防止并发修改同一订单
+func (r *productionOrderRepository) GetOrderItemsForUpdate(tx *sqlx.Tx, orderID int) ([]models.OrderItem, error) {
+	var locked int
+	err := tx.Get(&locked, `SELECT order_id FROM Production_Orders WHERE order_id = $1 FOR UPDATE`, orderID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("order not found")
+		}
+		return nil, fmt.Errorf("failed to lock order: %w", err)
+	}
+
+	var items []models.OrderItem
+	query := `SELECT item_id, order_id, color, size, quantity FROM Order_Items WHERE order_id = $1 ORDER BY item_id FOR UPDATE`
+	if err := tx.Select(&items, query, orderID); err != nil {
+		return nil, fmt.Errorf("failed to get order items: %w", err)
+	}
+	return items, nil
+}
+
+func (r *productionOrderRepository) AddOrderItem(tx *sqlx.Tx, orderID int, item models.CreateOrderItem) error {
+	query := `INSERT INTO Order_Items (order_id, color, size, quantity) VALUES ($1, $2, $3, $4)`
+	if _, err := tx.Exec(query, orderID, item.Color, item.Size, item.Quantity); err != nil {
+		return fmt.Errorf("failed to insert order item: %w", err)
+	}
+	return nil
+}
+
+func (r *productionOrderRepository) UpdateOrderItemQuantity(tx *sqlx.Tx, itemID int, quantity int) error {
+	if _, err := tx.Exec(`UPDATE Order_Items SET quantity = $1 WHERE item_id = $2`, quantity, itemID); err != nil {
+		return fmt.Errorf("failed to update order item: %w", err)
+	}
+	return nil
+}
+
+func (r *productionOrderRepository) DeleteOrderItem(tx *sqlx.Tx, itemID int) error {
+	if _, err := tx.Exec(`DELETE FROM Order_Items WHERE item_id = $1`, itemID); err != nil {
+		return fmt.Errorf("failed to delete order item: %w", err)
+	}
+	return nil
+}
+
+// orderExportFilter 与 GetAllOrders 使用相同的款号模糊筛选
+func orderExportFilter(styleNumberQuery string) (string, []interface{}) {
+	if styleNumberQuery == "" {
+		return " WHERE po.deleted_at IS NULL", nil
+	}
+	return " WHERE po.deleted_at IS NULL AND s.style_number ILIKE $1", []interface{}{"%" + styleNumberQuery + "%"}
+}
+
+// GetExportSizes 返回导出范围内出现过的全部尺码，按首次录入的先后排列 (由导出服务按尺码顺序重新排列)
+func (r *productionOrderRepository) GetExportSizes(styleNumberQuery string) ([]string, error) {
+	where, args := orderExportFilter(styleNumberQuery)
+	query := `
+        SELECT oi.size
+        FROM Order_Items oi
+        JOIN Production_Orders po ON po.order_id = oi.order_id
+        JOIN Styles s ON s.style_id = po.style_id` + where + `
+        GROUP BY oi.size
+        ORDER BY MIN(oi.item_id)`
+	var sizes []string
+	if err := r.db.Select(&sizes, query, args...); err != nil {
+		return nil, fmt.Errorf("failed to get export sizes: %w", err)
+	}
+	return sizes, nil
+}
+
+// StreamOrderItems 逐行读取订单明细，同一订单同一颜色的行相邻
+func (r *productionOrderRepository) StreamOrderItems(styleNumberQuery string, fn func(row *models.OrderExportRow) error) error {
+	where, args := orderExportFilter(styleNumberQuery)
+	query := `
+        SELECT po.order_id, po.order_number, s.style_number, po.created_at, oi.color, oi.size, oi.quantity
+        FROM Production_Orders po
+        JOIN Styles s ON s.style_id = po.style_id
+        JOIN Order_Items oi ON oi.order_id = po.order_id` + where + `
+        ORDER BY po.created_at DESC, po.order_id, oi.color, oi.item_id`
+	rows, err := r.db.Queryx(query, args...)
+	if err != nil {
+		return fmt.Errorf("failed to query order items for export: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var row models.OrderExportRow
+		if err := rows.StructScan(&row); err != nil {
+			return fmt.Errorf("failed to scan order item for export: %w", err)
+		}
+		if err := fn(&row); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+// LockOrderStatus 锁定订单并返回其当前状态，状态转换在同一事务中完成
+func (r *productionOrderRepository) LockOrderStatus(tx *sqlx.Tx, orderID int) (string, error) {
+	var status string
+	err := tx.Get(&status, `SELECT status FROM Production_Orders WHERE order_id = $1 FOR UPDATE`, orderID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return "", fmt.Errorf("order not found")
+		}
+		return "", fmt.Errorf("failed to lock order status: %w", err)
+	}
+	return status, nil
+}
+
+func (r *productionOrderRepository) UpdateOrderStatus(tx *sqlx.Tx, orderID int, status string) error {
+	query := `UPDATE Production_Orders SET status = $1, status_updated_at = CURRENT_TIMESTAMP WHERE order_id = $2`
+	if _, err := tx.Exec(query, status, orderID); err != nil {
+		return fmt.Errorf("failed to update order status: %w", err)
+	}
+	return nil
+}
+
+func (r *productionOrderRepository) CreateStatusChange(tx *sqlx.Tx, change *models.OrderStatusChange) error {
+	query := `
+        INSERT INTO Order_Status_History (order_id, from_status, to_status, actor_worker_id, actor_api_key_id, automatic, reason)
+        VALUES ($1, $2, $3, $4, $5, $6, NULLIF($7, ''))
+        RETURNING history_id, created_at`
+	err := tx.QueryRowx(query, change.OrderID, change.FromStatus, change.ToStatus, change.ActorWorkerID,
+		change.ActorAPIKeyID, change.Automatic, change.Reason).Scan(&change.HistoryID, &change.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to record order status change: %w", err)
+	}
+	return nil
+}
+
+func (r *productionOrderRepository) GetStatusHistory(orderID int) ([]models.OrderStatusChange, error) {
+	query := `
+        SELECT h.history_id, h.order_id, h.from_status, h.to_status, h.actor_worker_id, COALESCE(w.name, '') AS actor_name,
+               h.actor_api_key_id, h.automatic, COALESCE(h.reason, '') AS reason, h.created_at
+        FROM Order_Status_History h
+        LEFT JOIN Workers w ON w.worker_id = h.actor_worker_id
+        WHERE h.order_id = $1
+        ORDER BY h.created_at, h.history_id`
+	history := []models.OrderStatusChange{}
+	if err := r.db.Select(&history, query, orderID); err != nil {
+		return nil, fmt.Errorf("failed to get order status history: %w", err)
+	}
+	return history, nil
+}
+
+// FindOrderIDsByTask 返回任务所属计划关联的全部订单，计划已删除时返回空
+func (r *productionOrderRepository) FindOrderIDsByTask(tx *sqlx.Tx, taskID int) ([]int, error) {
+	query := `
+        SELECT plo.order_id
+        FROM Production_Tasks pt
+        JOIN Cutting_Layouts cl ON cl.layout_id = pt.layout_id
+        JOIN Production_Plans pp ON pp.plan_id = cl.plan_id AND pp.deleted_at IS NULL
+        JOIN Plan_Orders plo ON plo.plan_id = cl.plan_id
+        WHERE pt.task_id = $1
+        ORDER BY plo.order_id`
+	orderIDs := []int{}
+	if err := tx.Select(&orderIDs, query, taskID); err != nil {
+		return nil, fmt.Errorf("failed to find orders for task: %w", err)
+	}
+	return orderIDs, nil
+}
+
+// CountLinkedPlans 返回订单当前关联的计划数量 (不含已删除的计划)
+func (r *productionOrderRepository) CountLinkedPlans(tx *sqlx.Tx, orderID int) (int, error) {
+	var count int
+	query := `SELECT COUNT(*) FROM Plan_Orders plo
+	          JOIN Production_Plans pp ON pp.plan_id = plo.plan_id AND pp.deleted_at IS NULL
+	          WHERE plo.order_id = $1`
+	if err := tx.Get(&count, query, orderID); err != nil {
+		return 0, fmt.Errorf("failed to count linked plans: %w", err)
+	}
+	return count, nil
+}
+
+// GetTaskProgress 汇总订单关联计划下全部任务的裁剪进度
+func (r *productionOrderRepository) GetTaskProgress(tx *sqlx.Tx, orderID int) (*models.OrderTaskProgress, error) {
+	query := `
+        SELECT COUNT(pt.task_id) AS tasks,
+               COUNT(pt.task_id) FILTER (WHERE pt.completed_layers >= pt.planned_layers) AS completed_tasks,
+               COALESCE(SUM(pt.completed_layers), 0) AS completed_layers
+        FROM Plan_Orders plo
+        JOIN Production_Plans pp ON pp.plan_id = plo.plan_id AND pp.deleted_at IS NULL
+        JOIN Cutting_Layouts cl ON cl.plan_id = plo.plan_id
+        JOIN Production_Tasks pt ON pt.layout_id = cl.layout_id
+        WHERE plo.order_id = $1`
+	var progress models.OrderTaskProgress
+	if err := tx.Get(&progress, query, orderID); err != nil {
+		return nil, fmt.Errorf("failed to get order task progress: %w", err)
+	}
+	return &progress, nil
+}
@@ -2,23 +2,44 @@ package repositories
 
 import (
 	"cutrix-backend/internal/models"
+	"cutrix-backend/pkg/sizes"
 	"database/sql"
 	"fmt"
+	"slices"
 	"strings"
 
 	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
 )
 
 type ProductionPlanRepository interface {
 	CreatePlan(tx *sqlx.Tx, plan *models.CreateProductionPlanRequest) (*models.ProductionPlan, error)
 	UpdatePlan(tx *sqlx.Tx, planID int, plan *models.CreateProductionPlanRequest) error
+	GetLayoutsForUpdate(tx *sqlx.Tx, planID int) ([]models.CuttingLayout, error)
+	UpdateLayout(tx *sqlx.Tx, layoutID int, layout *models.CreateLayout) error
+	ReplaceRatios(tx *sqlx.Tx, layoutID int, ratios []models.CreateRatio) error
+	DeleteLayout(tx *sqlx.Tx, layoutID int) error
+	CreateTask(tx *sqlx.Tx, styleID, layoutID int, layoutName string, task models.CreateTaskForPlan) (int, error)
+	UpdateTask(tx *sqlx.Tx, taskID int, layoutName string, task models.CreateTaskForPlan) error
+	CountTaskLogs(tx *sqlx.Tx, taskID int) (int, error)
+	ReassignTaskLogs(tx *sqlx.Tx, fromTaskID, toTaskID int) error
+	DeleteTask(tx *sqlx.Tx, taskID int) error
 	CreateLayout(tx *sqlx.Tx, planID int, layout *models.CreateLayout) (*models.CuttingLayout, error)
 	CreateRatios(tx *sqlx.Tx, layoutID int, ratios []models.CreateRatio) error
 	CreateTasks(tx *sqlx.Tx, styleID int, layoutID int, layoutName string, tasks []models.CreateTaskForPlan) error
 	GetPlanWithDetails(planID int) (*models.ProductionPlan, error)
-	GetAllPlans(searchQuery string) ([]models.ProductionPlan, error)
+	GetPlanWithDetailsInTx(tx *sqlx.Tx, planID int) (*models.ProductionPlan, error)
+	GetAllPlans(filter *models.ProductionPlanFilter) ([]models.ProductionPlan, int, error)
 	GetPlanByOrderID(orderID int) (*models.ProductionPlan, error)
-	DeletePlan(planID int) error
+	GetPlansByOrderID(orderID int) ([]models.ProductionPlan, error)
+	// 计划与订单的多对多关联及件数分配
+	GetPlanOrders(planID int) ([]models.PlanOrder, error)
+	SetPlanOrders(tx *sqlx.Tx, planID int, orders []models.PlanOrder) error
+	GetAllocatedPieces(tx *sqlx.Tx, orderID int, excludePlanID int) ([]models.PlanOrderAllocation, error)
+	SetPlanWarnings(tx *sqlx.Tx, planID int, warnings []models.PlanWarning) error
+	GetPlanWarnings(planID int) ([]models.PlanWarning, error)
+	StreamPlanTasks(searchQuery string, fn func(row *models.PlanExportRow) error) error
+	DeletePlan(tx *sqlx.Tx, planID int) error
 }
 
 type productionPlanRepository struct {
@@ -29,103 +50,136 @@ func NewProductionPlanRepository(db *sqlx.DB) ProductionPlanRepository {
 	return &productionPlanRepository{db: db}
 }
 
+// planColumns 是查询计划时的列，linked_order_id 为关联订单中编号最小的一个 (兼容只支持单个订单的调用方)
+const planColumns = `pp.plan_id, pp.plan_name, pp.style_id,
+        (SELECT MIN(plo.order_id) FROM Plan_Orders plo WHERE plo.plan_id = pp.plan_id) AS linked_order_id, pp.created_at`
+
 // 辅助函数：连接SQL占位符
 func joinPlaceholders(placeholders []string) string {
 	return strings.Join(placeholders, ", ")
 }
 
-// UpdatePlan completely replaces the layouts, ratios, and tasks for a given plan.
+// UpdatePlan 更新计划本身的字段。排版、配比和任务由服务层按排版和任务逐项比对后，
+// 用下面的 UpdateLayout、UpdateTask 等方法就地更新，保留已有任务的进度和生产记录
 func (r *productionPlanRepository) UpdatePlan(tx *sqlx.Tx, planID int, req *models.CreateProductionPlanRequest) error {
-	// 1. Update the main plan table
 	_, err := tx.Exec(`UPDATE Production_Plans SET plan_name = $1 WHERE plan_id = $2`, req.PlanName, planID)
 	if err != nil {
 		return fmt.Errorf("failed to update plan name: %w", err)
 	}
+	return nil
+}
 
-	// 2. Find old layout IDs to delete tasks
-	var oldLayoutIDs []int
-	err = tx.Select(&oldLayoutIDs, `SELECT layout_id FROM Cutting_Layouts WHERE plan_id = $1`, planID)
-	if err != nil && err != sql.ErrNoRows {
-		return fmt.Errorf("failed to find old layouts: %w", err)
+// GetLayoutsForUpdate 返回计划的排版及各排版的尺码配比和任务 (均按ID排序)，并锁定任务，
+// 避免比对期间有新的拉布记录改变已完成层数
+func (r *productionPlanRepository) GetLayoutsForUpdate(tx *sqlx.Tx, planID int) ([]models.CuttingLayout, error) {
+	var layouts []models.CuttingLayout
+	if err := tx.Select(&layouts, `SELECT * FROM Cutting_Layouts WHERE plan_id = $1 ORDER BY layout_id`, planID); err != nil {
+		return nil, fmt.Errorf("failed to get layouts for plan: %w", err)
 	}
-
-	// 3. Disassociate or delete logs and then delete old tasks
-	if len(oldLayoutIDs) > 0 {
-		// 使用参数化查询替代IN查询，避免sqlx.In可能的问题
-		placeholders := make([]string, len(oldLayoutIDs))
-		args := make([]interface{}, len(oldLayoutIDs))
-		for i, id := range oldLayoutIDs {
-			placeholders[i] = fmt.Sprintf("$%d", i+1)
-			args[i] = id
+	for i := range layouts {
+		var tasks []models.ProductionTask
+		query := `SELECT * FROM Production_Tasks WHERE layout_id = $1 ORDER BY task_id FOR UPDATE`
+		if err := tx.Select(&tasks, query, layouts[i].LayoutID); err != nil {
+			return nil, fmt.Errorf("failed to get tasks for layout %d: %w", layouts[i].LayoutID, err)
 		}
-		
-		// 查找要删除的任务ID
-		var taskIDsToDelete []int
-		taskQuery := fmt.Sprintf("SELECT task_id FROM Production_Tasks WHERE layout_id IN (%s)", 
-			joinPlaceholders(placeholders))
-		err = tx.Select(&taskIDsToDelete, taskQuery, args...)
-		if err != nil && err != sql.ErrNoRows {
-			return fmt.Errorf("failed to find tasks to delete: %w", err)
+		layouts[i].Tasks = tasks
+		var ratios []models.LayoutSizeRatio
+		if err := tx.Select(&ratios, `SELECT * FROM Layout_Size_Ratios WHERE layout_id = $1 ORDER BY ratio_id`, layouts[i].LayoutID); err != nil {
+			return nil, fmt.Errorf("failed to get ratios for layout %d: %w", layouts[i].LayoutID, err)
 		}
+		layouts[i].Ratios = ratios
+	}
+	return layouts, nil
+}
 
-		// 更新生产日志，将任务ID设为NULL
-		if len(taskIDsToDelete) > 0 {
-			logPlaceholders := make([]string, len(taskIDsToDelete))
-			logArgs := make([]interface{}, len(taskIDsToDelete))
-			for i, id := range taskIDsToDelete {
-				logPlaceholders[i] = fmt.Sprintf("$%d", i+1)
-				logArgs[i] = id
-			}
-			
-			logQuery := fmt.Sprintf("UPDATE Production_Logs SET task_id = NULL WHERE task_id IN (%s)",
-				joinPlaceholders(logPlaceholders))
-			_, err = tx.Exec(logQuery, logArgs...)
-			if err != nil {
-				return fmt.Errorf("failed to update production_logs: %w", err)
-			}
-		}
+// UpdateLayout 修改排版的名称和说明
+func (r *productionPlanRepository) UpdateLayout(tx *sqlx.Tx, layoutID int, layoutReq *models.CreateLayout) error {
+	_, err := tx.Exec(`UPDATE Cutting_Layouts SET layout_name = $1, description = $2 WHERE layout_id = $3`,
+		layoutReq.LayoutName, layoutReq.Description, layoutID)
+	if err != nil {
+		return fmt.Errorf("failed to update layout: %w", err)
+	}
+	return nil
+}
 
-		// 删除旧任务
-		deleteTaskQuery := fmt.Sprintf("DELETE FROM Production_Tasks WHERE layout_id IN (%s)",
-			joinPlaceholders(placeholders))
-		_, err = tx.Exec(deleteTaskQuery, args...)
-		if err != nil {
-			return fmt.Errorf("failed to delete old tasks: %w", err)
-		}
+// ReplaceRatios 用给定的尺码配比替换排版原有的配比
+func (r *productionPlanRepository) ReplaceRatios(tx *sqlx.Tx, layoutID int, ratios []models.CreateRatio) error {
+	if _, err := tx.Exec(`DELETE FROM Layout_Size_Ratios WHERE layout_id = $1`, layoutID); err != nil {
+		return fmt.Errorf("failed to clear ratios: %w", err)
 	}
+	return r.CreateRatios(tx, layoutID, ratios)
+}
 
-	// 4. Delete old layouts (which cascades to ratios)
-	_, err = tx.Exec(`DELETE FROM Cutting_Layouts WHERE plan_id = $1`, planID)
-	if err != nil {
-		return fmt.Errorf("failed to delete old layouts: %w", err)
+// DeleteLayout 删除排版及其尺码配比，排版的任务必须已经删除
+func (r *productionPlanRepository) DeleteLayout(tx *sqlx.Tx, layoutID int) error {
+	if _, err := tx.Exec(`DELETE FROM Cutting_Layouts WHERE layout_id = $1`, layoutID); err != nil {
+		return fmt.Errorf("failed to delete layout: %w", err)
 	}
+	return nil
+}
 
-	// 5. Re-create layouts, ratios, and tasks with the new data (reusing existing functions)
-	for _, layoutReq := range req.Layouts {
-		layout, err := r.CreateLayout(tx, planID, &layoutReq)
-		if err != nil {
-			return err
-		}
-		if err := r.CreateRatios(tx, layout.LayoutID, layoutReq.Ratios); err != nil {
-			return err
-		}
-		if err := r.CreateTasks(tx, req.StyleID, layout.LayoutID, layout.LayoutName, layoutReq.Tasks); err != nil {
-			return err
-		}
+// CreateTask 新增一个任务并返回任务ID
+func (r *productionPlanRepository) CreateTask(tx *sqlx.Tx, styleID, layoutID int, layoutName string, task models.CreateTaskForPlan) (int, error) {
+	var taskID int
+	query := `INSERT INTO Production_Tasks (style_id, layout_id, layout_name, color, planned_layers)
+	          VALUES ($1, $2, $3, $4, $5) RETURNING task_id`
+	if err := tx.Get(&taskID, query, styleID, layoutID, layoutName, task.Color, task.PlannedLayers); err != nil {
+		return 0, fmt.Errorf("failed to insert task: %w", err)
 	}
+	return taskID, nil
+}
 
+// UpdateTask 就地修改任务的排版名称、颜色和计划层数，已完成层数保持不变
+func (r *productionPlanRepository) UpdateTask(tx *sqlx.Tx, taskID int, layoutName string, task models.CreateTaskForPlan) error {
+	_, err := tx.Exec(`UPDATE Production_Tasks SET layout_name = $1, color = $2, planned_layers = $3 WHERE task_id = $4`,
+		layoutName, task.Color, task.PlannedLayers, taskID)
+	if err != nil {
+		return fmt.Errorf("failed to update task: %w", err)
+	}
 	return nil
 }
 
-func (r *productionPlanRepository) DeletePlan(planID int) error {
-	tx, err := r.db.Beginx()
+// CountTaskLogs 返回任务的生产记录条数
+func (r *productionPlanRepository) CountTaskLogs(tx *sqlx.Tx, taskID int) (int, error) {
+	var count int
+	if err := tx.Get(&count, `SELECT COUNT(*) FROM Production_Logs WHERE task_id = $1`, taskID); err != nil {
+		return 0, fmt.Errorf("failed to count task logs: %w", err)
+	}
+	return count, nil
+}
+
+// ReassignTaskLogs 把任务的生产记录和已完成层数转移到另一个任务
+func (r *productionPlanRepository) ReassignTaskLogs(tx *sqlx.Tx, fromTaskID, toTaskID int) error {
+	if _, err := tx.Exec(`UPDATE Production_Logs SET task_id = $2 WHERE task_id = $1`, fromTaskID, toTaskID); err != nil {
+		return fmt.Errorf("failed to reassign task logs: %w", err)
+	}
+	_, err := tx.Exec(`
+        UPDATE Production_Tasks t SET completed_layers = t.completed_layers + f.completed_layers
+        FROM Production_Tasks f WHERE f.task_id = $1 AND t.task_id = $2`, fromTaskID, toTaskID)
 	if err != nil {
-		return fmt.Errorf("failed to begin transaction for plan deletion: %w", err)
+		return fmt.Errorf("failed to move completed layers: %w", err)
 	}
-	defer tx.Rollback()
-	
+	if _, err := tx.Exec(`UPDATE Production_Tasks SET completed_layers = 0 WHERE task_id = $1`, fromTaskID); err != nil {
+		return fmt.Errorf("failed to reset completed layers: %w", err)
+	}
+	return nil
+}
+
+// DeleteTask 删除没有生产记录的任务，任务仍有生产记录时返回 "task is still referenced"
+func (r *productionPlanRepository) DeleteTask(tx *sqlx.Tx, taskID int) error {
+	if _, err := tx.Exec(`DELETE FROM Production_Tasks WHERE task_id = $1`, taskID); err != nil {
+		if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == "23503" {
+			return fmt.Errorf("task is still referenced")
+		}
+		return fmt.Errorf("failed to delete task: %w", err)
+	}
+	return nil
+}
+
+// DeletePlan 彻底删除计划及其排版和任务，只用于从回收站清除没有生产记录的计划
+func (r *productionPlanRepository) DeletePlan(tx *sqlx.Tx, planID int) error {
 	var layoutIDs []int
-	err = tx.Select(&layoutIDs, `SELECT layout_id FROM Cutting_Layouts WHERE plan_id = $1`, planID)
+	err := tx.Select(&layoutIDs, `SELECT layout_id FROM Cutting_Layouts WHERE plan_id = $1`, planID)
 	if err != nil {
 		return fmt.Errorf("failed to find layouts for plan: %w", err)
 	}
@@ -189,12 +243,15 @@ func (r *productionPlanRepository) DeletePlan(planID int) error {
 		return fmt.Errorf("plan not found or already deleted")
 	}
 	
-	return tx.Commit()
+	return nil
 }
 
+// GetPlanByOrderID 返回订单关联的最早创建的计划，一个订单可能关联多个计划，需要全部计划时使用 GetPlansByOrderID
 func (r *productionPlanRepository) GetPlanByOrderID(orderID int) (*models.ProductionPlan, error) {
 	var plan models.ProductionPlan
-	query := `SELECT * FROM Production_Plans WHERE linked_order_id = $1 LIMIT 1`
+	query := `SELECT ` + planColumns + ` FROM Production_Plans pp
+	          JOIN Plan_Orders po ON po.plan_id = pp.plan_id
+	          WHERE po.order_id = $1 AND pp.deleted_at IS NULL ORDER BY pp.created_at, pp.plan_id LIMIT 1`
 	err := r.db.Get(&plan, query, orderID)
 	if err != nil {
 		if err == sql.ErrNoRows {
@@ -205,11 +262,130 @@ func (r *productionPlanRepository) GetPlanByOrderID(orderID int) (*models.Produc
 	return &plan, nil
 }
 
+func (r *productionPlanRepository) GetPlansByOrderID(orderID int) ([]models.ProductionPlan, error) {
+	plans := []models.ProductionPlan{}
+	query := `SELECT ` + planColumns + ` FROM Production_Plans pp
+	          JOIN Plan_Orders po ON po.plan_id = pp.plan_id
+	          WHERE po.order_id = $1 AND pp.deleted_at IS NULL ORDER BY pp.created_at, pp.plan_id`
+	if err := r.db.Select(&plans, query, orderID); err != nil {
+		return nil, fmt.Errorf("failed to get plans by order id: %w", err)
+	}
+	return plans, nil
+}
+
+// GetPlanOrders 返回计划关联的订单及分配件数，按交期 (空交期在后)、订单ID排序
+func (r *productionPlanRepository) GetPlanOrders(planID int) ([]models.PlanOrder, error) {
+	return queryPlanOrders(r.db, planID)
+}
+
+func queryPlanOrders(db sqlx.Queryer, planID int) ([]models.PlanOrder, error) {
+	orders := []models.PlanOrder{}
+	query := `
+        SELECT o.order_id, o.order_number, o.due_date
+        FROM Plan_Orders plo
+        JOIN Production_Orders o ON o.order_id = plo.order_id
+        WHERE plo.plan_id = $1
+        ORDER BY o.due_date NULLS LAST, o.order_id`
+	if err := sqlx.Select(db, &orders, query, planID); err != nil {
+		return nil, fmt.Errorf("failed to get plan orders: %w", err)
+	}
+
+	var allocations []models.PlanOrderAllocation
+	query = `SELECT plan_id, order_id, color, size, quantity FROM Plan_Order_Allocations WHERE plan_id = $1 ORDER BY allocation_id`
+	if err := sqlx.Select(db, &allocations, query, planID); err != nil {
+		return nil, fmt.Errorf("failed to get plan allocations: %w", err)
+	}
+	// 分配按颜色分组，组内按款号尺码组的顺序排列
+	run, err := queryStyleRun(db, `SELECT st.size_scale, st.sizes FROM Production_Plans pp
+	                                JOIN Styles st ON st.style_id = pp.style_id WHERE pp.plan_id = $1`, planID)
+	if err != nil {
+		return nil, err
+	}
+	sizes.SortByColor(run, allocations, func(a models.PlanOrderAllocation) (string, string) { return a.Color, a.Size })
+	byOrder := make(map[int][]models.PlanOrderAllocation, len(orders))
+	for _, allocation := range allocations {
+		byOrder[allocation.OrderID] = append(byOrder[allocation.OrderID], allocation)
+	}
+	for i := range orders {
+		orders[i].Allocations = byOrder[orders[i].OrderID]
+		if orders[i].Allocations == nil {
+			orders[i].Allocations = []models.PlanOrderAllocation{}
+		}
+	}
+	return orders, nil
+}
+
+// SetPlanOrders 用给定的订单及分配件数替换计划的全部关联
+func (r *productionPlanRepository) SetPlanOrders(tx *sqlx.Tx, planID int, orders []models.PlanOrder) error {
+	if _, err := tx.Exec(`DELETE FROM Plan_Orders WHERE plan_id = $1`, planID); err != nil {
+		return fmt.Errorf("failed to clear plan orders: %w", err)
+	}
+	for _, order := range orders {
+		if _, err := tx.Exec(`INSERT INTO Plan_Orders (plan_id, order_id) VALUES ($1, $2)`, planID, order.OrderID); err != nil {
+			return fmt.Errorf("failed to link order to plan: %w", err)
+		}
+		for _, allocation := range order.Allocations {
+			_, err := tx.Exec(`INSERT INTO Plan_Order_Allocations (plan_id, order_id, color, size, quantity) VALUES ($1, $2, $3, $4, $5)`,
+				planID, order.OrderID, allocation.Color, allocation.Size, allocation.Quantity)
+			if err != nil {
+				return fmt.Errorf("failed to insert plan allocation: %w", err)
+			}
+		}
+	}
+	return nil
+}
+
+// SetPlanWarnings 用给定的警告替换计划已保存的警告
+func (r *productionPlanRepository) SetPlanWarnings(tx *sqlx.Tx, planID int, warnings []models.PlanWarning) error {
+	if _, err := tx.Exec(`DELETE FROM Plan_Warnings WHERE plan_id = $1`, planID); err != nil {
+		return fmt.Errorf("failed to clear plan warnings: %w", err)
+	}
+	for _, warning := range warnings {
+		_, err := tx.Exec(`INSERT INTO Plan_Warnings (plan_id, warning_type, color, size, ordered_quantity, planned_pieces, message)
+		                   VALUES ($1, $2, $3, $4, $5, $6, $7)`,
+			planID, warning.Type, warning.Color, warning.Size, warning.OrderedQuantity, warning.PlannedPieces, warning.Message)
+		if err != nil {
+			return fmt.Errorf("failed to insert plan warning: %w", err)
+		}
+	}
+	return nil
+}
+
+// GetPlanWarnings 返回计划保存时记录的警告，按记录的顺序排列
+func (r *productionPlanRepository) GetPlanWarnings(planID int) ([]models.PlanWarning, error) {
+	return queryPlanWarnings(r.db, planID)
+}
+
+func queryPlanWarnings(db sqlx.Queryer, planID int) ([]models.PlanWarning, error) {
+	warnings := []models.PlanWarning{}
+	query := `SELECT warning_type, color, size, ordered_quantity, planned_pieces, message
+	          FROM Plan_Warnings WHERE plan_id = $1 ORDER BY warning_id`
+	if err := sqlx.Select(db, &warnings, query, planID); err != nil {
+		return nil, fmt.Errorf("failed to get plan warnings: %w", err)
+	}
+	return warnings, nil
+}
+
+// GetAllocatedPieces 汇总订单在其他未删除计划 (不含 excludePlanID) 中已分配的件数，按 颜色+尺码 合计
+func (r *productionPlanRepository) GetAllocatedPieces(tx *sqlx.Tx, orderID int, excludePlanID int) ([]models.PlanOrderAllocation, error) {
+	var allocations []models.PlanOrderAllocation
+	query := `
+        SELECT 0 AS plan_id, a.order_id, a.color, a.size, SUM(a.quantity) AS quantity
+        FROM Plan_Order_Allocations a
+        JOIN Production_Plans pp ON pp.plan_id = a.plan_id AND pp.deleted_at IS NULL
+        WHERE a.order_id = $1 AND a.plan_id <> $2
+        GROUP BY a.order_id, a.color, a.size`
+	if err := tx.Select(&allocations, query, orderID, excludePlanID); err != nil {
+		return nil, fmt.Errorf("failed to get allocated pieces: %w", err)
+	}
+	return allocations, nil
+}
+
 func (r *productionPlanRepository) CreatePlan(tx *sqlx.Tx, req *models.CreateProductionPlanRequest) (*models.ProductionPlan, error) {
-	query := `INSERT INTO Production_Plans (plan_name, style_id, linked_order_id) VALUES ($1, $2, $3) 
-	          RETURNING plan_id, plan_name, style_id, linked_order_id, created_at`
+	query := `INSERT INTO Production_Plans (plan_name, style_id) VALUES ($1, $2)
+	          RETURNING plan_id, plan_name, style_id, created_at`
 	var plan models.ProductionPlan
-	err := tx.QueryRowx(query, req.PlanName, req.StyleID, req.LinkedOrderID).StructScan(&plan)
+	err := tx.QueryRowx(query, req.PlanName, req.StyleID).StructScan(&plan)
 	if err != nil {
 		return nil, fmt.Errorf("failed to insert plan: %w", err)
 	}
@@ -261,14 +437,41 @@ func (r *productionPlanRepository) CreateTasks(tx *sqlx.Tx, styleID int, layoutI
 }
 
 func (r *productionPlanRepository) GetPlanWithDetails(planID int) (*models.ProductionPlan, error) {
+	return queryPlanWithDetails(r.db, planID)
+}
+
+// GetPlanWithDetailsInTx 在事务中读取计划详情，可以看到本事务中尚未提交的修改
+func (r *productionPlanRepository) GetPlanWithDetailsInTx(tx *sqlx.Tx, planID int) (*models.ProductionPlan, error) {
+	return queryPlanWithDetails(tx, planID)
+}
+
+func queryPlanWithDetails(db sqlx.Queryer, planID int) (*models.ProductionPlan, error) {
 	var plan models.ProductionPlan
-	err := r.db.Get(&plan, `SELECT * FROM Production_Plans WHERE plan_id = $1`, planID)
+	err := sqlx.Get(db, &plan, `SELECT `+planColumns+` FROM Production_Plans pp WHERE pp.plan_id = $1 AND pp.deleted_at IS NULL`, planID)
 	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("plan not found")
+		}
 		return nil, fmt.Errorf("failed to get plan: %w", err)
 	}
+
+	orders, err := queryPlanOrders(db, planID)
+	if err != nil {
+		return nil, err
+	}
+	plan.Orders = orders
+
+	if plan.Warnings, err = queryPlanWarnings(db, planID); err != nil {
+		return nil, err
+	}
+
+	run, err := queryStyleRun(db, `SELECT size_scale, sizes FROM Styles WHERE style_id = $1`, plan.StyleID)
+	if err != nil {
+		return nil, err
+	}
 	
 	var layouts []models.CuttingLayout
-	err = r.db.Select(&layouts, `SELECT * FROM Cutting_Layouts WHERE plan_id = $1 ORDER BY layout_id`, planID)
+	err = sqlx.Select(db, &layouts, `SELECT * FROM Cutting_Layouts WHERE plan_id = $1 ORDER BY layout_id`, planID)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get layouts for plan: %w", err)
 	}
@@ -277,14 +480,16 @@ func (r *productionPlanRepository) GetPlanWithDetails(planID int) (*models.Produ
 		layoutID := layouts[i].LayoutID
 		
 		var ratios []models.LayoutSizeRatio
-		err = r.db.Select(&ratios, `SELECT * FROM Layout_Size_Ratios WHERE layout_id = $1`, layoutID)
+		err = sqlx.Select(db, &ratios, `SELECT * FROM Layout_Size_Ratios WHERE layout_id = $1 ORDER BY ratio_id`, layoutID)
 		if err != nil {
 			return nil, fmt.Errorf("failed to get ratios for layout %d: %w", layoutID, err)
 		}
+		// 配比按款号尺码组的顺序排列
+		slices.SortStableFunc(ratios, func(a, b models.LayoutSizeRatio) int { return run.Compare(a.Size, b.Size) })
 		layouts[i].Ratios = ratios
 		
 		var tasks []models.ProductionTask
-		err = r.db.Select(&tasks, `SELECT * FROM Production_Tasks WHERE layout_id = $1`, layoutID)
+		err = sqlx.Select(db, &tasks, `SELECT * FROM Production_Tasks WHERE layout_id = $1 ORDER BY task_id`, layoutID)
 		if err != nil {
 			return nil, fmt.Errorf("failed to get tasks for layout %d: %w", layoutID, err)
 		}
@@ -295,26 +500,123 @@ func (r *productionPlanRepository) GetPlanWithDetails(planID int) (*models.Produ
 	return &plan, nil
 }
 
-func (r *productionPlanRepository) GetAllPlans(searchQuery string) ([]models.ProductionPlan, error) {
-	var plans []models.ProductionPlan
+// planSortColumns 是计划列表允许的排序字段
+var planSortColumns = map[string]string{
+	"created_at": "pp.created_at",
+	"plan_name":  "pp.plan_name",
+	"plan_id":    "pp.plan_id",
+}
 
-	baseQuery := `
-		SELECT pp.plan_id, pp.plan_name, pp.style_id, pp.linked_order_id, pp.created_at 
+// GetAllPlans 按过滤条件分页查询计划，同时返回满足条件的总数
+func (r *productionPlanRepository) GetAllPlans(filter *models.ProductionPlanFilter) ([]models.ProductionPlan, int, error) {
+	from := `
 		FROM Production_Plans pp
-		LEFT JOIN Production_Orders po ON pp.linked_order_id = po.order_id
-	`
+		JOIN Styles s ON s.style_id = pp.style_id
+		WHERE pp.deleted_at IS NULL`
 	args := []interface{}{}
 
+	if filter.Search != "" {
+		args = append(args, "%"+filter.Search+"%")
+		from += fmt.Sprintf(` AND (pp.plan_name ILIKE $%d OR EXISTS (
+			SELECT 1 FROM Plan_Orders plo JOIN Production_Orders po ON po.order_id = plo.order_id
+			WHERE plo.plan_id = pp.plan_id AND po.order_number ILIKE $%d))`, len(args), len(args))
+	}
+	if filter.StyleNumber != "" {
+		args = append(args, "%"+filter.StyleNumber+"%")
+		from += fmt.Sprintf(" AND s.style_number ILIKE $%d", len(args))
+	}
+	if filter.OrderID != nil {
+		args = append(args, *filter.OrderID)
+		from += fmt.Sprintf(" AND EXISTS (SELECT 1 FROM Plan_Orders plo WHERE plo.plan_id = pp.plan_id AND plo.order_id = $%d)", len(args))
+	}
+	if filter.CreatedFrom != nil {
+		args = append(args, *filter.CreatedFrom)
+		from += fmt.Sprintf(" AND pp.created_at >= $%d", len(args))
+	}
+	if filter.CreatedTo != nil {
+		args = append(args, *filter.CreatedTo)
+		from += fmt.Sprintf(" AND pp.created_at < $%d", len(args))
+	}
+
+	var total int
+	if err := r.db.Get(&total, "SELECT COUNT(*)"+from, args...); err != nil {
+		return nil, 0, fmt.Errorf("failed to count plans: %w", err)
+	}
+
+	clauses, args := listClauses(filter.ListQuery, planSortColumns, "created_at", "pp.plan_id", args)
+	plans := []models.ProductionPlan{}
+	if err := r.db.Select(&plans, "SELECT "+planColumns+from+clauses, args...); err != nil {
+		return nil, 0, fmt.Errorf("failed to get all plans: %w", err)
+	}
+	return plans, total, nil
+}
+
+// StreamPlanTasks 逐行读取计划的排版、尺码配比和颜色任务，按计划名称或关联订单号模糊筛选。
+// 尺码配比按款号尺码组的顺序排列，格式为 "S:1 M:2"
+func (r *productionPlanRepository) StreamPlanTasks(searchQuery string, fn func(row *models.PlanExportRow) error) error {
+	query := `
+        SELECT pp.plan_id, pp.plan_name, s.style_number, s.size_scale, s.sizes,
+               (SELECT string_agg(po.order_number, ', ' ORDER BY po.order_number)
+                FROM Plan_Orders plo JOIN Production_Orders po ON po.order_id = plo.order_id
+                WHERE plo.plan_id = pp.plan_id) AS order_number,
+               pp.created_at,
+               cl.layout_name, cl.description,
+               ARRAY(SELECT lsr.size::text FROM Layout_Size_Ratios lsr WHERE lsr.layout_id = cl.layout_id ORDER BY lsr.ratio_id) AS ratio_sizes,
+               ARRAY(SELECT lsr.ratio FROM Layout_Size_Ratios lsr WHERE lsr.layout_id = cl.layout_id ORDER BY lsr.ratio_id) AS ratio_values,
+               (SELECT SUM(lsr.ratio)::int FROM Layout_Size_Ratios lsr WHERE lsr.layout_id = cl.layout_id) AS pieces_per_layer,
+               pt.color, pt.planned_layers, pt.completed_layers
+        FROM Production_Plans pp
+        JOIN Styles s ON s.style_id = pp.style_id
+        LEFT JOIN Cutting_Layouts cl ON cl.plan_id = pp.plan_id
+        LEFT JOIN Production_Tasks pt ON pt.layout_id = cl.layout_id
+        WHERE pp.deleted_at IS NULL`
+	args := []interface{}{}
 	if searchQuery != "" {
-		baseQuery += " WHERE pp.plan_name ILIKE $1 OR po.order_number ILIKE $1"
+		query += ` AND (pp.plan_name ILIKE $1 OR EXISTS (
+            SELECT 1 FROM Plan_Orders plo JOIN Production_Orders po ON po.order_id = plo.order_id
+            WHERE plo.plan_id = pp.plan_id AND po.order_number ILIKE $1))`
 		args = append(args, "%"+searchQuery+"%")
 	}
+	query += " ORDER BY pp.created_at DESC, pp.plan_id, cl.layout_id, pt.task_id"
 
-	baseQuery += " ORDER BY pp.created_at DESC"
-
-	err := r.db.Select(&plans, baseQuery, args...)
+	rows, err := r.db.Queryx(query, args...)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get all plans: %w", err)
+		return fmt.Errorf("failed to query plans for export: %w", err)
 	}
-	return plans, nil
+	defer rows.Close()
+
+	var run *sizes.Run
+	runPlanID := 0
+	for rows.Next() {
+		var row struct {
+			models.PlanExportRow
+			SizeScale   string         `db:"size_scale"`
+			Sizes       pq.StringArray `db:"sizes"`
+			RatioSizes  pq.StringArray `db:"ratio_sizes"`
+			RatioValues pq.Int64Array  `db:"ratio_values"`
+		}
+		if err := rows.StructScan(&row); err != nil {
+			return fmt.Errorf("failed to scan plan for export: %w", err)
+		}
+		if len(row.RatioSizes) > 0 {
+			if runPlanID != row.PlanID {
+				run, runPlanID = sizes.LoadRun(row.SizeScale, row.Sizes), row.PlanID
+			}
+			ratios := make([]models.LayoutSizeRatio, len(row.RatioSizes))
+			for i, size := range row.RatioSizes {
+				ratios[i] = models.LayoutSizeRatio{Size: size, Ratio: int(row.RatioValues[i])}
+			}
+			slices.SortStableFunc(ratios, func(a, b models.LayoutSizeRatio) int { return run.Compare(a.Size, b.Size) })
+			parts := make([]string, len(ratios))
+			for i, ratio := range ratios {
+				parts[i] = fmt.Sprintf("%s:%d", ratio.Size, ratio.Ratio)
+			}
+			joined := strings.Join(parts, " ")
+			row.Ratios = &joined
+		}
+		if err := fn(&row.PlanExportRow); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
 }
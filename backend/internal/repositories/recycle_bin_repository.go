@@ -0,0 +1,199 @@
+package repositories
+
+import (
+	"cutrix-backend/internal/models"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// RecycleBinRepository 负责订单、计划、款号和员工的软删除、恢复及回收站查询。
+// entityType 与审计日志的对象类型一致
+type RecycleBinRepository interface {
+	Archive(tx *sqlx.Tx, entityType string, id int, deletedBy *int) error
+	Restore(tx *sqlx.Tx, entityType string, id int) error
+	GetArchived(entityType string, id int) (*models.ArchivedRecord, error)
+	GetArchivedParents(entityType string, id int) ([]models.ArchivedRecord, error)
+	ListArchived(entityType string, q models.ListQuery) ([]models.ArchivedRecord, int, error)
+}
+
+type recycleBinRepository struct {
+	db *sqlx.DB
+}
+
+func NewRecycleBinRepository(db *sqlx.DB) RecycleBinRepository {
+	return &recycleBinRepository{db: db}
+}
+
+// archivedTable 描述一种可软删除的记录。history 判断记录是否有生产记录或仍被其他数据引用，
+// 有则只能恢复、不能彻底删除
+type archivedTable struct {
+	table   string
+	id      string
+	name    string
+	history string
+}
+
+var archivedTables = map[string]archivedTable{
+	"production_order": {
+		table: "Production_Orders", id: "order_id", name: "order_number",
+		history: `EXISTS (SELECT 1 FROM Plan_Orders h WHERE h.order_id = t.order_id)`,
+	},
+	"production_plan": {
+		table: "Production_Plans", id: "plan_id", name: "plan_name",
+		history: `EXISTS (SELECT 1 FROM Production_Logs h
+		                  JOIN Production_Tasks pt ON pt.task_id = h.task_id
+		                  JOIN Cutting_Layouts cl ON cl.layout_id = pt.layout_id
+		                  WHERE cl.plan_id = t.plan_id)`,
+	},
+	"style": {
+		table: "Styles", id: "style_id", name: "style_number",
+		history: `(EXISTS (SELECT 1 FROM Production_Orders h WHERE h.style_id = t.style_id)
+		           OR EXISTS (SELECT 1 FROM Production_Plans h WHERE h.style_id = t.style_id)
+		           OR EXISTS (SELECT 1 FROM Production_Tasks h WHERE h.style_id = t.style_id))`,
+	},
+	"worker": {
+		table: "Workers", id: "worker_id", name: "name",
+		history: `EXISTS (SELECT 1 FROM Production_Logs h WHERE h.worker_id = t.worker_id)`,
+	},
+}
+
+// archivedParent 描述记录依赖的上级记录，ids 查询返回上级记录的 ID
+type archivedParent struct {
+	entityType string
+	ids        string
+}
+
+// archivedParents 恢复记录前必须先恢复的上级记录：订单依赖款号，计划依赖款号和关联的订单
+var archivedParents = map[string][]archivedParent{
+	"production_order": {
+		{entityType: "style", ids: `SELECT style_id FROM Production_Orders WHERE order_id = $1`},
+	},
+	"production_plan": {
+		{entityType: "style", ids: `SELECT style_id FROM Production_Plans WHERE plan_id = $1`},
+		{entityType: "production_order", ids: `SELECT order_id FROM Plan_Orders WHERE plan_id = $1`},
+	},
+}
+
+// archivedEntityTypes 回收站列出全部类型时的顺序
+var archivedEntityTypes = []string{"production_order", "production_plan", "style", "worker"}
+
+func (a archivedTable) selectArchived(entityType string) string {
+	return fmt.Sprintf(`SELECT '%s' AS entity_type, t.%s AS entity_id, t.%s AS name, t.deleted_at, t.deleted_by,
+	               w.name AS deleted_by_name, %s AS has_history
+	        FROM %s t LEFT JOIN Workers w ON w.worker_id = t.deleted_by
+	        WHERE t.deleted_at IS NOT NULL`, entityType, a.id, a.name, a.history, a.table)
+}
+
+func lookupArchivedTable(entityType string) (archivedTable, error) {
+	table, ok := archivedTables[entityType]
+	if !ok {
+		return table, fmt.Errorf("unknown entity type: %s", entityType)
+	}
+	return table, nil
+}
+
+// Archive 软删除一条记录，已删除或不存在时返回 "record not found"
+func (r *recycleBinRepository) Archive(tx *sqlx.Tx, entityType string, id int, deletedBy *int) error {
+	table, err := lookupArchivedTable(entityType)
+	if err != nil {
+		return err
+	}
+	query := fmt.Sprintf(`UPDATE %s SET deleted_at = CURRENT_TIMESTAMP, deleted_by = $2 WHERE %s = $1 AND deleted_at IS NULL`, table.table, table.id)
+	result, err := tx.Exec(query, id, deletedBy)
+	if err != nil {
+		return fmt.Errorf("failed to archive %s: %w", entityType, err)
+	}
+	return expectOneRow(result, "record not found")
+}
+
+// Restore 恢复回收站中的一条记录，记录不在回收站中时返回 "archived record not found"
+func (r *recycleBinRepository) Restore(tx *sqlx.Tx, entityType string, id int) error {
+	table, err := lookupArchivedTable(entityType)
+	if err != nil {
+		return err
+	}
+	query := fmt.Sprintf(`UPDATE %s SET deleted_at = NULL, deleted_by = NULL WHERE %s = $1 AND deleted_at IS NOT NULL`, table.table, table.id)
+	result, err := tx.Exec(query, id)
+	if err != nil {
+		return fmt.Errorf("failed to restore %s: %w", entityType, err)
+	}
+	return expectOneRow(result, "archived record not found")
+}
+
+func (r *recycleBinRepository) GetArchived(entityType string, id int) (*models.ArchivedRecord, error) {
+	table, err := lookupArchivedTable(entityType)
+	if err != nil {
+		return nil, err
+	}
+	var record models.ArchivedRecord
+	query := table.selectArchived(entityType) + fmt.Sprintf(" AND t.%s = $1", table.id)
+	if err := r.db.Get(&record, query, id); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("archived record not found")
+		}
+		return nil, fmt.Errorf("failed to get archived record: %w", err)
+	}
+	return &record, nil
+}
+
+// GetArchivedParents 返回记录依赖的、仍在回收站中的上级记录
+func (r *recycleBinRepository) GetArchivedParents(entityType string, id int) ([]models.ArchivedRecord, error) {
+	parents := []models.ArchivedRecord{}
+	for _, parent := range archivedParents[entityType] {
+		table := archivedTables[parent.entityType]
+		query := table.selectArchived(parent.entityType) + fmt.Sprintf(" AND t.%s IN (%s) ORDER BY t.%s", table.id, parent.ids, table.id)
+		var records []models.ArchivedRecord
+		if err := r.db.Select(&records, query, id); err != nil {
+			return nil, fmt.Errorf("failed to get archived parents: %w", err)
+		}
+		parents = append(parents, records...)
+	}
+	return parents, nil
+}
+
+// archivedSortColumns 是回收站列表允许的排序字段
+var archivedSortColumns = map[string]string{
+	"deleted_at": "r.deleted_at",
+	"name":       "r.name",
+}
+
+// ListArchived 分页列出回收站中的记录，entityType 为空时列出全部类型
+func (r *recycleBinRepository) ListArchived(entityType string, q models.ListQuery) ([]models.ArchivedRecord, int, error) {
+	parts := []string{}
+	for _, t := range archivedEntityTypes {
+		if entityType == "" || entityType == t {
+			parts = append(parts, archivedTables[t].selectArchived(t))
+		}
+	}
+	if len(parts) == 0 {
+		return nil, 0, fmt.Errorf("unknown entity type: %s", entityType)
+	}
+	from := " FROM (" + strings.Join(parts, " UNION ALL ") + ") r"
+
+	var total int
+	if err := r.db.Get(&total, "SELECT COUNT(*)"+from); err != nil {
+		return nil, 0, fmt.Errorf("failed to count archived records: %w", err)
+	}
+
+	clauses, args := listClauses(q, archivedSortColumns, "deleted_at", "(r.entity_type, r.entity_id)", nil)
+	records := []models.ArchivedRecord{}
+	if err := r.db.Select(&records, "SELECT r.*"+from+clauses, args...); err != nil {
+		return nil, 0, fmt.Errorf("failed to list archived records: %w", err)
+	}
+	return records, total, nil
+}
+
+func expectOneRow(result sql.Result, notFound string) error {
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to check rows affected: %w", err)
+	}
+	if rowsAffected == 0 {
+		return errors.New(notFound)
+	}
+	return nil
+}
@@ -0,0 +1,173 @@
+package repositories
+
+import (
+	"database/sql"
+	"fmt"
+
+	"cutrix-backend/internal/models"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+)
+
+type RoleRepository interface {
+	GetAll() ([]*models.Role, error)
+	GetByID(id int) (*models.Role, error)
+	GetByIDInTx(tx *sqlx.Tx, id int) (*models.Role, error)
+	GetByName(name string) (*models.Role, error)
+	LockByName(tx *sqlx.Tx, name string) (*models.Role, error)
+	GetPermissionCodes(roleName string) ([]string, error)
+	GetAllPermissions() ([]models.Permission, error)
+	Create(tx *sqlx.Tx, role *models.Role) error
+	Update(tx *sqlx.Tx, role *models.Role) error
+	SetPermissions(tx *sqlx.Tx, roleID int, permissions []string) error
+	Delete(tx *sqlx.Tx, id int) error
+}
+
+type roleRepository struct {
+	db *sqlx.DB
+}
+
+func NewRoleRepository(db *sqlx.DB) RoleRepository {
+	return &roleRepository{db: db}
+}
+
+const roleQuery = `
+    SELECT r.role_id, r.name, r.display_name, COALESCE(r.description, '') as description, r.is_system, r.max_per_group, r.created_at,
+           COALESCE(ARRAY(SELECT rp.permission_code FROM Role_Permissions rp
+                          WHERE rp.role_id = r.role_id ORDER BY rp.permission_code), '{}') as permissions,
+           (SELECT COUNT(*) FROM Workers w WHERE w.role = r.name) as worker_count
+    FROM Roles r
+`
+
+func (r *roleRepository) GetAll() ([]*models.Role, error) {
+	var roles []*models.Role
+	if err := r.db.Select(&roles, roleQuery+" ORDER BY r.is_system DESC, r.role_id"); err != nil {
+		return nil, fmt.Errorf("failed to get roles: %w", err)
+	}
+	return roles, nil
+}
+
+func (r *roleRepository) GetByID(id int) (*models.Role, error) {
+	return getRole(r.db, roleQuery+" WHERE r.role_id = $1", id)
+}
+
+// GetByIDInTx 在事务中读取角色，可以看到本事务中尚未提交的修改
+func (r *roleRepository) GetByIDInTx(tx *sqlx.Tx, id int) (*models.Role, error) {
+	return getRole(tx, roleQuery+" WHERE r.role_id = $1", id)
+}
+
+func (r *roleRepository) GetByName(name string) (*models.Role, error) {
+	return getRole(r.db, roleQuery+" WHERE r.name = $1", name)
+}
+
+// LockByName 在事务中锁定角色行 (SELECT ... FOR UPDATE)，使同一角色的并发分配按顺序执行，
+// 用于在事务内检查角色人数上限
+func (r *roleRepository) LockByName(tx *sqlx.Tx, name string) (*models.Role, error) {
+	var role models.Role
+	query := `SELECT role_id, name, display_name, COALESCE(description, '') as description, is_system, max_per_group, created_at
+	          FROM Roles WHERE name = $1 FOR UPDATE`
+	if err := tx.Get(&role, query, name); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("role not found")
+		}
+		return nil, fmt.Errorf("failed to lock role: %w", err)
+	}
+	return &role, nil
+}
+
+func getRole(db sqlx.Queryer, query string, arg interface{}) (*models.Role, error) {
+	var role models.Role
+	if err := sqlx.Get(db, &role, query, arg); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("role not found")
+		}
+		return nil, fmt.Errorf("failed to get role: %w", err)
+	}
+	return &role, nil
+}
+
+// GetPermissionCodes 返回指定角色拥有的全部权限代码，角色不存在时返回空列表
+func (r *roleRepository) GetPermissionCodes(roleName string) ([]string, error) {
+	codes := []string{}
+	query := `SELECT rp.permission_code FROM Role_Permissions rp
+	          JOIN Roles r ON rp.role_id = r.role_id
+	          WHERE r.name = $1`
+	if err := r.db.Select(&codes, query, roleName); err != nil {
+		return nil, fmt.Errorf("failed to get role permissions: %w", err)
+	}
+	return codes, nil
+}
+
+func (r *roleRepository) GetAllPermissions() ([]models.Permission, error) {
+	permissions := []models.Permission{}
+	query := `SELECT permission_code, description FROM Permissions ORDER BY permission_code`
+	if err := r.db.Select(&permissions, query); err != nil {
+		return nil, fmt.Errorf("failed to get permissions: %w", err)
+	}
+	return permissions, nil
+}
+
+func (r *roleRepository) Create(tx *sqlx.Tx, role *models.Role) error {
+	query := `INSERT INTO Roles (name, display_name, description, max_per_group) VALUES ($1, $2, $3, $4) RETURNING role_id, created_at`
+	err := tx.QueryRow(query, role.Name, role.DisplayName, role.Description, role.MaxPerGroup).Scan(&role.RoleID, &role.CreatedAt)
+	if err != nil {
+		if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == "23505" {
+			return fmt.Errorf("role name already exists")
+		}
+		return fmt.Errorf("failed to create role: %w", err)
+	}
+	return nil
+}
+
+func (r *roleRepository) Update(tx *sqlx.Tx, role *models.Role) error {
+	query := `UPDATE Roles SET display_name = $1, description = $2, max_per_group = $3 WHERE role_id = $4`
+	result, err := tx.Exec(query, role.DisplayName, role.Description, role.MaxPerGroup, role.RoleID)
+	if err != nil {
+		return fmt.Errorf("failed to update role: %w", err)
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+	if rowsAffected == 0 {
+		return fmt.Errorf("role not found")
+	}
+	return nil
+}
+
+// SetPermissions 用给定的权限列表替换角色的全部权限
+func (r *roleRepository) SetPermissions(tx *sqlx.Tx, roleID int, permissions []string) error {
+	if _, err := tx.Exec(`DELETE FROM Role_Permissions WHERE role_id = $1`, roleID); err != nil {
+		return fmt.Errorf("failed to clear role permissions: %w", err)
+	}
+	if len(permissions) == 0 {
+		return nil
+	}
+	query := `INSERT INTO Role_Permissions (role_id, permission_code) SELECT $1, unnest($2::varchar[])`
+	if _, err := tx.Exec(query, roleID, pq.Array(permissions)); err != nil {
+		if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == "23503" {
+			return fmt.Errorf("unknown permission")
+		}
+		return fmt.Errorf("failed to set role permissions: %w", err)
+	}
+	return nil
+}
+
+func (r *roleRepository) Delete(tx *sqlx.Tx, id int) error {
+	result, err := tx.Exec(`DELETE FROM Roles WHERE role_id = $1`, id)
+	if err != nil {
+		if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == "23503" {
+			return fmt.Errorf("role is still assigned to workers")
+		}
+		return fmt.Errorf("failed to delete role: %w", err)
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+	if rowsAffected == 0 {
+		return fmt.Errorf("role not found")
+	}
+	return nil
+}
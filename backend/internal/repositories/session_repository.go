@@ -0,0 +1,129 @@
+package repositories
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"cutrix-backend/internal/models"
+
+	"github.com/jmoiron/sqlx"
+)
+
+type SessionRepository interface {
+	Create(session *models.Session) error
+	GetByRefreshTokenHash(hash string) (*models.Session, error)
+	GetByPreviousRefreshTokenHash(hash string) (*models.Session, error)
+	IsActive(sessionID int64) (bool, error)
+	Rotate(sessionID int64, oldHash, newHash string, expiresAt, rotatedAt time.Time) error
+	Revoke(sessionID int64) error
+	RevokeAllForWorker(tx *sqlx.Tx, workerID int) error
+}
+
+type sessionRepository struct {
+	db *sqlx.DB
+}
+
+func NewSessionRepository(db *sqlx.DB) SessionRepository {
+	return &sessionRepository{db: db}
+}
+
+const sessionQueryFields = `
+    session_id,
+    worker_id,
+    COALESCE(refresh_token_hash, '') as refresh_token_hash,
+    scope,
+    COALESCE(client_ip, '') as client_ip,
+    COALESCE(user_agent, '') as user_agent,
+    created_at,
+    expires_at,
+    revoked_at,
+    rotated_at
+`
+
+func (r *sessionRepository) Create(session *models.Session) error {
+	query := `INSERT INTO Sessions (worker_id, refresh_token_hash, scope, client_ip, user_agent, expires_at)
+	          VALUES ($1, NULLIF($2, ''), $3, $4, $5, $6) RETURNING session_id, created_at`
+	err := r.db.QueryRow(query, session.WorkerID, session.RefreshTokenHash, session.Scope, session.ClientIP, session.UserAgent, session.ExpiresAt).
+		Scan(&session.SessionID, &session.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to create session: %w", err)
+	}
+	return nil
+}
+
+func (r *sessionRepository) GetByRefreshTokenHash(hash string) (*models.Session, error) {
+	return r.getOne("refresh_token_hash", hash)
+}
+
+// GetByPreviousRefreshTokenHash 查找上一个刷新令牌为 hash 的会话，即该令牌已被轮换
+func (r *sessionRepository) GetByPreviousRefreshTokenHash(hash string) (*models.Session, error) {
+	return r.getOne("previous_refresh_token_hash", hash)
+}
+
+func (r *sessionRepository) getOne(column, hash string) (*models.Session, error) {
+	var session models.Session
+	query := fmt.Sprintf("SELECT %s FROM Sessions WHERE %s = $1", sessionQueryFields, column)
+	err := r.db.Get(&session, query, hash)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("session not found")
+		}
+		return nil, fmt.Errorf("failed to get session: %w", err)
+	}
+	return &session, nil
+}
+
+// IsActive 判断会话是否仍然有效 (未吊销且未过期)
+func (r *sessionRepository) IsActive(sessionID int64) (bool, error) {
+	var active bool
+	query := `SELECT EXISTS(
+	              SELECT 1 FROM Sessions
+	              WHERE session_id = $1 AND revoked_at IS NULL AND expires_at > CURRENT_TIMESTAMP
+	          )`
+	if err := r.db.Get(&active, query, sessionID); err != nil {
+		return false, fmt.Errorf("failed to check session: %w", err)
+	}
+	return active, nil
+}
+
+// ErrRefreshTokenReused 表示刷新令牌已被其他请求轮换或会话已被吊销
+var ErrRefreshTokenReused = errors.New("refresh token already rotated or session revoked")
+
+// Rotate 仅当会话的刷新令牌仍为 oldHash 时替换为 newHash 并延长有效期，旧的刷新令牌随即失效，
+// 其哈希保存为 previous_refresh_token_hash。并发请求使用同一个刷新令牌时只有一个能成功，其余返回 ErrRefreshTokenReused
+func (r *sessionRepository) Rotate(sessionID int64, oldHash, newHash string, expiresAt, rotatedAt time.Time) error {
+	query := `UPDATE Sessions SET previous_refresh_token_hash = refresh_token_hash, refresh_token_hash = $1, expires_at = $2, rotated_at = $3
+	          WHERE session_id = $4 AND refresh_token_hash = $5 AND revoked_at IS NULL`
+	result, err := r.db.Exec(query, newHash, expiresAt, rotatedAt, sessionID, oldHash)
+	if err != nil {
+		return fmt.Errorf("failed to rotate session: %w", err)
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+	if rowsAffected == 0 {
+		return ErrRefreshTokenReused
+	}
+	return nil
+}
+
+func (r *sessionRepository) Revoke(sessionID int64) error {
+	query := `UPDATE Sessions SET revoked_at = CURRENT_TIMESTAMP WHERE session_id = $1 AND revoked_at IS NULL`
+	if _, err := r.db.Exec(query, sessionID); err != nil {
+		return fmt.Errorf("failed to revoke session: %w", err)
+	}
+	return nil
+}
+
+// RevokeAllForWorker 在事务中吊销员工的全部会话 (禁用、删除账户或修改登录凭证时调用)，
+// 与引起吊销的修改一起提交
+func (r *sessionRepository) RevokeAllForWorker(tx *sqlx.Tx, workerID int) error {
+	query := `UPDATE Sessions SET revoked_at = CURRENT_TIMESTAMP WHERE worker_id = $1 AND revoked_at IS NULL`
+	if _, err := tx.Exec(query, workerID); err != nil {
+		return fmt.Errorf("failed to revoke sessions: %w", err)
+	}
+	return nil
+}
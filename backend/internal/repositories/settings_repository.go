@@ -0,0 +1,56 @@
+package repositories
+
+import (
+	"database/sql"
+	"fmt"
+
+	"cutrix-backend/internal/models"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// 系统设置项
+const (
+	SettingOrderNumberTemplate = "order_number_template"
+)
+
+type SettingsRepository interface {
+	Get(key string) (*models.SystemSetting, error)
+	Set(tx *sqlx.Tx, key, value string, updatedBy *int) (*models.SystemSetting, error)
+}
+
+type settingsRepository struct {
+	db *sqlx.DB
+}
+
+func NewSettingsRepository(db *sqlx.DB) SettingsRepository {
+	return &settingsRepository{db: db}
+}
+
+const settingQueryFields = `setting_id, setting_key, setting_value, updated_by, updated_at`
+
+func (r *settingsRepository) Get(key string) (*models.SystemSetting, error) {
+	var setting models.SystemSetting
+	err := r.db.Get(&setting, `SELECT `+settingQueryFields+` FROM System_Settings WHERE setting_key = $1`, key)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("setting not found")
+		}
+		return nil, fmt.Errorf("failed to get setting: %w", err)
+	}
+	return &setting, nil
+}
+
+func (r *settingsRepository) Set(tx *sqlx.Tx, key, value string, updatedBy *int) (*models.SystemSetting, error) {
+	query := `
+        INSERT INTO System_Settings (setting_key, setting_value, updated_by)
+        VALUES ($1, $2, $3)
+        ON CONFLICT (setting_key) DO UPDATE
+        SET setting_value = EXCLUDED.setting_value, updated_by = EXCLUDED.updated_by, updated_at = CURRENT_TIMESTAMP
+        RETURNING ` + settingQueryFields
+	var setting models.SystemSetting
+	if err := tx.Get(&setting, query, key, value, updatedBy); err != nil {
+		return nil, fmt.Errorf("failed to save setting: %w", err)
+	}
+	return &setting, nil
+}
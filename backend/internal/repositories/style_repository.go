@@ -2,21 +2,41 @@ package repositories
 
 import (
 	"database/sql"
+	"errors"
 	"fmt"
 
 	"cutrix-backend/internal/models"
+	"cutrix-backend/pkg/sizes"
 
 	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
 )
 
 type StyleRepository interface {
-	Create(style *models.Style) error
-	CreateInTx(tx *sqlx.Tx, style *models.Style) error // <-- 新增
+	Create(tx *sqlx.Tx, style *models.Style) error
+	GetOrCreateInTx(tx *sqlx.Tx, styleNumber string, customerID *int) (*models.Style, bool, error)
 	GetByID(id int) (*models.Style, error)
+	GetByIDInTx(tx *sqlx.Tx, id int) (*models.Style, error)
 	GetByNumber(number string) (*models.Style, error)
 	GetAll() ([]*models.Style, error)
+	Update(tx *sqlx.Tx, style *models.Style) error
+	HasActiveOrdersOrPlans(id int) (bool, error)
+	GetUsedColorsAndSizes(id int) (colors, sizes []string, err error)
+	Delete(tx *sqlx.Tx, id int) error
 }
 
+// ErrStyleNumberTaken 表示款号已被其他款号 (包括回收站中的款号) 占用
+var ErrStyleNumberTaken = errors.New("style number already exists")
+
+// ErrStyleArchived 表示款号在回收站中
+var ErrStyleArchived = errors.New("style is in the recycle bin")
+
+// styleColumns 是查询款号时的列，customer_name 来自关联的客户
+const styleColumns = `s.style_id, s.style_number, s.description, s.season, s.customer_id, c.name AS customer_name,
+	s.size_scale, s.sizes, s.colors, s.main_fabric, s.created_at, s.updated_at`
+
+const styleFrom = ` FROM Styles s LEFT JOIN Customers c ON c.customer_id = s.customer_id`
+
 type styleRepository struct {
 	db *sqlx.DB
 }
@@ -25,22 +45,66 @@ func NewStyleRepository(db *sqlx.DB) StyleRepository {
 	return &styleRepository{db: db}
 }
 
-func (r *styleRepository) Create(style *models.Style) error {
-	query := `INSERT INTO Styles (style_number) VALUES ($1) RETURNING style_id`
+// Create 插入款号主数据，客户不存在时返回 "customer not found"
+func (r *styleRepository) Create(tx *sqlx.Tx, style *models.Style) error {
+	query := `INSERT INTO Styles (style_number, description, season, customer_id, size_scale, sizes, colors, main_fabric)
+	          VALUES ($1, $2, $3, $4, $5, $6, $7, $8) RETURNING style_id, created_at, updated_at`
 
-	err := r.db.QueryRow(query, style.StyleNumber).Scan(&style.StyleID)
+	err := tx.QueryRow(query, style.StyleNumber, style.Description, style.Season, style.CustomerID,
+		style.SizeScale, style.Sizes, style.Colors, style.MainFabric).Scan(&style.StyleID, &style.CreatedAt, &style.UpdatedAt)
 	if err != nil {
-		return fmt.Errorf("failed to create style: %w", err)
+		return styleWriteError(err, "failed to create style")
 	}
 
 	return nil
 }
 
+// Update 修改款号主数据，客户不存在时返回 "customer not found"
+func (r *styleRepository) Update(tx *sqlx.Tx, style *models.Style) error {
+	query := `UPDATE Styles SET style_number = $1, description = $2, season = $3, customer_id = $4, size_scale = $5, sizes = $6,
+	              colors = $7, main_fabric = $8, updated_at = CURRENT_TIMESTAMP
+	          WHERE style_id = $9 AND deleted_at IS NULL`
+	result, err := tx.Exec(query, style.StyleNumber, style.Description, style.Season, style.CustomerID,
+		style.SizeScale, style.Sizes, style.Colors, style.MainFabric, style.StyleID)
+	if err != nil {
+		return styleWriteError(err, "failed to update style")
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+	if rowsAffected == 0 {
+		return fmt.Errorf("style not found")
+	}
+	return nil
+}
+
+func styleWriteError(err error, message string) error {
+	if pqErr, ok := err.(*pq.Error); ok {
+		switch pqErr.Code {
+		case "23505":
+			return ErrStyleNumberTaken
+		case "23503":
+			return fmt.Errorf("customer not found")
+		}
+	}
+	return fmt.Errorf("%s: %w", message, err)
+}
+
 func (r *styleRepository) GetByID(id int) (*models.Style, error) {
+	return queryStyleByID(r.db, id)
+}
+
+// GetByIDInTx 在事务中读取款号，可以看到本事务中尚未提交的修改
+func (r *styleRepository) GetByIDInTx(tx *sqlx.Tx, id int) (*models.Style, error) {
+	return queryStyleByID(tx, id)
+}
+
+func queryStyleByID(db sqlx.Queryer, id int) (*models.Style, error) {
 	var style models.Style
-	query := `SELECT style_id, style_number FROM Styles WHERE style_id = $1`
+	query := `SELECT ` + styleColumns + styleFrom + ` WHERE s.style_id = $1 AND s.deleted_at IS NULL`
 
-	err := r.db.Get(&style, query, id)
+	err := sqlx.Get(db, &style, query, id)
 	if err != nil {
 		if err == sql.ErrNoRows {
 			return nil, fmt.Errorf("style not found")
@@ -53,7 +117,7 @@ func (r *styleRepository) GetByID(id int) (*models.Style, error) {
 
 func (r *styleRepository) GetByNumber(number string) (*models.Style, error) {
 	var style models.Style
-	query := `SELECT style_id, style_number FROM Styles WHERE style_number = $1`
+	query := `SELECT ` + styleColumns + styleFrom + ` WHERE s.style_number = $1 AND s.deleted_at IS NULL`
 
 	err := r.db.Get(&style, query, number)
 	if err != nil {
@@ -68,7 +132,7 @@ func (r *styleRepository) GetByNumber(number string) (*models.Style, error) {
 
 func (r *styleRepository) GetAll() ([]*models.Style, error) {
 	var styles []*models.Style
-	query := `SELECT style_id, style_number FROM Styles ORDER BY style_id`
+	query := `SELECT ` + styleColumns + styleFrom + ` WHERE s.deleted_at IS NULL ORDER BY s.style_id`
 
 	err := r.db.Select(&styles, query)
 	if err != nil {
@@ -78,12 +142,99 @@ func (r *styleRepository) GetAll() ([]*models.Style, error) {
 	return styles, nil
 }
 
-// CreateInTx 在一个事务中创建款号
-func (r *styleRepository) CreateInTx(tx *sqlx.Tx, style *models.Style) error {
-	query := `INSERT INTO Styles (style_number) VALUES ($1) RETURNING style_id`
-	err := tx.QueryRow(query, style.StyleNumber).Scan(&style.StyleID)
+// GetOrCreateInTx 在事务中按款号获取款号，不存在时只写入款号和客户新建 (下单时自动创建款号使用)，返回的 bool 表示是否为新建。
+// 并发创建同一款号时插入会等待另一个事务结束，随后读到它创建的款号；款号在回收站中时返回 ErrStyleArchived
+func (r *styleRepository) GetOrCreateInTx(tx *sqlx.Tx, styleNumber string, customerID *int) (*models.Style, bool, error) {
+	var styleID int
+	err := tx.Get(&styleID, `INSERT INTO Styles (style_number, customer_id) VALUES ($1, $2)
+	                         ON CONFLICT (style_number) DO NOTHING RETURNING style_id`, styleNumber, customerID)
+	created := err == nil
+	if err != nil && err != sql.ErrNoRows {
+		return nil, false, fmt.Errorf("failed to create style in tx: %w", err)
+	}
+
+	var row struct {
+		models.Style
+		Archived bool `db:"archived"`
+	}
+	query := `SELECT ` + styleColumns + `, s.deleted_at IS NOT NULL AS archived` + styleFrom + ` WHERE s.style_number = $1`
+	if err := tx.Get(&row, query, styleNumber); err != nil {
+		return nil, false, fmt.Errorf("failed to get style in tx: %w", err)
+	}
+	if row.Archived {
+		return nil, false, ErrStyleArchived
+	}
+	return &row.Style, created, nil
+}
+
+// HasActiveOrdersOrPlans 判断款号是否还有未删除的订单或生产计划
+func (r *styleRepository) HasActiveOrdersOrPlans(id int) (bool, error) {
+	var exists bool
+	query := `SELECT EXISTS (SELECT 1 FROM Production_Orders WHERE style_id = $1 AND deleted_at IS NULL)
+	              OR EXISTS (SELECT 1 FROM Production_Plans WHERE style_id = $1 AND deleted_at IS NULL)`
+	if err := r.db.Get(&exists, query, id); err != nil {
+		return false, fmt.Errorf("failed to check style usage: %w", err)
+	}
+	return exists, nil
+}
+
+// GetUsedColorsAndSizes 返回款号未删除的订单明细和生产计划中用到的颜色和尺码
+func (r *styleRepository) GetUsedColorsAndSizes(id int) (colors, sizes []string, err error) {
+	colorQuery := `SELECT oi.color FROM Order_Items oi
+	               JOIN Production_Orders po ON po.order_id = oi.order_id
+	               WHERE po.style_id = $1 AND po.deleted_at IS NULL
+	               UNION
+	               SELECT pt.color FROM Production_Tasks pt
+	               JOIN Cutting_Layouts cl ON cl.layout_id = pt.layout_id
+	               JOIN Production_Plans pp ON pp.plan_id = cl.plan_id
+	               WHERE pp.style_id = $1 AND pp.deleted_at IS NULL
+	               ORDER BY 1`
+	if err := r.db.Select(&colors, colorQuery, id); err != nil {
+		return nil, nil, fmt.Errorf("failed to get used colors: %w", err)
+	}
+	sizeQuery := `SELECT oi.size FROM Order_Items oi
+	              JOIN Production_Orders po ON po.order_id = oi.order_id
+	              WHERE po.style_id = $1 AND po.deleted_at IS NULL
+	              UNION
+	              SELECT lsr.size FROM Layout_Size_Ratios lsr
+	              JOIN Cutting_Layouts cl ON cl.layout_id = lsr.layout_id
+	              JOIN Production_Plans pp ON pp.plan_id = cl.plan_id
+	              WHERE pp.style_id = $1 AND pp.deleted_at IS NULL
+	              ORDER BY 1`
+	if err := r.db.Select(&sizes, sizeQuery, id); err != nil {
+		return nil, nil, fmt.Errorf("failed to get used sizes: %w", err)
+	}
+	return colors, sizes, nil
+}
+
+// Delete 彻底删除款号，仍被订单、计划或任务引用时返回 "style is still referenced"
+func (r *styleRepository) Delete(tx *sqlx.Tx, id int) error {
+	result, err := tx.Exec(`DELETE FROM Styles WHERE style_id = $1`, id)
+	if err != nil {
+		if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == "23503" {
+			return fmt.Errorf("style is still referenced")
+		}
+		return fmt.Errorf("failed to delete style: %w", err)
+	}
+	rowsAffected, err := result.RowsAffected()
 	if err != nil {
-		return fmt.Errorf("failed to create style in tx: %w", err)
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+	if rowsAffected == 0 {
+		return fmt.Errorf("style not found")
 	}
 	return nil
 }
+
+// queryStyleRun 按 query 查出款号的尺码体系和尺码组 (query 返回 size_scale, sizes 两列)，
+// 用于按尺码组的顺序排列明细。查不到款号时按通用顺序排列
+func queryStyleRun(db sqlx.Queryer, query string, args ...interface{}) (*sizes.Run, error) {
+	var row struct {
+		SizeScale string         `db:"size_scale"`
+		Sizes     pq.StringArray `db:"sizes"`
+	}
+	if err := sqlx.Get(db, &row, query, args...); err != nil && err != sql.ErrNoRows {
+		return nil, fmt.Errorf("failed to get style sizes: %w", err)
+	}
+	return sizes.LoadRun(row.SizeScale, row.Sizes), nil
+}
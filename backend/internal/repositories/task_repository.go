@@ -11,10 +11,15 @@ import (
 type TaskRepository interface {
 	GetByID(id int) (*models.ProductionTask, error)
 	GetByStyleID(styleID int) ([]*models.ProductionTask, error)
-	GetAll() ([]*models.ProductionTask, error)
+	GetAll(filter *models.TaskFilter) ([]*models.ProductionTask, int, error)
 	GetProgress() ([]*models.TaskProgress, error)
 }
 
+// activeTaskFilter 排除属于已删除计划的任务，格式化参数为任务表的别名
+const activeTaskFilter = `NOT EXISTS (SELECT 1 FROM Cutting_Layouts acl
+	          JOIN Production_Plans app ON app.plan_id = acl.plan_id
+	          WHERE acl.layout_id = %[1]s.layout_id AND app.deleted_at IS NOT NULL)`
+
 type taskRepository struct {
 	db *sqlx.DB
 }
@@ -26,7 +31,7 @@ func NewTaskRepository(db *sqlx.DB) TaskRepository {
 func (r *taskRepository) GetByID(id int) (*models.ProductionTask, error) {
 	var task models.ProductionTask
 	query := `SELECT task_id, style_id, layout_id, layout_name, color, planned_layers, completed_layers 
-	          FROM Production_Tasks WHERE task_id = $1`
+	          FROM Production_Tasks pt WHERE task_id = $1 AND ` + fmt.Sprintf(activeTaskFilter, "pt")
 
 	err := r.db.Get(&task, query, id)
 	if err != nil {
@@ -42,7 +47,7 @@ func (r *taskRepository) GetByID(id int) (*models.ProductionTask, error) {
 func (r *taskRepository) GetByStyleID(styleID int) ([]*models.ProductionTask, error) {
 	var tasks []*models.ProductionTask
 	query := `SELECT task_id, style_id, layout_id, layout_name, color, planned_layers, completed_layers 
-	          FROM Production_Tasks WHERE style_id = $1 ORDER BY task_id`
+	          FROM Production_Tasks pt WHERE style_id = $1 AND ` + fmt.Sprintf(activeTaskFilter, "pt") + ` ORDER BY task_id`
 
 	err := r.db.Select(&tasks, query, styleID)
 	if err != nil {
@@ -52,17 +57,57 @@ func (r *taskRepository) GetByStyleID(styleID int) ([]*models.ProductionTask, er
 	return tasks, nil
 }
 
-func (r *taskRepository) GetAll() ([]*models.ProductionTask, error) {
-	var tasks []*models.ProductionTask
-	query := `SELECT task_id, style_id, layout_id, layout_name, color, planned_layers, completed_layers 
-	          FROM Production_Tasks ORDER BY task_id`
+// taskSortColumns 是任务列表允许的排序字段，progress 为完成层数占计划层数的比例
+var taskSortColumns = map[string]string{
+	"task_id":        "pt.task_id",
+	"color":          "pt.color",
+	"planned_layers": "pt.planned_layers",
+	"progress":       "pt.completed_layers::FLOAT / NULLIF(pt.planned_layers, 0)",
+}
 
-	err := r.db.Select(&tasks, query)
-	if err != nil {
-		return nil, fmt.Errorf("failed to get production tasks: %w", err)
+// GetAll 按过滤条件分页查询任务，同时返回满足条件的总数
+func (r *taskRepository) GetAll(filter *models.TaskFilter) ([]*models.ProductionTask, int, error) {
+	from := `
+	          FROM Production_Tasks pt
+	          LEFT JOIN Styles s ON s.style_id = pt.style_id
+	          LEFT JOIN Cutting_Layouts cl ON cl.layout_id = pt.layout_id
+	          LEFT JOIN Production_Plans pp ON pp.plan_id = cl.plan_id
+	          WHERE pp.deleted_at IS NULL`
+	args := []interface{}{}
+
+	if filter.StyleNumber != "" {
+		args = append(args, "%"+filter.StyleNumber+"%")
+		from += fmt.Sprintf(" AND s.style_number ILIKE $%d", len(args))
+	}
+	if filter.PlanID != nil {
+		args = append(args, *filter.PlanID)
+		from += fmt.Sprintf(" AND cl.plan_id = $%d", len(args))
+	}
+	if filter.Color != "" {
+		args = append(args, filter.Color)
+		from += fmt.Sprintf(" AND pt.color = $%d", len(args))
+	}
+	switch filter.Status {
+	case models.TaskStatusPending:
+		from += " AND pt.completed_layers = 0"
+	case models.TaskStatusInProgress:
+		from += " AND pt.completed_layers > 0 AND pt.completed_layers < pt.planned_layers"
+	case models.TaskStatusCompleted:
+		from += " AND pt.completed_layers >= pt.planned_layers"
 	}
 
-	return tasks, nil
+	var total int
+	if err := r.db.Get(&total, "SELECT COUNT(*)"+from, args...); err != nil {
+		return nil, 0, fmt.Errorf("failed to count production tasks: %w", err)
+	}
+
+	clauses, args := listClauses(filter.ListQuery, taskSortColumns, "task_id", "pt.task_id", args)
+	query := `SELECT pt.task_id, pt.style_id, pt.layout_id, pt.layout_name, pt.color, pt.planned_layers, pt.completed_layers` + from + clauses
+	tasks := []*models.ProductionTask{}
+	if err := r.db.Select(&tasks, query, args...); err != nil {
+		return nil, 0, fmt.Errorf("failed to get production tasks: %w", err)
+	}
+	return tasks, total, nil
 }
 
 func (r *taskRepository) GetProgress() ([]*models.TaskProgress, error) {
@@ -72,7 +117,8 @@ func (r *taskRepository) GetProgress() ([]*models.TaskProgress, error) {
 	                     WHEN planned_layers = 0 THEN 0
 	                     ELSE ROUND((completed_layers::FLOAT / planned_layers::FLOAT) * 100, 2)
 	                 END as progress
-	          FROM Production_Tasks 
+	          FROM Production_Tasks pt
+	          WHERE ` + fmt.Sprintf(activeTaskFilter, "pt") + `
 	          ORDER BY task_id`
 
 	err := r.db.Select(&progress, query)
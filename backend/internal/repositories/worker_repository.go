@@ -2,23 +2,33 @@ package repositories
 
 import (
 	"database/sql"
+	"errors"
 	"fmt"
+	"time"
 
 	"cutrix-backend/internal/models"
 
 	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
 )
 
 type WorkerRepository interface {
 	GetByID(id int) (*models.Worker, error)
+	GetByIDInTx(tx *sqlx.Tx, id int) (*models.Worker, error)
 	GetByName(name string) (*models.Worker, error)
-	GetAll() ([]*models.Worker, error)
-	Create(worker *models.CreateWorkerRequest) (*models.Worker, error)
-	Update(id int, worker *models.UpdateWorkerRequest) (*models.Worker, error)
-	Delete(id int) error
+	GetAll(filter *models.WorkerFilter) ([]*models.Worker, int, error)
+	Create(tx *sqlx.Tx, worker *models.CreateWorkerRequest) (*models.Worker, error)
+	Update(tx *sqlx.Tx, id int, worker *models.UpdateWorkerRequest) (*models.Worker, error)
+	CountActiveByRoleAndGroup(tx *sqlx.Tx, role string, workerGroup *string, excludeWorkerID int) (int, error)
+	Delete(tx *sqlx.Tx, id int) error
 	GetWorkerTasks(workerID int) ([]*models.ProductionTask, error)
 	GetWorkerLogs(workerID int) ([]*models.ProductionLog, error)
-	UpdatePassword(id int, passwordHash string) error
+	UpdatePassword(tx *sqlx.Tx, id int, passwordHash string) error
+	RecordLoginFailure(id int, maxAttempts int, lockout time.Duration) (*models.Worker, error)
+	ResetLoginFailures(tx *sqlx.Tx, id int) error
+	GetByBadgeHash(badgeHash string) (*models.Worker, error)
+	UpdatePin(tx *sqlx.Tx, id int, pinHash *string) error
+	UpdateBadge(tx *sqlx.Tx, id int, badgeHash *string) error
 	GetWorkerTaskGroups(workerID int) ([]models.WorkerTaskGroup, error)
 }
 
@@ -38,14 +48,28 @@ const workerQueryFields = `
     role, 
     is_active, 
     COALESCE(notes, '') as notes,
-    worker_group
+    worker_group,
+    failed_login_attempts,
+    locked_until,
+    COALESCE(pin_hash, '') as pin_hash,
+    pin_hash IS NOT NULL as has_pin,
+    badge_code_hash IS NOT NULL as has_badge
 `
 
 func (r *workerRepository) GetByID(id int) (*models.Worker, error) {
+	return queryWorkerByID(r.db, id)
+}
+
+// GetByIDInTx 在事务中读取员工，可以看到本事务中尚未提交的修改 (例如刚从回收站恢复的员工)
+func (r *workerRepository) GetByIDInTx(tx *sqlx.Tx, id int) (*models.Worker, error) {
+	return queryWorkerByID(tx, id)
+}
+
+func queryWorkerByID(db sqlx.Queryer, id int) (*models.Worker, error) {
 	var worker models.Worker
-	query := fmt.Sprintf("SELECT %s FROM Workers WHERE worker_id = $1", workerQueryFields)
+	query := fmt.Sprintf("SELECT %s FROM Workers WHERE worker_id = $1 AND deleted_at IS NULL", workerQueryFields)
 
-	err := r.db.Get(&worker, query, id)
+	err := sqlx.Get(db, &worker, query, id)
 	if err != nil {
 		if err == sql.ErrNoRows {
 			return nil, fmt.Errorf("worker not found")
@@ -58,7 +82,7 @@ func (r *workerRepository) GetByID(id int) (*models.Worker, error) {
 
 func (r *workerRepository) GetByName(name string) (*models.Worker, error) {
 	var worker models.Worker
-	query := fmt.Sprintf("SELECT %s FROM Workers WHERE name = $1", workerQueryFields)
+	query := fmt.Sprintf("SELECT %s FROM Workers WHERE name = $1 AND deleted_at IS NULL", workerQueryFields)
 
 	err := r.db.Get(&worker, query, name)
 	if err != nil {
@@ -71,55 +95,113 @@ func (r *workerRepository) GetByName(name string) (*models.Worker, error) {
 	return &worker, nil
 }
 
-func (r *workerRepository) GetAll() ([]*models.Worker, error) {
-	var workers []*models.Worker
-	query := fmt.Sprintf("SELECT %s FROM Workers ORDER BY worker_id", workerQueryFields)
+// workerSortColumns 是员工列表允许的排序字段
+var workerSortColumns = map[string]string{
+	"worker_id":    "worker_id",
+	"name":         "name",
+	"worker_group": "worker_group",
+	"role":         "role",
+}
+
+// GetAll 按过滤条件分页查询员工，同时返回满足条件的总数
+func (r *workerRepository) GetAll(filter *models.WorkerFilter) ([]*models.Worker, int, error) {
+	where := " WHERE deleted_at IS NULL"
+	args := []interface{}{}
 
-	err := r.db.Select(&workers, query)
-	if err != nil {
-		return nil, fmt.Errorf("failed to get workers: %w", err)
+	if filter.Search != "" {
+		args = append(args, "%"+filter.Search+"%")
+		where += fmt.Sprintf(" AND name ILIKE $%d", len(args))
+	}
+	if filter.WorkerGroup != "" {
+		args = append(args, filter.WorkerGroup)
+		where += fmt.Sprintf(" AND worker_group = $%d", len(args))
+	}
+	if filter.Role != "" {
+		args = append(args, filter.Role)
+		where += fmt.Sprintf(" AND role = $%d", len(args))
+	}
+	if filter.IsActive != nil {
+		args = append(args, *filter.IsActive)
+		where += fmt.Sprintf(" AND is_active = $%d", len(args))
 	}
 
-	return workers, nil
+	var total int
+	if err := r.db.Get(&total, "SELECT COUNT(*) FROM Workers"+where, args...); err != nil {
+		return nil, 0, fmt.Errorf("failed to count workers: %w", err)
+	}
+
+	clauses, args := listClauses(filter.ListQuery, workerSortColumns, "worker_id", "worker_id", args)
+	query := fmt.Sprintf("SELECT %s FROM Workers", workerQueryFields) + where + clauses
+	workers := []*models.Worker{}
+	if err := r.db.Select(&workers, query, args...); err != nil {
+		return nil, 0, fmt.Errorf("failed to get workers: %w", err)
+	}
+	return workers, total, nil
 }
 
-func (r *workerRepository) Create(workerReq *models.CreateWorkerRequest) (*models.Worker, error) {
+func (r *workerRepository) Create(tx *sqlx.Tx, workerReq *models.CreateWorkerRequest) (*models.Worker, error) {
 	var worker models.Worker
 	query := `
         INSERT INTO Workers (name, notes, role, is_active, worker_group) 
         VALUES ($1, $2, $3, $4, $5) 
         RETURNING ` + workerQueryFields
-	err := r.db.QueryRowx(query, workerReq.Name, workerReq.Notes, workerReq.Role, workerReq.IsActive, workerReq.WorkerGroup).StructScan(&worker)
+	err := tx.QueryRowx(query, workerReq.Name, workerReq.Notes, workerReq.Role, workerReq.IsActive, workerReq.WorkerGroup).StructScan(&worker)
 	if err != nil {
+		if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == "23505" {
+			return nil, ErrWorkerNameTaken
+		}
 		return nil, fmt.Errorf("failed to create worker: %w", err)
 	}
 	return &worker, nil
 }
 
-func (r *workerRepository) Update(id int, workerReq *models.UpdateWorkerRequest) (*models.Worker, error) {
+func (r *workerRepository) Update(tx *sqlx.Tx, id int, workerReq *models.UpdateWorkerRequest) (*models.Worker, error) {
 	var worker models.Worker
 	query := `
         UPDATE Workers 
         SET name = $1, notes = $2, role = $3, is_active = $4, worker_group = $5
-        WHERE worker_id = $6 
+        WHERE worker_id = $6 AND deleted_at IS NULL
         RETURNING ` + workerQueryFields
 
-	err := r.db.Get(&worker, query, workerReq.Name, workerReq.Notes, workerReq.Role, workerReq.IsActive, workerReq.WorkerGroup, id)
+	err := tx.Get(&worker, query, workerReq.Name, workerReq.Notes, workerReq.Role, workerReq.IsActive, workerReq.WorkerGroup, id)
 	if err != nil {
 		if err == sql.ErrNoRows {
 			return nil, fmt.Errorf("worker not found")
 		}
+		if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == "23505" {
+			return nil, ErrWorkerNameTaken
+		}
 		return nil, fmt.Errorf("failed to update worker: %w", err)
 	}
 
 	return &worker, nil
 }
 
-func (r *workerRepository) Delete(id int) error {
+// CountActiveByRoleAndGroup 统计某个班组内担任指定角色的在职员工人数 (workerGroup 为 nil 表示未分组)，
+// excludeWorkerID 用于更新时排除员工本人
+func (r *workerRepository) CountActiveByRoleAndGroup(tx *sqlx.Tx, role string, workerGroup *string, excludeWorkerID int) (int, error) {
+	var count int
+	query := `SELECT COUNT(*) FROM Workers
+	          WHERE role = $1 AND NULLIF(worker_group, '') IS NOT DISTINCT FROM NULLIF($2, '')
+	            AND is_active = true AND deleted_at IS NULL AND worker_id <> $3`
+	if err := tx.Get(&count, query, role, workerGroup, excludeWorkerID); err != nil {
+		return 0, fmt.Errorf("failed to count workers by role and group: %w", err)
+	}
+	return count, nil
+}
+
+// ErrWorkerNameTaken 表示员工姓名已被其他员工 (包括回收站中的员工) 占用
+var ErrWorkerNameTaken = errors.New("worker name already exists")
+
+// Delete 彻底删除员工，仍被生产记录等数据引用时返回 "worker is still referenced"
+func (r *workerRepository) Delete(tx *sqlx.Tx, id int) error {
 	query := `DELETE FROM Workers WHERE worker_id = $1`
 
-	result, err := r.db.Exec(query, id)
+	result, err := tx.Exec(query, id)
 	if err != nil {
+		if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == "23503" {
+			return fmt.Errorf("worker is still referenced")
+		}
 		return fmt.Errorf("failed to delete worker: %w", err)
 	}
 
@@ -165,9 +247,9 @@ func (r *workerRepository) GetWorkerLogs(workerID int) ([]*models.ProductionLog,
 	return logs, nil
 }
 
-func (r *workerRepository) UpdatePassword(id int, passwordHash string) error {
+func (r *workerRepository) UpdatePassword(tx *sqlx.Tx, id int, passwordHash string) error {
 	query := `UPDATE Workers SET password_hash = $1 WHERE worker_id = $2`
-	result, err := r.db.Exec(query, passwordHash, id)
+	result, err := tx.Exec(query, passwordHash, id)
 	if err != nil {
 		return fmt.Errorf("failed to update password: %w", err)
 	}
@@ -181,6 +263,92 @@ func (r *workerRepository) UpdatePassword(id int, passwordHash string) error {
 	return nil
 }
 
+// RecordLoginFailure 累加员工的登录失败次数，达到 maxAttempts 时锁定账户 lockout 时长并重新计数
+func (r *workerRepository) RecordLoginFailure(id int, maxAttempts int, lockout time.Duration) (*models.Worker, error) {
+	var worker models.Worker
+	query := `
+        UPDATE Workers
+        SET failed_login_attempts = CASE WHEN failed_login_attempts + 1 >= $2 THEN 0 ELSE failed_login_attempts + 1 END,
+            locked_until = CASE WHEN failed_login_attempts + 1 >= $2
+                                THEN CURRENT_TIMESTAMP + make_interval(secs => $3)
+                                ELSE locked_until END
+        WHERE worker_id = $1
+        RETURNING ` + workerQueryFields
+
+	err := r.db.Get(&worker, query, id, maxAttempts, lockout.Seconds())
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("worker not found")
+		}
+		return nil, fmt.Errorf("failed to record login failure: %w", err)
+	}
+	return &worker, nil
+}
+
+// ResetLoginFailures 清除员工的登录失败计数并解除锁定
+func (r *workerRepository) ResetLoginFailures(tx *sqlx.Tx, id int) error {
+	query := `UPDATE Workers SET failed_login_attempts = 0, locked_until = NULL WHERE worker_id = $1`
+	result, err := tx.Exec(query, id)
+	if err != nil {
+		return fmt.Errorf("failed to reset login failures: %w", err)
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+	if rowsAffected == 0 {
+		return fmt.Errorf("worker not found")
+	}
+	return nil
+}
+
+func (r *workerRepository) GetByBadgeHash(badgeHash string) (*models.Worker, error) {
+	var worker models.Worker
+	query := fmt.Sprintf("SELECT %s FROM Workers WHERE badge_code_hash = $1 AND deleted_at IS NULL", workerQueryFields)
+
+	err := r.db.Get(&worker, query, badgeHash)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("worker not found")
+		}
+		return nil, fmt.Errorf("failed to get worker by badge: %w", err)
+	}
+
+	return &worker, nil
+}
+
+// UpdatePin 设置或清除 (pinHash 为 nil) 员工的PIN
+func (r *workerRepository) UpdatePin(tx *sqlx.Tx, id int, pinHash *string) error {
+	return updateCredential(tx, `UPDATE Workers SET pin_hash = $1 WHERE worker_id = $2`, pinHash, id)
+}
+
+// ErrBadgeAlreadyAssigned 表示工牌码已绑定到其他员工
+var ErrBadgeAlreadyAssigned = errors.New("badge code already assigned")
+
+// UpdateBadge 设置或清除 (badgeHash 为 nil) 员工的工牌码
+func (r *workerRepository) UpdateBadge(tx *sqlx.Tx, id int, badgeHash *string) error {
+	err := updateCredential(tx, `UPDATE Workers SET badge_code_hash = $1 WHERE worker_id = $2`, badgeHash, id)
+	if pqErr, ok := errors.Unwrap(err).(*pq.Error); ok && pqErr.Code == "23505" {
+		return ErrBadgeAlreadyAssigned
+	}
+	return err
+}
+
+func updateCredential(tx *sqlx.Tx, query string, value *string, id int) error {
+	result, err := tx.Exec(query, value, id)
+	if err != nil {
+		return fmt.Errorf("failed to update credential: %w", err)
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+	if rowsAffected == 0 {
+		return fmt.Errorf("worker not found")
+	}
+	return nil
+}
+
 // GetWorkerTaskGroups 为工人工作台聚合任务数据
 func (r *workerRepository) GetWorkerTaskGroups(workerID int) ([]models.WorkerTaskGroup, error) {
 	// 这个复杂的SQL查询是实现所有功能的核心
@@ -203,7 +371,7 @@ func (r *workerRepository) GetWorkerTaskGroups(workerID int) ([]models.WorkerTas
                 SUM(wrt.completed_layers) as total_completed
             FROM WorkerRelevantTasks wrt
             JOIN Cutting_Layouts cl ON wrt.layout_id = cl.layout_id
-            JOIN Production_Plans p ON cl.plan_id = p.plan_id
+            JOIN Production_Plans p ON cl.plan_id = p.plan_id AND p.deleted_at IS NULL
             JOIN Styles s ON p.style_id = s.style_id
             GROUP BY p.plan_id, s.style_number
         )
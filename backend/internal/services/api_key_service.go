@@ -0,0 +1,82 @@
+package services
+
+import (
+	"cutrix-backend/internal/models"
+	"cutrix-backend/internal/repositories"
+	"cutrix-backend/pkg/auth"
+	"strings"
+	"time"
+)
+
+// APIKeyService 管理系统集成使用的 API 密钥
+type APIKeyService interface {
+	Create(createdBy int, req *models.CreateAPIKeyRequest) (*models.CreateAPIKeyResponse, error)
+	GetAll() ([]*models.APIKey, error)
+	Revoke(id int) error
+	// AuthenticateAPIKey 校验明文密钥，密钥无效、已吊销或已过期时返回 nil, nil
+	AuthenticateAPIKey(key string) (*models.APIKey, error)
+}
+
+type apiKeyService struct {
+	apiKeyRepo repositories.APIKeyRepository
+}
+
+// NewAPIKeyService 创建新的 API 密钥服务
+func NewAPIKeyService(apiKeyRepo repositories.APIKeyRepository) APIKeyService {
+	return &apiKeyService{apiKeyRepo: apiKeyRepo}
+}
+
+func (s *apiKeyService) Create(createdBy int, req *models.CreateAPIKeyRequest) (*models.CreateAPIKeyResponse, error) {
+	name := strings.TrimSpace(req.Name)
+	if name == "" || len(name) > 100 {
+		return nil, &ValidationError{Message: "密钥名称不能为空且不能超过100个字符"}
+	}
+	if len(req.Scopes) == 0 {
+		return nil, &ValidationError{Message: "至少需要授予一个权限范围"}
+	}
+	seen := make(map[string]bool, len(req.Scopes))
+	scopes := make([]string, 0, len(req.Scopes))
+	for _, scope := range req.Scopes {
+		if !auth.IsValidAPIScope(scope) {
+			return nil, &ValidationError{Message: "无效的权限范围: " + scope}
+		}
+		if !seen[scope] {
+			seen[scope] = true
+			scopes = append(scopes, scope)
+		}
+	}
+	if req.ExpiresAt != nil && !req.ExpiresAt.After(time.Now()) {
+		return nil, &ValidationError{Message: "过期时间必须晚于当前时间"}
+	}
+
+	plainKey, prefix, hash, err := auth.NewAPIKey()
+	if err != nil {
+		return nil, err
+	}
+	key := &models.APIKey{
+		Name:      name,
+		KeyPrefix: prefix,
+		Scopes:    scopes,
+		CreatedBy: &createdBy,
+		ExpiresAt: req.ExpiresAt,
+	}
+	if err := s.apiKeyRepo.Create(key, hash); err != nil {
+		return nil, err
+	}
+	return &models.CreateAPIKeyResponse{Key: plainKey, APIKey: key}, nil
+}
+
+func (s *apiKeyService) GetAll() ([]*models.APIKey, error) {
+	return s.apiKeyRepo.GetAll()
+}
+
+func (s *apiKeyService) Revoke(id int) error {
+	return s.apiKeyRepo.Revoke(id)
+}
+
+func (s *apiKeyService) AuthenticateAPIKey(key string) (*models.APIKey, error) {
+	if !strings.HasPrefix(key, auth.APIKeyPrefix) {
+		return nil, nil
+	}
+	return s.apiKeyRepo.Authenticate(auth.HashOpaqueToken(key))
+}
@@ -0,0 +1,98 @@
+package services
+
+import (
+	"cutrix-backend/internal/models"
+	"cutrix-backend/internal/repositories"
+	"encoding/json"
+	"fmt"
+	"log"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// 审计对象类型
+const (
+	AuditEntityProductionOrder = "production_order"
+	AuditEntityProductionPlan  = "production_plan"
+	AuditEntityWorker          = "worker"
+	AuditEntityStyle           = "style"
+	AuditEntityProductionLog   = "production_log"
+	AuditEntityRole            = "role"
+	AuditEntitySystemSetting   = "system_setting"
+	AuditEntityCustomer        = "customer"
+)
+
+// 审计动作
+const (
+	AuditActionCreate         = "create"
+	AuditActionUpdate         = "update"
+	AuditActionDelete         = "delete"
+	AuditActionPasswordChange = "password_change"
+	AuditActionUnlock         = "unlock"
+	AuditActionPinSet         = "pin_set"
+	AuditActionPinClear       = "pin_clear"
+	AuditActionBadgeSet       = "badge_set"
+	AuditActionBadgeClear     = "badge_clear"
+	AuditActionRestore        = "restore"
+	AuditActionPurge          = "purge"
+)
+
+// AuditService 提供审计日志查询
+type AuditService interface {
+	List(filter *models.AuditLogFilter) (*models.AuditLogPage, error)
+}
+
+type auditService struct {
+	auditRepo repositories.AuditRepository
+}
+
+// NewAuditService 创建新的审计日志服务
+func NewAuditService(auditRepo repositories.AuditRepository) AuditService {
+	return &auditService{auditRepo: auditRepo}
+}
+
+func (s *auditService) List(filter *models.AuditLogFilter) (*models.AuditLogPage, error) {
+	items, total, err := s.auditRepo.List(filter)
+	if err != nil {
+		return nil, err
+	}
+	return &models.AuditLogPage{Items: items, Total: total, Page: filter.Page, PageSize: filter.PageSize}, nil
+}
+
+// auditRecorder 供各业务服务在写操作的事务中记录审计日志。
+// 审计记录与业务修改在同一事务中提交，写入失败时整个操作回滚，不会出现没有审计记录的修改。
+type auditRecorder struct {
+	repo repositories.AuditRepository
+}
+
+// record 在 tx 中写入一条审计记录，before/after 为变更前后的对象 (创建时 before 为 nil，删除时 after 为 nil)。
+// after 应在同一事务中读取，调用方须在 tx.Commit() 之前调用并处理返回的错误。
+func (a auditRecorder) record(tx *sqlx.Tx, actor models.Actor, action, entityType string, entityID int64, before, after interface{}) error {
+	entry := &models.AuditLog{
+		ActorWorkerID: actor.WorkerID,
+		ActorAPIKeyID: actor.APIKeyID,
+		ActorRole:     actor.Role,
+		ClientIP:      actor.ClientIP,
+		Action:        action,
+		EntityType:    entityType,
+		EntityID:      entityID,
+		Before:        auditSnapshot(before),
+		After:         auditSnapshot(after),
+	}
+	if err := a.repo.Create(tx, entry); err != nil {
+		return fmt.Errorf("failed to record audit log (%s %s #%d): %w", action, entityType, entityID, err)
+	}
+	return nil
+}
+
+func auditSnapshot(v interface{}) json.RawMessage {
+	if v == nil {
+		return nil
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		log.Printf("Failed to marshal audit snapshot: %v", err)
+		return nil
+	}
+	return data
+}
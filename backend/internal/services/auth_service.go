@@ -3,48 +3,430 @@ package services
 import (
 	"cutrix-backend/internal/models"
 	"cutrix-backend/internal/repositories"
+	"cutrix-backend/pkg/auth"
+	"errors"
 	"fmt"
+	"log"
+	"time"
 
+	"github.com/jmoiron/sqlx"
 	"golang.org/x/crypto/bcrypt"
 )
 
+// TooManyAttemptsError 表示登录因失败次数过多而被暂时拒绝
+type TooManyAttemptsError struct {
+	Message    string
+	RetryAfter time.Duration
+}
+
+func (e *TooManyAttemptsError) Error() string {
+	return e.Message
+}
+
+// AuthSettings 是认证服务的可配置参数
+type AuthSettings struct {
+	RefreshTTL             time.Duration
+	MaxFailedAttempts      int           // 单个账户连续失败多少次后锁定
+	MaxFailedAttemptsPerIP int           // 单个IP在锁定时长内最多允许的失败次数
+	LockoutDuration        time.Duration // 账户锁定时长，同时作为IP失败次数的统计窗口
+	KioskTTL               time.Duration // 车间终端令牌有效期
+}
+
+// 登录方式
+const (
+	loginMethodPassword = "password"
+	loginMethodPin      = "pin"
+	loginMethodBadge    = "badge"
+)
+
+// 登录事件原因
+const (
+	loginReasonSuccess       = "success"
+	loginReasonUnknownUser   = "unknown_user"
+	loginReasonBadPassword   = "bad_password"
+	loginReasonNoPassword    = "no_password"
+	loginReasonNoPin         = "no_pin"
+	loginReasonDisabled      = "disabled"
+	loginReasonLocked        = "locked"
+	loginReasonIPThrottled   = "ip_throttled"
+	loginReasonAccountLocked = "account_locked"
+)
+
 // AuthService 定义认证服务接口
 type AuthService interface {
-	Login(req *models.LoginRequest) (*models.Worker, error)
+	Login(req *models.LoginRequest, clientIP, userAgent string) (*models.LoginResponse, error)
+	KioskLogin(req *models.KioskLoginRequest, clientIP, userAgent string) (*models.KioskLoginResponse, error)
+	ListLoginEvents(filter *models.LoginEventFilter) ([]models.LoginEvent, error)
+	Refresh(refreshToken string) (*models.LoginResponse, error)
+	Logout(refreshToken string) error
+	ChangePassword(workerID int, req *models.ChangePasswordRequest) error
+	// IsSessionActive 供认证中间件校验访问令牌所属会话是否已被吊销
+	IsSessionActive(sessionID int64) (bool, error)
 }
 
 type authService struct {
-	workerRepo repositories.WorkerRepository
+	db             *sqlx.DB
+	workerRepo     repositories.WorkerRepository
+	sessionRepo    repositories.SessionRepository
+	loginEventRepo repositories.LoginEventRepository
+	tokens         *auth.TokenManager
+	settings       AuthSettings
 }
 
 // NewAuthService 创建新的认证服务实例
-func NewAuthService(workerRepo repositories.WorkerRepository) AuthService {
-	return &authService{workerRepo: workerRepo}
+func NewAuthService(db *sqlx.DB, workerRepo repositories.WorkerRepository, sessionRepo repositories.SessionRepository, loginEventRepo repositories.LoginEventRepository, tokens *auth.TokenManager, settings AuthSettings) AuthService {
+	return &authService{
+		db:             db,
+		workerRepo:     workerRepo,
+		sessionRepo:    sessionRepo,
+		loginEventRepo: loginEventRepo,
+		tokens:         tokens,
+		settings:       settings,
+	}
 }
 
-// Login 处理用户登录请求
-func (s *authService) Login(req *models.LoginRequest) (*models.Worker, error) {
-	// 1. 根据用户名查找用户
+// Login 处理用户登录请求，验证通过后创建会话并签发访问令牌和刷新令牌。
+// 每次尝试都会记录登录事件；同一账户或同一IP失败次数过多时暂时拒绝登录。
+func (s *authService) Login(req *models.LoginRequest, clientIP, userAgent string) (*models.LoginResponse, error) {
+	event := &models.LoginEvent{WorkerName: req.Name, ClientIP: clientIP, UserAgent: userAgent, Method: loginMethodPassword}
+
+	// 1. 检查该IP近期的失败次数
+	if err := s.checkIPThrottle(event); err != nil {
+		return nil, err
+	}
+
+	// 2. 根据用户名查找用户
 	worker, err := s.workerRepo.GetByName(req.Name)
 	if err != nil {
+		s.recordLoginEvent(event, loginReasonUnknownUser)
 		return nil, fmt.Errorf("无效的用户名或密码")
 	}
+	event.WorkerID = &worker.WorkerID
 
-	// 2. 检查用户是否激活
-	if !worker.IsActive {
-		return nil, fmt.Errorf("用户已被禁用")
+	// 3. 检查账户是否被锁定或禁用
+	if err := s.checkAccountUsable(event, worker); err != nil {
+		return nil, err
 	}
 
-	// 3. 强制验证密码
+	// 4. 强制验证密码
 	if req.Password == "" {
+		s.recordLoginEvent(event, loginReasonNoPassword)
 		return nil, fmt.Errorf("请输入密码")
 	}
 	err = bcrypt.CompareHashAndPassword([]byte(worker.PasswordHash), []byte(req.Password))
 	if err != nil {
-		return nil, fmt.Errorf("无效的用户名或密码")
+		return nil, s.handleBadCredentials(event, worker.WorkerID)
+	}
+
+	// 5. 登录成功，清除失败计数
+	if err := s.recordLoginSuccess(event, worker); err != nil {
+		return nil, err
+	}
+
+	// 6. 创建会话
+	refreshToken, refreshHash, err := auth.NewOpaqueToken()
+	if err != nil {
+		return nil, err
+	}
+	session := &models.Session{
+		WorkerID:         worker.WorkerID,
+		RefreshTokenHash: refreshHash,
+		Scope:            auth.ScopeFull,
+		ClientIP:         clientIP,
+		UserAgent:        userAgent,
+		ExpiresAt:        time.Now().Add(s.settings.RefreshTTL),
+	}
+	if err := s.sessionRepo.Create(session); err != nil {
+		return nil, err
+	}
+
+	// 7. 返回令牌和用户信息（确保清空密码哈希）
+	worker.PasswordHash = ""
+	return s.issueTokens(worker, session, refreshToken)
+}
+
+// KioskLogin 处理车间终端的 PIN 或工牌快捷登录，签发只能提交本人生产记录
+// 和查看本人任务组的短期令牌。失败次数与锁定规则与密码登录共用。
+func (s *authService) KioskLogin(req *models.KioskLoginRequest, clientIP, userAgent string) (*models.KioskLoginResponse, error) {
+	event := &models.LoginEvent{WorkerName: req.Name, ClientIP: clientIP, UserAgent: userAgent}
+	usePin := req.BadgeCode == ""
+	if usePin && (req.Name == "" || req.Pin == "") {
+		return nil, &ValidationError{Message: "请提供姓名和PIN，或刷工牌登录"}
+	}
+
+	// 1. 检查该IP近期的失败次数
+	if err := s.checkIPThrottle(event); err != nil {
+		return nil, err
+	}
+
+	// 2. 根据工牌码或姓名查找员工
+	var worker *models.Worker
+	var err error
+	if usePin {
+		event.Method = loginMethodPin
+		worker, err = s.workerRepo.GetByName(req.Name)
+	} else {
+		event.Method = loginMethodBadge
+		worker, err = s.workerRepo.GetByBadgeHash(auth.HashOpaqueToken(req.BadgeCode))
+	}
+	if err != nil {
+		s.recordLoginEvent(event, loginReasonUnknownUser)
+		if usePin {
+			return nil, fmt.Errorf("无效的姓名或PIN")
+		}
+		return nil, fmt.Errorf("无效的工牌")
+	}
+	event.WorkerID = &worker.WorkerID
+	event.WorkerName = worker.Name
+
+	// 3. 检查账户是否被锁定或禁用
+	if err := s.checkAccountUsable(event, worker); err != nil {
+		return nil, err
+	}
+
+	// 4. 验证PIN (工牌登录以持有工牌为凭证)
+	if usePin {
+		if worker.PinHash == "" {
+			s.recordLoginEvent(event, loginReasonNoPin)
+			return nil, fmt.Errorf("该员工尚未设置PIN，请联系车间主任")
+		}
+		if bcrypt.CompareHashAndPassword([]byte(worker.PinHash), []byte(req.Pin)) != nil {
+			return nil, s.handleBadCredentials(event, worker.WorkerID)
+		}
+	}
+
+	// 5. 登录成功，创建没有刷新令牌的短期终端会话
+	if err := s.recordLoginSuccess(event, worker); err != nil {
+		return nil, err
+	}
+	session := &models.Session{
+		WorkerID:  worker.WorkerID,
+		Scope:     auth.ScopeKiosk,
+		ClientIP:  clientIP,
+		UserAgent: userAgent,
+		ExpiresAt: time.Now().Add(s.settings.KioskTTL),
+	}
+	if err := s.sessionRepo.Create(session); err != nil {
+		return nil, err
+	}
+
+	token, expiresAt, err := s.tokens.IssueKiosk(worker.WorkerID, worker.Role, session.SessionID, session.ExpiresAt)
+	if err != nil {
+		return nil, err
+	}
+	worker.PasswordHash = ""
+	worker.PinHash = ""
+	return &models.KioskLoginResponse{Token: token, ExpiresAt: expiresAt, Worker: worker}, nil
+}
+
+// refreshReuseGracePeriod 刷新令牌轮换后的宽限时间。同一客户端的并发刷新请求可能在轮换后
+// 才到达，宽限时间内使用上一个刷新令牌只会被拒绝，不视为令牌被盗用
+const refreshReuseGracePeriod = 30 * time.Second
+
+// Refresh 使用刷新令牌换取新的访问令牌，同时轮换刷新令牌。
+// 已被轮换的上一个刷新令牌在宽限时间后再次出现时视为被盗用，吊销整个会话
+func (s *authService) Refresh(refreshToken string) (*models.LoginResponse, error) {
+	oldHash := auth.HashOpaqueToken(refreshToken)
+	session, err := s.sessionRepo.GetByRefreshTokenHash(oldHash)
+	if err != nil {
+		if err.Error() != "session not found" {
+			return nil, err
+		}
+		return nil, s.checkRefreshTokenReuse(oldHash)
+	}
+	if session.Scope != auth.ScopeFull {
+		return nil, fmt.Errorf("无效的刷新令牌")
+	}
+	if session.RevokedAt != nil || time.Now().After(session.ExpiresAt) {
+		return nil, fmt.Errorf("会话已失效，请重新登录")
+	}
+
+	worker, err := s.workerRepo.GetByID(session.WorkerID)
+	if err != nil {
+		return nil, fmt.Errorf("会话已失效，请重新登录")
+	}
+	if !worker.IsActive {
+		if err := s.sessionRepo.Revoke(session.SessionID); err != nil {
+			return nil, err
+		}
+		return nil, fmt.Errorf("用户已被禁用")
+	}
+
+	newToken, newHash, err := auth.NewOpaqueToken()
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	session.ExpiresAt = now.Add(s.settings.RefreshTTL)
+	if err := s.sessionRepo.Rotate(session.SessionID, oldHash, newHash, session.ExpiresAt, now); err != nil {
+		if !errors.Is(err, repositories.ErrRefreshTokenReused) {
+			return nil, err
+		}
+		// 刷新令牌刚被并发的请求轮换，只拒绝本次请求，会话保持有效
+		return nil, fmt.Errorf("无效的刷新令牌")
 	}
 
-	// 4. 登录成功，返回用户信息（确保清空密码哈希）
 	worker.PasswordHash = ""
-	return worker, nil
+	return s.issueTokens(worker, session, newToken)
+}
+
+// checkRefreshTokenReuse 处理找不到会话的刷新令牌：若它是某个会话已轮换的上一个刷新令牌，
+// 且超过了宽限时间，说明令牌已泄露，吊销该会话
+func (s *authService) checkRefreshTokenReuse(hash string) error {
+	session, err := s.sessionRepo.GetByPreviousRefreshTokenHash(hash)
+	if err != nil {
+		if err.Error() != "session not found" {
+			return err
+		}
+		return fmt.Errorf("无效的刷新令牌")
+	}
+	if session.RevokedAt != nil {
+		return fmt.Errorf("会话已失效，请重新登录")
+	}
+	if session.RotatedAt != nil && time.Since(*session.RotatedAt) < refreshReuseGracePeriod {
+		return fmt.Errorf("无效的刷新令牌")
+	}
+	if err := s.sessionRepo.Revoke(session.SessionID); err != nil {
+		return err
+	}
+	return fmt.Errorf("会话已失效，请重新登录")
+}
+
+// Logout 吊销刷新令牌所属的会话
+func (s *authService) Logout(refreshToken string) error {
+	session, err := s.sessionRepo.GetByRefreshTokenHash(auth.HashOpaqueToken(refreshToken))
+	if err != nil {
+		// 令牌不存在时视为已注销
+		return nil
+	}
+	return s.sessionRepo.Revoke(session.SessionID)
+}
+
+// checkIPThrottle 检查该IP在锁定窗口内的失败次数是否已达上限。只统计真正的凭证错误，
+// 因限流或账户锁定而被拒绝的尝试不计入，否则被限流的IP会不断延长自己的限流时间
+func (s *authService) checkIPThrottle(event *models.LoginEvent) error {
+	ipFailures, err := s.loginEventRepo.CountRecentFailuresByIP(event.ClientIP, s.settings.LockoutDuration, []string{loginReasonIPThrottled, loginReasonLocked})
+	if err != nil {
+		return err
+	}
+	if ipFailures >= s.settings.MaxFailedAttemptsPerIP {
+		s.recordLoginEvent(event, loginReasonIPThrottled)
+		return &TooManyAttemptsError{Message: "登录失败次数过多，请稍后再试", RetryAfter: s.settings.LockoutDuration}
+	}
+	return nil
+}
+
+// checkAccountUsable 检查账户是否处于锁定或禁用状态
+func (s *authService) checkAccountUsable(event *models.LoginEvent, worker *models.Worker) error {
+	if worker.LockedUntil != nil && worker.LockedUntil.After(time.Now()) {
+		s.recordLoginEvent(event, loginReasonLocked)
+		return &TooManyAttemptsError{Message: "账户已被锁定，请稍后再试或联系管理员解锁", RetryAfter: time.Until(*worker.LockedUntil)}
+	}
+	if !worker.IsActive {
+		s.recordLoginEvent(event, loginReasonDisabled)
+		return fmt.Errorf("用户已被禁用")
+	}
+	return nil
+}
+
+// recordLoginSuccess 清除失败计数并记录成功的登录事件
+func (s *authService) recordLoginSuccess(event *models.LoginEvent, worker *models.Worker) error {
+	if worker.FailedLoginAttempts > 0 {
+		tx, err := s.db.Beginx()
+		if err != nil {
+			return fmt.Errorf("failed to begin transaction: %w", err)
+		}
+		defer tx.Rollback()
+
+		if err := s.workerRepo.ResetLoginFailures(tx, worker.WorkerID); err != nil {
+			return err
+		}
+		if err := tx.Commit(); err != nil {
+			return fmt.Errorf("failed to commit transaction: %w", err)
+		}
+	}
+	s.recordLoginEvent(event, loginReasonSuccess)
+	return nil
+}
+
+// handleBadCredentials 记录一次密码错误，达到阈值时锁定账户
+func (s *authService) handleBadCredentials(event *models.LoginEvent, workerID int) error {
+	worker, err := s.workerRepo.RecordLoginFailure(workerID, s.settings.MaxFailedAttempts, s.settings.LockoutDuration)
+	if err != nil {
+		return err
+	}
+	if worker.LockedUntil != nil && worker.LockedUntil.After(time.Now()) {
+		s.recordLoginEvent(event, loginReasonAccountLocked)
+		return &TooManyAttemptsError{Message: "密码错误次数过多，账户已被锁定", RetryAfter: s.settings.LockoutDuration}
+	}
+	s.recordLoginEvent(event, loginReasonBadPassword)
+	return fmt.Errorf("无效的用户名或密码")
+}
+
+// recordLoginEvent 写入登录事件，写入失败不影响登录流程本身
+func (s *authService) recordLoginEvent(event *models.LoginEvent, reason string) {
+	event.Reason = reason
+	event.Success = reason == loginReasonSuccess
+	if err := s.loginEventRepo.Create(event); err != nil {
+		log.Printf("Failed to record login event for %q: %v", event.WorkerName, err)
+	}
+}
+
+func (s *authService) ListLoginEvents(filter *models.LoginEventFilter) ([]models.LoginEvent, error) {
+	return s.loginEventRepo.List(filter)
+}
+
+func (s *authService) IsSessionActive(sessionID int64) (bool, error) {
+	return s.sessionRepo.IsActive(sessionID)
+}
+
+func (s *authService) issueTokens(worker *models.Worker, session *models.Session, refreshToken string) (*models.LoginResponse, error) {
+	token, expiresAt, err := s.tokens.Issue(worker.WorkerID, worker.Role, session.SessionID)
+	if err != nil {
+		return nil, err
+	}
+	return &models.LoginResponse{
+		Token:            token,
+		ExpiresAt:        expiresAt,
+		RefreshToken:     refreshToken,
+		RefreshExpiresAt: session.ExpiresAt,
+		Worker:           worker,
+	}, nil
+}
+
+// ChangePassword 允许员工在验证原密码后修改自己的密码，修改后该员工的所有会话均被吊销
+func (s *authService) ChangePassword(workerID int, req *models.ChangePasswordRequest) error {
+	worker, err := s.workerRepo.GetByID(workerID)
+	if err != nil {
+		return err
+	}
+
+	if bcrypt.CompareHashAndPassword([]byte(worker.PasswordHash), []byte(req.OldPassword)) != nil {
+		return &ValidationError{Message: "原密码错误"}
+	}
+	if len(req.NewPassword) < 6 {
+		return &ValidationError{Message: "新密码长度不能少于6位"}
+	}
+
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(req.NewPassword), bcrypt.DefaultCost)
+	if err != nil {
+		return fmt.Errorf("failed to hash password: %w", err)
+	}
+
+	tx, err := s.db.Beginx()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err := s.workerRepo.UpdatePassword(tx, workerID, string(hashedPassword)); err != nil {
+		return err
+	}
+	if err := s.sessionRepo.RevokeAllForWorker(tx, workerID); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
 }
@@ -0,0 +1,138 @@
+package services
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"cutrix-backend/internal/models"
+	"cutrix-backend/internal/repositories"
+	"cutrix-backend/pkg/auth"
+)
+
+// memRefreshSessions 是内存中的会话仓库，只实现刷新令牌轮换用到的方法
+type memRefreshSessions struct {
+	repositories.SessionRepository
+	sessions map[int64]*models.Session
+	previous map[int64]string
+}
+
+func (r *memRefreshSessions) find(match func(id int64, session *models.Session) bool) (*models.Session, error) {
+	for id, session := range r.sessions {
+		if match(id, session) {
+			copied := *session
+			return &copied, nil
+		}
+	}
+	return nil, fmt.Errorf("session not found")
+}
+
+func (r *memRefreshSessions) GetByRefreshTokenHash(hash string) (*models.Session, error) {
+	return r.find(func(id int64, session *models.Session) bool { return session.RefreshTokenHash == hash })
+}
+
+func (r *memRefreshSessions) GetByPreviousRefreshTokenHash(hash string) (*models.Session, error) {
+	return r.find(func(id int64, session *models.Session) bool { return r.previous[id] == hash })
+}
+
+func (r *memRefreshSessions) Rotate(sessionID int64, oldHash, newHash string, expiresAt, rotatedAt time.Time) error {
+	session := r.sessions[sessionID]
+	if session.RefreshTokenHash != oldHash || session.RevokedAt != nil {
+		return repositories.ErrRefreshTokenReused
+	}
+	r.previous[sessionID] = session.RefreshTokenHash
+	session.RefreshTokenHash, session.ExpiresAt, session.RotatedAt = newHash, expiresAt, &rotatedAt
+	return nil
+}
+
+func (r *memRefreshSessions) Revoke(sessionID int64) error {
+	now := time.Now()
+	r.sessions[sessionID].RevokedAt = &now
+	return nil
+}
+
+// newRefreshFixture 创建一个已登录的会话，返回认证服务、会话仓库和初始刷新令牌
+func newRefreshFixture(t *testing.T) (AuthService, *memRefreshSessions, string) {
+	t.Helper()
+	workers := newMemWorkerRepository()
+	workers.workers[1] = &models.Worker{WorkerID: 1, Name: "admin", Role: models.RoleAdmin, IsActive: true}
+	token, hash, err := auth.NewOpaqueToken()
+	if err != nil {
+		t.Fatal(err)
+	}
+	sessions := &memRefreshSessions{
+		sessions: map[int64]*models.Session{1: {SessionID: 1, WorkerID: 1, RefreshTokenHash: hash, Scope: auth.ScopeFull, ExpiresAt: time.Now().Add(time.Hour)}},
+		previous: map[int64]string{},
+	}
+	service := NewAuthService(newTxOnlyDB(), workers, sessions, nil, auth.NewTokenManager("test-secret", time.Minute), AuthSettings{RefreshTTL: time.Hour})
+	return service, sessions, token
+}
+
+func TestRefreshRotatesToken(t *testing.T) {
+	service, sessions, token := newRefreshFixture(t)
+
+	resp, err := service.Refresh(token)
+	if err != nil {
+		t.Fatalf("refresh: %v", err)
+	}
+	if resp.RefreshToken == token {
+		t.Fatal("refresh token was not rotated")
+	}
+	if _, err := service.Refresh(resp.RefreshToken); err != nil {
+		t.Fatalf("refresh with rotated token: %v", err)
+	}
+	if sessions.sessions[1].RevokedAt != nil {
+		t.Fatal("session revoked after normal refreshes")
+	}
+}
+
+func TestRefreshWithRotatedTokenWithinGracePeriodKeepsSession(t *testing.T) {
+	service, sessions, token := newRefreshFixture(t)
+
+	resp, err := service.Refresh(token)
+	if err != nil {
+		t.Fatalf("refresh: %v", err)
+	}
+	// 同一客户端的另一个并发请求在轮换后才带着旧令牌到达
+	if _, err := service.Refresh(token); err == nil || err.Error() != "无效的刷新令牌" {
+		t.Fatalf("late concurrent refresh: got %v, want 无效的刷新令牌", err)
+	}
+	if sessions.sessions[1].RevokedAt != nil {
+		t.Fatal("session revoked by a concurrent refresh")
+	}
+	if _, err := service.Refresh(resp.RefreshToken); err != nil {
+		t.Fatalf("refresh with current token: %v", err)
+	}
+}
+
+func TestRefreshWithReplayedTokenRevokesSession(t *testing.T) {
+	service, sessions, token := newRefreshFixture(t)
+
+	resp, err := service.Refresh(token)
+	if err != nil {
+		t.Fatalf("refresh: %v", err)
+	}
+	rotatedAt := time.Now().Add(-2 * refreshReuseGracePeriod)
+	sessions.sessions[1].RotatedAt = &rotatedAt
+
+	if _, err := service.Refresh(token); err == nil || err.Error() != "会话已失效，请重新登录" {
+		t.Fatalf("replayed refresh: got %v, want 会话已失效，请重新登录", err)
+	}
+	if sessions.sessions[1].RevokedAt == nil {
+		t.Fatal("session not revoked after a rotated refresh token was replayed")
+	}
+	if _, err := service.Refresh(resp.RefreshToken); err == nil {
+		t.Fatal("current refresh token still works after the session was revoked")
+	}
+}
+
+func TestRefreshWithUnknownTokenIsInvalid(t *testing.T) {
+	service, sessions, _ := newRefreshFixture(t)
+
+	if _, err := service.Refresh("unknown"); err == nil || err.Error() != "无效的刷新令牌" {
+		t.Fatalf("unknown token: got %v, want 无效的刷新令牌", err)
+	}
+	if sessions.sessions[1].RevokedAt != nil {
+		t.Fatal("session revoked by an unknown token")
+	}
+}
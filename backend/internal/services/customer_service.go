@@ -0,0 +1,155 @@
+package services
+
+import (
+	"cutrix-backend/internal/models"
+	"cutrix-backend/internal/repositories"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// customerCodePattern 客户编码会出现在订单号中，只允许字母、数字、- 和 _
+var customerCodePattern = regexp.MustCompile(`^[A-Za-z0-9_\-]{1,20}$`)
+
+// CustomerService 管理客户主数据
+type CustomerService interface {
+	GetAll(search string) ([]models.Customer, error)
+	GetByID(id int) (*models.Customer, error)
+	Create(actor models.Actor, req *models.CreateCustomerRequest) (*models.Customer, error)
+	Update(actor models.Actor, id int, req *models.UpdateCustomerRequest) (*models.Customer, error)
+	Delete(actor models.Actor, id int) error
+}
+
+type customerService struct {
+	db           *sqlx.DB
+	customerRepo repositories.CustomerRepository
+	audit        auditRecorder
+}
+
+// NewCustomerService 创建新的客户服务
+func NewCustomerService(db *sqlx.DB, customerRepo repositories.CustomerRepository, auditRepo repositories.AuditRepository) CustomerService {
+	return &customerService{db: db, customerRepo: customerRepo, audit: auditRecorder{repo: auditRepo}}
+}
+
+func (s *customerService) GetAll(search string) ([]models.Customer, error) {
+	return s.customerRepo.GetAll(strings.TrimSpace(search))
+}
+
+func (s *customerService) GetByID(id int) (*models.Customer, error) {
+	return s.customerRepo.GetByID(id)
+}
+
+func (s *customerService) Create(actor models.Actor, req *models.CreateCustomerRequest) (*models.Customer, error) {
+	customer, err := normalizeCustomer(req.CustomerCode, req.Name, req.ContactName, req.Phone, req.Remarks)
+	if err != nil {
+		return nil, err
+	}
+
+	tx, err := s.db.Beginx()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err := s.customerRepo.Create(tx, customer); err != nil {
+		if err.Error() == "customer already exists" {
+			return nil, &ValidationError{Message: "客户编码或名称已存在"}
+		}
+		return nil, err
+	}
+	if err := s.audit.record(tx, actor, AuditActionCreate, AuditEntityCustomer, int64(customer.CustomerID), nil, customer); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return customer, nil
+}
+
+func (s *customerService) Update(actor models.Actor, id int, req *models.UpdateCustomerRequest) (*models.Customer, error) {
+	before, err := s.customerRepo.GetByID(id)
+	if err != nil {
+		return nil, err
+	}
+	customer, err := normalizeCustomer(req.CustomerCode, req.Name, req.ContactName, req.Phone, req.Remarks)
+	if err != nil {
+		return nil, err
+	}
+	customer.CustomerID = id
+
+	tx, err := s.db.Beginx()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err := s.customerRepo.Update(tx, customer); err != nil {
+		if err.Error() == "customer already exists" {
+			return nil, &ValidationError{Message: "客户编码或名称已存在"}
+		}
+		return nil, err
+	}
+	if err := s.audit.record(tx, actor, AuditActionUpdate, AuditEntityCustomer, int64(id), before, customer); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return customer, nil
+}
+
+// Delete 删除客户，已有订单或款号的客户不能删除
+func (s *customerService) Delete(actor models.Actor, id int) error {
+	before, err := s.customerRepo.GetByID(id)
+	if err != nil {
+		return err
+	}
+
+	tx, err := s.db.Beginx()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err := s.customerRepo.Delete(tx, id); err != nil {
+		if err.Error() == "customer is still referenced" {
+			return &ValidationError{Message: "该客户已有生产订单或款号，不能删除"}
+		}
+		return err
+	}
+	if err := s.audit.record(tx, actor, AuditActionDelete, AuditEntityCustomer, int64(id), before, nil); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
+
+func normalizeCustomer(code, name, contactName, phone, remarks string) (*models.Customer, error) {
+	code = strings.TrimSpace(code)
+	if !customerCodePattern.MatchString(code) {
+		return nil, &ValidationError{Message: "客户编码只能包含字母、数字、- 和 _，且不超过20个字符"}
+	}
+	name = strings.TrimSpace(name)
+	if name == "" || len([]rune(name)) > 100 {
+		return nil, &ValidationError{Message: "客户名称不能为空且不能超过100个字符"}
+	}
+	contactName = strings.TrimSpace(contactName)
+	if len([]rune(contactName)) > 50 {
+		return nil, &ValidationError{Message: "联系人不能超过50个字符"}
+	}
+	phone = strings.TrimSpace(phone)
+	if len(phone) > 30 {
+		return nil, &ValidationError{Message: "电话不能超过30个字符"}
+	}
+	return &models.Customer{
+		CustomerCode: code,
+		Name:         name,
+		ContactName:  contactName,
+		Phone:        phone,
+		Remarks:      strings.TrimSpace(remarks),
+	}, nil
+}
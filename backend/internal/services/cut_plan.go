@@ -0,0 +1,164 @@
+package services
+
+import (
+	"cutrix-backend/internal/models"
+	"cutrix-backend/pkg/cutplan"
+	"cutrix-backend/pkg/sizes"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// ProposeCutPlan 为订单尚未排入其他计划的 颜色+尺码 数量自动生成裁剪方案 (排版配比和各颜色的拉布层数)，
+// 在约束内尽量减少排版数和床数。方案不会保存，返回的计划请求关联该订单，可核对后提交给 CreatePlan
+func (s *productionPlanService) ProposeCutPlan(req *models.CutPlanRequest) (*models.CutPlanProposal, error) {
+	constraints := cutplan.Constraints{
+		MaxPlies:             req.MaxPlies,
+		MaxGarmentsPerMarker: req.MaxGarmentsPerMarker,
+		MaxMarkers:           req.MaxMarkers,
+		OvercutPercent:       req.OvercutPercent,
+	}
+	switch {
+	case req.MaxPlies < 1:
+		return nil, &ValidationError{Message: "每床最多层数必须大于0"}
+	case req.MaxGarmentsPerMarker < 1:
+		return nil, &ValidationError{Message: "每个排版最多件数必须大于0"}
+	case req.MaxMarkers < 1:
+		return nil, &ValidationError{Message: "最多排版数必须大于0"}
+	case req.OvercutPercent < 0 || req.OvercutPercent > 100:
+		return nil, &ValidationError{Message: "允许超裁比例必须在 0 到 100 之间"}
+	}
+
+	order, err := s.orderRepo.GetOrderWithItems(req.OrderID)
+	if err != nil {
+		if err.Error() == "order not found" {
+			return nil, &ValidationError{Message: fmt.Sprintf("订单 %d 不存在", req.OrderID)}
+		}
+		return nil, err
+	}
+	if order.Status == OrderStatusCancelled || order.Status == OrderStatusHandedOver {
+		return nil, &ValidationError{Message: fmt.Sprintf("订单状态为「%s」，不能生成裁剪方案", orderStatusLabel(order.Status))}
+	}
+	style, err := s.styleRepo.GetByID(order.StyleID)
+	if err != nil {
+		return nil, err
+	}
+
+	// 需求 = 订单数量 - 其他计划已分配给该订单的件数
+	need := make(map[orderItemKey]int, len(order.Items))
+	for _, item := range order.Items {
+		need[orderItemKey{item.Color, item.Size}] += item.Quantity
+	}
+	tx, err := s.db.Beginx()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+	allocated, err := s.planRepo.GetAllocatedPieces(tx, order.OrderID, 0)
+	if err != nil {
+		return nil, err
+	}
+	for _, allocation := range allocated {
+		key := orderItemKey{allocation.Color, allocation.Size}
+		if _, ok := need[key]; ok {
+			need[key] -= allocation.Quantity
+		}
+	}
+
+	// 颜色按订单明细中出现的顺序，尺码按款号尺码组的顺序 (订单明细已按此排列)
+	colors, sizeRun := []string{}, []string{}
+	for _, item := range order.Items {
+		if need[orderItemKey{item.Color, item.Size}] <= 0 {
+			continue
+		}
+		if !slices.Contains(colors, item.Color) {
+			colors = append(colors, item.Color)
+		}
+		if !slices.Contains(sizeRun, item.Size) {
+			sizeRun = append(sizeRun, item.Size)
+		}
+	}
+	if len(colors) == 0 {
+		return nil, &ValidationError{Message: fmt.Sprintf("订单「%s」的数量已全部排入生产计划", order.OrderNumber)}
+	}
+	run := sizes.LoadRun(style.SizeScale, style.Sizes)
+	slices.SortStableFunc(sizeRun, run.Compare)
+
+	demand := make([][]int, len(colors))
+	for ci, color := range colors {
+		demand[ci] = make([]int, len(sizeRun))
+		for si, size := range sizeRun {
+			demand[ci][si] = max(need[orderItemKey{color, size}], 0)
+		}
+	}
+	markers, err := cutplan.Solve(demand, constraints)
+	if err != nil {
+		if errors.Is(err, cutplan.ErrTooManyMarkers) {
+			return nil, &ValidationError{Message: fmt.Sprintf("%d 个排版内无法覆盖订单「%s」，请增加排版数、每床层数、每个排版的件数或允许超裁比例", req.MaxMarkers, order.OrderNumber)}
+		}
+		return nil, err
+	}
+
+	planName := strings.TrimSpace(req.PlanName)
+	if planName == "" {
+		planName = order.OrderNumber
+	}
+	proposal := &models.CutPlanProposal{
+		Plan: models.CreateProductionPlanRequest{
+			PlanName: planName,
+			StyleID:  order.StyleID,
+			Orders:   []models.PlanOrderRequest{{OrderID: order.OrderID}},
+			Layouts:  make([]models.CreateLayout, 0, len(markers)),
+		},
+		MarkerCount: len(markers),
+		Lines:       []models.CutPlanLine{},
+	}
+	planned := make([][]int, len(colors))
+	for ci := range planned {
+		planned[ci] = make([]int, len(sizeRun))
+	}
+	for i, marker := range markers {
+		layout := models.CreateLayout{
+			LayoutName:  fmt.Sprintf("%s-%d", style.StyleNumber, i+1),
+			Description: "自动排版",
+			Ratios:      []models.CreateRatio{},
+			Tasks:       []models.CreateTaskForPlan{},
+		}
+		for si, ratio := range marker.Ratio {
+			if ratio > 0 {
+				layout.Ratios = append(layout.Ratios, models.CreateRatio{Size: sizeRun[si], Ratio: ratio})
+			}
+		}
+		for ci, layers := range marker.Layers {
+			for _, plies := range cutplan.SplitPlies(layers, req.MaxPlies) {
+				layout.Tasks = append(layout.Tasks, models.CreateTaskForPlan{Color: colors[ci], PlannedLayers: plies})
+			}
+			for si, ratio := range marker.Ratio {
+				planned[ci][si] += layers * ratio
+			}
+		}
+		proposal.SpreadCount += len(layout.Tasks)
+		proposal.Plan.Layouts = append(proposal.Plan.Layouts, layout)
+	}
+
+	for ci, color := range colors {
+		for si, size := range sizeRun {
+			if demand[ci][si] == 0 && planned[ci][si] == 0 {
+				continue
+			}
+			line := models.CutPlanLine{
+				Color:         color,
+				Size:          size,
+				Quantity:      demand[ci][si],
+				PlannedPieces: planned[ci][si],
+				OvercutPieces: planned[ci][si] - demand[ci][si],
+			}
+			proposal.Lines = append(proposal.Lines, line)
+			proposal.Quantity += line.Quantity
+			proposal.PlannedPieces += line.PlannedPieces
+			proposal.OvercutPieces += line.OvercutPieces
+		}
+	}
+	return proposal, nil
+}
@@ -0,0 +1,119 @@
+package services
+
+import (
+	"cutrix-backend/internal/models"
+	"cutrix-backend/internal/repositories"
+	"cutrix-backend/pkg/sizes"
+	"cutrix-backend/pkg/spreadsheet"
+	"slices"
+	"time"
+)
+
+// ExportService 把订单、计划和生产记录逐行写入表格，数据直接从数据库流式读取，不整体加载到内存
+type ExportService interface {
+	ExportOrders(w spreadsheet.Writer, styleNumberQuery string) error
+	ExportPlans(w spreadsheet.Writer, searchQuery string) error
+	ExportLogs(w spreadsheet.Writer, from, to *time.Time) error
+}
+
+type exportService struct {
+	orderRepo repositories.ProductionOrderRepository
+	planRepo  repositories.ProductionPlanRepository
+	logRepo   repositories.LogRepository
+}
+
+// NewExportService 创建新的导出服务
+func NewExportService(orderRepo repositories.ProductionOrderRepository, planRepo repositories.ProductionPlanRepository, logRepo repositories.LogRepository) ExportService {
+	return &exportService{orderRepo: orderRepo, planRepo: planRepo, logRepo: logRepo}
+}
+
+// ExportOrders 每个订单的每个颜色一行，尺码为列 (与客户下单的表格一致)，尺码列按通用的尺码顺序排列
+func (s *exportService) ExportOrders(w spreadsheet.Writer, styleNumberQuery string) error {
+	sizeColumns, err := s.orderRepo.GetExportSizes(styleNumberQuery)
+	if err != nil {
+		return err
+	}
+	slices.SortStableFunc(sizeColumns, sizes.Compare)
+	sizeIndex := make(map[string]int, len(sizeColumns))
+	header := []interface{}{"订单号", "款号", "下单日期", "颜色"}
+	for i, size := range sizeColumns {
+		sizeIndex[size] = i
+		header = append(header, size)
+	}
+	header = append(header, "合计")
+	if err := w.WriteRow(header...); err != nil {
+		return err
+	}
+
+	// 当前正在累计的 订单+颜色 行
+	var current *models.OrderExportRow
+	quantities := make([]interface{}, len(sizeColumns))
+	total := 0
+	flush := func() error {
+		if current == nil {
+			return nil
+		}
+		row := []interface{}{current.OrderNumber, current.StyleNumber, current.CreatedAt.Format("2006-01-02"), current.Color}
+		row = append(row, quantities...)
+		row = append(row, total)
+		return w.WriteRow(row...)
+	}
+
+	err = s.orderRepo.StreamOrderItems(styleNumberQuery, func(item *models.OrderExportRow) error {
+		if current == nil || current.OrderID != item.OrderID || current.Color != item.Color {
+			if err := flush(); err != nil {
+				return err
+			}
+			current = item
+			quantities = make([]interface{}, len(sizeColumns))
+			total = 0
+		}
+		if i, ok := sizeIndex[item.Size]; ok {
+			quantities[i] = item.Quantity
+		}
+		total += item.Quantity
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	return flush()
+}
+
+// ExportPlans 每个排版的每个颜色任务一行，没有排版或任务的计划也会输出一行
+func (s *exportService) ExportPlans(w spreadsheet.Writer, searchQuery string) error {
+	header := []interface{}{"计划名称", "款号", "关联订单号", "创建时间", "排版名称", "排版说明", "尺码配比", "每层件数", "颜色", "计划层数", "已完成层数", "计划件数"}
+	if err := w.WriteRow(header...); err != nil {
+		return err
+	}
+	return s.planRepo.StreamPlanTasks(searchQuery, func(row *models.PlanExportRow) error {
+		var plannedPieces interface{}
+		if row.PiecesPerLayer != nil && row.PlannedLayers != nil {
+			plannedPieces = *row.PiecesPerLayer * *row.PlannedLayers
+		}
+		return w.WriteRow(row.PlanName, row.StyleNumber, row.OrderNumber, row.CreatedAt,
+			row.LayoutName, row.Description, row.Ratios, row.PiecesPerLayer,
+			row.Color, row.PlannedLayers, row.CompletedLayers, plannedPieces)
+	})
+}
+
+// ExportLogs 按时间顺序导出 [from, to) 范围内的生产记录
+func (s *exportService) ExportLogs(w spreadsheet.Writer, from, to *time.Time) error {
+	header := []interface{}{"记录ID", "时间", "员工", "班组", "工序", "完成层数", "任务ID", "款号", "生产计划", "排版", "颜色", "关联记录ID", "提交来源"}
+	if err := w.WriteRow(header...); err != nil {
+		return err
+	}
+	return s.logRepo.StreamForExport(from, to, func(row *models.LogExportRow) error {
+		var parentLogID interface{}
+		if row.ParentLogID != nil {
+			parentLogID = *row.ParentLogID
+		}
+		source := "员工"
+		if row.APIKeyName != nil {
+			source = "API: " + *row.APIKeyName
+		}
+		return w.WriteRow(row.LogID, row.LogTime, row.WorkerName, row.WorkerGroup, row.ProcessName,
+			row.LayersCompleted, row.TaskID, row.StyleNumber, row.PlanName, row.LayoutName,
+			row.Color, parentLogID, source)
+	})
+}
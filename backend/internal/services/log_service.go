@@ -5,24 +5,38 @@ import (
 	"cutrix-backend/internal/repositories"
 	"fmt"
 	"time"
+
+	"github.com/jmoiron/sqlx"
 )
 
+// processSpreading 拉布工序，拉布记录会累计任务的完成层数并推进订单状态
+const processSpreading = "拉布"
+
 type LogService interface {
-	CreateLog(log *models.CreateLogRequest) error
+	// CreateLog 创建生产记录，actor.APIKeyID 非空表示记录由该 API 密钥对应的系统集成提交
+	CreateLog(actor models.Actor, log *models.CreateProductionLogRequest) error
 	GetLogsByTaskID(taskID int) ([]*models.ProductionLog, error)
 }
 
 type logService struct {
-	logRepo repositories.LogRepository
+	db        *sqlx.DB
+	logRepo   repositories.LogRepository
+	orderRepo repositories.ProductionOrderRepository
+	status    orderStatusMachine
+	audit     auditRecorder
 }
 
-func NewLogService(logRepo repositories.LogRepository) LogService {
+func NewLogService(db *sqlx.DB, logRepo repositories.LogRepository, orderRepo repositories.ProductionOrderRepository, auditRepo repositories.AuditRepository) LogService {
 	return &logService{
-		logRepo: logRepo,
+		db:        db,
+		logRepo:   logRepo,
+		orderRepo: orderRepo,
+		status:    orderStatusMachine{orderRepo: orderRepo},
+		audit:     auditRecorder{repo: auditRepo},
 	}
 }
 
-func (s *logService) CreateLog(req *models.CreateLogRequest) error {
+func (s *logService) CreateLog(actor models.Actor, req *models.CreateProductionLogRequest) error {
 	log := &models.ProductionLog{
 		TaskID:          req.TaskID,
 		ParentLogID:     req.ParentLogID,
@@ -30,12 +44,45 @@ func (s *logService) CreateLog(req *models.CreateLogRequest) error {
 		ProcessName:     req.ProcessName,
 		LayersCompleted: req.LayersCompleted,
 		LogTime:         time.Now(),
+		APIKeyID:        actor.APIKeyID,
 	}
 
-	if err := s.logRepo.Create(log); err != nil {
+	// 生产记录和订单状态同步在同一事务中完成，状态同步失败时记录也不会保存
+	tx, err := s.db.Beginx()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err := s.logRepo.Create(tx, log); err != nil {
 		return fmt.Errorf("创建生产记录失败: %w", err)
 	}
+	if log.ProcessName == processSpreading && log.TaskID != nil {
+		if err := s.advanceOrderStatus(tx, actor, *log.TaskID); err != nil {
+			return err
+		}
+	}
+	if err := s.audit.record(tx, actor, AuditActionCreate, AuditEntityProductionLog, log.LogID, nil, log); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
 
+// advanceOrderStatus 根据拉布进度推进任务所属计划关联的各订单的状态：首次拉布进入裁剪中，
+// 全部任务完成进入裁剪完成。订单按ID顺序加锁，避免并发的拉布记录互相死锁
+func (s *logService) advanceOrderStatus(tx *sqlx.Tx, actor models.Actor, taskID int) error {
+	orderIDs, err := s.orderRepo.FindOrderIDsByTask(tx, taskID)
+	if err != nil {
+		return err
+	}
+	for _, orderID := range orderIDs {
+		if err := s.status.recordSpreading(tx, actor, orderID); err != nil {
+			return fmt.Errorf("同步订单 %d 状态失败: %w", orderID, err)
+		}
+	}
 	return nil
 }
 
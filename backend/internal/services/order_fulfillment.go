@@ -0,0 +1,124 @@
+package services
+
+import (
+	"cutrix-backend/internal/models"
+	"cutrix-backend/pkg/sizes"
+	"math"
+)
+
+// GetFulfillment 汇总订单的每个 颜色+尺码：订单数量、关联计划分配给该订单的计划件数
+// 和其中实际裁剪的件数。计划分配给该订单、但订单里没有的 颜色+尺码 也会列出，订单数量为 0。
+// 行按颜色分组，组内按款号尺码组的顺序排列。
+func (s *productionOrderService) GetFulfillment(id int) (*models.OrderFulfillment, error) {
+	order, err := s.orderRepo.GetOrderWithItems(id)
+	if err != nil {
+		return nil, err
+	}
+	plans, err := s.linkedPlans(id)
+	if err != nil {
+		return nil, err
+	}
+
+	// 先按订单明细的顺序收集 颜色+尺码，再追加只在计划分配中出现的，最后按尺码组排序
+	keys := []orderItemKey{}
+	lines := map[orderItemKey]*models.OrderFulfillmentLine{}
+	lineFor := func(key orderItemKey) *models.OrderFulfillmentLine {
+		line, ok := lines[key]
+		if !ok {
+			line = &models.OrderFulfillmentLine{Color: key.color, Size: key.size}
+			lines[key] = line
+			keys = append(keys, key)
+		}
+		return line
+	}
+
+	for _, item := range order.Items {
+		lineFor(orderItemKey{item.Color, item.Size}).OrderedQuantity += item.Quantity
+	}
+	planIDs := make([]int, 0, len(plans))
+	for _, plan := range plans {
+		planIDs = append(planIDs, plan.PlanID)
+		for _, planOrder := range plan.Orders {
+			if planOrder.OrderID != id {
+				continue
+			}
+			for _, allocation := range planOrder.Allocations {
+				lineFor(orderItemKey{allocation.Color, allocation.Size}).PlannedPieces += allocation.Quantity
+			}
+		}
+		for key, pieces := range splitCutPieces(plan)[id] {
+			lineFor(key).CutPieces += pieces
+		}
+	}
+
+	style, err := s.styleRepo.GetByID(order.StyleID)
+	if err != nil {
+		return nil, err
+	}
+	sizes.SortByColor(sizes.LoadRun(style.SizeScale, style.Sizes), keys, func(key orderItemKey) (string, string) { return key.color, key.size })
+
+	report := &models.OrderFulfillment{
+		OrderID:     order.OrderID,
+		OrderNumber: order.OrderNumber,
+		PlanIDs:     planIDs,
+		Lines:       make([]models.OrderFulfillmentLine, 0, len(keys)),
+	}
+	for _, key := range keys {
+		line := lines[key]
+		fillFulfillmentDiffs(line)
+		report.Lines = append(report.Lines, *line)
+		report.Total.OrderedQuantity += line.OrderedQuantity
+		report.Total.PlannedPieces += line.PlannedPieces
+		report.Total.CutPieces += line.CutPieces
+	}
+	fillFulfillmentDiffs(&report.Total)
+	return report, nil
+}
+
+// splitCutPieces 把计划中每个 颜色+尺码 已裁剪的件数分摊到关联的订单：
+// 按交期顺序 (plan.Orders 的顺序) 依次填满各订单的分配数量，超出全部分配的部分记在最后一个分配了该 颜色+尺码 的订单上
+func splitCutPieces(plan *models.ProductionPlan) map[int]map[orderItemKey]int {
+	split := make(map[int]map[orderItemKey]int, len(plan.Orders))
+	for key, remaining := range cutPiecesByColorSize(plan) {
+		lastOrderID := 0
+		for _, planOrder := range plan.Orders {
+			for _, allocation := range planOrder.Allocations {
+				if allocation.Color != key.color || allocation.Size != key.size {
+					continue
+				}
+				lastOrderID = planOrder.OrderID
+				pieces := min(remaining, allocation.Quantity)
+				if pieces > 0 {
+					if split[planOrder.OrderID] == nil {
+						split[planOrder.OrderID] = make(map[orderItemKey]int)
+					}
+					split[planOrder.OrderID][key] += pieces
+					remaining -= pieces
+				}
+			}
+		}
+		if remaining > 0 && lastOrderID != 0 {
+			if split[lastOrderID] == nil {
+				split[lastOrderID] = make(map[orderItemKey]int)
+			}
+			split[lastOrderID][key] += remaining
+		}
+	}
+	return split
+}
+
+// fillFulfillmentDiffs 计算计划、裁剪件数相对订单数量的差额和百分比 (保留两位小数)
+func fillFulfillmentDiffs(line *models.OrderFulfillmentLine) {
+	line.PlannedDiff = line.PlannedPieces - line.OrderedQuantity
+	line.CutDiff = line.CutPieces - line.OrderedQuantity
+	line.PlannedPercent = percentOf(line.PlannedPieces, line.OrderedQuantity)
+	line.CutPercent = percentOf(line.CutPieces, line.OrderedQuantity)
+}
+
+func percentOf(value, total int) *float64 {
+	if total <= 0 {
+		return nil
+	}
+	percent := math.Round(float64(value)*10000/float64(total)) / 100
+	return &percent
+}
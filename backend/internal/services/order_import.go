@@ -0,0 +1,429 @@
+package services
+
+import (
+	"cutrix-backend/internal/models"
+	"cutrix-backend/pkg/sizes"
+	"cutrix-backend/pkg/spreadsheet"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+const (
+	// maxImportRows 单个导入文件最多的数据行数
+	maxImportRows = 5000
+	// 与 Styles / Order_Items 表的字段长度保持一致
+	maxStyleNumberLength = 50
+	maxColorLength       = 50
+	maxSizeLength        = 50
+)
+
+// 表头别名 (比较时忽略大小写和首尾空白)
+var (
+	importStyleHeaders    = []string{"款号", "款式", "款式编号", "style", "style_number", "style no", "style no."}
+	importColorHeaders    = []string{"颜色", "色号", "color", "colour"}
+	importSizeHeaders     = []string{"尺码", "size"}
+	importQuantityHeaders = []string{"数量", "件数", "qty", "quantity"}
+	// 汇总行/列在矩阵中常见，导入时忽略
+	importTotalLabels = []string{"合计", "总计", "小计", "total", "sum"}
+)
+
+// importOrderGroup 是解析过程中的一张订单，同一款号的所有行合并在一起
+type importOrderGroup struct {
+	order    models.ImportedOrder
+	firstRow int
+	seen     map[orderItemKey]int // 颜色+尺码 -> 首次出现的行号
+}
+
+// orderSheet 是解析单张订单表格时的状态
+type orderSheet struct {
+	opts      *models.ImportOrdersOptions
+	styleCol  int
+	colorCol  int
+	sizeCol   int
+	qtyCol    int
+	sizeCols  map[int]string // 矩阵布局：列序号 -> 尺码
+	groups    []*importOrderGroup
+	byStyle   map[string]*importOrderGroup
+	errors    []models.OrderImportError
+	lastStyle string
+	checkItem func(styleNumber, color, size string) (string, error) // 校验颜色和尺码属于已有款号的颜色和尺码组，返回规范化后的尺码
+}
+
+// ImportOrders 从表格导入订单。表格中的任何错误都会在结果中逐行列出，此时不会创建任何订单；
+// dry run 只做解析与校验。所有订单在同一个事务中创建，要么全部成功，要么全部不创建。
+func (s *productionOrderService) ImportOrders(actor models.Actor, rows [][]string, opts *models.ImportOrdersOptions) (*models.ImportOrdersResult, error) {
+	layout := strings.ToLower(strings.TrimSpace(opts.Layout))
+	if layout == "" {
+		layout = models.OrderImportLayoutAuto
+	}
+	if layout != models.OrderImportLayoutAuto && layout != models.OrderImportLayoutMatrix && layout != models.OrderImportLayoutLong {
+		return nil, &ValidationError{Message: "layout 只能是 auto、matrix 或 long"}
+	}
+	opts.Layout = layout
+	opts.StyleNumber = strings.TrimSpace(opts.StyleNumber)
+	details, err := s.parseOrderDetails(opts.CustomerID, opts.DueDate, "", "")
+	if err != nil {
+		return nil, err
+	}
+
+	// 已有款号只查询一次，款号不存在时为 nil。查询出错时不能当作新款号，
+	// 记下第一个错误，表格解析完后中止导入
+	styles := map[string]*models.Style{}
+	var lookupErr error
+	lookupStyle := func(styleNumber string) (*models.Style, error) {
+		style, ok := styles[styleNumber]
+		if !ok {
+			var err error
+			style, err = s.styleRepo.GetByNumber(styleNumber)
+			if err != nil {
+				if err.Error() != "style not found" {
+					return nil, err
+				}
+			}
+			styles[styleNumber] = style
+		}
+		return style, nil
+	}
+	checkItem := func(styleNumber, color, size string) (string, error) {
+		style, err := lookupStyle(styleNumber)
+		if err != nil {
+			if lookupErr == nil {
+				lookupErr = err
+			}
+			return "", err
+		}
+		if style != nil {
+			return normalizeStyleColorSize(style, color, size)
+		}
+		return sizes.Normalize(size), nil
+	}
+
+	layout, orders, importErrors := parseOrderSheet(rows, opts, checkItem)
+	if lookupErr != nil {
+		return nil, lookupErr
+	}
+	result := &models.ImportOrdersResult{DryRun: opts.DryRun, Layout: layout, Orders: orders, Errors: importErrors}
+
+	for i := range result.Orders {
+		style, err := lookupStyle(result.Orders[i].StyleNumber)
+		if err != nil {
+			return nil, err
+		}
+		if style == nil {
+			result.Orders[i].NewStyle = true
+		}
+	}
+	if len(result.Errors) > 0 || opts.DryRun {
+		return result, nil
+	}
+
+	tx, err := s.db.Beginx()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	for i := range result.Orders {
+		imported := &result.Orders[i]
+		order, style, styleCreated, err := s.createOrderInTx(tx, actor, imported.StyleNumber, details, imported.Items)
+		if err != nil {
+			if _, ok := err.(*ValidationError); ok {
+				return nil, err
+			}
+			return nil, fmt.Errorf("failed to create order for style %s: %w", imported.StyleNumber, err)
+		}
+		imported.OrderID = order.OrderID
+		imported.OrderNumber = order.OrderNumber
+		imported.NewStyle = styleCreated
+		if styleCreated {
+			if err := s.audit.record(tx, actor, AuditActionCreate, AuditEntityStyle, int64(style.StyleID), nil, style); err != nil {
+				return nil, err
+			}
+		}
+		created, err := s.orderRepo.GetOrderWithItemsInTx(tx, order.OrderID)
+		if err != nil {
+			return nil, err
+		}
+		if err := s.audit.record(tx, actor, AuditActionCreate, AuditEntityProductionOrder, int64(created.OrderID), nil, created); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return result, nil
+}
+
+// parseOrderSheet 识别表头和布局，逐行逐格校验并按款号合并为订单
+func parseOrderSheet(rows [][]string, opts *models.ImportOrdersOptions, checkItem func(styleNumber, color, size string) (string, error)) (string, []models.ImportedOrder, []models.OrderImportError) {
+	sheet := &orderSheet{
+		opts:     opts,
+		styleCol: -1, colorCol: -1, sizeCol: -1, qtyCol: -1,
+		sizeCols: map[int]string{},
+		byStyle:  map[string]*importOrderGroup{},
+		errors:   []models.OrderImportError{},
+	}
+	sheet.checkItem = checkItem
+	layout := opts.Layout
+
+	headerIndex := -1
+	for i, row := range rows {
+		if !spreadsheet.IsBlankRow(row) {
+			headerIndex = i
+			break
+		}
+	}
+	if headerIndex < 0 {
+		sheet.addError(1, "", "表格为空")
+		return layout, nil, sheet.errors
+	}
+	if len(rows)-headerIndex-1 > maxImportRows {
+		sheet.addError(headerIndex+1, "", fmt.Sprintf("数据行数超过上限 %d 行，请拆分后分批导入", maxImportRows))
+		return layout, nil, sheet.errors
+	}
+
+	header := rows[headerIndex]
+	headerRow := headerIndex + 1
+	for col, cell := range header {
+		switch {
+		case cell == "":
+		case matchesAny(cell, importStyleHeaders) && sheet.styleCol < 0:
+			sheet.styleCol = col
+		case matchesAny(cell, importColorHeaders) && sheet.colorCol < 0:
+			sheet.colorCol = col
+		case matchesAny(cell, importSizeHeaders) && sheet.sizeCol < 0:
+			sheet.sizeCol = col
+		case matchesAny(cell, importQuantityHeaders) && sheet.qtyCol < 0:
+			sheet.qtyCol = col
+		}
+	}
+
+	if layout == models.OrderImportLayoutAuto {
+		layout = models.OrderImportLayoutMatrix
+		if sheet.sizeCol >= 0 && sheet.qtyCol >= 0 {
+			layout = models.OrderImportLayoutLong
+		}
+	}
+
+	if sheet.colorCol < 0 {
+		sheet.addError(headerRow, "", "表头中缺少颜色列")
+	}
+	if sheet.styleCol < 0 && opts.StyleNumber == "" {
+		sheet.addError(headerRow, "", "表头中缺少款号列，请在表格中添加款号列或在导入时指定款号")
+	}
+	if layout == models.OrderImportLayoutLong {
+		if sheet.sizeCol < 0 || sheet.qtyCol < 0 {
+			sheet.addError(headerRow, "", "逐行格式的表头必须包含尺码列和数量列")
+		}
+	} else {
+		sheet.collectSizeColumns(header, headerRow)
+	}
+	if len(sheet.errors) > 0 {
+		return layout, nil, sheet.errors
+	}
+
+	for i := headerIndex + 1; i < len(rows); i++ {
+		row := rows[i]
+		if spreadsheet.IsBlankRow(row) {
+			continue
+		}
+		rowNumber := i + 1
+
+		color := cellAt(row, sheet.colorCol)
+		if matchesAny(color, importTotalLabels) || matchesAny(cellAt(row, sheet.styleCol), importTotalLabels) {
+			continue
+		}
+		style, ok := sheet.resolveStyle(row, rowNumber)
+		if !ok {
+			continue
+		}
+		if color == "" {
+			sheet.addError(rowNumber, spreadsheet.ColumnName(sheet.colorCol), "颜色不能为空")
+			continue
+		}
+		if len([]rune(color)) > maxColorLength {
+			sheet.addError(rowNumber, spreadsheet.ColumnName(sheet.colorCol), fmt.Sprintf("颜色不能超过%d个字符", maxColorLength))
+			continue
+		}
+
+		group := sheet.group(style, rowNumber)
+		if layout == models.OrderImportLayoutLong {
+			sheet.parseLongRow(group, row, rowNumber, color)
+		} else {
+			sheet.parseMatrixRow(group, row, rowNumber, color)
+		}
+	}
+
+	orders := make([]models.ImportedOrder, 0, len(sheet.groups))
+	for _, group := range sheet.groups {
+		if len(group.order.Items) == 0 {
+			sheet.addError(group.firstRow, "", fmt.Sprintf("款号 %s 没有任何数量", group.order.StyleNumber))
+			continue
+		}
+		orders = append(orders, group.order)
+	}
+	if len(orders) == 0 && len(sheet.errors) == 0 {
+		sheet.addError(headerRow, "", "表格中没有可导入的订单明细")
+	}
+	return layout, orders, sheet.errors
+}
+
+// collectSizeColumns 矩阵布局下，除款号、颜色和汇总列外的每个表头都是一个尺码
+func (sheet *orderSheet) collectSizeColumns(header []string, headerRow int) {
+	seen := map[string]bool{}
+	for col, cell := range header {
+		if cell == "" || col == sheet.styleCol || col == sheet.colorCol || matchesAny(cell, importTotalLabels) {
+			continue
+		}
+		if len([]rune(cell)) > maxSizeLength {
+			sheet.addError(headerRow, spreadsheet.ColumnName(col), fmt.Sprintf("尺码不能超过%d个字符", maxSizeLength))
+			continue
+		}
+		if seen[cell] {
+			sheet.addError(headerRow, spreadsheet.ColumnName(col), fmt.Sprintf("尺码 %s 重复出现", cell))
+			continue
+		}
+		seen[cell] = true
+		sheet.sizeCols[col] = cell
+	}
+	if len(sheet.sizeCols) == 0 && len(sheet.errors) == 0 {
+		sheet.addError(headerRow, "", "矩阵格式的表头中没有尺码列")
+	}
+}
+
+// resolveStyle 取本行的款号；款号单元格为空时沿用上一行 (对应表格中合并的款号单元格)，
+// 表格没有款号列时使用导入参数中的款号
+func (sheet *orderSheet) resolveStyle(row []string, rowNumber int) (string, bool) {
+	style := ""
+	if sheet.styleCol >= 0 {
+		style = cellAt(row, sheet.styleCol)
+		if style == "" {
+			style = sheet.lastStyle
+		}
+	}
+	if style == "" {
+		style = sheet.opts.StyleNumber
+	}
+	if style == "" {
+		sheet.addError(rowNumber, spreadsheet.ColumnName(sheet.styleCol), "款号不能为空")
+		return "", false
+	}
+	if len([]rune(style)) > maxStyleNumberLength {
+		sheet.addError(rowNumber, spreadsheet.ColumnName(sheet.styleCol), fmt.Sprintf("款号不能超过%d个字符", maxStyleNumberLength))
+		return "", false
+	}
+	sheet.lastStyle = style
+	return style, true
+}
+
+func (sheet *orderSheet) group(style string, rowNumber int) *importOrderGroup {
+	group, ok := sheet.byStyle[style]
+	if !ok {
+		group = &importOrderGroup{
+			order:    models.ImportedOrder{StyleNumber: style, Items: []models.CreateOrderItem{}},
+			firstRow: rowNumber,
+			seen:     map[orderItemKey]int{},
+		}
+		sheet.byStyle[style] = group
+		sheet.groups = append(sheet.groups, group)
+	}
+	return group
+}
+
+func (sheet *orderSheet) parseMatrixRow(group *importOrderGroup, row []string, rowNumber int, color string) {
+	for col := range row {
+		size, ok := sheet.sizeCols[col]
+		if !ok {
+			continue
+		}
+		quantity, ok := sheet.parseQuantity(row[col], rowNumber, col, true)
+		if !ok || quantity == 0 {
+			continue
+		}
+		sheet.addItem(group, rowNumber, col, color, size, quantity)
+	}
+}
+
+func (sheet *orderSheet) parseLongRow(group *importOrderGroup, row []string, rowNumber int, color string) {
+	size := cellAt(row, sheet.sizeCol)
+	if size == "" {
+		sheet.addError(rowNumber, spreadsheet.ColumnName(sheet.sizeCol), "尺码不能为空")
+		return
+	}
+	if len([]rune(size)) > maxSizeLength {
+		sheet.addError(rowNumber, spreadsheet.ColumnName(sheet.sizeCol), fmt.Sprintf("尺码不能超过%d个字符", maxSizeLength))
+		return
+	}
+	quantity, ok := sheet.parseQuantity(cellAt(row, sheet.qtyCol), rowNumber, sheet.qtyCol, false)
+	if !ok {
+		return
+	}
+	sheet.addItem(group, rowNumber, sheet.qtyCol, color, size, quantity)
+}
+
+// parseQuantity 解析数量单元格。矩阵布局允许空白或 0 表示该尺码不下单；
+// Excel 中的数字可能带有 ".0" 或千分位，只要是非负整数即可
+func (sheet *orderSheet) parseQuantity(cell string, rowNumber, col int, allowEmpty bool) (int, bool) {
+	column := spreadsheet.ColumnName(col)
+	value := strings.ReplaceAll(cell, ",", "")
+	if value == "" {
+		if allowEmpty {
+			return 0, true
+		}
+		sheet.addError(rowNumber, column, "数量不能为空")
+		return 0, false
+	}
+	number, err := strconv.ParseFloat(value, 64)
+	if err != nil || number != math.Trunc(number) || number > math.MaxInt32 {
+		sheet.addError(rowNumber, column, fmt.Sprintf("数量 %q 不是有效的整数", cell))
+		return 0, false
+	}
+	if number < 0 || (number == 0 && !allowEmpty) {
+		sheet.addError(rowNumber, column, "数量必须大于0")
+		return 0, false
+	}
+	return int(number), true
+}
+
+func (sheet *orderSheet) addItem(group *importOrderGroup, rowNumber, col int, color, size string, quantity int) {
+	if sheet.checkItem != nil {
+		normalized, err := sheet.checkItem(group.order.StyleNumber, color, size)
+		if err != nil {
+			sheet.addError(rowNumber, spreadsheet.ColumnName(col), err.Error())
+			return
+		}
+		size = normalized
+	}
+	key := orderItemKey{color, size}
+	if firstRow, ok := group.seen[key]; ok {
+		sheet.addError(rowNumber, spreadsheet.ColumnName(col),
+			fmt.Sprintf("款号 %s 的颜色 %s 尺码 %s 已在第 %d 行出现", group.order.StyleNumber, color, size, firstRow))
+		return
+	}
+	group.seen[key] = rowNumber
+	group.order.Items = append(group.order.Items, models.CreateOrderItem{Color: color, Size: size, Quantity: quantity})
+	group.order.TotalQuantity += quantity
+}
+
+func (sheet *orderSheet) addError(row int, column, message string) {
+	sheet.errors = append(sheet.errors, models.OrderImportError{Row: row, Column: column, Message: message})
+}
+
+func cellAt(row []string, col int) string {
+	if col < 0 || col >= len(row) {
+		return ""
+	}
+	return row[col]
+}
+
+func matchesAny(cell string, aliases []string) bool {
+	for _, alias := range aliases {
+		if strings.EqualFold(strings.TrimSpace(cell), alias) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,102 @@
+package services
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"time"
+)
+
+// DefaultOrderNumberTemplate 未配置订单号规则时使用的默认规则：PO-YYYYMMDD-款号-两位序号
+const DefaultOrderNumberTemplate = "PO-{date}-{style}-{seq:2}"
+
+const (
+	maxOrderNumberTemplateLength = 60
+	maxOrderNumberLength         = 100
+	maxOrderSequenceWidth        = 9
+	// sequenceKeyPlaceholder 替换序号后得到计数器的键，同一个键下的订单共用一个递增序号
+	sequenceKeyPlaceholder = "{seq}"
+)
+
+// 订单号模板支持的占位符：
+//
+//	{date}     创建日期，格式 YYYYMMDD
+//	{style}    款号
+//	{customer} 客户编码 (订单未指定客户时为空)
+//	{seq:N}    序号，至少 N 位，不足补零；{seq} 不补零
+//
+// 序号在其余部分相同的订单号之间递增，例如默认规则下每个款号每天从 01 开始。
+var orderNumberTokenPattern = regexp.MustCompile(`\{([a-z]+)(?::([0-9]+))?\}`)
+
+var orderNumberLiteralPattern = regexp.MustCompile(`^[A-Za-z0-9_\-./#]*$`)
+
+// orderNumberValues 是生成订单号时可用的占位符取值
+type orderNumberValues struct {
+	Date     time.Time
+	Style    string
+	Customer string
+}
+
+// validateOrderNumberTemplate 检查模板只包含已知占位符和安全的字面字符，且恰好包含一个序号
+func validateOrderNumberTemplate(template string) error {
+	if template == "" || len(template) > maxOrderNumberTemplateLength {
+		return &ValidationError{Message: fmt.Sprintf("订单号规则不能为空且不能超过%d个字符", maxOrderNumberTemplateLength)}
+	}
+
+	seqCount := 0
+	for _, match := range orderNumberTokenPattern.FindAllStringSubmatch(template, -1) {
+		name, arg := match[1], match[2]
+		switch name {
+		case "date", "style", "customer":
+			if arg != "" {
+				return &ValidationError{Message: fmt.Sprintf("占位符 {%s} 不支持参数", name)}
+			}
+		case "seq":
+			seqCount++
+			if arg != "" {
+				width, err := strconv.Atoi(arg)
+				if err != nil || width < 1 || width > maxOrderSequenceWidth {
+					return &ValidationError{Message: fmt.Sprintf("序号位数必须在1到%d之间", maxOrderSequenceWidth)}
+				}
+			}
+		default:
+			return &ValidationError{Message: fmt.Sprintf("不支持的占位符 {%s}", name)}
+		}
+	}
+	if seqCount != 1 {
+		return &ValidationError{Message: "订单号规则必须包含且只能包含一个序号占位符 {seq} 或 {seq:N}"}
+	}
+
+	literal := orderNumberTokenPattern.ReplaceAllString(template, "")
+	if !orderNumberLiteralPattern.MatchString(literal) {
+		return &ValidationError{Message: "订单号规则只能包含字母、数字、- _ . / # 以及占位符"}
+	}
+	return nil
+}
+
+// renderOrderNumber 按模板生成订单号；seq 小于 1 时序号位置保留为 {seq}，得到的字符串用作计数器的键
+func renderOrderNumber(template string, values orderNumberValues, seq int) string {
+	return orderNumberTokenPattern.ReplaceAllStringFunc(template, func(token string) string {
+		match := orderNumberTokenPattern.FindStringSubmatch(token)
+		switch match[1] {
+		case "date":
+			return values.Date.Format("20060102")
+		case "style":
+			return values.Style
+		case "customer":
+			return values.Customer
+		case "seq":
+			if seq < 1 {
+				return sequenceKeyPlaceholder
+			}
+			width, _ := strconv.Atoi(match[2])
+			return fmt.Sprintf("%0*d", width, seq)
+		}
+		return token
+	})
+}
+
+// previewOrderNumber 用示例数据展示模板生成的订单号
+func previewOrderNumber(template string) string {
+	return renderOrderNumber(template, orderNumberValues{Date: time.Now(), Style: "ST001", Customer: "C001"}, 1)
+}
@@ -0,0 +1,197 @@
+package services
+
+import (
+	"cutrix-backend/internal/models"
+	"cutrix-backend/internal/repositories"
+	"fmt"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// 订单状态
+const (
+	OrderStatusDraft       = "draft"
+	OrderStatusConfirmed   = "confirmed"
+	OrderStatusPlanned     = "planned"
+	OrderStatusCutting     = "cutting"
+	OrderStatusCutComplete = "cut_complete"
+	OrderStatusHandedOver  = "handed_over"
+	OrderStatusCancelled   = "cancelled"
+)
+
+var orderStatusLabels = map[string]string{
+	OrderStatusDraft:       "草稿",
+	OrderStatusConfirmed:   "已确认",
+	OrderStatusPlanned:     "已排计划",
+	OrderStatusCutting:     "裁剪中",
+	OrderStatusCutComplete: "裁剪完成",
+	OrderStatusHandedOver:  "已交接",
+	OrderStatusCancelled:   "已取消",
+}
+
+// manualOrderTransitions 可以由用户通过接口手动触发的状态转换
+var manualOrderTransitions = map[string][]string{
+	OrderStatusDraft:       {OrderStatusConfirmed, OrderStatusCancelled},
+	OrderStatusConfirmed:   {OrderStatusCancelled},
+	OrderStatusPlanned:     {OrderStatusCancelled},
+	OrderStatusCutComplete: {OrderStatusHandedOver},
+}
+
+// automaticOrderTransitions 只能由业务事件自动触发的状态转换：
+// 关联计划 -> planned，删除计划 -> confirmed，开始拉布 -> cutting，
+// 全部任务完成 -> cut_complete，计划调整后出现未完成任务 -> 回到 cutting
+var automaticOrderTransitions = map[string][]string{
+	OrderStatusDraft:       {OrderStatusPlanned},
+	OrderStatusConfirmed:   {OrderStatusPlanned},
+	OrderStatusPlanned:     {OrderStatusConfirmed, OrderStatusCutting, OrderStatusCutComplete},
+	OrderStatusCutting:     {OrderStatusCutComplete},
+	OrderStatusCutComplete: {OrderStatusCutting},
+}
+
+func orderStatusLabel(status string) string {
+	if label, ok := orderStatusLabels[status]; ok {
+		return label
+	}
+	return status
+}
+
+func canTransitionOrder(from, to string, automatic bool) bool {
+	transitions := manualOrderTransitions
+	if automatic {
+		transitions = automaticOrderTransitions
+	}
+	for _, allowed := range transitions[from] {
+		if allowed == to {
+			return true
+		}
+	}
+	return false
+}
+
+// orderStatusMachine 在调用方的事务中执行订单状态转换，并写入状态变更历史。
+// 订单、计划和生产记录服务共用它，保证所有状态变更都经过同一套规则。
+type orderStatusMachine struct {
+	orderRepo repositories.ProductionOrderRepository
+}
+
+// transition 把订单从当前状态变更为 to，不允许的转换返回 ValidationError；状态未变化时不做任何事
+func (m orderStatusMachine) transition(tx *sqlx.Tx, actor models.Actor, orderID int, to string, automatic bool, reason string) error {
+	from, err := m.orderRepo.LockOrderStatus(tx, orderID)
+	if err != nil {
+		return err
+	}
+	return m.apply(tx, actor, orderID, from, to, automatic, reason)
+}
+
+func (m orderStatusMachine) apply(tx *sqlx.Tx, actor models.Actor, orderID int, from, to string, automatic bool, reason string) error {
+	if from == to {
+		return nil
+	}
+	if !canTransitionOrder(from, to, automatic) {
+		return &ValidationError{Message: fmt.Sprintf("订单状态不能从「%s」变更为「%s」", orderStatusLabel(from), orderStatusLabel(to))}
+	}
+	if err := m.orderRepo.UpdateOrderStatus(tx, orderID, to); err != nil {
+		return err
+	}
+	return m.orderRepo.CreateStatusChange(tx, &models.OrderStatusChange{
+		OrderID:       orderID,
+		FromStatus:    &from,
+		ToStatus:      to,
+		ActorWorkerID: actor.WorkerID,
+		ActorAPIKeyID: actor.APIKeyID,
+		Automatic:     automatic,
+		Reason:        reason,
+	})
+}
+
+// recordInitial 记录新订单的初始状态
+func (m orderStatusMachine) recordInitial(tx *sqlx.Tx, actor models.Actor, order *models.ProductionOrder, reason string) error {
+	return m.orderRepo.CreateStatusChange(tx, &models.OrderStatusChange{
+		OrderID:       order.OrderID,
+		ToStatus:      order.Status,
+		ActorWorkerID: actor.WorkerID,
+		ActorAPIKeyID: actor.APIKeyID,
+		Reason:        reason,
+	})
+}
+
+// releasePlan 删除订单关联的生产计划：已排计划的订单回到已确认，已取消的订单保持不变，
+// 已开始裁剪的订单不允许删除计划
+func (m orderStatusMachine) releasePlan(tx *sqlx.Tx, actor models.Actor, orderID int, reason string) error {
+	status, err := m.orderRepo.LockOrderStatus(tx, orderID)
+	if err != nil {
+		return err
+	}
+	switch status {
+	case OrderStatusPlanned:
+		return m.apply(tx, actor, orderID, status, OrderStatusConfirmed, true, reason)
+	case OrderStatusDraft, OrderStatusConfirmed, OrderStatusCancelled:
+		return nil
+	default:
+		return &ValidationError{Message: fmt.Sprintf("订单状态为「%s」，不能删除其生产计划", orderStatusLabel(status))}
+	}
+}
+
+// linkPlan 订单被关联到生产计划：草稿和已确认的订单进入已排计划，已排计划或裁剪中的订单保持不变，
+// 已取消或已交接的订单不能再关联计划
+func (m orderStatusMachine) linkPlan(tx *sqlx.Tx, actor models.Actor, orderID int, reason string) error {
+	status, err := m.orderRepo.LockOrderStatus(tx, orderID)
+	if err != nil {
+		return err
+	}
+	switch status {
+	case OrderStatusDraft, OrderStatusConfirmed:
+		return m.apply(tx, actor, orderID, status, OrderStatusPlanned, true, reason)
+	case OrderStatusPlanned, OrderStatusCutting, OrderStatusCutComplete:
+		return nil
+	default:
+		return &ValidationError{Message: fmt.Sprintf("订单状态为「%s」，不能关联生产计划", orderStatusLabel(status))}
+	}
+}
+
+// recordSpreading 收到拉布记录时把已排计划的订单推进到裁剪中，并检查是否已全部完成
+func (m orderStatusMachine) recordSpreading(tx *sqlx.Tx, actor models.Actor, orderID int) error {
+	status, err := m.orderRepo.LockOrderStatus(tx, orderID)
+	if err != nil {
+		return err
+	}
+	if status == OrderStatusPlanned {
+		if err := m.apply(tx, actor, orderID, status, OrderStatusCutting, true, "首次拉布记录"); err != nil {
+			return err
+		}
+	}
+	return m.syncProgress(tx, actor, orderID)
+}
+
+// syncProgress 根据关联计划的裁剪进度自动推进订单状态：
+// 已有拉布层数进入 cutting，全部任务完成进入 cut_complete，计划调整后又有未完成任务则回到 cutting
+func (m orderStatusMachine) syncProgress(tx *sqlx.Tx, actor models.Actor, orderID int) error {
+	status, err := m.orderRepo.LockOrderStatus(tx, orderID)
+	if err != nil {
+		return err
+	}
+	progress, err := m.orderRepo.GetTaskProgress(tx, orderID)
+	if err != nil {
+		return err
+	}
+	allComplete := progress.Tasks > 0 && progress.CompletedTasks == progress.Tasks
+
+	switch status {
+	case OrderStatusPlanned:
+		if allComplete {
+			return m.apply(tx, actor, orderID, status, OrderStatusCutComplete, true, "全部裁剪任务已完成")
+		}
+		if progress.CompletedLayers > 0 {
+			return m.apply(tx, actor, orderID, status, OrderStatusCutting, true, "开始拉布")
+		}
+	case OrderStatusCutting:
+		if allComplete {
+			return m.apply(tx, actor, orderID, status, OrderStatusCutComplete, true, "全部裁剪任务已完成")
+		}
+	case OrderStatusCutComplete:
+		if !allComplete {
+			return m.apply(tx, actor, orderID, status, OrderStatusCutting, true, "计划调整后有未完成的裁剪任务")
+		}
+	}
+	return nil
+}
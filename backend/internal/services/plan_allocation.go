@@ -0,0 +1,242 @@
+package services
+
+import (
+	"cutrix-backend/internal/models"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// plannedPiecesFromRequest 统计计划请求中每个 颜色+尺码 的计划件数 (尺码配比 × 计划层数)，
+// keys 按排版、任务、尺码在请求中出现的顺序排列
+func plannedPiecesFromRequest(req *models.CreateProductionPlanRequest) ([]orderItemKey, map[orderItemKey]int) {
+	keys := []orderItemKey{}
+	pieces := make(map[orderItemKey]int)
+	for _, layout := range req.Layouts {
+		for _, task := range layout.Tasks {
+			for _, ratio := range layout.Ratios {
+				key := orderItemKey{task.Color, ratio.Size}
+				if _, ok := pieces[key]; !ok {
+					keys = append(keys, key)
+				}
+				pieces[key] += ratio.Ratio * task.PlannedLayers
+			}
+		}
+	}
+	return keys, pieces
+}
+
+// planOrderRequests 合并请求中的 linked_order_id 和 orders，existing 为 orders 未给出时沿用的已关联订单
+func planOrderRequests(req *models.CreateProductionPlanRequest, existing []models.PlanOrder) ([]models.PlanOrderRequest, error) {
+	requests := []models.PlanOrderRequest{}
+	seen := make(map[int]bool)
+	if req.Orders == nil {
+		for _, order := range existing {
+			requests = append(requests, models.PlanOrderRequest{OrderID: order.OrderID})
+			seen[order.OrderID] = true
+		}
+	}
+	for _, order := range req.Orders {
+		if order.OrderID <= 0 {
+			return nil, &ValidationError{Message: "关联订单ID无效"}
+		}
+		if seen[order.OrderID] {
+			return nil, &ValidationError{Message: fmt.Sprintf("订单 %d 在计划中重复关联", order.OrderID)}
+		}
+		seen[order.OrderID] = true
+		requests = append(requests, order)
+	}
+	if req.LinkedOrderID != nil && !seen[*req.LinkedOrderID] {
+		requests = append(requests, models.PlanOrderRequest{OrderID: *req.LinkedOrderID})
+	}
+	return requests, nil
+}
+
+// resolvePlanOrders 校验计划关联的订单并计算每个订单分到的计划件数。
+// 订单必须与计划同款；明确给出的分配按原样使用 (合计不能超过计划件数)，
+// 其余订单按交期顺序依次分配各自尚未被其他计划覆盖的数量，剩余件数记在最后一个下了该 颜色+尺码 的订单上。
+func (s *productionPlanService) resolvePlanOrders(tx *sqlx.Tx, planID int, req *models.CreateProductionPlanRequest, requests []models.PlanOrderRequest) ([]models.PlanOrder, error) {
+	keys, planned := plannedPiecesFromRequest(req)
+
+	type resolved struct {
+		order    *models.ProductionOrder
+		request  models.PlanOrderRequest
+		assigned map[orderItemKey]int
+	}
+	resolvedOrders := make([]*resolved, 0, len(requests))
+	for _, request := range requests {
+		order, err := s.orderRepo.GetOrderWithItems(request.OrderID)
+		if err != nil {
+			if err.Error() == "order not found" {
+				return nil, &ValidationError{Message: fmt.Sprintf("订单 %d 不存在", request.OrderID)}
+			}
+			return nil, err
+		}
+		if order.StyleID != req.StyleID {
+			return nil, &ValidationError{Message: fmt.Sprintf("订单「%s」的款号与计划不一致", order.OrderNumber)}
+		}
+		resolvedOrders = append(resolvedOrders, &resolved{order: order, request: request, assigned: make(map[orderItemKey]int)})
+	}
+	sort.SliceStable(resolvedOrders, func(i, j int) bool {
+		a, b := resolvedOrders[i].order, resolvedOrders[j].order
+		if (a.DueDate == nil) != (b.DueDate == nil) {
+			return a.DueDate != nil
+		}
+		if a.DueDate != nil && !a.DueDate.Equal(*b.DueDate) {
+			return a.DueDate.Before(*b.DueDate)
+		}
+		return a.OrderID < b.OrderID
+	})
+
+	// 先扣除明确给出的分配
+	remaining := make(map[orderItemKey]int, len(planned))
+	for key, pieces := range planned {
+		remaining[key] = pieces
+	}
+	autoOrders := []*resolved{}
+	for _, r := range resolvedOrders {
+		if len(r.request.Allocations) == 0 {
+			autoOrders = append(autoOrders, r)
+			continue
+		}
+		for _, allocation := range r.request.Allocations {
+			key := orderItemKey{allocation.Color, allocation.Size}
+			if allocation.Quantity <= 0 {
+				return nil, &ValidationError{Message: fmt.Sprintf("订单「%s」颜色 %s 尺码 %s 的分配件数必须大于0", r.order.OrderNumber, key.color, key.size)}
+			}
+			if _, ok := planned[key]; !ok {
+				return nil, &ValidationError{Message: fmt.Sprintf("计划中没有颜色 %s 尺码 %s，不能分配给订单「%s」", key.color, key.size, r.order.OrderNumber)}
+			}
+			r.assigned[key] += allocation.Quantity
+			remaining[key] -= allocation.Quantity
+			if remaining[key] < 0 {
+				return nil, &ValidationError{Message: fmt.Sprintf("颜色 %s 尺码 %s 分配给各订单的件数合计超过计划件数 %d", key.color, key.size, planned[key])}
+			}
+		}
+	}
+
+	// 其余订单按交期顺序自动分配
+	if len(autoOrders) > 0 {
+		needs := make([]map[orderItemKey]int, len(autoOrders))
+		for i, r := range autoOrders {
+			needs[i] = make(map[orderItemKey]int)
+			for _, item := range r.order.Items {
+				needs[i][orderItemKey{item.Color, item.Size}] += item.Quantity
+			}
+			allocated, err := s.planRepo.GetAllocatedPieces(tx, r.order.OrderID, planID)
+			if err != nil {
+				return nil, err
+			}
+			for _, allocation := range allocated {
+				needs[i][orderItemKey{allocation.Color, allocation.Size}] -= allocation.Quantity
+			}
+		}
+		for _, key := range keys {
+			last := -1
+			for i, r := range autoOrders {
+				if _, ordered := needs[i][key]; ordered {
+					last = i
+				}
+				if pieces := min(remaining[key], needs[i][key]); pieces > 0 {
+					r.assigned[key] += pieces
+					remaining[key] -= pieces
+				}
+			}
+			if remaining[key] > 0 {
+				if last < 0 {
+					last = 0
+				}
+				autoOrders[last].assigned[key] += remaining[key]
+				remaining[key] = 0
+			}
+		}
+	}
+
+	orders := make([]models.PlanOrder, 0, len(resolvedOrders))
+	for _, r := range resolvedOrders {
+		planOrder := models.PlanOrder{
+			OrderID:     r.order.OrderID,
+			OrderNumber: r.order.OrderNumber,
+			DueDate:     r.order.DueDate,
+			Allocations: []models.PlanOrderAllocation{},
+		}
+		for _, key := range keys {
+			if pieces := r.assigned[key]; pieces > 0 {
+				planOrder.Allocations = append(planOrder.Allocations, models.PlanOrderAllocation{
+					PlanID: planID, OrderID: r.order.OrderID, Color: key.color, Size: key.size, Quantity: pieces,
+				})
+			}
+		}
+		orders = append(orders, planOrder)
+	}
+	return orders, nil
+}
+
+// planRequestFrom 把已保存的计划还原为计划请求 (不含关联订单)，用于按现有排版重新分配和比对订单
+func planRequestFrom(plan *models.ProductionPlan) *models.CreateProductionPlanRequest {
+	req := &models.CreateProductionPlanRequest{PlanName: plan.PlanName, StyleID: plan.StyleID, Layouts: make([]models.CreateLayout, 0, len(plan.Layouts))}
+	for _, layout := range plan.Layouts {
+		layoutReq := models.CreateLayout{LayoutName: layout.LayoutName, Description: layout.Description, Ratios: []models.CreateRatio{}, Tasks: []models.CreateTaskForPlan{}}
+		for _, ratio := range layout.Ratios {
+			layoutReq.Ratios = append(layoutReq.Ratios, models.CreateRatio{Size: ratio.Size, Ratio: ratio.Ratio})
+		}
+		for _, task := range layout.Tasks {
+			layoutReq.Tasks = append(layoutReq.Tasks, models.CreateTaskForPlan{Color: task.Color, PlannedLayers: task.PlannedLayers})
+		}
+		req.Layouts = append(req.Layouts, layoutReq)
+	}
+	return req
+}
+
+// reallocateRestoredPlan 在恢复计划的事务中按关联订单当前尚未被其他计划覆盖的数量重新分配计划件数
+// (与修改计划时未给出 orders 相同)，并重新比对订单、保存警告。计划在回收站期间订单已由其他计划排产，
+// 恢复后出现删除前没有的超裁时拒绝恢复，避免同一订单被重复分配
+func (s *productionPlanService) reallocateRestoredPlan(tx *sqlx.Tx, planID int) error {
+	plan, err := s.planRepo.GetPlanWithDetailsInTx(tx, planID)
+	if err != nil {
+		return err
+	}
+	if len(plan.Orders) == 0 {
+		return nil
+	}
+	style, err := s.styleRepo.GetByID(plan.StyleID)
+	if err != nil {
+		return err
+	}
+	req := planRequestFrom(plan)
+	requests, err := planOrderRequests(req, plan.Orders)
+	if err != nil {
+		return err
+	}
+	orders, err := s.resolvePlanOrders(tx, planID, req, requests)
+	if err != nil {
+		return err
+	}
+	warnings, err := s.planOrderWarnings(tx, planID, req, style, orders, defaultPlanOvercutTolerance)
+	if err != nil {
+		return err
+	}
+
+	accepted := make(map[orderItemKey]bool, len(plan.Warnings))
+	for _, warning := range plan.Warnings {
+		if warning.Type == models.PlanWarningOverCut {
+			accepted[orderItemKey{warning.Color, warning.Size}] = true
+		}
+	}
+	overcut := []string{}
+	for _, warning := range warnings {
+		if warning.Type == models.PlanWarningOverCut && !accepted[orderItemKey{warning.Color, warning.Size}] {
+			overcut = append(overcut, warning.Message)
+		}
+	}
+	if len(overcut) > 0 {
+		return &ValidationError{Message: fmt.Sprintf("生产计划「%s」删除后关联订单已由其他计划排产，恢复后会重复分配：%s", plan.PlanName, strings.Join(overcut, "；"))}
+	}
+
+	if err := s.planRepo.SetPlanOrders(tx, planID, orders); err != nil {
+		return err
+	}
+	return s.planRepo.SetPlanWarnings(tx, planID, warnings)
+}
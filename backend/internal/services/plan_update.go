@@ -0,0 +1,281 @@
+package services
+
+import (
+	"cutrix-backend/internal/models"
+	"fmt"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// planTaskKey 用排版名称和颜色标识更新后计划中的任务，作为生产记录的转移目标
+type planTaskKey struct {
+	layoutName string
+	color      string
+}
+
+// updatePlanLayouts 按排版和任务比对计划的新旧内容并就地更新：匹配到的排版修改名称、说明和尺码配比，
+// 匹配到的任务保留任务ID、已完成层数和生产记录，只修改颜色和计划层数；未匹配的排版和任务新建。
+// 已有进度 (已完成层数大于0或已有生产记录) 的任务不能改颜色，计划层数不能少于已完成层数，
+// 其所在排版不能改尺码配比。删除的任务若已有进度，必须在 LogReassignments 中指定转移到
+// 同颜色的哪个任务，且转移后目标任务的已完成层数不能超过计划层数，否则拒绝保存
+func (s *productionPlanService) updatePlanLayouts(tx *sqlx.Tx, planID int, req *models.CreateProductionPlanRequest) error {
+	existing, err := s.planRepo.GetLayoutsForUpdate(tx, planID)
+	if err != nil {
+		return err
+	}
+	matches, err := matchPlanLayouts(req.Layouts, existing)
+	if err != nil {
+		return err
+	}
+	logCounts := map[int]int{}
+	for _, layout := range existing {
+		for _, task := range layout.Tasks {
+			if logCounts[task.TaskID], err = s.planRepo.CountTaskLogs(tx, task.TaskID); err != nil {
+				return err
+			}
+		}
+	}
+
+	// updated 为更新后计划中的任务，已完成层数为转移生产记录之前的值
+	updated := map[int]models.ProductionTask{}
+	targets := map[planTaskKey]int{}
+	removed := []models.ProductionTask{}
+	for i := range req.Layouts {
+		layoutReq := &req.Layouts[i]
+		var layoutID int
+		var oldTasks []models.ProductionTask
+		if layout := matches[i]; layout != nil {
+			layoutID, oldTasks = layout.LayoutID, layout.Tasks
+			if err := s.planRepo.UpdateLayout(tx, layoutID, layoutReq); err != nil {
+				return err
+			}
+			if !sameRatios(layout.Ratios, layoutReq.Ratios) {
+				for _, task := range oldTasks {
+					if taskHasProgress(task, logCounts) {
+						return &ValidationError{Message: fmt.Sprintf("排版「%s」的颜色 %s 已有生产进度，不能修改尺码配比", layout.LayoutName, task.Color)}
+					}
+				}
+				if err := s.planRepo.ReplaceRatios(tx, layoutID, layoutReq.Ratios); err != nil {
+					return err
+				}
+			}
+		} else {
+			layout, err := s.planRepo.CreateLayout(tx, planID, layoutReq)
+			if err != nil {
+				return err
+			}
+			layoutID = layout.LayoutID
+			if err := s.planRepo.CreateRatios(tx, layoutID, layoutReq.Ratios); err != nil {
+				return err
+			}
+		}
+
+		taskMatches, err := matchPlanTasks(layoutReq, oldTasks)
+		if err != nil {
+			return err
+		}
+		for j, taskReq := range layoutReq.Tasks {
+			task := models.ProductionTask{LayoutName: layoutReq.LayoutName, Color: taskReq.Color, PlannedLayers: taskReq.PlannedLayers}
+			if old := taskMatches[j]; old != nil {
+				if taskReq.Color != old.Color && taskHasProgress(*old, logCounts) {
+					return &ValidationError{Message: fmt.Sprintf("排版「%s」颜色 %s 的任务 (ID %d) 已有生产进度，不能修改颜色", layoutReq.LayoutName, old.Color, old.TaskID)}
+				}
+				if taskReq.PlannedLayers < old.CompletedLayers {
+					return &ValidationError{Message: fmt.Sprintf("排版「%s」颜色 %s 已完成 %d 层，计划层数不能少于已完成层数", layoutReq.LayoutName, old.Color, old.CompletedLayers)}
+				}
+				task.TaskID, task.CompletedLayers = old.TaskID, old.CompletedLayers
+				if err := s.planRepo.UpdateTask(tx, task.TaskID, layoutReq.LayoutName, taskReq); err != nil {
+					return err
+				}
+			} else if task.TaskID, err = s.planRepo.CreateTask(tx, req.StyleID, layoutID, layoutReq.LayoutName, taskReq); err != nil {
+				return err
+			}
+			updated[task.TaskID] = task
+			if _, ok := targets[planTaskKey{layoutReq.LayoutName, taskReq.Color}]; !ok {
+				targets[planTaskKey{layoutReq.LayoutName, taskReq.Color}] = task.TaskID
+			}
+		}
+		for _, old := range oldTasks {
+			if _, ok := updated[old.TaskID]; !ok {
+				removed = append(removed, old)
+			}
+		}
+	}
+	removedLayouts := []int{}
+	for _, layout := range existing {
+		if !layoutMatched(matches, layout.LayoutID) {
+			removed = append(removed, layout.Tasks...)
+			removedLayouts = append(removedLayouts, layout.LayoutID)
+		}
+	}
+
+	reassign, err := planLogReassignments(req.LogReassignments, removed, updated, targets)
+	if err != nil {
+		return err
+	}
+	for _, task := range removed {
+		if taskHasProgress(task, logCounts) {
+			to, ok := reassign[task.TaskID]
+			if !ok {
+				return &ValidationError{Message: fmt.Sprintf("排版「%s」颜色 %s 的任务 (ID %d) 已完成 %d 层、有 %d 条生产记录，不能删除；请保留该任务，或在 log_reassignments 中指定把生产记录转移到哪个任务", task.LayoutName, task.Color, task.TaskID, task.CompletedLayers, logCounts[task.TaskID])}
+			}
+			target := updated[to]
+			if target.Color != task.Color {
+				return &ValidationError{Message: fmt.Sprintf("任务 %d 的颜色是 %s，生产记录不能转移到颜色为 %s 的任务 %d", task.TaskID, task.Color, target.Color, to)}
+			}
+			if target.CompletedLayers+task.CompletedLayers > target.PlannedLayers {
+				return &ValidationError{Message: fmt.Sprintf("任务 %d 的 %d 层转移到任务 %d 后，已完成 %d 层，超过计划层数 %d", task.TaskID, task.CompletedLayers, to, target.CompletedLayers+task.CompletedLayers, target.PlannedLayers)}
+			}
+			target.CompletedLayers += task.CompletedLayers
+			updated[to] = target
+			if err := s.planRepo.ReassignTaskLogs(tx, task.TaskID, to); err != nil {
+				return err
+			}
+		}
+		if err := s.planRepo.DeleteTask(tx, task.TaskID); err != nil {
+			return err
+		}
+	}
+	for _, layoutID := range removedLayouts {
+		if err := s.planRepo.DeleteLayout(tx, layoutID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// matchPlanLayouts 为请求中的每个排版找到对应的已有排版 (没有对应时为 nil)：
+// 先按 layout_id 匹配，其余按排版名称匹配尚未被匹配的已有排版
+func matchPlanLayouts(layouts []models.CreateLayout, existing []models.CuttingLayout) ([]*models.CuttingLayout, error) {
+	matches := make([]*models.CuttingLayout, len(layouts))
+	matched := map[int]bool{}
+	for i, layoutReq := range layouts {
+		if layoutReq.LayoutID == nil {
+			continue
+		}
+		for j := range existing {
+			if existing[j].LayoutID == *layoutReq.LayoutID {
+				matches[i] = &existing[j]
+			}
+		}
+		if matches[i] == nil {
+			return nil, &ValidationError{Message: fmt.Sprintf("排版 %d 不属于该计划", *layoutReq.LayoutID)}
+		}
+		if matched[*layoutReq.LayoutID] {
+			return nil, &ValidationError{Message: fmt.Sprintf("排版 %d 在计划中重复出现", *layoutReq.LayoutID)}
+		}
+		matched[*layoutReq.LayoutID] = true
+	}
+	for i, layoutReq := range layouts {
+		if layoutReq.LayoutID != nil {
+			continue
+		}
+		for j := range existing {
+			if !matched[existing[j].LayoutID] && existing[j].LayoutName == layoutReq.LayoutName {
+				matches[i] = &existing[j]
+				matched[existing[j].LayoutID] = true
+				break
+			}
+		}
+	}
+	return matches, nil
+}
+
+func layoutMatched(matches []*models.CuttingLayout, layoutID int) bool {
+	for _, layout := range matches {
+		if layout != nil && layout.LayoutID == layoutID {
+			return true
+		}
+	}
+	return false
+}
+
+// matchPlanTasks 为排版中的每个任务找到对应的已有任务 (没有对应时为 nil)：
+// 先按 task_id 匹配，其余按颜色依次匹配尚未被匹配的已有任务
+func matchPlanTasks(layoutReq *models.CreateLayout, oldTasks []models.ProductionTask) ([]*models.ProductionTask, error) {
+	matches := make([]*models.ProductionTask, len(layoutReq.Tasks))
+	matched := map[int]bool{}
+	for i, taskReq := range layoutReq.Tasks {
+		if taskReq.TaskID == nil {
+			continue
+		}
+		for j := range oldTasks {
+			if oldTasks[j].TaskID == *taskReq.TaskID {
+				matches[i] = &oldTasks[j]
+			}
+		}
+		if matches[i] == nil {
+			return nil, &ValidationError{Message: fmt.Sprintf("任务 %d 不属于排版「%s」", *taskReq.TaskID, layoutReq.LayoutName)}
+		}
+		if matched[*taskReq.TaskID] {
+			return nil, &ValidationError{Message: fmt.Sprintf("任务 %d 在排版「%s」中重复出现", *taskReq.TaskID, layoutReq.LayoutName)}
+		}
+		matched[*taskReq.TaskID] = true
+	}
+	for i, taskReq := range layoutReq.Tasks {
+		if taskReq.TaskID != nil {
+			continue
+		}
+		for j := range oldTasks {
+			if !matched[oldTasks[j].TaskID] && oldTasks[j].Color == taskReq.Color {
+				matches[i] = &oldTasks[j]
+				matched[oldTasks[j].TaskID] = true
+				break
+			}
+		}
+	}
+	return matches, nil
+}
+
+// planLogReassignments 解析生产记录的转移目标，返回 被删除任务ID -> 目标任务ID。
+// 只能转移本次被删除的任务，目标必须是更新后计划中的任务
+func planLogReassignments(reassignments []models.TaskLogReassignment, removed []models.ProductionTask, updated map[int]models.ProductionTask, targets map[planTaskKey]int) (map[int]int, error) {
+	removedIDs := make(map[int]bool, len(removed))
+	for _, task := range removed {
+		removedIDs[task.TaskID] = true
+	}
+	reassign := make(map[int]int, len(reassignments))
+	for _, r := range reassignments {
+		if !removedIDs[r.FromTaskID] {
+			return nil, &ValidationError{Message: fmt.Sprintf("任务 %d 没有从计划中删除，不能转移它的生产记录", r.FromTaskID)}
+		}
+		if _, ok := reassign[r.FromTaskID]; ok {
+			return nil, &ValidationError{Message: fmt.Sprintf("任务 %d 的生产记录转移重复指定", r.FromTaskID)}
+		}
+		if r.ToTaskID != nil {
+			if _, ok := updated[*r.ToTaskID]; !ok {
+				return nil, &ValidationError{Message: fmt.Sprintf("生产记录的目标任务 %d 不在更新后的计划中", *r.ToTaskID)}
+			}
+			reassign[r.FromTaskID] = *r.ToTaskID
+			continue
+		}
+		to, ok := targets[planTaskKey{r.LayoutName, r.Color}]
+		if !ok {
+			return nil, &ValidationError{Message: fmt.Sprintf("更新后的计划中没有排版「%s」颜色 %s 的任务，不能转移生产记录", r.LayoutName, r.Color)}
+		}
+		reassign[r.FromTaskID] = to
+	}
+	return reassign, nil
+}
+
+// taskHasProgress 判断任务是否已有生产进度 (已完成层数大于0或已有生产记录)
+func taskHasProgress(task models.ProductionTask, logCounts map[int]int) bool {
+	return task.CompletedLayers > 0 || logCounts[task.TaskID] > 0
+}
+
+// sameRatios 判断排版现有的尺码配比与请求中的配比是否相同 (不论顺序)
+func sameRatios(existing []models.LayoutSizeRatio, ratios []models.CreateRatio) bool {
+	if len(existing) != len(ratios) {
+		return false
+	}
+	current := make(map[string]int, len(existing))
+	for _, ratio := range existing {
+		current[ratio.Size] = ratio.Ratio
+	}
+	for _, ratio := range ratios {
+		if r, ok := current[ratio.Size]; !ok || r != ratio.Ratio {
+			return false
+		}
+	}
+	return true
+}
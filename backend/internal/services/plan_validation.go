@@ -0,0 +1,161 @@
+package services
+
+import (
+	"cutrix-backend/internal/models"
+	"cutrix-backend/pkg/sizes"
+	"fmt"
+	"strings"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// defaultPlanOvercutTolerance 请求未指定时允许超裁的百分比
+const defaultPlanOvercutTolerance = 5.0
+
+// PlanWarningsError 表示严格模式下计划与关联订单不一致，计划未保存
+type PlanWarningsError struct {
+	Warnings []models.PlanWarning
+}
+
+func (e *PlanWarningsError) Error() string {
+	messages := make([]string, len(e.Warnings))
+	for i, warning := range e.Warnings {
+		messages[i] = warning.Message
+	}
+	return "计划与关联订单不一致：" + strings.Join(messages, "；")
+}
+
+// planValidation 是保存计划时与关联订单比对的方式
+type planValidation struct {
+	strict    bool
+	tolerance float64
+}
+
+// planValidationFrom 解析请求中的校验模式和允许超裁比例，校验模式为空时为宽松模式
+func planValidationFrom(req *models.CreateProductionPlanRequest) (planValidation, error) {
+	validation := planValidation{tolerance: defaultPlanOvercutTolerance}
+	switch req.ValidationMode {
+	case "", models.PlanValidationLenient:
+	case models.PlanValidationStrict:
+		validation.strict = true
+	default:
+		return validation, &ValidationError{Message: "校验模式只能是 strict 或 lenient"}
+	}
+	if req.OvercutTolerance != nil {
+		if *req.OvercutTolerance < 0 || *req.OvercutTolerance > 100 {
+			return validation, &ValidationError{Message: "允许超裁比例必须在 0 到 100 之间"}
+		}
+		validation.tolerance = *req.OvercutTolerance
+	}
+	return validation, nil
+}
+
+// checkPlanOrders 比对计划件数与关联订单，严格模式下有警告时返回 PlanWarningsError，
+// 否则把警告随计划保存 (没有警告时清除之前保存的警告)
+func (s *productionPlanService) checkPlanOrders(tx *sqlx.Tx, planID int, req *models.CreateProductionPlanRequest, style *models.Style, orders []models.PlanOrder, validation planValidation) error {
+	warnings, err := s.planOrderWarnings(tx, planID, req, style, orders, validation.tolerance)
+	if err != nil {
+		return err
+	}
+	if validation.strict && len(warnings) > 0 {
+		return &PlanWarningsError{Warnings: warnings}
+	}
+	return s.planRepo.SetPlanWarnings(tx, planID, warnings)
+}
+
+// planOrderWarnings 把计划每个 颜色+尺码 的计划件数 (尺码配比 × 计划层数) 与关联订单尚未被其他计划覆盖的数量比对：
+// 少于订单数量、超出订单数量且超过允许的超裁比例，以及订单中没有的颜色、尺码都会产生警告。
+// 计划没有关联订单时不比对。警告按颜色分组，组内按款号尺码组的顺序排列
+func (s *productionPlanService) planOrderWarnings(tx *sqlx.Tx, planID int, req *models.CreateProductionPlanRequest, style *models.Style, orders []models.PlanOrder, tolerance float64) ([]models.PlanWarning, error) {
+	warnings := []models.PlanWarning{}
+	if len(orders) == 0 {
+		return warnings, nil
+	}
+	plannedKeys, planned := plannedPiecesFromRequest(req)
+
+	neededKeys := []orderItemKey{}
+	needed := map[orderItemKey]int{}
+	orderColors, orderSizes := map[string]bool{}, map[string]bool{}
+	for _, planOrder := range orders {
+		order, err := s.orderRepo.GetOrderWithItems(planOrder.OrderID)
+		if err != nil {
+			return nil, err
+		}
+		for _, item := range order.Items {
+			key := orderItemKey{item.Color, item.Size}
+			if _, ok := needed[key]; !ok {
+				neededKeys = append(neededKeys, key)
+			}
+			needed[key] += item.Quantity
+			orderColors[item.Color] = true
+			orderSizes[item.Size] = true
+		}
+		allocated, err := s.planRepo.GetAllocatedPieces(tx, order.OrderID, planID)
+		if err != nil {
+			return nil, err
+		}
+		for _, allocation := range allocated {
+			key := orderItemKey{allocation.Color, allocation.Size}
+			if _, ok := needed[key]; ok {
+				needed[key] -= allocation.Quantity
+			}
+		}
+	}
+
+	// 订单中没有的颜色、尺码按颜色或尺码合计，颜色和尺码都在订单中时按 颜色+尺码 列出
+	unknownColors, unknownSizes := []string{}, []string{}
+	unknownColorPieces, unknownSizePieces := map[string]int{}, map[string]int{}
+	for _, key := range plannedKeys {
+		pieces := planned[key]
+		switch {
+		case !orderColors[key.color]:
+			if _, ok := unknownColorPieces[key.color]; !ok {
+				unknownColors = append(unknownColors, key.color)
+			}
+			unknownColorPieces[key.color] += pieces
+		case !orderSizes[key.size]:
+			if _, ok := unknownSizePieces[key.size]; !ok {
+				unknownSizes = append(unknownSizes, key.size)
+			}
+			unknownSizePieces[key.size] += pieces
+		default:
+			if _, ok := needed[key]; !ok {
+				warnings = append(warnings, models.PlanWarning{
+					Type: models.PlanWarningUnknownItem, Color: key.color, Size: key.size, PlannedPieces: pieces,
+					Message: fmt.Sprintf("关联订单中没有颜色 %s 尺码 %s，计划 %d 件", key.color, key.size, pieces),
+				})
+			}
+		}
+	}
+	for _, color := range unknownColors {
+		warnings = append(warnings, models.PlanWarning{
+			Type: models.PlanWarningUnknownColor, Color: color, PlannedPieces: unknownColorPieces[color],
+			Message: fmt.Sprintf("颜色 %s 不在关联订单中，计划 %d 件", color, unknownColorPieces[color]),
+		})
+	}
+	for _, size := range unknownSizes {
+		warnings = append(warnings, models.PlanWarning{
+			Type: models.PlanWarningUnknownSize, Size: size, PlannedPieces: unknownSizePieces[size],
+			Message: fmt.Sprintf("尺码 %s 不在关联订单中，计划 %d 件", size, unknownSizePieces[size]),
+		})
+	}
+
+	for _, key := range neededKeys {
+		quantity, pieces := max(needed[key], 0), planned[key]
+		warning := models.PlanWarning{Color: key.color, Size: key.size, OrderedQuantity: quantity, PlannedPieces: pieces}
+		switch {
+		case pieces < quantity:
+			warning.Type = models.PlanWarningUnderCut
+			warning.Message = fmt.Sprintf("颜色 %s 尺码 %s 计划 %d 件，少于订单未排数量 %d 件", key.color, key.size, pieces, quantity)
+		case pieces > quantity+int(float64(quantity)*tolerance/100):
+			warning.Type = models.PlanWarningOverCut
+			warning.Message = fmt.Sprintf("颜色 %s 尺码 %s 计划 %d 件，超出订单未排数量 %d 件，超过允许的超裁比例 %g%%", key.color, key.size, pieces, quantity, tolerance)
+		default:
+			continue
+		}
+		warnings = append(warnings, warning)
+	}
+
+	sizes.SortByColor(sizes.LoadRun(style.SizeScale, style.Sizes), warnings, func(warning models.PlanWarning) (string, string) { return warning.Color, warning.Size })
+	return warnings, nil
+}
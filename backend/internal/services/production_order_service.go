@@ -3,31 +3,57 @@ package services
 import (
 	"cutrix-backend/internal/models"
 	"cutrix-backend/internal/repositories"
+	"cutrix-backend/pkg/sizes"
+	"errors"
 	"fmt"
+	"log"
+	"strings"
 	"time"
 
 	"github.com/jmoiron/sqlx"
 )
 
 type ProductionOrderService interface {
-	CreateOrder(order *models.CreateProductionOrderRequest) (*models.ProductionOrder, error)
+	CreateOrder(actor models.Actor, order *models.CreateProductionOrderRequest) (*models.ProductionOrder, error)
 	GetOrderByID(id int) (*models.ProductionOrder, error)
-	GetAllOrders(styleNumberQuery string) ([]models.ProductionOrder, error)
+	GetAllOrders(filter *models.ProductionOrderFilter) ([]models.ProductionOrder, int, error)
 	GetAllUnplannedOrders() ([]models.ProductionOrder, error) // <-- 新增
-	DeleteOrderByID(id int) error
+	// GetAtRiskOrders 返回 days 天内到期 (含已逾期) 且裁剪进度低于 threshold 的未完成订单
+	GetAtRiskOrders(days int, threshold float64) ([]models.AtRiskOrder, error)
+	UpdateOrder(actor models.Actor, id int, req *models.UpdateProductionOrderRequest) (*models.UpdateProductionOrderResponse, error)
+	UpdateOrderDetails(actor models.Actor, id int, req *models.UpdateOrderDetailsRequest) (*models.ProductionOrder, error)
+	ImportOrders(actor models.Actor, rows [][]string, opts *models.ImportOrdersOptions) (*models.ImportOrdersResult, error)
+	DeleteOrderByID(actor models.Actor, id int) error
+	// TransitionOrder 手动变更订单状态 (确认、取消、交接)
+	TransitionOrder(actor models.Actor, id int, status string, reason string) (*models.ProductionOrder, error)
+	GetStatusHistory(id int) ([]models.OrderStatusChange, error)
+	// GetFulfillment 按 颜色+尺码 对比订单数量、关联计划的计划件数和实际裁剪件数
+	GetFulfillment(id int) (*models.OrderFulfillment, error)
 }
 
 type productionOrderService struct {
-	db        *sqlx.DB
-	orderRepo repositories.ProductionOrderRepository
-	styleRepo repositories.StyleRepository
+	db           *sqlx.DB
+	orderRepo    repositories.ProductionOrderRepository
+	styleRepo    repositories.StyleRepository
+	planRepo     repositories.ProductionPlanRepository
+	customerRepo repositories.CustomerRepository
+	settingsRepo repositories.SettingsRepository
+	recycleBin   repositories.RecycleBinRepository
+	status       orderStatusMachine
+	audit        auditRecorder
 }
 
-func NewProductionOrderService(db *sqlx.DB, orderRepo repositories.ProductionOrderRepository, styleRepo repositories.StyleRepository) ProductionOrderService {
+func NewProductionOrderService(db *sqlx.DB, orderRepo repositories.ProductionOrderRepository, styleRepo repositories.StyleRepository, planRepo repositories.ProductionPlanRepository, customerRepo repositories.CustomerRepository, settingsRepo repositories.SettingsRepository, recycleBinRepo repositories.RecycleBinRepository, auditRepo repositories.AuditRepository) ProductionOrderService {
 	return &productionOrderService{
-		db:        db,
-		orderRepo: orderRepo,
-		styleRepo: styleRepo,
+		db:           db,
+		orderRepo:    orderRepo,
+		styleRepo:    styleRepo,
+		planRepo:     planRepo,
+		customerRepo: customerRepo,
+		settingsRepo: settingsRepo,
+		recycleBin:   recycleBinRepo,
+		status:       orderStatusMachine{orderRepo: orderRepo},
+		audit:        auditRecorder{repo: auditRepo},
 	}
 }
 
@@ -37,58 +63,275 @@ func (s *productionOrderService) GetAllUnplannedOrders() ([]models.ProductionOrd
 
 // ... (其他函数不变)
 
-func (s *productionOrderService) CreateOrder(req *models.CreateProductionOrderRequest) (*models.ProductionOrder, error) {
+func (s *productionOrderService) CreateOrder(actor models.Actor, req *models.CreateProductionOrderRequest) (*models.ProductionOrder, error) {
+	details, err := s.parseOrderDetails(req.CustomerID, req.DueDate, req.Priority, req.Remarks)
+	if err != nil {
+		return nil, err
+	}
+
 	tx, err := s.db.Beginx()
 	if err != nil {
 		return nil, fmt.Errorf("failed to begin transaction: %w", err)
 	}
 	defer tx.Rollback()
 
-	// 1. 获取或创建款号
-	style, err := s.styleRepo.GetByNumber(req.StyleNumber)
+	order, style, styleCreated, err := s.createOrderInTx(tx, actor, req.StyleNumber, details, req.Items)
+	if err != nil {
+		return nil, err
+	}
+
+	created, err := s.orderRepo.GetOrderWithItemsInTx(tx, order.OrderID)
+	if err != nil {
+		return nil, err
+	}
+	if styleCreated {
+		if err := s.audit.record(tx, actor, AuditActionCreate, AuditEntityStyle, int64(style.StyleID), nil, style); err != nil {
+			return nil, err
+		}
+	}
+	if err := s.audit.record(tx, actor, AuditActionCreate, AuditEntityProductionOrder, int64(created.OrderID), nil, created); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return created, nil
+}
+
+// orderDetails 是订单的客户、交期、优先级和备注
+type orderDetails struct {
+	customer *models.Customer
+	dueDate  *time.Time
+	priority string
+	remarks  string
+}
+
+// parseOrderDetails 校验客户是否存在并解析交期 (YYYY-MM-DD)，优先级为空时取 normal
+func (s *productionOrderService) parseOrderDetails(customerID *int, dueDate, priority, remarks string) (*orderDetails, error) {
+	details := &orderDetails{priority: strings.TrimSpace(priority), remarks: strings.TrimSpace(remarks)}
+	if customerID != nil {
+		customer, err := s.customerRepo.GetByID(*customerID)
+		if err != nil {
+			if err.Error() == "customer not found" {
+				return nil, &ValidationError{Message: "客户不存在"}
+			}
+			return nil, err
+		}
+		details.customer = customer
+	}
+	if dueDate = strings.TrimSpace(dueDate); dueDate != "" {
+		t, err := time.Parse("2006-01-02", dueDate)
+		if err != nil {
+			return nil, &ValidationError{Message: "交期必须是 YYYY-MM-DD 格式"}
+		}
+		details.dueDate = &t
+	}
+	if details.priority == "" {
+		details.priority = models.OrderPriorityNormal
+	}
+	if !isOrderPriority(details.priority) {
+		return nil, &ValidationError{Message: "优先级只能是 low、normal、high 或 urgent"}
+	}
+	return details, nil
+}
+
+func isOrderPriority(priority string) bool {
+	switch priority {
+	case models.OrderPriorityLow, models.OrderPriorityNormal, models.OrderPriorityHigh, models.OrderPriorityUrgent:
+		return true
+	}
+	return false
+}
+
+// normalizeOrderItemsForStyle 校验订单明细的颜色和尺码属于款号的颜色和尺码组，
+// 把尺码规范为款号尺码组中的写法，规范化后同一 颜色+尺码 不能重复
+func normalizeOrderItemsForStyle(style *models.Style, items []models.CreateOrderItem) ([]models.CreateOrderItem, error) {
+	normalized := make([]models.CreateOrderItem, 0, len(items))
+	seen := make(map[orderItemKey]bool, len(items))
+	for _, item := range items {
+		size, err := normalizeStyleColorSize(style, item.Color, item.Size)
+		if err != nil {
+			return nil, err
+		}
+		item.Size = size
+		key := orderItemKey{item.Color, item.Size}
+		if seen[key] {
+			return nil, &ValidationError{Message: fmt.Sprintf("颜色 %s 尺码 %s 重复出现", item.Color, item.Size)}
+		}
+		seen[key] = true
+		normalized = append(normalized, item)
+	}
+	return normalized, nil
+}
+
+// createOrderInTx 获取或创建款号、生成订单号并创建订单，返回的 bool 表示款号是否为新建
+func (s *productionOrderService) createOrderInTx(tx *sqlx.Tx, actor models.Actor, styleNumber string, details *orderDetails, items []models.CreateOrderItem) (*models.ProductionOrder, *models.Style, bool, error) {
+	// 1. 在事务中获取或创建款号，新建款号的客户取自订单
+	var customerID *int
+	if details.customer != nil {
+		customerID = &details.customer.CustomerID
+	}
+	style, styleCreated, err := s.styleRepo.GetOrCreateInTx(tx, styleNumber, customerID)
 	if err != nil {
-		// 如果款号不存在，则创建它
-		style = &models.Style{StyleNumber: req.StyleNumber}
-		if err := s.styleRepo.CreateInTx(tx, style); err != nil {
-			return nil, fmt.Errorf("failed to create new style: %w", err)
+		if errors.Is(err, repositories.ErrStyleArchived) {
+			return nil, nil, false, &ValidationError{Message: fmt.Sprintf("款号「%s」已被删除，请先在回收站中恢复", styleNumber)}
 		}
+		return nil, nil, false, fmt.Errorf("failed to get or create style: %w", err)
+	}
+	if items, err = normalizeOrderItemsForStyle(style, items); err != nil {
+		return nil, nil, false, err
 	}
 
-	// 2. 自动生成订单号
-	// 获取当天该款号的订单数量，以生成序号
-	today := time.Now().Format("20060102")
-	count, err := s.orderRepo.CountOrdersByStyleAndDate(style.StyleID, today)
+	// 2. 按订单号规则生成订单号，序号在本事务中原子分配
+	values := orderNumberValues{Date: time.Now(), Style: style.StyleNumber}
+	order := &models.ProductionOrder{StyleID: style.StyleID, DueDate: details.dueDate, Priority: details.priority, Remarks: details.remarks}
+	if details.customer != nil {
+		values.Customer = details.customer.CustomerCode
+		order.CustomerID = &details.customer.CustomerID
+	}
+	orderNumber, err := s.allocateOrderNumber(tx, values)
 	if err != nil {
-		return nil, fmt.Errorf("failed to count existing orders for today: %w", err)
+		return nil, nil, false, err
 	}
-	// 格式化订单号：PO-YYYYMMDD-款号-当日序号
-	orderNumber := fmt.Sprintf("PO-%s-%s-%02d", today, style.StyleNumber, count+1)
+	order.OrderNumber = orderNumber
 
 	// 3. 创建订单
-	order, err := s.orderRepo.CreateOrder(tx, orderNumber, style.StyleID, req.Items)
-	if err != nil {
-		return nil, err
+	if err := s.orderRepo.CreateOrder(tx, order, items); err != nil {
+		return nil, nil, false, err
 	}
+	if err := s.status.recordInitial(tx, actor, order, "创建订单"); err != nil {
+		return nil, nil, false, err
+	}
+	return order, style, styleCreated, nil
+}
 
-	if err := tx.Commit(); err != nil {
-		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+// maxOrderNumberAttempts 计数器与历史订单号冲突时 (例如修改规则后又改回) 最多跳过的次数
+const maxOrderNumberAttempts = 20
+
+// allocateOrderNumber 读取当前订单号规则，从计数器分配序号并生成一个未被占用的订单号
+func (s *productionOrderService) allocateOrderNumber(tx *sqlx.Tx, values orderNumberValues) (string, error) {
+	template := DefaultOrderNumberTemplate
+	setting, err := s.settingsRepo.Get(repositories.SettingOrderNumberTemplate)
+	if err == nil {
+		template = setting.Value
+	} else if err.Error() != "setting not found" {
+		return "", err
 	}
 
-	return s.GetOrderByID(order.OrderID)
+	sequenceKey := renderOrderNumber(template, values, 0)
+	for attempt := 0; attempt < maxOrderNumberAttempts; attempt++ {
+		seq, err := s.orderRepo.NextOrderSequence(tx, sequenceKey)
+		if err != nil {
+			return "", err
+		}
+		orderNumber := renderOrderNumber(template, values, seq)
+		if len(orderNumber) > maxOrderNumberLength {
+			return "", &ValidationError{Message: fmt.Sprintf("生成的订单号超过%d个字符，请调整订单号规则", maxOrderNumberLength)}
+		}
+		exists, err := s.orderRepo.OrderNumberExists(tx, orderNumber)
+		if err != nil {
+			return "", err
+		}
+		if !exists {
+			return orderNumber, nil
+		}
+	}
+	return "", fmt.Errorf("failed to allocate a unique order number for %s", sequenceKey)
 }
 
 func (s *productionOrderService) GetOrderByID(id int) (*models.ProductionOrder, error) {
 	return s.orderRepo.GetOrderWithItems(id)
 }
 
-func (s *productionOrderService) GetAllOrders(styleNumberQuery string) ([]models.ProductionOrder, error) {
-	return s.orderRepo.GetAllOrders(styleNumberQuery)
+func (s *productionOrderService) GetAllOrders(filter *models.ProductionOrderFilter) ([]models.ProductionOrder, int, error) {
+	if filter.Status != "" {
+		if _, ok := orderStatusLabels[filter.Status]; !ok {
+			return nil, 0, &ValidationError{Message: "未知的订单状态: " + filter.Status}
+		}
+	}
+	if filter.Priority != "" && !isOrderPriority(filter.Priority) {
+		return nil, 0, &ValidationError{Message: "优先级只能是 low、normal、high 或 urgent"}
+	}
+	return s.orderRepo.GetAllOrders(filter)
+}
+
+// maxAtRiskDays 风险订单最多向后查看的天数
+const maxAtRiskDays = 365
+
+func (s *productionOrderService) GetAtRiskOrders(days int, threshold float64) ([]models.AtRiskOrder, error) {
+	if days < 0 || days > maxAtRiskDays {
+		return nil, &ValidationError{Message: fmt.Sprintf("days 必须在0到%d之间", maxAtRiskDays)}
+	}
+	if threshold <= 0 || threshold > 1 {
+		return nil, &ValidationError{Message: "threshold 必须大于0且不超过1"}
+	}
+	candidates, err := s.orderRepo.GetOpenOrdersDueWithin(days)
+	if err != nil {
+		return nil, err
+	}
+
+	// 进度 = 每个 颜色+尺码 已裁剪件数 (不超过分配给订单的计划件数) 之和 / 分配给订单的计划件数，
+	// 两个件数由仓储层一次汇总
+	atRisk := []models.AtRiskOrder{}
+	for _, order := range candidates {
+		if order.PlannedPieces > 0 {
+			order.Progress = float64(order.CutPieces) / float64(order.PlannedPieces)
+		}
+		if order.Progress < threshold {
+			atRisk = append(atRisk, order)
+		}
+	}
+	return atRisk, nil
+}
+
+// UpdateOrderDetails 修改订单的客户、交期、优先级和备注。订单号在创建时已经生成，修改客户不会改变订单号
+func (s *productionOrderService) UpdateOrderDetails(actor models.Actor, id int, req *models.UpdateOrderDetailsRequest) (*models.ProductionOrder, error) {
+	details, err := s.parseOrderDetails(req.CustomerID, req.DueDate, req.Priority, req.Remarks)
+	if err != nil {
+		return nil, err
+	}
+	before, err := s.orderRepo.GetOrderWithItems(id)
+	if err != nil {
+		return nil, err
+	}
+
+	order := &models.ProductionOrder{OrderID: id, DueDate: details.dueDate, Priority: details.priority, Remarks: details.remarks}
+	if details.customer != nil {
+		order.CustomerID = &details.customer.CustomerID
+	}
+
+	tx, err := s.db.Beginx()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err := s.orderRepo.UpdateOrderDetails(tx, order); err != nil {
+		if err.Error() == "customer not found" {
+			return nil, &ValidationError{Message: "客户不存在"}
+		}
+		return nil, err
+	}
+
+	after, err := s.orderRepo.GetOrderWithItemsInTx(tx, id)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.audit.record(tx, actor, AuditActionUpdate, AuditEntityProductionOrder, int64(id), before, after); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return after, nil
 }
 
-func (s *productionOrderService) DeleteOrderByID(id int) error {
+func (s *productionOrderService) DeleteOrderByID(actor models.Actor, id int) error {
 	// 先检查是否有关联的生产计划
 	var planExists bool
-	err := s.db.Get(&planExists, `SELECT EXISTS(SELECT 1 FROM Production_Plans WHERE linked_order_id = $1)`, id)
+	err := s.db.Get(&planExists, `SELECT EXISTS(SELECT 1 FROM Plan_Orders po
+	    JOIN Production_Plans pp ON pp.plan_id = po.plan_id
+	    WHERE po.order_id = $1 AND pp.deleted_at IS NULL)`, id)
 	if err != nil {
 		return fmt.Errorf("检查关联生产计划失败: %w", err)
 	}
@@ -98,16 +341,275 @@ func (s *productionOrderService) DeleteOrderByID(id int) error {
 		return fmt.Errorf("无法删除订单：请先删除与此订单关联的生产计划")
 	}
 	
-	// 如果没有关联的生产计划，继续删除订单
+	// 如果没有关联的生产计划，把订单移入回收站
+	before, err := s.orderRepo.GetOrderWithItems(id)
+	if err != nil {
+		return err
+	}
+
 	tx, err := s.db.Beginx()
 	if err != nil {
 		return fmt.Errorf("failed to begin transaction: %w", err)
 	}
 	defer tx.Rollback()
 
-	if err := s.orderRepo.DeleteOrder(tx, id); err != nil {
+	if err := s.recycleBin.Archive(tx, AuditEntityProductionOrder, id, actor.WorkerID); err != nil {
 		return err
 	}
+	if err := s.audit.record(tx, actor, AuditActionDelete, AuditEntityProductionOrder, int64(id), before, nil); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	return nil
+}
+
+const (
+	OrderItemAdded   = "added"
+	OrderItemUpdated = "updated"
+	OrderItemRemoved = "removed"
+)
+
+// UpdateOrder 以请求中的明细作为订单的完整明细，按 颜色+尺码 与现有明细比对后
+// 在同一事务中新增、修改、删除。若订单已关联生产计划，返回对计划件数影响的提示。
+func (s *productionOrderService) UpdateOrder(actor models.Actor, id int, req *models.UpdateProductionOrderRequest) (*models.UpdateProductionOrderResponse, error) {
+	items, err := normalizeOrderItems(req.Items)
+	if err != nil {
+		return nil, err
+	}
+
+	before, err := s.orderRepo.GetOrderWithItems(id)
+	if err != nil {
+		return nil, err
+	}
+	style, err := s.styleRepo.GetByID(before.StyleID)
+	if err != nil {
+		return nil, err
+	}
+	if items, err = normalizeOrderItemsForStyle(style, items); err != nil {
+		return nil, err
+	}
+	// 旧明细的尺码可能不是规范写法，按规范化后的尺码比对
+	run := sizes.LoadRun(style.SizeScale, style.Sizes)
+	itemKey := func(color, size string) orderItemKey {
+		normalized, _ := run.Normalize(size)
+		return orderItemKey{color, normalized}
+	}
+
+	tx, err := s.db.Beginx()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	status, err := s.orderRepo.LockOrderStatus(tx, id)
+	if err != nil {
+		return nil, err
+	}
+	if status == OrderStatusCancelled || status == OrderStatusHandedOver {
+		return nil, &ValidationError{Message: fmt.Sprintf("订单状态为「%s」，不能修改明细", orderStatusLabel(status))}
+	}
+
+	existing, err := s.orderRepo.GetOrderItemsForUpdate(tx, id)
+	if err != nil {
+		return nil, err
+	}
+	before.Items = existing
+
+	existingByKey := make(map[orderItemKey]models.OrderItem, len(existing))
+	for _, item := range existing {
+		existingByKey[itemKey(item.Color, item.Size)] = item
+	}
+
+	changes := []models.OrderItemChange{}
+	wanted := make(map[orderItemKey]bool, len(items))
+	for _, item := range items {
+		key := orderItemKey{item.Color, item.Size}
+		wanted[key] = true
+		old, ok := existingByKey[key]
+		if !ok {
+			if err := s.orderRepo.AddOrderItem(tx, id, item); err != nil {
+				return nil, err
+			}
+			changes = append(changes, models.OrderItemChange{Color: item.Color, Size: item.Size, Action: OrderItemAdded, NewQuantity: item.Quantity})
+			continue
+		}
+		if old.Quantity != item.Quantity {
+			if err := s.orderRepo.UpdateOrderItemQuantity(tx, old.ItemID, item.Quantity); err != nil {
+				return nil, err
+			}
+			changes = append(changes, models.OrderItemChange{Color: item.Color, Size: item.Size, Action: OrderItemUpdated, OldQuantity: old.Quantity, NewQuantity: item.Quantity})
+		}
+	}
+	for _, old := range existing {
+		if wanted[itemKey(old.Color, old.Size)] {
+			continue
+		}
+		if err := s.orderRepo.DeleteOrderItem(tx, old.ItemID); err != nil {
+			return nil, err
+		}
+		changes = append(changes, models.OrderItemChange{Color: old.Color, Size: old.Size, Action: OrderItemRemoved, OldQuantity: old.Quantity})
+	}
+
+	after, err := s.orderRepo.GetOrderWithItemsInTx(tx, id)
+	if err != nil {
+		return nil, err
+	}
+	if len(changes) > 0 {
+		if err := s.audit.record(tx, actor, AuditActionUpdate, AuditEntityProductionOrder, int64(id), before, after); err != nil {
+			return nil, err
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	warnings, err := s.linkedPlanWarnings(id, changes)
+	if err != nil {
+		// 订单已修改成功，计划影响分析失败不应影响结果
+		log.Printf("Failed to analyze linked plan for order %d: %v", id, err)
+		warnings = []string{"订单已更新，但无法分析对关联生产计划的影响"}
+	}
+
+	return &models.UpdateProductionOrderResponse{Order: after, Changes: changes, Warnings: warnings}, nil
+}
+
+type orderItemKey struct {
+	color string
+	size  string
+}
+
+// normalizeOrderItems 校验订单明细：颜色、尺码必填，数量至少为1，同一 颜色+尺码 不能重复
+func normalizeOrderItems(items []models.CreateOrderItem) ([]models.CreateOrderItem, error) {
+	if len(items) == 0 {
+		return nil, &ValidationError{Message: "订单至少需要一条明细"}
+	}
+	normalized := make([]models.CreateOrderItem, 0, len(items))
+	seen := make(map[orderItemKey]bool, len(items))
+	for _, item := range items {
+		item.Color = strings.TrimSpace(item.Color)
+		item.Size = strings.TrimSpace(item.Size)
+		if item.Color == "" || item.Size == "" {
+			return nil, &ValidationError{Message: "订单明细的颜色和尺码不能为空"}
+		}
+		if item.Quantity < 1 {
+			return nil, &ValidationError{Message: fmt.Sprintf("颜色 %s 尺码 %s 的数量必须大于0", item.Color, item.Size)}
+		}
+		key := orderItemKey{item.Color, item.Size}
+		if seen[key] {
+			return nil, &ValidationError{Message: fmt.Sprintf("颜色 %s 尺码 %s 重复出现", item.Color, item.Size)}
+		}
+		seen[key] = true
+		normalized = append(normalized, item)
+	}
+	return normalized, nil
+}
+
+// linkedPlanWarnings 对比修改后的订单数量与各关联计划分配给该订单的计划件数
+func (s *productionOrderService) linkedPlanWarnings(orderID int, changes []models.OrderItemChange) ([]string, error) {
+	warnings := []string{}
+	if len(changes) == 0 {
+		return warnings, nil
+	}
+
+	plans, err := s.linkedPlans(orderID)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, plan := range plans {
+		planned := allocatedPiecesByColorSize(plan, orderID)
+		for _, change := range changes {
+			pieces := planned[orderItemKey{change.Color, change.Size}]
+			switch {
+			case change.Action == OrderItemRemoved && pieces > 0:
+				warnings = append(warnings, fmt.Sprintf("关联计划「%s」中颜色 %s 尺码 %s 仍计划裁剪 %d 件，但该明细已从订单中删除",
+					plan.PlanName, change.Color, change.Size, pieces))
+			case change.Action != OrderItemRemoved && pieces < change.NewQuantity:
+				warnings = append(warnings, fmt.Sprintf("关联计划「%s」中颜色 %s 尺码 %s 计划 %d 件，少于订单数量 %d 件，缺少 %d 件",
+					plan.PlanName, change.Color, change.Size, pieces, change.NewQuantity, change.NewQuantity-pieces))
+			case change.Action != OrderItemRemoved && pieces > change.NewQuantity:
+				warnings = append(warnings, fmt.Sprintf("关联计划「%s」中颜色 %s 尺码 %s 计划 %d 件，多于订单数量 %d 件，超出 %d 件",
+					plan.PlanName, change.Color, change.Size, pieces, change.NewQuantity, pieces-change.NewQuantity))
+			}
+		}
+	}
+	return warnings, nil
+}
+
+// linkedPlans 加载订单关联的全部生产计划 (含排版、尺码配比、任务和各订单的分配)
+func (s *productionOrderService) linkedPlans(orderID int) ([]*models.ProductionPlan, error) {
+	linked, err := s.planRepo.GetPlansByOrderID(orderID)
+	if err != nil {
+		return nil, err
+	}
+	plans := make([]*models.ProductionPlan, 0, len(linked))
+	for _, summary := range linked {
+		plan, err := s.planRepo.GetPlanWithDetails(summary.PlanID)
+		if err != nil {
+			return nil, err
+		}
+		plans = append(plans, plan)
+	}
+	return plans, nil
+}
+
+// allocatedPiecesByColorSize 统计计划分配给某个订单的每个 颜色+尺码 的件数
+func allocatedPiecesByColorSize(plan *models.ProductionPlan, orderID int) map[orderItemKey]int {
+	pieces := make(map[orderItemKey]int)
+	for _, order := range plan.Orders {
+		if order.OrderID != orderID {
+			continue
+		}
+		for _, allocation := range order.Allocations {
+			pieces[orderItemKey{allocation.Color, allocation.Size}] += allocation.Quantity
+		}
+	}
+	return pieces
+}
+
+// cutPiecesByColorSize 统计计划中每个 颜色+尺码 已裁剪的件数 (尺码配比 × 完成层数)
+func cutPiecesByColorSize(plan *models.ProductionPlan) map[orderItemKey]int {
+	return piecesByColorSize(plan, func(task models.ProductionTask) int { return task.CompletedLayers })
+}
+
+func piecesByColorSize(plan *models.ProductionPlan, layers func(task models.ProductionTask) int) map[orderItemKey]int {
+	pieces := make(map[orderItemKey]int)
+	for _, layout := range plan.Layouts {
+		for _, task := range layout.Tasks {
+			for _, ratio := range layout.Ratios {
+				pieces[orderItemKey{task.Color, ratio.Size}] += ratio.Ratio * layers(task)
+			}
+		}
+	}
+	return pieces
+}
 
-	return tx.Commit()
+func (s *productionOrderService) TransitionOrder(actor models.Actor, id int, status string, reason string) (*models.ProductionOrder, error) {
+	if len([]rune(reason)) > 255 {
+		return nil, &ValidationError{Message: "原因不能超过255个字符"}
+	}
+
+	tx, err := s.db.Beginx()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err := s.status.transition(tx, actor, id, status, false, strings.TrimSpace(reason)); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return s.GetOrderByID(id)
+}
+
+func (s *productionOrderService) GetStatusHistory(id int) ([]models.OrderStatusChange, error) {
+	if _, err := s.orderRepo.GetOrderWithItems(id); err != nil {
+		return nil, err
+	}
+	return s.orderRepo.GetStatusHistory(id)
 }
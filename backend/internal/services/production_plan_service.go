@@ -9,24 +9,56 @@ import (
 )
 
 type ProductionPlanService interface {
-	CreatePlan(plan *models.CreateProductionPlanRequest) (*models.ProductionPlan, error)
-	UpdatePlan(planID int, plan *models.CreateProductionPlanRequest) (*models.ProductionPlan, error) // <-- 新增
+	CreatePlan(actor models.Actor, plan *models.CreateProductionPlanRequest) (*models.ProductionPlan, error)
+	UpdatePlan(actor models.Actor, planID int, plan *models.CreateProductionPlanRequest) (*models.ProductionPlan, error) // <-- 新增
 	GetPlanByID(id int) (*models.ProductionPlan, error)
-	GetAllPlans(searchQuery string) ([]models.ProductionPlan, error)
+	GetAllPlans(filter *models.ProductionPlanFilter) ([]models.ProductionPlan, int, error)
 	GetPlanByOrderID(orderID int) (*models.ProductionPlan, error)
-	DeletePlanByID(id int) error
+	GetPlansByOrderID(orderID int) ([]models.ProductionPlan, error)
+	DeletePlanByID(actor models.Actor, id int) error
+	ProposeCutPlan(req *models.CutPlanRequest) (*models.CutPlanProposal, error)
 }
 
 type productionPlanService struct {
-	planRepo repositories.ProductionPlanRepository
-	db       *sqlx.DB
+	planRepo   repositories.ProductionPlanRepository
+	orderRepo  repositories.ProductionOrderRepository
+	styleRepo  repositories.StyleRepository
+	recycleBin repositories.RecycleBinRepository
+	db         *sqlx.DB
+	status     orderStatusMachine
+	audit      auditRecorder
 }
 
-func NewProductionPlanService(db *sqlx.DB, planRepo repositories.ProductionPlanRepository) ProductionPlanService {
-	return &productionPlanService{db: db, planRepo: planRepo}
+func NewProductionPlanService(db *sqlx.DB, planRepo repositories.ProductionPlanRepository, orderRepo repositories.ProductionOrderRepository, styleRepo repositories.StyleRepository, recycleBinRepo repositories.RecycleBinRepository, auditRepo repositories.AuditRepository) ProductionPlanService {
+	return &productionPlanService{
+		db:         db,
+		planRepo:   planRepo,
+		orderRepo:  orderRepo,
+		styleRepo:  styleRepo,
+		recycleBin: recycleBinRepo,
+		status:     orderStatusMachine{orderRepo: orderRepo},
+		audit:      auditRecorder{repo: auditRepo},
+	}
 }
 
-func (s *productionPlanService) UpdatePlan(planID int, req *models.CreateProductionPlanRequest) (*models.ProductionPlan, error) {
+func (s *productionPlanService) UpdatePlan(actor models.Actor, planID int, req *models.CreateProductionPlanRequest) (*models.ProductionPlan, error) {
+	before, err := s.GetPlanByID(planID)
+	if err != nil {
+		return nil, err
+	}
+	// 已有任务和生产记录都属于原款号，计划不能改为其他款号
+	if req.StyleID != before.StyleID {
+		return nil, &ValidationError{Message: "计划的款号不能修改，如需更换款号请删除后重新创建计划"}
+	}
+	style, err := s.checkPlanStyle(req)
+	if err != nil {
+		return nil, err
+	}
+	validation, err := planValidationFrom(req)
+	if err != nil {
+		return nil, err
+	}
+
 	tx, err := s.db.Beginx()
 	if err != nil {
 		return nil, fmt.Errorf("failed to begin transaction for plan update: %w", err)
@@ -36,22 +68,160 @@ func (s *productionPlanService) UpdatePlan(planID int, req *models.CreateProduct
 	if err := s.planRepo.UpdatePlan(tx, planID, req); err != nil {
 		return nil, err
 	}
+	// 排版和任务逐项比对后就地更新，保留已有任务的进度和生产记录
+	if err := s.updatePlanLayouts(tx, planID, req); err != nil {
+		return nil, err
+	}
+
+	// 未给出 orders 时沿用已关联的订单，并按调整后的计划件数重新分配
+	requests, err := planOrderRequests(req, before.Orders)
+	if err != nil {
+		return nil, err
+	}
+	orders, err := s.resolvePlanOrders(tx, planID, req, requests)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.planRepo.SetPlanOrders(tx, planID, orders); err != nil {
+		return nil, err
+	}
+	if err := s.checkPlanOrders(tx, planID, req, style, orders, validation); err != nil {
+		return nil, err
+	}
 
+	linked := make(map[int]bool, len(before.Orders))
+	for _, order := range before.Orders {
+		linked[order.OrderID] = true
+	}
+	current := make(map[int]bool, len(orders))
+	for _, order := range orders {
+		current[order.OrderID] = true
+	}
+	for _, order := range before.Orders {
+		if !current[order.OrderID] {
+			if err := s.unlinkOrder(tx, actor, order.OrderID, fmt.Sprintf("从生产计划「%s」中移除", req.PlanName)); err != nil {
+				return nil, err
+			}
+		}
+	}
+	for _, order := range orders {
+		if !linked[order.OrderID] {
+			if err := s.status.linkPlan(tx, actor, order.OrderID, fmt.Sprintf("关联生产计划「%s」", req.PlanName)); err != nil {
+				return nil, err
+			}
+		}
+		// 计划调整会改变任务的计划层数和已完成层数，需要按新的任务进度同步订单状态
+		if err := s.status.syncProgress(tx, actor, order.OrderID); err != nil {
+			return nil, err
+		}
+	}
+
+	after, err := s.planRepo.GetPlanWithDetailsInTx(tx, planID)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.audit.record(tx, actor, AuditActionUpdate, AuditEntityProductionPlan, int64(planID), before, after); err != nil {
+		return nil, err
+	}
 	if err := tx.Commit(); err != nil {
 		return nil, fmt.Errorf("failed to commit transaction for plan update: %w", err)
 	}
-
-	return s.GetPlanByID(planID)
+	return after, nil
 }
 
 // ... (其他函数不变)
-func (s *productionPlanService) DeletePlanByID(id int) error {
-	return s.planRepo.DeletePlan(id)
+func (s *productionPlanService) DeletePlanByID(actor models.Actor, id int) error {
+	before, err := s.GetPlanByID(id)
+	if err != nil {
+		return err
+	}
+	tx, err := s.db.Beginx()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction for plan deletion: %w", err)
+	}
+	defer tx.Rollback()
+
+	// 计划移入回收站，任务和生产记录保持不变
+	if err := s.recycleBin.Archive(tx, AuditEntityProductionPlan, id, actor.WorkerID); err != nil {
+		return err
+	}
+	for _, order := range before.Orders {
+		if err := s.unlinkOrder(tx, actor, order.OrderID, fmt.Sprintf("删除生产计划「%s」", before.PlanName)); err != nil {
+			return err
+		}
+	}
+
+	if err := s.audit.record(tx, actor, AuditActionDelete, AuditEntityProductionPlan, int64(id), before, nil); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction for plan deletion: %w", err)
+	}
+	return nil
 }
+
+// checkPlanStyle 校验计划的款号存在，且排版的尺码和任务的颜色属于款号的尺码组和颜色，
+// 并把排版的尺码规范为款号尺码组中的写法
+func (s *productionPlanService) checkPlanStyle(req *models.CreateProductionPlanRequest) (*models.Style, error) {
+	style, err := s.styleRepo.GetByID(req.StyleID)
+	if err != nil {
+		if err.Error() == "style not found" {
+			return nil, &ValidationError{Message: fmt.Sprintf("款号 %d 不存在", req.StyleID)}
+		}
+		return nil, err
+	}
+	for _, layout := range req.Layouts {
+		seen := make(map[string]bool, len(layout.Ratios))
+		for i := range layout.Ratios {
+			size, err := normalizeStyleSize(style, layout.Ratios[i].Size)
+			if err != nil {
+				return nil, err
+			}
+			if seen[size] {
+				return nil, &ValidationError{Message: fmt.Sprintf("排版「%s」的尺码 %s 重复出现", layout.LayoutName, size)}
+			}
+			seen[size] = true
+			layout.Ratios[i].Size = size
+		}
+		for _, task := range layout.Tasks {
+			if err := checkStyleColor(style, task.Color); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return style, nil
+}
+
+// unlinkOrder 订单与计划解除关联后：没有其他计划时释放订单的计划状态，否则按剩余计划的进度同步状态
+func (s *productionPlanService) unlinkOrder(tx *sqlx.Tx, actor models.Actor, orderID int, reason string) error {
+	count, err := s.orderRepo.CountLinkedPlans(tx, orderID)
+	if err != nil {
+		return err
+	}
+	if count == 0 {
+		return s.status.releasePlan(tx, actor, orderID, reason)
+	}
+	return s.status.syncProgress(tx, actor, orderID)
+}
+
 func (s *productionPlanService) GetPlanByOrderID(orderID int) (*models.ProductionPlan, error) {
 	return s.planRepo.GetPlanByOrderID(orderID)
 }
-func (s *productionPlanService) CreatePlan(req *models.CreateProductionPlanRequest) (*models.ProductionPlan, error) {
+
+// GetPlansByOrderID 返回订单关联的全部计划
+func (s *productionPlanService) GetPlansByOrderID(orderID int) ([]models.ProductionPlan, error) {
+	return s.planRepo.GetPlansByOrderID(orderID)
+}
+func (s *productionPlanService) CreatePlan(actor models.Actor, req *models.CreateProductionPlanRequest) (*models.ProductionPlan, error) {
+	style, err := s.checkPlanStyle(req)
+	if err != nil {
+		return nil, err
+	}
+	validation, err := planValidationFrom(req)
+	if err != nil {
+		return nil, err
+	}
+
 	tx, err := s.db.Beginx()
 	if err != nil {
 		return nil, fmt.Errorf("failed to begin transaction: %w", err)
@@ -76,15 +246,41 @@ func (s *productionPlanService) CreatePlan(req *models.CreateProductionPlanReque
 		}
 	}
 
+	requests, err := planOrderRequests(req, nil)
+	if err != nil {
+		return nil, err
+	}
+	orders, err := s.resolvePlanOrders(tx, plan.PlanID, req, requests)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.planRepo.SetPlanOrders(tx, plan.PlanID, orders); err != nil {
+		return nil, err
+	}
+	if err := s.checkPlanOrders(tx, plan.PlanID, req, style, orders, validation); err != nil {
+		return nil, err
+	}
+	for _, order := range orders {
+		if err := s.status.linkPlan(tx, actor, order.OrderID, fmt.Sprintf("关联生产计划「%s」", req.PlanName)); err != nil {
+			return nil, err
+		}
+	}
+
+	created, err := s.planRepo.GetPlanWithDetailsInTx(tx, plan.PlanID)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.audit.record(tx, actor, AuditActionCreate, AuditEntityProductionPlan, int64(created.PlanID), nil, created); err != nil {
+		return nil, err
+	}
 	if err := tx.Commit(); err != nil {
 		return nil, fmt.Errorf("failed to commit transaction: %w", err)
 	}
-
-	return s.GetPlanByID(plan.PlanID)
+	return created, nil
 }
 func (s *productionPlanService) GetPlanByID(id int) (*models.ProductionPlan, error) {
 	return s.planRepo.GetPlanWithDetails(id)
 }
-func (s *productionPlanService) GetAllPlans(searchQuery string) ([]models.ProductionPlan, error) {
-	return s.planRepo.GetAllPlans(searchQuery)
+func (s *productionPlanService) GetAllPlans(filter *models.ProductionPlanFilter) ([]models.ProductionPlan, int, error) {
+	return s.planRepo.GetAllPlans(filter)
 }
@@ -0,0 +1,190 @@
+package services
+
+import (
+	"cutrix-backend/internal/models"
+	"cutrix-backend/internal/repositories"
+	"fmt"
+	"strings"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// recycleBinEntityLabels 回收站支持的记录类型
+var recycleBinEntityLabels = map[string]string{
+	AuditEntityProductionOrder: "订单",
+	AuditEntityProductionPlan:  "生产计划",
+	AuditEntityStyle:           "款号",
+	AuditEntityWorker:          "员工",
+}
+
+// RecycleBinService 管理已删除的订单、计划、款号和员工：列出、恢复和彻底删除
+type RecycleBinService interface {
+	List(entityType string, q models.ListQuery) ([]models.ArchivedRecord, int, error)
+	Restore(actor models.Actor, entityType string, id int) error
+	Purge(actor models.Actor, entityType string, id int) error
+}
+
+type recycleBinService struct {
+	db         *sqlx.DB
+	recycleBin repositories.RecycleBinRepository
+	orderRepo  repositories.ProductionOrderRepository
+	planRepo   repositories.ProductionPlanRepository
+	styleRepo  repositories.StyleRepository
+	workerRepo repositories.WorkerRepository
+	plans      *productionPlanService
+	groupLimit groupLimitChecker
+	status     orderStatusMachine
+	audit      auditRecorder
+}
+
+// NewRecycleBinService 创建新的回收站服务
+func NewRecycleBinService(db *sqlx.DB, recycleBinRepo repositories.RecycleBinRepository, orderRepo repositories.ProductionOrderRepository, planRepo repositories.ProductionPlanRepository, styleRepo repositories.StyleRepository, workerRepo repositories.WorkerRepository, roleRepo repositories.RoleRepository, auditRepo repositories.AuditRepository) RecycleBinService {
+	return &recycleBinService{
+		db:         db,
+		recycleBin: recycleBinRepo,
+		orderRepo:  orderRepo,
+		planRepo:   planRepo,
+		styleRepo:  styleRepo,
+		workerRepo: workerRepo,
+		plans:      &productionPlanService{planRepo: planRepo, orderRepo: orderRepo, styleRepo: styleRepo},
+		groupLimit: groupLimitChecker{roleRepo: roleRepo, workerRepo: workerRepo},
+		status:     orderStatusMachine{orderRepo: orderRepo},
+		audit:      auditRecorder{repo: auditRepo},
+	}
+}
+
+func checkRecycleBinEntity(entityType string) error {
+	if _, ok := recycleBinEntityLabels[entityType]; !ok {
+		return &ValidationError{Message: fmt.Sprintf("不支持的记录类型: %s", entityType)}
+	}
+	return nil
+}
+
+// List 分页列出回收站中的记录，entityType 为空时列出全部类型
+func (s *recycleBinService) List(entityType string, q models.ListQuery) ([]models.ArchivedRecord, int, error) {
+	if entityType != "" {
+		if err := checkRecycleBinEntity(entityType); err != nil {
+			return nil, 0, err
+		}
+	}
+	return s.recycleBin.ListArchived(entityType, q)
+}
+
+// Restore 恢复一条已删除的记录。订单和计划依赖的款号、订单必须先恢复；
+// 恢复员工时与创建、修改员工一样检查班组人数上限；恢复计划时按订单当前的排产情况重新分配计划件数，
+// 之后其关联的订单重新进入已排计划，并按任务进度同步状态
+func (s *recycleBinService) Restore(actor models.Actor, entityType string, id int) error {
+	if err := checkRecycleBinEntity(entityType); err != nil {
+		return err
+	}
+	record, err := s.recycleBin.GetArchived(entityType, id)
+	if err != nil {
+		return err
+	}
+	parents, err := s.recycleBin.GetArchivedParents(entityType, id)
+	if err != nil {
+		return err
+	}
+	if len(parents) > 0 {
+		names := make([]string, len(parents))
+		for i, parent := range parents {
+			names[i] = fmt.Sprintf("%s「%s」", recycleBinEntityLabels[parent.EntityType], parent.Name)
+		}
+		return &ValidationError{Message: fmt.Sprintf("请先恢复%s", strings.Join(names, "、"))}
+	}
+
+	var planOrders []models.PlanOrder
+	if entityType == AuditEntityProductionPlan {
+		if planOrders, err = s.planRepo.GetPlanOrders(id); err != nil {
+			return err
+		}
+	}
+
+	tx, err := s.db.Beginx()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err := s.recycleBin.Restore(tx, entityType, id); err != nil {
+		return err
+	}
+	switch entityType {
+	case AuditEntityWorker:
+		worker, err := s.workerRepo.GetByIDInTx(tx, id)
+		if err != nil {
+			return err
+		}
+		if worker.IsActive {
+			if err := s.groupLimit.check(tx, worker.Role, worker.WorkerGroup, id); err != nil {
+				return err
+			}
+		}
+	case AuditEntityProductionPlan:
+		if err := s.plans.reallocateRestoredPlan(tx, id); err != nil {
+			return err
+		}
+	}
+	for _, order := range planOrders {
+		if err := s.status.linkPlan(tx, actor, order.OrderID, fmt.Sprintf("恢复生产计划「%s」", record.Name)); err != nil {
+			return err
+		}
+		if err := s.status.syncProgress(tx, actor, order.OrderID); err != nil {
+			return err
+		}
+	}
+
+	if err := s.audit.record(tx, actor, AuditActionRestore, entityType, int64(id), nil, record); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
+
+// Purge 彻底删除回收站中没有生产记录、也未被其他数据引用的记录
+func (s *recycleBinService) Purge(actor models.Actor, entityType string, id int) error {
+	if err := checkRecycleBinEntity(entityType); err != nil {
+		return err
+	}
+	record, err := s.recycleBin.GetArchived(entityType, id)
+	if err != nil {
+		return err
+	}
+	historyErr := &ValidationError{Message: fmt.Sprintf("%s「%s」有生产记录或仍被其他数据引用，只能恢复，不能彻底删除", recycleBinEntityLabels[entityType], record.Name)}
+	if record.HasHistory {
+		return historyErr
+	}
+
+	tx, err := s.db.Beginx()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	switch entityType {
+	case AuditEntityProductionOrder:
+		err = s.orderRepo.DeleteOrder(tx, id)
+	case AuditEntityProductionPlan:
+		err = s.planRepo.DeletePlan(tx, id)
+	case AuditEntityStyle:
+		err = s.styleRepo.Delete(tx, id)
+	case AuditEntityWorker:
+		err = s.workerRepo.Delete(tx, id)
+	}
+	if err != nil {
+		if strings.HasSuffix(err.Error(), "is still referenced") {
+			return historyErr
+		}
+		return err
+	}
+
+	if err := s.audit.record(tx, actor, AuditActionPurge, entityType, int64(id), record, nil); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
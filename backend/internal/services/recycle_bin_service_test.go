@@ -0,0 +1,85 @@
+package services
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"cutrix-backend/internal/models"
+	"cutrix-backend/internal/repositories"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// memRecycleBin 是只支持员工的内存回收站：删除的员工从 memWorkerRepository 移到 archived，恢复时移回
+type memRecycleBin struct {
+	repositories.RecycleBinRepository
+	workers  *memWorkerRepository
+	archived map[int]*models.Worker
+}
+
+func (b *memRecycleBin) Archive(tx *sqlx.Tx, entityType string, id int, deletedBy *int) error {
+	worker, ok := b.workers.workers[id]
+	if entityType != AuditEntityWorker || !ok {
+		return fmt.Errorf("record not found")
+	}
+	b.archived[id] = worker
+	delete(b.workers.workers, id)
+	return nil
+}
+
+func (b *memRecycleBin) Restore(tx *sqlx.Tx, entityType string, id int) error {
+	worker, ok := b.archived[id]
+	if entityType != AuditEntityWorker || !ok {
+		return fmt.Errorf("archived record not found")
+	}
+	b.workers.workers[id] = worker
+	delete(b.archived, id)
+	return nil
+}
+
+func (b *memRecycleBin) GetArchived(entityType string, id int) (*models.ArchivedRecord, error) {
+	worker, ok := b.archived[id]
+	if entityType != AuditEntityWorker || !ok {
+		return nil, fmt.Errorf("archived record not found")
+	}
+	return &models.ArchivedRecord{EntityType: entityType, EntityID: id, Name: worker.Name, DeletedAt: time.Now()}, nil
+}
+
+func (b *memRecycleBin) GetArchivedParents(entityType string, id int) ([]models.ArchivedRecord, error) {
+	return []models.ArchivedRecord{}, nil
+}
+
+func TestRestoreWorkerChecksGroupLimit(t *testing.T) {
+	f := newWorkerServiceFixture()
+	bin := NewRecycleBinService(newTxOnlyDB(), f.recycleBin, nil, nil, nil, f.workers, f.roles, nopAuditRepository{})
+	a1 := f.mustCreate(t, "A1", "cutting_lead", group("A"), true)
+	f.mustCreate(t, "A2", "cutting_lead", group("A"), true)
+
+	if err := f.service.Delete(adminActor, a1.WorkerID); err != nil {
+		t.Fatalf("delete: %v", err)
+	}
+	if err := bin.Restore(adminActor, AuditEntityWorker, a1.WorkerID); err != nil {
+		t.Fatalf("restore into a group with room: %v", err)
+	}
+
+	// 删除后补招一名组长，班组已满，再恢复原来的组长会突破上限
+	if err := f.service.Delete(adminActor, a1.WorkerID); err != nil {
+		t.Fatalf("delete: %v", err)
+	}
+	f.mustCreate(t, "A3", "cutting_lead", group("A"), true)
+	err := bin.Restore(adminActor, AuditEntityWorker, a1.WorkerID)
+	assertGroupLimitError(t, err)
+	if err.Error() != "班组 'A' 的裁床组长已达到上限 (2 人)" {
+		t.Errorf("err = %q", err.Error())
+	}
+
+	// 停用的员工不占名额，可以恢复
+	a4 := f.mustCreate(t, "A4", "cutting_lead", group("A"), false)
+	if err := f.service.Delete(adminActor, a4.WorkerID); err != nil {
+		t.Fatalf("delete: %v", err)
+	}
+	if err := bin.Restore(adminActor, AuditEntityWorker, a4.WorkerID); err != nil {
+		t.Fatalf("restore an inactive worker: %v", err)
+	}
+}
@@ -0,0 +1,230 @@
+package services
+
+import (
+	"cutrix-backend/internal/models"
+	"cutrix-backend/internal/repositories"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// roleNamePattern 限制角色标识只能使用小写字母、数字和下划线，例如 cutting_supervisor
+var roleNamePattern = regexp.MustCompile(`^[a-z][a-z0-9_]{1,49}$`)
+
+// RoleService 管理角色及其权限，并为授权中间件解析角色的权限
+type RoleService interface {
+	GetAll() ([]*models.Role, error)
+	GetByID(id int) (*models.Role, error)
+	GetAllPermissions() ([]models.Permission, error)
+	Create(actor models.Actor, req *models.CreateRoleRequest) (*models.Role, error)
+	Update(actor models.Actor, id int, req *models.UpdateRoleRequest) (*models.Role, error)
+	Delete(actor models.Actor, id int) error
+	// PermissionsForRole 返回角色拥有的权限集合，供每个请求的授权检查使用
+	PermissionsForRole(roleName string) (map[string]bool, error)
+}
+
+type roleService struct {
+	db       *sqlx.DB
+	roleRepo repositories.RoleRepository
+	audit    auditRecorder
+}
+
+// NewRoleService 创建新的角色服务
+func NewRoleService(db *sqlx.DB, roleRepo repositories.RoleRepository, auditRepo repositories.AuditRepository) RoleService {
+	return &roleService{db: db, roleRepo: roleRepo, audit: auditRecorder{repo: auditRepo}}
+}
+
+func (s *roleService) GetAll() ([]*models.Role, error) {
+	return s.roleRepo.GetAll()
+}
+
+func (s *roleService) GetByID(id int) (*models.Role, error) {
+	return s.roleRepo.GetByID(id)
+}
+
+func (s *roleService) GetAllPermissions() ([]models.Permission, error) {
+	return s.roleRepo.GetAllPermissions()
+}
+
+func (s *roleService) PermissionsForRole(roleName string) (map[string]bool, error) {
+	codes, err := s.roleRepo.GetPermissionCodes(roleName)
+	if err != nil {
+		return nil, err
+	}
+	permissions := make(map[string]bool, len(codes))
+	for _, code := range codes {
+		permissions[code] = true
+	}
+	return permissions, nil
+}
+
+func (s *roleService) Create(actor models.Actor, req *models.CreateRoleRequest) (*models.Role, error) {
+	if !roleNamePattern.MatchString(req.Name) {
+		return nil, &ValidationError{Message: "角色标识只能包含小写字母、数字和下划线，且以字母开头"}
+	}
+	displayName := strings.TrimSpace(req.DisplayName)
+	if displayName == "" {
+		return nil, &ValidationError{Message: "角色名称不能为空"}
+	}
+	if req.MaxPerGroup != nil && *req.MaxPerGroup < 1 {
+		return nil, &ValidationError{Message: "班组人数上限必须大于 0"}
+	}
+	permissions, err := s.validatePermissions(req.Permissions)
+	if err != nil {
+		return nil, err
+	}
+
+	tx, err := s.db.Beginx()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	role := &models.Role{Name: req.Name, DisplayName: displayName, Description: req.Description, MaxPerGroup: req.MaxPerGroup}
+	if err := s.roleRepo.Create(tx, role); err != nil {
+		if err.Error() == "role name already exists" {
+			return nil, &ValidationError{Message: "角色标识已存在"}
+		}
+		return nil, err
+	}
+	if err := s.roleRepo.SetPermissions(tx, role.RoleID, permissions); err != nil {
+		return nil, err
+	}
+
+	created, err := s.roleRepo.GetByIDInTx(tx, role.RoleID)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.audit.record(tx, actor, AuditActionCreate, AuditEntityRole, int64(created.RoleID), nil, created); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return created, nil
+}
+
+func (s *roleService) Update(actor models.Actor, id int, req *models.UpdateRoleRequest) (*models.Role, error) {
+	before, err := s.roleRepo.GetByID(id)
+	if err != nil {
+		return nil, err
+	}
+	displayName := strings.TrimSpace(req.DisplayName)
+	if displayName == "" {
+		return nil, &ValidationError{Message: "角色名称不能为空"}
+	}
+	if req.MaxPerGroup != nil && *req.MaxPerGroup < 1 {
+		return nil, &ValidationError{Message: "班组人数上限必须大于 0"}
+	}
+	permissions, err := s.validatePermissions(req.Permissions)
+	if err != nil {
+		return nil, err
+	}
+	// 管理员角色始终拥有全部权限，避免误操作导致无人可以管理系统
+	if before.Name == models.RoleAdmin && !sameStrings(permissions, before.Permissions) {
+		return nil, &ValidationError{Message: "管理员角色的权限不可修改"}
+	}
+
+	tx, err := s.db.Beginx()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	role := &models.Role{RoleID: id, DisplayName: displayName, Description: req.Description, MaxPerGroup: req.MaxPerGroup}
+	if err := s.roleRepo.Update(tx, role); err != nil {
+		return nil, err
+	}
+	if err := s.roleRepo.SetPermissions(tx, id, permissions); err != nil {
+		return nil, err
+	}
+
+	after, err := s.roleRepo.GetByIDInTx(tx, id)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.audit.record(tx, actor, AuditActionUpdate, AuditEntityRole, int64(id), before, after); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return after, nil
+}
+
+func (s *roleService) Delete(actor models.Actor, id int) error {
+	role, err := s.roleRepo.GetByID(id)
+	if err != nil {
+		return err
+	}
+	if role.IsSystem {
+		return &ValidationError{Message: "系统内置角色不能删除"}
+	}
+	if role.WorkerCount > 0 {
+		return &ValidationError{Message: fmt.Sprintf("仍有 %d 名员工使用该角色，无法删除", role.WorkerCount)}
+	}
+
+	tx, err := s.db.Beginx()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err := s.roleRepo.Delete(tx, id); err != nil {
+		if err.Error() == "role is still assigned to workers" {
+			return &ValidationError{Message: "仍有员工使用该角色，无法删除"}
+		}
+		return err
+	}
+	if err := s.audit.record(tx, actor, AuditActionDelete, AuditEntityRole, int64(id), role, nil); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
+
+// validatePermissions 检查权限代码均存在，并去除重复项
+func (s *roleService) validatePermissions(codes []string) ([]string, error) {
+	all, err := s.roleRepo.GetAllPermissions()
+	if err != nil {
+		return nil, err
+	}
+	known := make(map[string]bool, len(all))
+	for _, p := range all {
+		known[p.Code] = true
+	}
+
+	seen := make(map[string]bool, len(codes))
+	result := make([]string, 0, len(codes))
+	for _, code := range codes {
+		if !known[code] {
+			return nil, &ValidationError{Message: "未知的权限: " + code}
+		}
+		if !seen[code] {
+			seen[code] = true
+			result = append(result, code)
+		}
+	}
+	return result, nil
+}
+
+// sameStrings 判断两个字符串列表是否包含相同的元素 (忽略顺序)
+func sameStrings(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	set := make(map[string]bool, len(a))
+	for _, v := range a {
+		set[v] = true
+	}
+	for _, v := range b {
+		if !set[v] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,83 @@
+package services
+
+import (
+	"cutrix-backend/internal/models"
+	"cutrix-backend/internal/repositories"
+	"fmt"
+	"strings"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// SettingsService 管理由管理员维护的系统设置
+type SettingsService interface {
+	GetOrderNumberSettings() (*models.OrderNumberSettings, error)
+	UpdateOrderNumberSettings(actor models.Actor, req *models.UpdateOrderNumberSettingsRequest) (*models.OrderNumberSettings, error)
+}
+
+type settingsService struct {
+	db           *sqlx.DB
+	settingsRepo repositories.SettingsRepository
+	audit        auditRecorder
+}
+
+// NewSettingsService 创建新的系统设置服务
+func NewSettingsService(db *sqlx.DB, settingsRepo repositories.SettingsRepository, auditRepo repositories.AuditRepository) SettingsService {
+	return &settingsService{db: db, settingsRepo: settingsRepo, audit: auditRecorder{repo: auditRepo}}
+}
+
+func (s *settingsService) GetOrderNumberSettings() (*models.OrderNumberSettings, error) {
+	setting, err := s.settingsRepo.Get(repositories.SettingOrderNumberTemplate)
+	if err != nil {
+		if err.Error() != "setting not found" {
+			return nil, err
+		}
+		return &models.OrderNumberSettings{Template: DefaultOrderNumberTemplate, Preview: previewOrderNumber(DefaultOrderNumberTemplate)}, nil
+	}
+	return orderNumberSettingsFrom(setting), nil
+}
+
+func (s *settingsService) UpdateOrderNumberSettings(actor models.Actor, req *models.UpdateOrderNumberSettingsRequest) (*models.OrderNumberSettings, error) {
+	template := strings.TrimSpace(req.Template)
+	if err := validateOrderNumberTemplate(template); err != nil {
+		return nil, err
+	}
+
+	before, err := s.settingsRepo.Get(repositories.SettingOrderNumberTemplate)
+	if err != nil && err.Error() != "setting not found" {
+		return nil, err
+	}
+
+	tx, err := s.db.Beginx()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	after, err := s.settingsRepo.Set(tx, repositories.SettingOrderNumberTemplate, template, actor.WorkerID)
+	if err != nil {
+		return nil, err
+	}
+
+	if before == nil {
+		err = s.audit.record(tx, actor, AuditActionCreate, AuditEntitySystemSetting, int64(after.SettingID), nil, after)
+	} else {
+		err = s.audit.record(tx, actor, AuditActionUpdate, AuditEntitySystemSetting, int64(after.SettingID), before, after)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return orderNumberSettingsFrom(after), nil
+}
+
+func orderNumberSettingsFrom(setting *models.SystemSetting) *models.OrderNumberSettings {
+	return &models.OrderNumberSettings{
+		Template:  setting.Value,
+		Preview:   previewOrderNumber(setting.Value),
+		UpdatedBy: setting.UpdatedBy,
+		UpdatedAt: setting.UpdatedAt,
+	}
+}
@@ -1,50 +1,234 @@
 package services
 
 import (
+	"errors"
 	"fmt"
+	"slices"
+	"strings"
+	"unicode/utf8"
 
 	"cutrix-backend/internal/models"
 	"cutrix-backend/internal/repositories"
+	"cutrix-backend/pkg/sizes"
 
-	"github.com/go-playground/validator/v10"
+	"github.com/jmoiron/sqlx"
 )
 
 type StyleService struct {
-	styleRepo repositories.StyleRepository
-	validator *validator.Validate
+	db         *sqlx.DB
+	styleRepo  repositories.StyleRepository
+	recycleBin repositories.RecycleBinRepository
+	audit      auditRecorder
 }
 
-func NewStyleService(styleRepo repositories.StyleRepository) *StyleService {
+func NewStyleService(db *sqlx.DB, styleRepo repositories.StyleRepository, recycleBinRepo repositories.RecycleBinRepository, auditRepo repositories.AuditRepository) *StyleService {
 	return &StyleService{
-		styleRepo: styleRepo,
-		validator: validator.New(),
+		db:         db,
+		styleRepo:  styleRepo,
+		recycleBin: recycleBinRepo,
+		audit:      auditRecorder{repo: auditRepo},
 	}
 }
 
-func (s *StyleService) CreateStyle(req *models.CreateStyleRequest) (*models.Style, error) {
-	// 验证请求
-	if err := s.validator.Struct(req); err != nil {
-		return nil, fmt.Errorf("validation error: %w", err)
-	}
-
+func (s *StyleService) CreateStyle(actor models.Actor, req *models.CreateStyleRequest) (*models.Style, error) {
 	// 检查款号是否已存在
 	existing, err := s.styleRepo.GetByNumber(req.StyleNumber)
 	if err == nil && existing != nil {
-		return nil, fmt.Errorf("style number already exists")
+		return nil, &ValidationError{Message: "款号已存在"}
 	}
 
 	// 创建款号
-	style := &models.Style{
-		StyleNumber: req.StyleNumber,
+	style, err := normalizeStyle(req.StyleNumber, req.Description, req.Season, req.CustomerID, req.SizeScale, req.Sizes, req.Colors, req.MainFabric)
+	if err != nil {
+		return nil, err
+	}
+
+	tx, err := s.db.Beginx()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err := s.styleRepo.Create(tx, style); err != nil {
+		return nil, styleSaveError(err)
+	}
+
+	created, err := s.styleRepo.GetByIDInTx(tx, style.StyleID)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.audit.record(tx, actor, AuditActionCreate, AuditEntityStyle, int64(style.StyleID), nil, created); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return created, nil
+}
+
+// UpdateStyle 修改款号主数据。已有订单或生产计划的款号不能修改款号，
+// 尺码体系、尺码组和颜色必须包含订单和计划中已用到的尺码、颜色
+func (s *StyleService) UpdateStyle(actor models.Actor, id int, req *models.UpdateStyleRequest) (*models.Style, error) {
+	before, err := s.styleRepo.GetByID(id)
+	if err != nil {
+		return nil, err
 	}
+	style, err := normalizeStyle(req.StyleNumber, req.Description, req.Season, req.CustomerID, req.SizeScale, req.Sizes, req.Colors, req.MainFabric)
+	if err != nil {
+		return nil, err
+	}
+	style.StyleID = id
 
-	if err := s.styleRepo.Create(style); err != nil {
-		return nil, fmt.Errorf("failed to create style: %w", err)
+	inUse, err := s.styleRepo.HasActiveOrdersOrPlans(id)
+	if err != nil {
+		return nil, err
+	}
+	if inUse {
+		if style.StyleNumber != before.StyleNumber {
+			return nil, &ValidationError{Message: "款号已有订单或生产计划，不能修改款号"}
+		}
+		usedColors, usedSizes, err := s.styleRepo.GetUsedColorsAndSizes(id)
+		if err != nil {
+			return nil, err
+		}
+		run := sizes.LoadRun(style.SizeScale, style.Sizes)
+		missing := []string{}
+		for _, size := range usedSizes {
+			if _, ok := run.Normalize(size); !ok {
+				missing = append(missing, size)
+			}
+		}
+		if len(missing) > 0 {
+			return nil, &ValidationError{Message: fmt.Sprintf("尺码 %s 已用于订单或生产计划，必须保留在%s的尺码组中", strings.Join(missing, "、"), sizes.Label(run.Scale()))}
+		}
+		if missing := missingValues(usedColors, style.Colors); len(style.Colors) > 0 && len(missing) > 0 {
+			return nil, &ValidationError{Message: fmt.Sprintf("颜色 %s 已用于订单或生产计划，必须保留在款号的颜色中", strings.Join(missing, "、"))}
+		}
 	}
 
+	tx, err := s.db.Beginx()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err := s.styleRepo.Update(tx, style); err != nil {
+		return nil, styleSaveError(err)
+	}
+
+	after, err := s.styleRepo.GetByIDInTx(tx, id)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.audit.record(tx, actor, AuditActionUpdate, AuditEntityStyle, int64(id), before, after); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return after, nil
+}
+
+func styleSaveError(err error) error {
+	if errors.Is(err, repositories.ErrStyleNumberTaken) {
+		return &ValidationError{Message: "款号已存在或在回收站中"}
+	}
+	if err.Error() == "customer not found" {
+		return &ValidationError{Message: "客户不存在"}
+	}
+	return err
+}
+
+// normalizeStyle 去掉首尾空格，检查各字段的长度，按尺码体系规范化并排列尺码组，并检查尺码组和颜色中没有空值或重复值
+func normalizeStyle(styleNumber, description, season string, customerID *int, sizeScale string, sizeRun, colors []string, mainFabric string) (*models.Style, error) {
+	style := &models.Style{
+		StyleNumber: strings.TrimSpace(styleNumber),
+		Description: strings.TrimSpace(description),
+		Season:      strings.TrimSpace(season),
+		CustomerID:  customerID,
+		MainFabric:  strings.TrimSpace(mainFabric),
+	}
+	switch {
+	case style.StyleNumber == "" || utf8.RuneCountInString(style.StyleNumber) > 50:
+		return nil, &ValidationError{Message: "款号不能为空且不能超过50个字符"}
+	case utf8.RuneCountInString(style.Season) > 20:
+		return nil, &ValidationError{Message: "季节不能超过20个字符"}
+	case utf8.RuneCountInString(style.MainFabric) > 100:
+		return nil, &ValidationError{Message: "主面料不能超过100个字符"}
+	}
+	run, err := sizes.NewRun(strings.TrimSpace(sizeScale), sizeRun)
+	if err != nil {
+		return nil, &ValidationError{Message: err.Error()}
+	}
+	style.SizeScale, style.Sizes = run.Scale(), run.Sizes()
+	if style.Colors, err = normalizeStyleValues(colors, "颜色"); err != nil {
+		return nil, err
+	}
 	return style, nil
 }
 
+func normalizeStyleValues(values []string, kind string) ([]string, error) {
+	normalized := make([]string, 0, len(values))
+	seen := make(map[string]bool, len(values))
+	for _, value := range values {
+		value = strings.TrimSpace(value)
+		if value == "" {
+			return nil, &ValidationError{Message: fmt.Sprintf("%s不能为空", kind)}
+		}
+		if seen[value] {
+			return nil, &ValidationError{Message: fmt.Sprintf("%s %s 重复", kind, value)}
+		}
+		seen[value] = true
+		normalized = append(normalized, value)
+	}
+	return normalized, nil
+}
+
+// missingValues 返回 used 中有而 allowed 中没有的值
+func missingValues(used, allowed []string) []string {
+	kept := make(map[string]bool, len(allowed))
+	for _, value := range allowed {
+		kept[value] = true
+	}
+	missing := []string{}
+	for _, value := range used {
+		if !kept[value] {
+			missing = append(missing, value)
+		}
+	}
+	return missing
+}
+
+// checkStyleColor 校验颜色属于款号的颜色，款号未设置颜色时不限制
+func checkStyleColor(style *models.Style, color string) error {
+	if len(style.Colors) > 0 && !slices.Contains(style.Colors, color) {
+		return &ValidationError{Message: fmt.Sprintf("颜色 %s 不在款号「%s」的颜色 (%s) 中", color, style.StyleNumber, strings.Join(style.Colors, "、"))}
+	}
+	return nil
+}
+
+// normalizeStyleSize 把尺码规范为款号尺码组中的写法 (如 "xl" -> "XL")，
+// 尺码不在款号的尺码组或尺码体系中时返回 ValidationError
+func normalizeStyleSize(style *models.Style, size string) (string, error) {
+	run := sizes.LoadRun(style.SizeScale, style.Sizes)
+	normalized, ok := run.Normalize(size)
+	if !ok {
+		if len(run.Sizes()) > 0 {
+			return "", &ValidationError{Message: fmt.Sprintf("尺码 %s 不在款号「%s」的尺码组 (%s) 中", size, style.StyleNumber, strings.Join(run.Sizes(), "、"))}
+		}
+		return "", &ValidationError{Message: fmt.Sprintf("尺码 %s 不属于款号「%s」的%s", size, style.StyleNumber, sizes.Label(run.Scale()))}
+	}
+	return normalized, nil
+}
+
+// normalizeStyleColorSize 校验颜色属于款号的颜色，并返回规范化后的尺码
+func normalizeStyleColorSize(style *models.Style, color, size string) (string, error) {
+	if err := checkStyleColor(style, color); err != nil {
+		return "", err
+	}
+	return normalizeStyleSize(style, size)
+}
+
 func (s *StyleService) GetStyle(id int) (*models.Style, error) {
 	return s.styleRepo.GetByID(id)
 }
@@ -55,4 +239,36 @@ func (s *StyleService) GetStyles() ([]*models.Style, error) {
 
 func (s *StyleService) GetStyleByNumber(number string) (*models.Style, error) {
 	return s.styleRepo.GetByNumber(number)
-}
\ No newline at end of file
+}
+
+// DeleteStyle 把款号移入回收站，仍有未删除的订单或生产计划时不允许删除
+func (s *StyleService) DeleteStyle(actor models.Actor, id int) error {
+	before, err := s.styleRepo.GetByID(id)
+	if err != nil {
+		return err
+	}
+	inUse, err := s.styleRepo.HasActiveOrdersOrPlans(id)
+	if err != nil {
+		return err
+	}
+	if inUse {
+		return &ValidationError{Message: "款号还有订单或生产计划，请先删除"}
+	}
+
+	tx, err := s.db.Beginx()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err := s.recycleBin.Archive(tx, AuditEntityStyle, id, actor.WorkerID); err != nil {
+		return err
+	}
+	if err := s.audit.record(tx, actor, AuditActionDelete, AuditEntityStyle, int64(id), before, nil); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
@@ -25,8 +25,13 @@ func (s *TaskService) GetTask(id int) (*models.ProductionTask, error) {
 	return s.taskRepo.GetByID(id)
 }
 
-func (s *TaskService) GetTasks() ([]*models.ProductionTask, error) {
-	return s.taskRepo.GetAll()
+func (s *TaskService) GetTasks(filter *models.TaskFilter) ([]*models.ProductionTask, int, error) {
+	switch filter.Status {
+	case "", models.TaskStatusPending, models.TaskStatusInProgress, models.TaskStatusCompleted:
+	default:
+		return nil, 0, &ValidationError{Message: "status 只能是 pending、in_progress 或 completed"}
+	}
+	return s.taskRepo.GetAll(filter)
 }
 
 func (s *TaskService) GetTasksByStyle(styleID int) ([]*models.ProductionTask, error) {
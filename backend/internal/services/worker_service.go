@@ -3,8 +3,11 @@ package services
 import (
 	"cutrix-backend/internal/models"
 	"cutrix-backend/internal/repositories"
+	"cutrix-backend/pkg/auth"
+	"errors"
 	"fmt"
 
+	"github.com/jmoiron/sqlx"
 	"golang.org/x/crypto/bcrypt"
 )
 
@@ -22,26 +25,37 @@ type WorkerService interface {
 	// Query methods
 	GetByID(id int) (*models.Worker, error)
 	GetByName(name string) (*models.Worker, error)
-	GetAll() ([]*models.Worker, error)
+	GetAll(filter *models.WorkerFilter) ([]*models.Worker, int, error)
 	GetWorkerTasks(workerID int) ([]*models.ProductionTask, error)
 	GetWorkerLogs(workerID int) ([]*models.ProductionLog, error)
 	// Management methods
-	Create(worker *models.CreateWorkerRequest) (*models.Worker, error)
-	Update(id int, worker *models.UpdateWorkerRequest) (*models.Worker, error)
-	Delete(id int) error
+	Create(actor models.Actor, worker *models.CreateWorkerRequest) (*models.Worker, error)
+	Update(actor models.Actor, id int, worker *models.UpdateWorkerRequest) (*models.Worker, error)
+	Delete(actor models.Actor, id int) error
 
-	UpdatePassword(updatingUserID int, updatingUserRole string, targetWorkerID int, newPassword string) error
+	UpdatePassword(actor models.Actor, targetWorkerID int, newPassword string) error
+	Unlock(actor models.Actor, id int) error
+	SetPin(actor models.Actor, targetWorkerID int, pin string) error
+	ClearPin(actor models.Actor, targetWorkerID int) error
+	SetBadge(actor models.Actor, targetWorkerID int, badgeCode string) error
+	ClearBadge(actor models.Actor, targetWorkerID int) error
 
 	GetWorkerTaskGroups(workerID int) ([]models.WorkerTaskGroup, error)
 }
 
 type workerService struct {
-	workerRepo repositories.WorkerRepository
+	db          *sqlx.DB
+	workerRepo  repositories.WorkerRepository
+	sessionRepo repositories.SessionRepository
+	roleRepo    repositories.RoleRepository
+	recycleBin  repositories.RecycleBinRepository
+	groupLimit  groupLimitChecker
+	audit       auditRecorder
 }
 
 // NewWorkerService 创建新的统一员工服务实例
-func NewWorkerService(workerRepo repositories.WorkerRepository) WorkerService {
-	return &workerService{workerRepo: workerRepo}
+func NewWorkerService(db *sqlx.DB, workerRepo repositories.WorkerRepository, sessionRepo repositories.SessionRepository, roleRepo repositories.RoleRepository, recycleBinRepo repositories.RecycleBinRepository, auditRepo repositories.AuditRepository) WorkerService {
+	return &workerService{db: db, workerRepo: workerRepo, sessionRepo: sessionRepo, roleRepo: roleRepo, recycleBin: recycleBinRepo, groupLimit: groupLimitChecker{roleRepo: roleRepo, workerRepo: workerRepo}, audit: auditRecorder{repo: auditRepo}}
 }
 
 // --- 查询方法 ---
@@ -54,8 +68,8 @@ func (s *workerService) GetByName(name string) (*models.Worker, error) {
 	return s.workerRepo.GetByName(name)
 }
 
-func (s *workerService) GetAll() ([]*models.Worker, error) {
-	return s.workerRepo.GetAll()
+func (s *workerService) GetAll(filter *models.WorkerFilter) ([]*models.Worker, int, error) {
+	return s.workerRepo.GetAll(filter)
 }
 
 func (s *workerService) GetWorkerTasks(workerID int) ([]*models.ProductionTask, error) {
@@ -68,70 +82,385 @@ func (s *workerService) GetWorkerLogs(workerID int) ([]*models.ProductionLog, er
 
 // --- 管理方法 ---
 
-func (s *workerService) Create(workerReq *models.CreateWorkerRequest) (*models.Worker, error) {
+func (s *workerService) Create(actor models.Actor, workerReq *models.CreateWorkerRequest) (*models.Worker, error) {
 	// 检查同名员工是否已存在
 	existingWorker, err := s.workerRepo.GetByName(workerReq.Name)
 	if err == nil && existingWorker != nil {
 		return nil, &ValidationError{Message: "员工姓名已存在"}
 	}
-	return s.workerRepo.Create(workerReq)
+	if err := s.checkRoleAssignment(actor, workerReq.Role); err != nil {
+		return nil, err
+	}
+
+	tx, err := s.db.Beginx()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if workerReq.IsActive {
+		if err := s.groupLimit.check(tx, workerReq.Role, workerReq.WorkerGroup, 0); err != nil {
+			return nil, err
+		}
+	}
+	worker, err := s.workerRepo.Create(tx, workerReq)
+	if err != nil {
+		return nil, workerNameError(err)
+	}
+	if err := s.audit.record(tx, actor, AuditActionCreate, AuditEntityWorker, int64(worker.WorkerID), nil, worker); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return worker, nil
 }
 
-func (s *workerService) Update(id int, workerReq *models.UpdateWorkerRequest) (*models.Worker, error) {
+func (s *workerService) Update(actor models.Actor, id int, workerReq *models.UpdateWorkerRequest) (*models.Worker, error) {
+	before, err := s.workerRepo.GetByID(id)
+	if err != nil {
+		return nil, err
+	}
+
 	// 检查其他员工是否已使用该名称
 	existingWorker, err := s.workerRepo.GetByName(workerReq.Name)
 	if err == nil && existingWorker != nil && existingWorker.WorkerID != id {
 		return nil, &ValidationError{Message: "员工姓名已存在"}
 	}
 
-	// 验证角色值
-	validRoles := map[string]bool{"admin": true, "manager": true, "worker": true, "pattern_maker": true}
-	if !validRoles[workerReq.Role] {
-		return nil, &ValidationError{Message: "无效的角色"}
+	// 验证角色，并禁止越权修改拥有员工管理权限的账户
+	if err := s.checkRoleAssignment(actor, workerReq.Role); err != nil {
+		return nil, err
+	}
+	if err := s.checkCanManage(actor, before, "信息"); err != nil {
+		return nil, err
+	}
+
+	tx, err := s.db.Beginx()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
 	}
+	defer tx.Rollback()
 
-	return s.workerRepo.Update(id, workerReq)
+	// 只有角色、班组变化或重新启用账户时才占用新的名额，
+	// 避免上限调低后已有员工连普通信息都无法修改
+	assignmentChanged := before.Role != workerReq.Role ||
+		!sameGroup(before.WorkerGroup, workerReq.WorkerGroup) ||
+		(!before.IsActive && workerReq.IsActive)
+	if workerReq.IsActive && assignmentChanged {
+		if err := s.groupLimit.check(tx, workerReq.Role, workerReq.WorkerGroup, id); err != nil {
+			return nil, err
+		}
+	}
+	worker, err := s.workerRepo.Update(tx, id, workerReq)
+	if err != nil {
+		return nil, workerNameError(err)
+	}
+	// 禁用账户或更换角色时立即吊销其全部会话，已签发的令牌中的角色随之失效
+	if !worker.IsActive || before.Role != worker.Role {
+		if err := s.sessionRepo.RevokeAllForWorker(tx, id); err != nil {
+			return nil, err
+		}
+	}
+	if err := s.audit.record(tx, actor, AuditActionUpdate, AuditEntityWorker, int64(id), before, worker); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return worker, nil
 }
 
-func (s *workerService) Delete(id int) error {
+func (s *workerService) Delete(actor models.Actor, id int) error {
 	// 检查员工是否存在
 	worker, err := s.workerRepo.GetByID(id)
 	if err != nil {
 		return err // worker not found
 	}
 
-	// 禁止删除 admin 或 manager
-	if worker.Role == "admin" || worker.Role == "manager" {
-		return &ValidationError{Message: "不能删除管理员或车间主任账户"}
+	// 禁止删除拥有员工管理权限的账户 (如管理员、车间主任)
+	privileged, err := s.isPrivilegedRole(worker.Role)
+	if err != nil {
+		return err
+	}
+	if privileged {
+		return &ValidationError{Message: "不能删除拥有员工管理权限的账户"}
+	}
+
+	// 员工移入回收站，生产记录保持不变，同时吊销其全部会话
+	tx, err := s.db.Beginx()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err := s.recycleBin.Archive(tx, AuditEntityWorker, id, actor.WorkerID); err != nil {
+		return err
+	}
+	if err := s.sessionRepo.RevokeAllForWorker(tx, id); err != nil {
+		return err
+	}
+	if err := s.audit.record(tx, actor, AuditActionDelete, AuditEntityWorker, int64(id), worker, nil); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
+
+// workerNameError 同名员工已在回收站中时，提示先恢复或彻底删除
+func workerNameError(err error) error {
+	if errors.Is(err, repositories.ErrWorkerNameTaken) {
+		return &ValidationError{Message: "员工姓名已被回收站中的员工占用，请先恢复或彻底删除该员工"}
+	}
+	return err
+}
+
+func (s *workerService) UpdatePassword(actor models.Actor, targetWorkerID int, newPassword string) error {
+	// 1. 获取目标用户信息并检查权限
+	if err := s.checkCredentialPermission(actor, targetWorkerID, "密码"); err != nil {
+		return err
+	}
+
+	// 2. 哈希新密码
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(newPassword), bcrypt.DefaultCost)
+	if err != nil {
+		return fmt.Errorf("failed to hash password: %w", err)
+	}
+
+	// 3. 更新数据库并吊销该用户的全部会话，迫使其使用新密码重新登录
+	return s.changeCredential(actor, AuditActionPasswordChange, targetWorkerID, func(tx *sqlx.Tx) error {
+		return s.workerRepo.UpdatePassword(tx, targetWorkerID, string(hashedPassword))
+	})
+}
+
+// SetPin 为员工设置车间终端登录PIN，并吊销其现有会话
+func (s *workerService) SetPin(actor models.Actor, targetWorkerID int, pin string) error {
+	if !isValidPin(pin) {
+		return &ValidationError{Message: "PIN必须为4到8位数字"}
+	}
+	if err := s.checkCredentialPermission(actor, targetWorkerID, "PIN"); err != nil {
+		return err
+	}
+	hashedPin, err := bcrypt.GenerateFromPassword([]byte(pin), bcrypt.DefaultCost)
+	if err != nil {
+		return fmt.Errorf("failed to hash pin: %w", err)
+	}
+	pinHash := string(hashedPin)
+	return s.changeCredential(actor, AuditActionPinSet, targetWorkerID, func(tx *sqlx.Tx) error {
+		return s.workerRepo.UpdatePin(tx, targetWorkerID, &pinHash)
+	})
+}
+
+// ClearPin 清除员工的终端登录PIN
+func (s *workerService) ClearPin(actor models.Actor, targetWorkerID int) error {
+	if err := s.checkCredentialPermission(actor, targetWorkerID, "PIN"); err != nil {
+		return err
 	}
+	return s.changeCredential(actor, AuditActionPinClear, targetWorkerID, func(tx *sqlx.Tx) error {
+		return s.workerRepo.UpdatePin(tx, targetWorkerID, nil)
+	})
+}
 
-	return s.workerRepo.Delete(id)
+// SetBadge 为员工绑定工牌码，工牌码只保存哈希值
+func (s *workerService) SetBadge(actor models.Actor, targetWorkerID int, badgeCode string) error {
+	if len(badgeCode) < 4 || len(badgeCode) > 64 {
+		return &ValidationError{Message: "工牌码长度必须在4到64个字符之间"}
+	}
+	if err := s.checkCredentialPermission(actor, targetWorkerID, "工牌"); err != nil {
+		return err
+	}
+	badgeHash := auth.HashOpaqueToken(badgeCode)
+	err := s.changeCredential(actor, AuditActionBadgeSet, targetWorkerID, func(tx *sqlx.Tx) error {
+		return s.workerRepo.UpdateBadge(tx, targetWorkerID, &badgeHash)
+	})
+	if err != nil {
+		if errors.Is(err, repositories.ErrBadgeAlreadyAssigned) {
+			return &ValidationError{Message: "该工牌已绑定其他员工"}
+		}
+		return err
+	}
+	return nil
 }
 
-func (s *workerService) UpdatePassword(updatingUserID int, updatingUserRole string, targetWorkerID int, newPassword string) error {
-	// 1. 获取目标用户信息
+// ClearBadge 解除员工的工牌绑定 (例如工牌遗失)
+func (s *workerService) ClearBadge(actor models.Actor, targetWorkerID int) error {
+	if err := s.checkCredentialPermission(actor, targetWorkerID, "工牌"); err != nil {
+		return err
+	}
+	return s.changeCredential(actor, AuditActionBadgeClear, targetWorkerID, func(tx *sqlx.Tx) error {
+		return s.workerRepo.UpdateBadge(tx, targetWorkerID, nil)
+	})
+}
+
+// changeCredential 在同一事务中修改员工的登录凭证、吊销其全部会话并记录审计日志
+func (s *workerService) changeCredential(actor models.Actor, action string, targetWorkerID int, update func(tx *sqlx.Tx) error) error {
+	tx, err := s.db.Beginx()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err := update(tx); err != nil {
+		return err
+	}
+	if err := s.sessionRepo.RevokeAllForWorker(tx, targetWorkerID); err != nil {
+		return err
+	}
+	if err := s.audit.record(tx, actor, action, AuditEntityWorker, int64(targetWorkerID), nil, nil); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
+
+// isValidPin 检查PIN是否为4到8位数字
+func isValidPin(pin string) bool {
+	if len(pin) < 4 || len(pin) > 8 {
+		return false
+	}
+	for _, r := range pin {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// checkCredentialPermission 检查调用者能否修改目标员工的登录凭证
+func (s *workerService) checkCredentialPermission(actor models.Actor, targetWorkerID int, credential string) error {
 	targetWorker, err := s.workerRepo.GetByID(targetWorkerID)
 	if err != nil {
 		return &ValidationError{Message: "目标用户不存在"}
 	}
+	return s.checkCanManage(actor, targetWorker, credential)
+}
 
-	// 2. 权限检查
-	if updatingUserRole == "manager" {
-		if targetWorker.Role == "admin" || targetWorker.Role == "manager" {
-			return &ValidationError{Message: "权限不足，无法修改该用户的密码"}
-		}
-	} else if updatingUserRole != "admin" {
+// checkCanManage 检查调用者能否修改目标员工：需要员工管理权限，
+// 且只有拥有角色管理权限的调用者 (管理员) 才能修改同样拥有员工管理权限的账户
+func (s *workerService) checkCanManage(actor models.Actor, target *models.Worker, what string) error {
+	if !actor.Can(auth.PermWorkerManage) {
 		return &ValidationError{Message: "权限不足"}
 	}
+	if actor.Can(auth.PermRoleManage) {
+		return nil
+	}
+	privileged, err := s.isPrivilegedRole(target.Role)
+	if err != nil {
+		return err
+	}
+	if privileged {
+		return &ValidationError{Message: "权限不足，无法修改该用户的" + what}
+	}
+	return nil
+}
 
-	// 3. 哈希新密码
-	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(newPassword), bcrypt.DefaultCost)
+// checkRoleAssignment 检查角色是否存在，以及调用者能否分配该角色
+func (s *workerService) checkRoleAssignment(actor models.Actor, roleName string) error {
+	if _, err := s.roleRepo.GetByName(roleName); err != nil {
+		if err.Error() == "role not found" {
+			return &ValidationError{Message: "无效的角色"}
+		}
+		return err
+	}
+	if actor.Can(auth.PermRoleManage) {
+		return nil
+	}
+	privileged, err := s.isPrivilegedRole(roleName)
 	if err != nil {
-		return fmt.Errorf("failed to hash password: %w", err)
+		return err
+	}
+	if privileged {
+		return &ValidationError{Message: "只有管理员可以分配拥有员工管理权限的角色"}
 	}
+	return nil
+}
 
-	// 4. 更新数据库
-	return s.workerRepo.UpdatePassword(targetWorkerID, string(hashedPassword))
+// groupLimitChecker 检查角色的班组人数上限，供员工的创建、修改和从回收站恢复共用
+type groupLimitChecker struct {
+	roleRepo   repositories.RoleRepository
+	workerRepo repositories.WorkerRepository
+}
+
+// check 在事务中检查角色的班组人数上限。先锁定角色行，使同一角色的
+// 并发创建/修改/恢复串行执行，再统计班组内的现有人数，因此并发请求无法同时突破上限。
+func (c groupLimitChecker) check(tx *sqlx.Tx, roleName string, workerGroup *string, excludeWorkerID int) error {
+	role, err := c.roleRepo.LockByName(tx, roleName)
+	if err != nil {
+		if err.Error() == "role not found" {
+			return &ValidationError{Message: "无效的角色"}
+		}
+		return err
+	}
+	if role.MaxPerGroup == nil {
+		return nil
+	}
+	count, err := c.workerRepo.CountActiveByRoleAndGroup(tx, roleName, workerGroup, excludeWorkerID)
+	if err != nil {
+		return err
+	}
+	if count >= *role.MaxPerGroup {
+		groupName := "未分组"
+		if workerGroup != nil && *workerGroup != "" {
+			groupName = *workerGroup
+		}
+		return &ValidationError{Message: fmt.Sprintf("班组 '%s' 的%s已达到上限 (%d 人)", groupName, role.DisplayName, *role.MaxPerGroup)}
+	}
+	return nil
+}
+
+// sameGroup 比较两个班组，nil 和空字符串都表示未分组
+func sameGroup(a, b *string) bool {
+	var groupA, groupB string
+	if a != nil {
+		groupA = *a
+	}
+	if b != nil {
+		groupB = *b
+	}
+	return groupA == groupB
+}
+
+// isPrivilegedRole 判断角色是否拥有员工管理权限
+func (s *workerService) isPrivilegedRole(roleName string) (bool, error) {
+	codes, err := s.roleRepo.GetPermissionCodes(roleName)
+	if err != nil {
+		return false, err
+	}
+	for _, code := range codes {
+		if code == auth.PermWorkerManage {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// Unlock 解除因登录失败次数过多而被锁定的账户
+func (s *workerService) Unlock(actor models.Actor, id int) error {
+	before, err := s.workerRepo.GetByID(id)
+	if err != nil {
+		return err
+	}
+
+	tx, err := s.db.Beginx()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err := s.workerRepo.ResetLoginFailures(tx, id); err != nil {
+		return err
+	}
+	if err := s.audit.record(tx, actor, AuditActionUnlock, AuditEntityWorker, int64(id), before, nil); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
 }
 
 func (s *workerService) GetWorkerTaskGroups(workerID int) ([]models.WorkerTaskGroup, error) {
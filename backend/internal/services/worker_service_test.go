@@ -0,0 +1,378 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"cutrix-backend/internal/models"
+	"cutrix-backend/internal/repositories"
+	"cutrix-backend/pkg/auth"
+	"cutrix-backend/pkg/database"
+
+	"github.com/jmoiron/sqlx"
+	_ "github.com/lib/pq"
+)
+
+// txOnlyConnector 提供只支持开启、提交和回滚事务的数据库连接，
+// 供使用内存仓库的单元测试调用 db.Beginx()
+type txOnlyConnector struct{}
+
+func (txOnlyConnector) Connect(context.Context) (driver.Conn, error) { return txOnlyConn{}, nil }
+func (txOnlyConnector) Driver() driver.Driver                        { return nil }
+
+type txOnlyConn struct{}
+
+func (txOnlyConn) Prepare(string) (driver.Stmt, error) {
+	return nil, errors.New("txOnlyConn does not execute queries")
+}
+func (txOnlyConn) Close() error              { return nil }
+func (txOnlyConn) Begin() (driver.Tx, error) { return txOnlyConn{}, nil }
+func (txOnlyConn) Commit() error             { return nil }
+func (txOnlyConn) Rollback() error           { return nil }
+
+func newTxOnlyDB() *sqlx.DB {
+	return sqlx.NewDb(sql.OpenDB(txOnlyConnector{}), "postgres")
+}
+
+// memWorkerRepository 是内存中的员工仓库，只实现 WorkerService 创建和修改员工用到的方法
+type memWorkerRepository struct {
+	repositories.WorkerRepository
+	workers map[int]*models.Worker
+	nextID  int
+}
+
+func newMemWorkerRepository() *memWorkerRepository {
+	return &memWorkerRepository{workers: map[int]*models.Worker{}, nextID: 1}
+}
+
+func (r *memWorkerRepository) GetByID(id int) (*models.Worker, error) {
+	if worker, ok := r.workers[id]; ok {
+		copied := *worker
+		return &copied, nil
+	}
+	return nil, fmt.Errorf("worker not found")
+}
+
+func (r *memWorkerRepository) GetByIDInTx(tx *sqlx.Tx, id int) (*models.Worker, error) {
+	return r.GetByID(id)
+}
+
+func (r *memWorkerRepository) GetByName(name string) (*models.Worker, error) {
+	for _, worker := range r.workers {
+		if worker.Name == name {
+			copied := *worker
+			return &copied, nil
+		}
+	}
+	return nil, fmt.Errorf("worker not found")
+}
+
+func (r *memWorkerRepository) Create(tx *sqlx.Tx, req *models.CreateWorkerRequest) (*models.Worker, error) {
+	worker := &models.Worker{WorkerID: r.nextID, Name: req.Name, Notes: req.Notes, Role: req.Role, WorkerGroup: req.WorkerGroup, IsActive: req.IsActive}
+	r.workers[worker.WorkerID] = worker
+	r.nextID++
+	return r.GetByID(worker.WorkerID)
+}
+
+func (r *memWorkerRepository) Update(tx *sqlx.Tx, id int, req *models.UpdateWorkerRequest) (*models.Worker, error) {
+	worker, ok := r.workers[id]
+	if !ok {
+		return nil, fmt.Errorf("worker not found")
+	}
+	worker.Name, worker.Notes, worker.Role, worker.WorkerGroup, worker.IsActive = req.Name, req.Notes, req.Role, req.WorkerGroup, req.IsActive
+	return r.GetByID(id)
+}
+
+func (r *memWorkerRepository) CountActiveByRoleAndGroup(tx *sqlx.Tx, role string, workerGroup *string, excludeWorkerID int) (int, error) {
+	count := 0
+	for _, worker := range r.workers {
+		if worker.Role == role && worker.IsActive && sameGroup(worker.WorkerGroup, workerGroup) && worker.WorkerID != excludeWorkerID {
+			count++
+		}
+	}
+	return count, nil
+}
+
+// memRoleRepository 是内存中的角色仓库
+type memRoleRepository struct {
+	repositories.RoleRepository
+	roles map[string]*models.Role
+}
+
+func (r *memRoleRepository) GetByName(name string) (*models.Role, error) {
+	if role, ok := r.roles[name]; ok {
+		return role, nil
+	}
+	return nil, fmt.Errorf("role not found")
+}
+
+func (r *memRoleRepository) LockByName(tx *sqlx.Tx, name string) (*models.Role, error) {
+	return r.GetByName(name)
+}
+
+func (r *memRoleRepository) GetPermissionCodes(roleName string) ([]string, error) {
+	if role, ok := r.roles[roleName]; ok {
+		return role.Permissions, nil
+	}
+	return []string{}, nil
+}
+
+// memSessionRepository 记录被吊销全部会话的员工
+type memSessionRepository struct {
+	repositories.SessionRepository
+	revoked []int
+}
+
+func (r *memSessionRepository) RevokeAllForWorker(tx *sqlx.Tx, workerID int) error {
+	r.revoked = append(r.revoked, workerID)
+	return nil
+}
+
+type nopAuditRepository struct {
+	repositories.AuditRepository
+}
+
+func (nopAuditRepository) Create(tx *sqlx.Tx, entry *models.AuditLog) error { return nil }
+
+// adminActor 拥有员工管理和角色管理权限，不受越权修改的限制
+var adminActor = models.Actor{Role: "admin", Permissions: map[string]bool{auth.PermWorkerManage: true, auth.PermRoleManage: true}}
+
+func intPtr(v int) *int { return &v }
+
+func group(name string) *string { return &name }
+
+type workerServiceFixture struct {
+	service    WorkerService
+	workers    *memWorkerRepository
+	roles      *memRoleRepository
+	sessions   *memSessionRepository
+	recycleBin *memRecycleBin
+}
+
+// newWorkerServiceFixture 创建使用内存仓库的员工服务：裁床组长 (cutting_lead) 每个班组最多 2 人，工人不限
+func newWorkerServiceFixture() *workerServiceFixture {
+	f := &workerServiceFixture{
+		workers: newMemWorkerRepository(),
+		roles: &memRoleRepository{roles: map[string]*models.Role{
+			"cutting_lead": {Name: "cutting_lead", DisplayName: "裁床组长", MaxPerGroup: intPtr(2)},
+			"worker":       {Name: "worker", DisplayName: "工人"},
+		}},
+		sessions: &memSessionRepository{},
+	}
+	f.recycleBin = &memRecycleBin{workers: f.workers, archived: map[int]*models.Worker{}}
+	f.service = NewWorkerService(newTxOnlyDB(), f.workers, f.sessions, f.roles, f.recycleBin, nopAuditRepository{})
+	return f
+}
+
+func (f *workerServiceFixture) create(t *testing.T, name, role string, workerGroup *string, active bool) (*models.Worker, error) {
+	t.Helper()
+	return f.service.Create(adminActor, &models.CreateWorkerRequest{Name: name, Role: role, WorkerGroup: workerGroup, IsActive: active})
+}
+
+func (f *workerServiceFixture) mustCreate(t *testing.T, name, role string, workerGroup *string, active bool) *models.Worker {
+	t.Helper()
+	worker, err := f.create(t, name, role, workerGroup, active)
+	if err != nil {
+		t.Fatalf("create %s: %v", name, err)
+	}
+	return worker
+}
+
+func updateRequest(worker *models.Worker) *models.UpdateWorkerRequest {
+	return &models.UpdateWorkerRequest{Name: worker.Name, Notes: worker.Notes, Role: worker.Role, WorkerGroup: worker.WorkerGroup, IsActive: worker.IsActive}
+}
+
+func assertGroupLimitError(t *testing.T, err error) {
+	t.Helper()
+	var validationErr *ValidationError
+	if !errors.As(err, &validationErr) {
+		t.Fatalf("err = %v, want a ValidationError for the group limit", err)
+	}
+}
+
+func TestWorkerGroupLimitOnCreate(t *testing.T) {
+	f := newWorkerServiceFixture()
+	f.mustCreate(t, "A1", "cutting_lead", group("A"), true)
+	f.mustCreate(t, "A2", "cutting_lead", group("A"), true)
+
+	_, err := f.create(t, "A3", "cutting_lead", group("A"), true)
+	assertGroupLimitError(t, err)
+	if err.Error() != "班组 'A' 的裁床组长已达到上限 (2 人)" {
+		t.Errorf("err = %q", err.Error())
+	}
+
+	// 其他班组、停用的员工和不限人数的角色不受影响
+	f.mustCreate(t, "B1", "cutting_lead", group("B"), true)
+	f.mustCreate(t, "A3", "cutting_lead", group("A"), false)
+	f.mustCreate(t, "W1", "worker", group("A"), true)
+}
+
+func TestWorkerGroupLimitTreatsEmptyGroupAsUngrouped(t *testing.T) {
+	f := newWorkerServiceFixture()
+	f.mustCreate(t, "N1", "cutting_lead", nil, true)
+	f.mustCreate(t, "N2", "cutting_lead", group(""), true)
+
+	_, err := f.create(t, "N3", "cutting_lead", nil, true)
+	assertGroupLimitError(t, err)
+	if err.Error() != "班组 '未分组' 的裁床组长已达到上限 (2 人)" {
+		t.Errorf("err = %q", err.Error())
+	}
+}
+
+func TestWorkerGroupLimitOnUpdate(t *testing.T) {
+	f := newWorkerServiceFixture()
+	f.mustCreate(t, "A1", "cutting_lead", group("A"), true)
+	f.mustCreate(t, "A2", "cutting_lead", group("A"), true)
+	b1 := f.mustCreate(t, "B1", "cutting_lead", group("B"), true)
+	w1 := f.mustCreate(t, "W1", "worker", group("A"), true)
+
+	t.Run("move into a full group", func(t *testing.T) {
+		req := updateRequest(b1)
+		req.WorkerGroup = group("A")
+		_, err := f.service.Update(adminActor, b1.WorkerID, req)
+		assertGroupLimitError(t, err)
+	})
+	t.Run("promote into a full group", func(t *testing.T) {
+		req := updateRequest(w1)
+		req.Role = "cutting_lead"
+		_, err := f.service.Update(adminActor, w1.WorkerID, req)
+		assertGroupLimitError(t, err)
+	})
+	t.Run("promote into a group with room", func(t *testing.T) {
+		req := updateRequest(w1)
+		req.Role, req.WorkerGroup = "cutting_lead", group("B")
+		if _, err := f.service.Update(adminActor, w1.WorkerID, req); err != nil {
+			t.Fatalf("update: %v", err)
+		}
+	})
+	t.Run("edit a worker in a group over a lowered limit", func(t *testing.T) {
+		f.roles.roles["cutting_lead"].MaxPerGroup = intPtr(1)
+		defer func() { f.roles.roles["cutting_lead"].MaxPerGroup = intPtr(2) }()
+		a1, _ := f.workers.GetByName("A1")
+		req := updateRequest(a1)
+		req.Notes = "只修改备注"
+		if _, err := f.service.Update(adminActor, a1.WorkerID, req); err != nil {
+			t.Fatalf("update without changing the assignment: %v", err)
+		}
+	})
+}
+
+func TestWorkerGroupLimitOnReactivation(t *testing.T) {
+	f := newWorkerServiceFixture()
+	a1 := f.mustCreate(t, "A1", "cutting_lead", group("A"), true)
+	f.mustCreate(t, "A2", "cutting_lead", group("A"), true)
+
+	req := updateRequest(a1)
+	req.IsActive = false
+	if _, err := f.service.Update(adminActor, a1.WorkerID, req); err != nil {
+		t.Fatalf("deactivate: %v", err)
+	}
+	f.mustCreate(t, "A3", "cutting_lead", group("A"), true)
+
+	req.IsActive = true
+	_, err := f.service.Update(adminActor, a1.WorkerID, req)
+	assertGroupLimitError(t, err)
+	if worker, _ := f.workers.GetByID(a1.WorkerID); worker.IsActive {
+		t.Fatal("worker was reactivated although the group is full")
+	}
+}
+
+// TestWorkerGroupLimitConcurrentCreate 在真实的 PostgreSQL 中并发创建员工，验证 LockByName 的行锁
+// 使并发请求串行执行，班组人数不会超过上限。TEST_DATABASE_URL 须指向可写入的测试库，
+// 未设置时跳过；测试会先执行迁移，结束时删除创建的角色和员工
+func TestWorkerGroupLimitConcurrentCreate(t *testing.T) {
+	databaseURL := os.Getenv("TEST_DATABASE_URL")
+	if databaseURL == "" {
+		t.Skip("TEST_DATABASE_URL is not set")
+	}
+	db, err := sqlx.Connect("postgres", databaseURL)
+	if err != nil {
+		t.Fatalf("connect: %v", err)
+	}
+	defer db.Close()
+	if err := database.RunMigrations(db, "../../migrations"); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+
+	const limit, attempts = 3, 12
+	suffix := time.Now().UnixNano()
+	roleName, groupName := fmt.Sprintf("test_lead_%d", suffix), fmt.Sprintf("test_group_%d", suffix)
+	if _, err := db.Exec(`INSERT INTO Roles (name, display_name, max_per_group) VALUES ($1, $2, $3)`, roleName, "测试组长", limit); err != nil {
+		t.Fatalf("create role: %v", err)
+	}
+	t.Cleanup(func() {
+		db.Exec(`DELETE FROM Workers WHERE role = $1`, roleName)
+		db.Exec(`DELETE FROM Roles WHERE name = $1`, roleName)
+	})
+
+	service := NewWorkerService(db, repositories.NewWorkerRepository(db), repositories.NewSessionRepository(db),
+		repositories.NewRoleRepository(db), repositories.NewRecycleBinRepository(db), nopAuditRepository{})
+
+	var wg sync.WaitGroup
+	errs := make(chan error, attempts)
+	for i := range attempts {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := service.Create(adminActor, &models.CreateWorkerRequest{
+				Name: fmt.Sprintf("%s_%d", groupName, i), Role: roleName, WorkerGroup: group(groupName), IsActive: true,
+			})
+			errs <- err
+		}()
+	}
+	wg.Wait()
+	close(errs)
+
+	created := 0
+	for err := range errs {
+		if err == nil {
+			created++
+			continue
+		}
+		assertGroupLimitError(t, err)
+	}
+	var count int
+	if err := db.Get(&count, `SELECT COUNT(*) FROM Workers WHERE role = $1 AND worker_group = $2 AND is_active`, roleName, groupName); err != nil {
+		t.Fatalf("count: %v", err)
+	}
+	if created != limit || count != limit {
+		t.Fatalf("created %d workers (%d in the database), want exactly %d", created, count, limit)
+	}
+}
+
+func TestWorkerUpdateRevokesSessions(t *testing.T) {
+	f := newWorkerServiceFixture()
+	worker := f.mustCreate(t, "W1", "worker", group("A"), true)
+
+	tests := []struct {
+		name   string
+		change func(req *models.UpdateWorkerRequest)
+		revoke bool
+	}{
+		{"edit notes", func(req *models.UpdateWorkerRequest) { req.Notes = "夜班" }, false},
+		{"change group", func(req *models.UpdateWorkerRequest) { req.WorkerGroup = group("B") }, false},
+		{"change role", func(req *models.UpdateWorkerRequest) { req.Role = "cutting_lead" }, true},
+		{"deactivate", func(req *models.UpdateWorkerRequest) { req.IsActive = false }, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			current, _ := f.workers.GetByID(worker.WorkerID)
+			req := updateRequest(current)
+			tt.change(req)
+			f.sessions.revoked = nil
+			if _, err := f.service.Update(adminActor, worker.WorkerID, req); err != nil {
+				t.Fatalf("update: %v", err)
+			}
+			if revoked := len(f.sessions.revoked) > 0; revoked != tt.revoke {
+				t.Fatalf("sessions revoked = %v, want %v", revoked, tt.revoke)
+			}
+		})
+	}
+}
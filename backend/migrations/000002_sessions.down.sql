@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS idx_sessions_worker_id;
+DROP TABLE IF EXISTS Sessions;
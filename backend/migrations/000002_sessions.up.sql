@@ -0,0 +1,13 @@
+-- 登录会话表 (保存刷新令牌，支持服务端吊销)
+CREATE TABLE Sessions (
+    session_id BIGSERIAL PRIMARY KEY,
+    worker_id INT NOT NULL REFERENCES Workers(worker_id) ON DELETE CASCADE,
+    refresh_token_hash VARCHAR(64) NOT NULL UNIQUE,
+    client_ip VARCHAR(64),
+    user_agent VARCHAR(255),
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    expires_at TIMESTAMP NOT NULL,
+    revoked_at TIMESTAMP
+);
+
+CREATE INDEX idx_sessions_worker_id ON Sessions(worker_id);
@@ -0,0 +1,7 @@
+DROP INDEX IF EXISTS idx_login_events_created_at;
+DROP INDEX IF EXISTS idx_login_events_client_ip;
+DROP INDEX IF EXISTS idx_login_events_worker_name;
+DROP TABLE IF EXISTS Login_Events;
+
+ALTER TABLE Workers DROP COLUMN IF EXISTS locked_until;
+ALTER TABLE Workers DROP COLUMN IF EXISTS failed_login_attempts;
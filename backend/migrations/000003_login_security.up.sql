@@ -0,0 +1,19 @@
+-- 员工登录失败计数与锁定
+ALTER TABLE Workers ADD COLUMN failed_login_attempts INT NOT NULL DEFAULT 0;
+ALTER TABLE Workers ADD COLUMN locked_until TIMESTAMP;
+
+-- 登录事件表 (记录每一次登录成功与失败)
+CREATE TABLE Login_Events (
+    event_id BIGSERIAL PRIMARY KEY,
+    worker_id INT REFERENCES Workers(worker_id) ON DELETE SET NULL,
+    worker_name VARCHAR(50) NOT NULL,
+    client_ip VARCHAR(64) NOT NULL,
+    user_agent VARCHAR(255),
+    success BOOLEAN NOT NULL,
+    reason VARCHAR(50),
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX idx_login_events_worker_name ON Login_Events(worker_name, created_at);
+CREATE INDEX idx_login_events_client_ip ON Login_Events(client_ip, created_at);
+CREATE INDEX idx_login_events_created_at ON Login_Events(created_at);
@@ -0,0 +1,9 @@
+ALTER TABLE Login_Events DROP COLUMN IF EXISTS method;
+
+DELETE FROM Sessions WHERE refresh_token_hash IS NULL;
+ALTER TABLE Sessions DROP COLUMN IF EXISTS scope;
+ALTER TABLE Sessions ALTER COLUMN refresh_token_hash SET NOT NULL;
+
+DROP INDEX IF EXISTS idx_workers_badge_code_hash;
+ALTER TABLE Workers DROP COLUMN IF EXISTS badge_code_hash;
+ALTER TABLE Workers DROP COLUMN IF EXISTS pin_hash;
@@ -0,0 +1,11 @@
+-- 车间终端快捷登录凭证：数字PIN (bcrypt) 与工牌码 (SHA-256，用于按工牌查找员工)
+ALTER TABLE Workers ADD COLUMN pin_hash VARCHAR(255);
+ALTER TABLE Workers ADD COLUMN badge_code_hash VARCHAR(64);
+CREATE UNIQUE INDEX idx_workers_badge_code_hash ON Workers(badge_code_hash) WHERE badge_code_hash IS NOT NULL;
+
+-- 终端会话没有刷新令牌，并只允许有限的操作
+ALTER TABLE Sessions ALTER COLUMN refresh_token_hash DROP NOT NULL;
+ALTER TABLE Sessions ADD COLUMN scope VARCHAR(20) NOT NULL DEFAULT 'full';
+
+-- 登录事件区分登录方式
+ALTER TABLE Login_Events ADD COLUMN method VARCHAR(20) NOT NULL DEFAULT 'password';
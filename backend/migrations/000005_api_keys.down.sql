@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS idx_production_logs_api_key_id;
+ALTER TABLE Production_Logs DROP COLUMN IF EXISTS api_key_id;
+DROP TABLE IF EXISTS API_Keys;
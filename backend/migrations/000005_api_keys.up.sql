@@ -0,0 +1,17 @@
+-- 系统集成 API 密钥 (自动裁床、ERP 等)，只保存密钥的 SHA-256 哈希
+CREATE TABLE API_Keys (
+    api_key_id SERIAL PRIMARY KEY,
+    name VARCHAR(100) NOT NULL,
+    key_prefix VARCHAR(16) NOT NULL,
+    key_hash VARCHAR(64) NOT NULL UNIQUE,
+    scopes TEXT[] NOT NULL DEFAULT '{}',
+    created_by INT REFERENCES Workers(worker_id) ON DELETE SET NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    expires_at TIMESTAMP,
+    last_used_at TIMESTAMP,
+    revoked_at TIMESTAMP
+);
+
+-- 记录通过 API 密钥提交的生产记录来自哪个集成
+ALTER TABLE Production_Logs ADD COLUMN api_key_id INT REFERENCES API_Keys(api_key_id);
+CREATE INDEX idx_production_logs_api_key_id ON Production_Logs(api_key_id);
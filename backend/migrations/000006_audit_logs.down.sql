@@ -0,0 +1 @@
+DROP TABLE IF EXISTS Audit_Logs;
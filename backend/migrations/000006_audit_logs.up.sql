@@ -0,0 +1,18 @@
+-- 审计日志：记录每一次写操作的操作者、动作、对象以及变更前后的快照
+CREATE TABLE Audit_Logs (
+    audit_id BIGSERIAL PRIMARY KEY,
+    actor_worker_id INT REFERENCES Workers(worker_id) ON DELETE SET NULL,
+    actor_api_key_id INT REFERENCES API_Keys(api_key_id),
+    actor_role VARCHAR(50),
+    client_ip VARCHAR(64),
+    action VARCHAR(50) NOT NULL,
+    entity_type VARCHAR(50) NOT NULL,
+    entity_id BIGINT NOT NULL,
+    before_data JSONB,
+    after_data JSONB,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX idx_audit_logs_entity ON Audit_Logs(entity_type, entity_id);
+CREATE INDEX idx_audit_logs_actor_worker_id ON Audit_Logs(actor_worker_id);
+CREATE INDEX idx_audit_logs_created_at ON Audit_Logs(created_at);
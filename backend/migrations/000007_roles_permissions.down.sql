@@ -0,0 +1,8 @@
+ALTER TABLE Workers DROP CONSTRAINT IF EXISTS workers_role_fkey;
+UPDATE Workers SET role = 'worker' WHERE role NOT IN ('admin', 'manager', 'worker', 'pattern_maker');
+ALTER TABLE Workers ALTER COLUMN role TYPE VARCHAR(20);
+ALTER TABLE Workers ADD CONSTRAINT workers_role_check CHECK (role IN ('admin', 'manager', 'worker', 'pattern_maker'));
+
+DROP TABLE IF EXISTS Role_Permissions;
+DROP TABLE IF EXISTS Permissions;
+DROP TABLE IF EXISTS Roles;
@@ -0,0 +1,76 @@
+-- 角色与权限改为数据驱动：管理员可以创建自定义角色 (如裁剪组长、质检员) 并分配权限
+CREATE TABLE Roles (
+    role_id SERIAL PRIMARY KEY,
+    name VARCHAR(50) NOT NULL UNIQUE,
+    display_name VARCHAR(100) NOT NULL,
+    description VARCHAR(255),
+    is_system BOOLEAN NOT NULL DEFAULT false,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE Permissions (
+    permission_code VARCHAR(50) PRIMARY KEY,
+    description VARCHAR(255) NOT NULL
+);
+
+CREATE TABLE Role_Permissions (
+    role_id INT NOT NULL REFERENCES Roles(role_id) ON DELETE CASCADE,
+    permission_code VARCHAR(50) NOT NULL REFERENCES Permissions(permission_code) ON DELETE CASCADE,
+    PRIMARY KEY (role_id, permission_code)
+);
+
+INSERT INTO Permissions (permission_code, description) VALUES
+('style.view', '查看款号'),
+('style.manage', '创建和维护款号'),
+('order.view', '查看生产订单'),
+('order.create', '创建生产订单'),
+('order.delete', '删除生产订单'),
+('plan.list', '浏览生产计划列表'),
+('plan.view', '查看生产计划详情'),
+('plan.create', '创建生产计划'),
+('plan.edit', '修改生产计划'),
+('plan.delete', '删除生产计划'),
+('task.view', '查看生产任务和进度'),
+('log.view', '查看生产记录'),
+('log.create', '为本人提交生产记录'),
+('log.create_for_others', '代其他员工提交生产记录'),
+('log.void', '作废生产记录'),
+('worker.view', '查看员工信息'),
+('worker.manage', '创建、修改、删除员工及重置其登录凭证'),
+('worker.unlock', '解除员工账户锁定'),
+('security.view', '查看登录记录'),
+('audit.view', '查看审计日志'),
+('api_key.manage', '管理系统集成 API 密钥'),
+('role.manage', '管理角色和权限');
+
+INSERT INTO Roles (name, display_name, description, is_system) VALUES
+('admin', '管理员', '拥有全部权限', true),
+('manager', '车间主任', '负责订单、计划和车间人员管理', true),
+('pattern_maker', '版师', '查看订单和计划', true),
+('worker', '工人', '执行生产任务并提交记录', true);
+
+-- 管理员拥有全部权限
+INSERT INTO Role_Permissions (role_id, permission_code)
+SELECT r.role_id, p.permission_code FROM Roles r CROSS JOIN Permissions p WHERE r.name = 'admin';
+
+INSERT INTO Role_Permissions (role_id, permission_code)
+SELECT r.role_id, p.code FROM Roles r
+JOIN (VALUES
+    ('manager', 'style.view'), ('manager', 'style.manage'),
+    ('manager', 'order.view'), ('manager', 'order.create'), ('manager', 'order.delete'),
+    ('manager', 'plan.list'), ('manager', 'plan.view'), ('manager', 'plan.create'), ('manager', 'plan.edit'), ('manager', 'plan.delete'),
+    ('manager', 'task.view'),
+    ('manager', 'log.view'), ('manager', 'log.create'), ('manager', 'log.create_for_others'), ('manager', 'log.void'),
+    ('manager', 'worker.view'), ('manager', 'worker.manage'),
+    ('manager', 'security.view'),
+    ('pattern_maker', 'style.view'), ('pattern_maker', 'order.view'),
+    ('pattern_maker', 'plan.list'), ('pattern_maker', 'plan.view'),
+    ('pattern_maker', 'task.view'), ('pattern_maker', 'log.view'), ('pattern_maker', 'log.create'),
+    ('worker', 'style.view'), ('worker', 'plan.view'),
+    ('worker', 'task.view'), ('worker', 'log.view'), ('worker', 'log.create')
+) AS p(role_name, code) ON p.role_name = r.name;
+
+-- 员工角色改为引用 Roles 表，取代固定的 CHECK 约束
+ALTER TABLE Workers DROP CONSTRAINT IF EXISTS workers_role_check;
+ALTER TABLE Workers ALTER COLUMN role TYPE VARCHAR(50);
+ALTER TABLE Workers ADD CONSTRAINT workers_role_fkey FOREIGN KEY (role) REFERENCES Roles(name);
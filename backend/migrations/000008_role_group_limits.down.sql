@@ -0,0 +1 @@
+ALTER TABLE Roles DROP COLUMN IF EXISTS max_per_group;
@@ -0,0 +1,4 @@
+-- 角色在每个班组内的人数上限 (NULL 表示不限)，取代"只能有一个管理员/车间主任"的硬编码规则。
+-- 默认每个班组最多一名车间主任；未分组的员工视为同一个班组。
+ALTER TABLE Roles ADD COLUMN max_per_group INT CHECK (max_per_group > 0);
+UPDATE Roles SET max_per_group = 1 WHERE name = 'manager';
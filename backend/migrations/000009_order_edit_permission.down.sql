@@ -0,0 +1 @@
+DELETE FROM Permissions WHERE permission_code = 'order.edit';
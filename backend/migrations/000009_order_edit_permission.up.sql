@@ -0,0 +1,6 @@
+-- 修改生产订单明细 (增删改颜色/尺码行) 需要单独的权限
+INSERT INTO Permissions (permission_code, description) VALUES
+('order.edit', '修改生产订单明细');
+
+INSERT INTO Role_Permissions (role_id, permission_code)
+SELECT role_id, 'order.edit' FROM Roles WHERE name IN ('admin', 'manager');
@@ -0,0 +1,3 @@
+DELETE FROM Permissions WHERE permission_code = 'settings.manage';
+DROP TABLE IF EXISTS System_Settings;
+DROP TABLE IF EXISTS Order_Number_Sequences;
@@ -0,0 +1,33 @@
+-- 订单号计数器：按订单号中序号以外的部分 (如 PO-20250101-ST01-{seq}) 分别计数，
+-- 在创建订单的事务中原子递增；删除订单不会回收已经使用过的号码
+CREATE TABLE Order_Number_Sequences (
+    sequence_key VARCHAR(150) PRIMARY KEY,
+    last_value INT NOT NULL,
+    updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+-- 按现有订单号初始化计数器，避免新订单号与历史订单号冲突
+INSERT INTO Order_Number_Sequences (sequence_key, last_value)
+SELECT regexp_replace(order_number, '[0-9]{1,9}$', '{seq}'),
+       MAX(substring(order_number from '([0-9]{1,9})$')::int)
+FROM Production_Orders
+WHERE order_number ~ '[0-9]$'
+GROUP BY 1;
+
+-- 系统设置 (键值对)，由管理员维护
+CREATE TABLE System_Settings (
+    setting_id SERIAL PRIMARY KEY,
+    setting_key VARCHAR(100) NOT NULL UNIQUE,
+    setting_value TEXT NOT NULL,
+    updated_by INT REFERENCES Workers(worker_id) ON DELETE SET NULL,
+    updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+INSERT INTO System_Settings (setting_key, setting_value) VALUES
+('order_number_template', 'PO-{date}-{style}-{seq:2}');
+
+INSERT INTO Permissions (permission_code, description) VALUES
+('settings.manage', '管理系统设置 (如订单号规则)');
+
+INSERT INTO Role_Permissions (role_id, permission_code)
+SELECT role_id, 'settings.manage' FROM Roles WHERE name = 'admin';
@@ -0,0 +1,5 @@
+DELETE FROM Permissions WHERE permission_code = 'order.transition';
+DROP TABLE IF EXISTS Order_Status_History;
+DROP INDEX IF EXISTS idx_production_orders_status;
+ALTER TABLE Production_Orders DROP COLUMN IF EXISTS status_updated_at;
+ALTER TABLE Production_Orders DROP COLUMN IF EXISTS status;
@@ -0,0 +1,56 @@
+-- 订单生命周期状态：
+-- draft 草稿 -> confirmed 已确认 -> planned 已排计划 -> cutting 裁剪中 -> cut_complete 裁剪完成 -> handed_over 已交接
+-- 以及 cancelled 已取消。状态只能按服务端定义的转换规则变更。
+ALTER TABLE Production_Orders ADD COLUMN status VARCHAR(20) NOT NULL DEFAULT 'draft'
+    CHECK (status IN ('draft', 'confirmed', 'planned', 'cutting', 'cut_complete', 'handed_over', 'cancelled'));
+ALTER TABLE Production_Orders ADD COLUMN status_updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP;
+
+CREATE INDEX idx_production_orders_status ON Production_Orders(status);
+
+-- 订单状态变更历史 (automatic 表示由关联计划、拉布记录等业务事件自动触发)
+CREATE TABLE Order_Status_History (
+    history_id BIGSERIAL PRIMARY KEY,
+    order_id INT NOT NULL REFERENCES Production_Orders(order_id) ON DELETE CASCADE,
+    from_status VARCHAR(20),
+    to_status VARCHAR(20) NOT NULL,
+    actor_worker_id INT REFERENCES Workers(worker_id) ON DELETE SET NULL,
+    actor_api_key_id INT REFERENCES API_Keys(api_key_id),
+    automatic BOOLEAN NOT NULL DEFAULT false,
+    reason VARCHAR(255),
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX idx_order_status_history_order_id ON Order_Status_History(order_id);
+
+-- 根据现有计划和裁剪进度初始化历史订单的状态
+WITH progress AS (
+    SELECT pp.linked_order_id AS order_id,
+           COUNT(pt.task_id) AS tasks,
+           COUNT(pt.task_id) FILTER (WHERE pt.completed_layers >= pt.planned_layers) AS completed_tasks,
+           COALESCE(SUM(pt.completed_layers), 0) AS completed_layers
+    FROM Production_Plans pp
+    LEFT JOIN Cutting_Layouts cl ON cl.plan_id = pp.plan_id
+    LEFT JOIN Production_Tasks pt ON pt.layout_id = cl.layout_id
+    WHERE pp.linked_order_id IS NOT NULL
+    GROUP BY pp.linked_order_id
+)
+UPDATE Production_Orders po
+SET status = CASE
+        WHEN p.order_id IS NULL THEN 'confirmed'
+        WHEN p.tasks > 0 AND p.completed_tasks = p.tasks THEN 'cut_complete'
+        WHEN p.completed_layers > 0 THEN 'cutting'
+        ELSE 'planned'
+    END
+FROM Production_Orders o
+LEFT JOIN progress p ON p.order_id = o.order_id
+WHERE po.order_id = o.order_id;
+
+INSERT INTO Order_Status_History (order_id, from_status, to_status, automatic, reason)
+SELECT order_id, NULL, status, true, '根据已有计划和裁剪进度初始化'
+FROM Production_Orders;
+
+INSERT INTO Permissions (permission_code, description) VALUES
+('order.transition', '确认、取消和交接生产订单');
+
+INSERT INTO Role_Permissions (role_id, permission_code)
+SELECT role_id, 'order.transition' FROM Roles WHERE name IN ('admin', 'manager');
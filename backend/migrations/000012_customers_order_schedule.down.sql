@@ -0,0 +1,11 @@
+DELETE FROM Permissions WHERE permission_code IN ('customer.view', 'customer.manage');
+
+DROP INDEX IF EXISTS idx_production_orders_due_date;
+DROP INDEX IF EXISTS idx_production_orders_customer_id;
+
+ALTER TABLE Production_Orders DROP COLUMN IF EXISTS remarks;
+ALTER TABLE Production_Orders DROP COLUMN IF EXISTS priority;
+ALTER TABLE Production_Orders DROP COLUMN IF EXISTS due_date;
+ALTER TABLE Production_Orders DROP COLUMN IF EXISTS customer_id;
+
+DROP TABLE IF EXISTS Customers;
@@ -0,0 +1,31 @@
+-- 客户主数据。customer_code 用于订单号规则中的 {customer} 占位符，只允许字母、数字、- 和 _
+CREATE TABLE Customers (
+    customer_id SERIAL PRIMARY KEY,
+    customer_code VARCHAR(20) NOT NULL UNIQUE,
+    name VARCHAR(100) NOT NULL UNIQUE,
+    contact_name VARCHAR(50) NOT NULL DEFAULT '',
+    phone VARCHAR(30) NOT NULL DEFAULT '',
+    remarks TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+-- 订单的客户、交期、优先级和备注。已有订单的客户和交期留空
+ALTER TABLE Production_Orders ADD COLUMN customer_id INT REFERENCES Customers(customer_id) ON DELETE RESTRICT;
+ALTER TABLE Production_Orders ADD COLUMN due_date DATE;
+ALTER TABLE Production_Orders ADD COLUMN priority VARCHAR(10) NOT NULL DEFAULT 'normal'
+    CHECK (priority IN ('low', 'normal', 'high', 'urgent'));
+ALTER TABLE Production_Orders ADD COLUMN remarks TEXT NOT NULL DEFAULT '';
+
+CREATE INDEX idx_production_orders_customer_id ON Production_Orders(customer_id);
+CREATE INDEX idx_production_orders_due_date ON Production_Orders(due_date);
+
+INSERT INTO Permissions (permission_code, description) VALUES
+('customer.view', '查看客户'),
+('customer.manage', '创建和维护客户');
+
+INSERT INTO Role_Permissions (role_id, permission_code)
+SELECT role_id, 'customer.view' FROM Roles WHERE name IN ('admin', 'manager', 'pattern_maker');
+
+INSERT INTO Role_Permissions (role_id, permission_code)
+SELECT role_id, 'customer.manage' FROM Roles WHERE name IN ('admin', 'manager');
@@ -0,0 +1,8 @@
+-- 回退时每个计划只保留编号最小的关联订单，分配信息丢失
+ALTER TABLE Production_Plans ADD COLUMN linked_order_id INT REFERENCES Production_Orders(order_id);
+
+UPDATE Production_Plans pp
+SET linked_order_id = (SELECT MIN(po.order_id) FROM Plan_Orders po WHERE po.plan_id = pp.plan_id);
+
+DROP TABLE IF EXISTS Plan_Order_Allocations;
+DROP TABLE IF EXISTS Plan_Orders;
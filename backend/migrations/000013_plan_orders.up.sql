@@ -0,0 +1,39 @@
+-- 生产计划与订单改为多对多：同一款号的多个小订单可以合并到一个裁剪计划中
+CREATE TABLE Plan_Orders (
+    plan_id INT NOT NULL REFERENCES Production_Plans(plan_id) ON DELETE CASCADE,
+    order_id INT NOT NULL REFERENCES Production_Orders(order_id),
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (plan_id, order_id)
+);
+
+CREATE INDEX idx_plan_orders_order_id ON Plan_Orders(order_id);
+
+-- 计划件数在关联订单之间的分配 (每个订单每个 颜色+尺码 分到多少件)
+CREATE TABLE Plan_Order_Allocations (
+    allocation_id SERIAL PRIMARY KEY,
+    plan_id INT NOT NULL,
+    order_id INT NOT NULL,
+    color VARCHAR(50) NOT NULL,
+    size VARCHAR(50) NOT NULL,
+    quantity INT NOT NULL CHECK (quantity > 0),
+    FOREIGN KEY (plan_id, order_id) REFERENCES Plan_Orders(plan_id, order_id) ON DELETE CASCADE,
+    UNIQUE (plan_id, order_id, color, size)
+);
+
+CREATE INDEX idx_plan_order_allocations_order_id ON Plan_Order_Allocations(order_id);
+
+-- 迁移已有的单订单关联，计划的全部件数都分配给该订单
+INSERT INTO Plan_Orders (plan_id, order_id, created_at)
+SELECT plan_id, linked_order_id, created_at FROM Production_Plans WHERE linked_order_id IS NOT NULL;
+
+INSERT INTO Plan_Order_Allocations (plan_id, order_id, color, size, quantity)
+SELECT pp.plan_id, pp.linked_order_id, pt.color, lsr.size, SUM(lsr.ratio * pt.planned_layers)
+FROM Production_Plans pp
+JOIN Cutting_Layouts cl ON cl.plan_id = pp.plan_id
+JOIN Production_Tasks pt ON pt.layout_id = cl.layout_id
+JOIN Layout_Size_Ratios lsr ON lsr.layout_id = cl.layout_id
+WHERE pp.linked_order_id IS NOT NULL
+GROUP BY pp.plan_id, pp.linked_order_id, pt.color, lsr.size
+HAVING SUM(lsr.ratio * pt.planned_layers) > 0;
+
+ALTER TABLE Production_Plans DROP COLUMN linked_order_id;
@@ -0,0 +1,16 @@
+DELETE FROM Permissions WHERE permission_code = 'recycle_bin.manage';
+
+-- 回退后回收站中的记录会重新出现在各列表中
+DROP INDEX IF EXISTS idx_workers_deleted_at;
+DROP INDEX IF EXISTS idx_styles_deleted_at;
+DROP INDEX IF EXISTS idx_production_plans_deleted_at;
+DROP INDEX IF EXISTS idx_production_orders_deleted_at;
+
+ALTER TABLE Workers DROP COLUMN IF EXISTS deleted_by;
+ALTER TABLE Workers DROP COLUMN IF EXISTS deleted_at;
+ALTER TABLE Styles DROP COLUMN IF EXISTS deleted_by;
+ALTER TABLE Styles DROP COLUMN IF EXISTS deleted_at;
+ALTER TABLE Production_Plans DROP COLUMN IF EXISTS deleted_by;
+ALTER TABLE Production_Plans DROP COLUMN IF EXISTS deleted_at;
+ALTER TABLE Production_Orders DROP COLUMN IF EXISTS deleted_by;
+ALTER TABLE Production_Orders DROP COLUMN IF EXISTS deleted_at;
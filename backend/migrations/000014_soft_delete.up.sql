@@ -0,0 +1,26 @@
+-- 订单、计划、款号和员工改为软删除：删除时只记录删除时间和操作人，生产记录保持完整。
+-- 默认查询隐藏已删除的记录；管理员可以在回收站中恢复，或彻底删除没有生产记录的数据。
+-- 已删除记录的订单号、款号和员工姓名仍然占用，彻底删除后才能重新使用。
+ALTER TABLE Production_Orders ADD COLUMN deleted_at TIMESTAMP;
+ALTER TABLE Production_Orders ADD COLUMN deleted_by INT REFERENCES Workers(worker_id) ON DELETE SET NULL;
+
+ALTER TABLE Production_Plans ADD COLUMN deleted_at TIMESTAMP;
+ALTER TABLE Production_Plans ADD COLUMN deleted_by INT REFERENCES Workers(worker_id) ON DELETE SET NULL;
+
+ALTER TABLE Styles ADD COLUMN deleted_at TIMESTAMP;
+ALTER TABLE Styles ADD COLUMN deleted_by INT REFERENCES Workers(worker_id) ON DELETE SET NULL;
+
+ALTER TABLE Workers ADD COLUMN deleted_at TIMESTAMP;
+ALTER TABLE Workers ADD COLUMN deleted_by INT REFERENCES Workers(worker_id) ON DELETE SET NULL;
+
+-- 回收站按删除时间列出记录，只索引已删除的行
+CREATE INDEX idx_production_orders_deleted_at ON Production_Orders(deleted_at) WHERE deleted_at IS NOT NULL;
+CREATE INDEX idx_production_plans_deleted_at ON Production_Plans(deleted_at) WHERE deleted_at IS NOT NULL;
+CREATE INDEX idx_styles_deleted_at ON Styles(deleted_at) WHERE deleted_at IS NOT NULL;
+CREATE INDEX idx_workers_deleted_at ON Workers(deleted_at) WHERE deleted_at IS NOT NULL;
+
+INSERT INTO Permissions (permission_code, description) VALUES
+('recycle_bin.manage', '管理回收站 (恢复或彻底删除已删除的数据)');
+
+INSERT INTO Role_Permissions (role_id, permission_code)
+SELECT role_id, 'recycle_bin.manage' FROM Roles WHERE name = 'admin';
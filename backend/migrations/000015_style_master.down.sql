@@ -0,0 +1,10 @@
+DROP INDEX IF EXISTS idx_styles_customer_id;
+
+ALTER TABLE Styles DROP COLUMN IF EXISTS updated_at;
+ALTER TABLE Styles DROP COLUMN IF EXISTS created_at;
+ALTER TABLE Styles DROP COLUMN IF EXISTS main_fabric;
+ALTER TABLE Styles DROP COLUMN IF EXISTS colors;
+ALTER TABLE Styles DROP COLUMN IF EXISTS sizes;
+ALTER TABLE Styles DROP COLUMN IF EXISTS customer_id;
+ALTER TABLE Styles DROP COLUMN IF EXISTS season;
+ALTER TABLE Styles DROP COLUMN IF EXISTS description;
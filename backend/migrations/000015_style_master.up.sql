@@ -0,0 +1,12 @@
+-- 款号主数据：描述、季节、客户、有序的尺码组、允许的颜色和主面料。
+-- 尺码组和颜色为空时不限制订单明细和排版中的尺码、颜色，兼容下单时自动创建的款号
+ALTER TABLE Styles ADD COLUMN description TEXT NOT NULL DEFAULT '';
+ALTER TABLE Styles ADD COLUMN season VARCHAR(20) NOT NULL DEFAULT '';
+ALTER TABLE Styles ADD COLUMN customer_id INT REFERENCES Customers(customer_id) ON DELETE RESTRICT;
+ALTER TABLE Styles ADD COLUMN sizes TEXT[] NOT NULL DEFAULT '{}';
+ALTER TABLE Styles ADD COLUMN colors TEXT[] NOT NULL DEFAULT '{}';
+ALTER TABLE Styles ADD COLUMN main_fabric VARCHAR(100) NOT NULL DEFAULT '';
+ALTER TABLE Styles ADD COLUMN created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP;
+ALTER TABLE Styles ADD COLUMN updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP;
+
+CREATE INDEX idx_styles_customer_id ON Styles(customer_id);
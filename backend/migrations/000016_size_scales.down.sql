@@ -0,0 +1 @@
+ALTER TABLE Styles DROP COLUMN IF EXISTS size_scale;
@@ -0,0 +1,4 @@
+-- 款号的尺码体系：alpha (字母)、numeric (数字)、kids (童装) 或 custom (款号自定义的尺码组)。
+-- 已有款号按自定义处理，尺码组保持原来的顺序
+ALTER TABLE Styles ADD COLUMN size_scale VARCHAR(20) NOT NULL DEFAULT 'custom'
+    CHECK (size_scale IN ('alpha', 'numeric', 'kids', 'custom'));
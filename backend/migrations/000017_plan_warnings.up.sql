@@ -0,0 +1,14 @@
+-- 保存计划时计划件数与关联订单比对产生的警告 (宽松模式下计划照常保存，警告随计划保存)
+CREATE TABLE Plan_Warnings (
+    warning_id SERIAL PRIMARY KEY,
+    plan_id INT NOT NULL REFERENCES Production_Plans(plan_id) ON DELETE CASCADE,
+    warning_type VARCHAR(30) NOT NULL,
+    color VARCHAR(50) NOT NULL DEFAULT '',
+    size VARCHAR(50) NOT NULL DEFAULT '',
+    ordered_quantity INT NOT NULL DEFAULT 0,
+    planned_pieces INT NOT NULL DEFAULT 0,
+    message TEXT NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX idx_plan_warnings_plan_id ON Plan_Warnings(plan_id);
@@ -0,0 +1,5 @@
+-- 轮换刷新令牌时保留上一个令牌的哈希和轮换时间，用于识别已轮换的令牌被再次使用 (令牌被盗用)
+ALTER TABLE Sessions ADD COLUMN previous_refresh_token_hash VARCHAR(64);
+ALTER TABLE Sessions ADD COLUMN rotated_at TIMESTAMP;
+
+CREATE INDEX idx_sessions_previous_refresh_token_hash ON Sessions(previous_refresh_token_hash) WHERE previous_refresh_token_hash IS NOT NULL;
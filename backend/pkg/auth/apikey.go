@@ -0,0 +1,52 @@
+package auth
+
+// APIKeyPrefix 是所有 API 密钥的固定前缀，便于在日志和配置中识别
+const APIKeyPrefix = "ctx_"
+
+// ScopeAPIKey 标记通过 API 密钥认证的请求 (区别于用户令牌的 full/kiosk)
+const ScopeAPIKey = "api_key"
+
+// API 密钥可授予的权限范围
+const (
+	APIScopeLogsRead       = "logs:read"
+	APIScopeLogsWrite      = "logs:write"
+	APIScopeOrdersRead     = "orders:read"
+	APIScopeOrdersWrite    = "orders:write"
+	APIScopePlansRead      = "plans:read"
+	APIScopePlansWrite     = "plans:write"
+	APIScopeTasksRead      = "tasks:read"
+	APIScopeStylesRead     = "styles:read"
+	APIScopeStylesWrite    = "styles:write"
+	APIScopeCustomersRead  = "customers:read"
+	APIScopeCustomersWrite = "customers:write"
+)
+
+// APIScopes 列出全部合法的 API 密钥权限范围
+var APIScopes = []string{
+	APIScopeLogsRead, APIScopeLogsWrite,
+	APIScopeOrdersRead, APIScopeOrdersWrite,
+	APIScopePlansRead, APIScopePlansWrite,
+	APIScopeTasksRead,
+	APIScopeStylesRead, APIScopeStylesWrite,
+	APIScopeCustomersRead, APIScopeCustomersWrite,
+}
+
+// IsValidAPIScope 判断权限范围是否合法
+func IsValidAPIScope(scope string) bool {
+	for _, s := range APIScopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// NewAPIKey 生成新的 API 密钥，返回明文密钥、用于展示的前缀以及存储用的哈希
+func NewAPIKey() (key string, prefix string, hash string, err error) {
+	token, _, err := NewOpaqueToken()
+	if err != nil {
+		return "", "", "", err
+	}
+	key = APIKeyPrefix + token
+	return key, key[:len(APIKeyPrefix)+8], HashOpaqueToken(key), nil
+}
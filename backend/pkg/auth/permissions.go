@@ -0,0 +1,34 @@
+package auth
+
+// 权限代码，与 Permissions 表中的数据一一对应。
+// 角色拥有哪些权限保存在数据库中，由管理员维护。
+const (
+	PermStyleView          = "style.view"
+	PermStyleManage        = "style.manage"
+	PermCustomerView       = "customer.view"
+	PermCustomerManage     = "customer.manage"
+	PermOrderView          = "order.view"
+	PermOrderCreate        = "order.create"
+	PermOrderEdit          = "order.edit"
+	PermOrderTransition    = "order.transition"
+	PermOrderDelete        = "order.delete"
+	PermPlanList           = "plan.list"
+	PermPlanView           = "plan.view"
+	PermPlanCreate         = "plan.create"
+	PermPlanEdit           = "plan.edit"
+	PermPlanDelete         = "plan.delete"
+	PermTaskView           = "task.view"
+	PermLogView            = "log.view"
+	PermLogCreate          = "log.create"
+	PermLogCreateForOthers = "log.create_for_others"
+	PermLogVoid            = "log.void"
+	PermWorkerView         = "worker.view"
+	PermWorkerManage       = "worker.manage"
+	PermWorkerUnlock       = "worker.unlock"
+	PermSecurityView       = "security.view"
+	PermAuditView          = "audit.view"
+	PermAPIKeyManage       = "api_key.manage"
+	PermRoleManage         = "role.manage"
+	PermSettingsManage     = "settings.manage"
+	PermRecycleBinManage   = "recycle_bin.manage"
+)
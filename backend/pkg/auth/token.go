@@ -0,0 +1,90 @@
+package auth
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// 令牌作用域
+const (
+	// ScopeFull 是通过账号密码登录获得的完整权限令牌
+	ScopeFull = "full"
+	// ScopeKiosk 是车间终端通过PIN或工牌登录获得的受限令牌，
+	// 只能为本人提交生产记录和查看本人的任务组
+	ScopeKiosk = "kiosk"
+)
+
+// Claims 是访问令牌中携带的声明
+type Claims struct {
+	WorkerID  int    `json:"worker_id"`
+	Role      string `json:"role"`
+	SessionID int64  `json:"sid"`
+	Scope     string `json:"scope"`
+	jwt.RegisteredClaims
+}
+
+// TokenManager 负责签发和校验访问令牌
+type TokenManager struct {
+	secret []byte
+	ttl    time.Duration
+}
+
+// NewTokenManager 创建新的令牌管理器
+func NewTokenManager(secret string, ttl time.Duration) *TokenManager {
+	return &TokenManager{secret: []byte(secret), ttl: ttl}
+}
+
+// Issue 为指定员工的登录会话签发完整权限的访问令牌，返回令牌字符串及其过期时间
+func (m *TokenManager) Issue(workerID int, role string, sessionID int64) (string, time.Time, error) {
+	return m.sign(workerID, role, sessionID, ScopeFull, time.Now().Add(m.ttl))
+}
+
+// IssueKiosk 为车间终端会话签发受限令牌，令牌与会话同时过期
+func (m *TokenManager) IssueKiosk(workerID int, role string, sessionID int64, expiresAt time.Time) (string, time.Time, error) {
+	return m.sign(workerID, role, sessionID, ScopeKiosk, expiresAt)
+}
+
+func (m *TokenManager) sign(workerID int, role string, sessionID int64, scope string, expiresAt time.Time) (string, time.Time, error) {
+	now := time.Now()
+	claims := Claims{
+		WorkerID:  workerID,
+		Role:      role,
+		SessionID: sessionID,
+		Scope:     scope,
+		RegisteredClaims: jwt.RegisteredClaims{
+			Subject:   fmt.Sprintf("%d", workerID),
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(expiresAt),
+		},
+	}
+
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(m.secret)
+	if err != nil {
+		return "", time.Time{}, fmt.Errorf("failed to sign token: %w", err)
+	}
+	return token, expiresAt, nil
+}
+
+// Parse 校验令牌签名和有效期，并返回其中的声明
+func (m *TokenManager) Parse(tokenString string) (*Claims, error) {
+	claims := &Claims{}
+	_, err := jwt.ParseWithClaims(tokenString, claims, func(t *jwt.Token) (interface{}, error) {
+		return m.secret, nil
+	}, jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}), jwt.WithExpirationRequired())
+	if err != nil {
+		if errors.Is(err, jwt.ErrTokenExpired) {
+			return nil, fmt.Errorf("token expired")
+		}
+		return nil, fmt.Errorf("invalid token: %w", err)
+	}
+	if claims.WorkerID == 0 || claims.Role == "" || claims.SessionID == 0 {
+		return nil, fmt.Errorf("invalid token: missing worker claims")
+	}
+	if claims.Scope != ScopeFull && claims.Scope != ScopeKiosk {
+		return nil, fmt.Errorf("invalid token: unknown scope")
+	}
+	return claims, nil
+}
@@ -0,0 +1,226 @@
+// Package cutplan 根据订单的 颜色 × 尺码 数量自动生成裁剪方案 (排版配比和各颜色的拉布层数)。
+// 采用贪心算法：每次选出在约束内覆盖剩余数量最多的排版，直到订单全部覆盖，
+// 因此排版数通常最少，但不保证是全局最优解
+package cutplan
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// ErrTooManyMarkers 表示在允许的排版数内无法覆盖全部数量
+var ErrTooManyMarkers = errors.New("demand cannot be covered within the allowed number of markers")
+
+// Constraints 是裁剪方案的约束条件
+type Constraints struct {
+	MaxPlies             int     // 每床最多拉布层数
+	MaxGarmentsPerMarker int     // 每个排版 (唛架) 最多排的件数，即尺码配比之和
+	MaxMarkers           int     // 最多排版数
+	OvercutPercent       float64 // 每个 颜色+尺码 允许超裁的百分比
+}
+
+// Validate 检查约束条件的取值
+func (c Constraints) Validate() error {
+	if c.MaxPlies < 1 {
+		return fmt.Errorf("max plies must be at least 1")
+	}
+	if c.MaxGarmentsPerMarker < 1 {
+		return fmt.Errorf("max garments per marker must be at least 1")
+	}
+	if c.MaxMarkers < 1 {
+		return fmt.Errorf("max markers must be at least 1")
+	}
+	if c.OvercutPercent < 0 || c.OvercutPercent > 100 {
+		return fmt.Errorf("overcut percent must be between 0 and 100")
+	}
+	return nil
+}
+
+// Marker 是一个排版：Ratio[s] 为第 s 个尺码的配比，Layers[c] 为第 c 个颜色的拉布层数 (0 表示该颜色不用此排版)
+type Marker struct {
+	Ratio  []int
+	Layers []int
+}
+
+// Garments 返回排版每层的件数
+func (m Marker) Garments() int {
+	total := 0
+	for _, ratio := range m.Ratio {
+		total += ratio
+	}
+	return total
+}
+
+// Solve 为 demand[c][s] (第 c 个颜色、第 s 个尺码的数量) 生成裁剪方案。
+// 每个 颜色+尺码 的裁剪件数不少于需求，且超出部分不超过需求 × OvercutPercent%
+func Solve(demand [][]int, c Constraints) ([]Marker, error) {
+	if err := c.Validate(); err != nil {
+		return nil, err
+	}
+	sizeCount := 0
+	if len(demand) > 0 {
+		sizeCount = len(demand[0])
+	}
+	remaining := make([][]int, len(demand))
+	allowance := make([][]int, len(demand))
+	for ci, row := range demand {
+		if len(row) != sizeCount {
+			return nil, fmt.Errorf("demand rows must have the same number of sizes")
+		}
+		remaining[ci] = make([]int, sizeCount)
+		allowance[ci] = make([]int, sizeCount)
+		for si, quantity := range row {
+			if quantity < 0 {
+				return nil, fmt.Errorf("demand cannot be negative")
+			}
+			remaining[ci][si] = quantity
+			allowance[ci][si] = int(float64(quantity) * c.OvercutPercent / 100)
+		}
+	}
+
+	markers := []Marker{}
+	for total(remaining) > 0 {
+		if len(markers) == c.MaxMarkers {
+			return nil, ErrTooManyMarkers
+		}
+		marker := bestMarker(remaining, allowance, c)
+		for ci, layers := range marker.Layers {
+			for si, ratio := range marker.Ratio {
+				cut := layers * ratio
+				covered := min(cut, remaining[ci][si])
+				remaining[ci][si] -= covered
+				allowance[ci][si] -= cut - covered
+			}
+		}
+		markers = append(markers, marker)
+	}
+	return markers, nil
+}
+
+// SplitPlies 把 layers 层按每床最多 maxPlies 层拆成尽量均匀的几床
+func SplitPlies(layers, maxPlies int) []int {
+	if layers <= 0 {
+		return nil
+	}
+	spreads := (layers + maxPlies - 1) / maxPlies
+	plies := make([]int, spreads)
+	for i := range plies {
+		plies[i] = layers / spreads
+		if i < layers%spreads {
+			plies[i]++
+		}
+	}
+	return plies
+}
+
+func total(grid [][]int) int {
+	sum := 0
+	for _, row := range grid {
+		for _, v := range row {
+			sum += v
+		}
+	}
+	return sum
+}
+
+// candidate 是一个候选排版及其评分
+type candidate struct {
+	marker  Marker
+	covered int // 覆盖的剩余件数
+	spreads int // 需要拉布的床数
+}
+
+func (a candidate) betterThan(b candidate) bool {
+	if a.covered != b.covered {
+		return a.covered > b.covered
+	}
+	if a.spreads != b.spreads {
+		return a.spreads < b.spreads
+	}
+	return a.marker.Garments() < b.marker.Garments()
+}
+
+// bestMarker 在候选配比中选出覆盖剩余数量最多的排版。候选配比来自各尺码剩余总数按层数
+// 等比缩小 (向下和向上取整)，以及只排一个尺码的配比 (保证每次至少覆盖一件)
+func bestMarker(remaining, allowance [][]int, c Constraints) Marker {
+	sizeCount := len(remaining[0])
+	sizeTotals := make([]int, sizeCount)
+	maxTotal := 0
+	for _, row := range remaining {
+		for si, v := range row {
+			sizeTotals[si] += v
+		}
+	}
+	for _, v := range sizeTotals {
+		maxTotal = max(maxTotal, v)
+	}
+
+	var best *candidate
+	seen := map[string]bool{}
+	consider := func(ratio []int) {
+		garments, key := 0, make([]string, len(ratio))
+		for si, r := range ratio {
+			garments += r
+			key[si] = strconv.Itoa(r)
+		}
+		if garments == 0 || garments > c.MaxGarmentsPerMarker || seen[strings.Join(key, ",")] {
+			return
+		}
+		seen[strings.Join(key, ",")] = true
+		cand := evaluate(ratio, remaining, allowance, c.MaxPlies)
+		if cand.covered > 0 && (best == nil || cand.betterThan(*best)) {
+			best = &cand
+		}
+	}
+
+	for layers := 1; layers <= maxTotal; layers++ {
+		floor := make([]int, sizeCount)
+		ceil := make([]int, sizeCount)
+		for si, v := range sizeTotals {
+			floor[si] = v / layers
+			ceil[si] = (v + layers - 1) / layers
+		}
+		consider(floor)
+		consider(ceil)
+	}
+	for si, v := range sizeTotals {
+		if v > 0 {
+			unit := make([]int, sizeCount)
+			unit[si] = 1
+			consider(unit)
+		}
+	}
+	return best.marker
+}
+
+// evaluate 计算配比 ratio 下每个颜色的拉布层数：不超过裁剪件数允许的上限 (剩余数量加允许超裁的件数)，
+// 也不多于覆盖剩余数量所需的层数；同时统计覆盖的件数和床数
+func evaluate(ratio []int, remaining, allowance [][]int, maxPlies int) candidate {
+	cand := candidate{marker: Marker{Ratio: ratio, Layers: make([]int, len(remaining))}}
+	for ci := range remaining {
+		limit, needed := -1, 0
+		for si, r := range ratio {
+			if r == 0 {
+				continue
+			}
+			if fit := (remaining[ci][si] + allowance[ci][si]) / r; limit < 0 || fit < limit {
+				limit = fit
+			}
+			needed = max(needed, (remaining[ci][si]+r-1)/r)
+		}
+		layers := min(limit, needed)
+		if layers <= 0 {
+			continue
+		}
+		covered := 0
+		for si, r := range ratio {
+			covered += min(layers*r, remaining[ci][si])
+		}
+		cand.marker.Layers[ci] = layers
+		cand.covered += covered
+		cand.spreads += (layers + maxPlies - 1) / maxPlies
+	}
+	return cand
+}
@@ -0,0 +1,154 @@
+package cutplan
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestSolve(t *testing.T) {
+	tests := []struct {
+		name        string
+		demand      [][]int
+		constraints Constraints
+		wantMarkers int
+		wantErr     error // 为 nil 时检查方案覆盖需求且满足约束
+	}{
+		{
+			name:        "exact fit grid",
+			demand:      [][]int{{10, 20, 10}, {5, 10, 5}},
+			constraints: Constraints{MaxPlies: 50, MaxGarmentsPerMarker: 4, MaxMarkers: 5},
+			wantMarkers: 1,
+		},
+		{
+			// 9 × 12% = 1.08 向下取整为 1，1:1:1 拉 10 层正好用满允许的超裁
+			name:        "overcut allowance rounds down",
+			demand:      [][]int{{10, 10, 9}},
+			constraints: Constraints{MaxPlies: 50, MaxGarmentsPerMarker: 3, MaxMarkers: 5, OvercutPercent: 12},
+			wantMarkers: 1,
+		},
+		{
+			// 9 × 10% = 0.9 向下取整为 0，不能多裁，需要第二个排版补齐
+			name:        "overcut allowance below one garment",
+			demand:      [][]int{{10, 10, 9}},
+			constraints: Constraints{MaxPlies: 50, MaxGarmentsPerMarker: 3, MaxMarkers: 5, OvercutPercent: 10},
+			wantMarkers: 2,
+		},
+		{
+			// 每个排版只能排 1 件时，只有单尺码配比可用
+			name:        "single size fallback",
+			demand:      [][]int{{7, 3}},
+			constraints: Constraints{MaxPlies: 50, MaxGarmentsPerMarker: 1, MaxMarkers: 5},
+			wantMarkers: 2,
+		},
+		{
+			name:        "max plies splits spreads but not markers",
+			demand:      [][]int{{120, 120}},
+			constraints: Constraints{MaxPlies: 50, MaxGarmentsPerMarker: 2, MaxMarkers: 1},
+			wantMarkers: 1,
+		},
+		{
+			name:        "too many markers",
+			demand:      [][]int{{7, 3}},
+			constraints: Constraints{MaxPlies: 50, MaxGarmentsPerMarker: 1, MaxMarkers: 1},
+			wantErr:     ErrTooManyMarkers,
+		},
+		{
+			name:        "empty demand",
+			demand:      [][]int{{0, 0}},
+			constraints: Constraints{MaxPlies: 50, MaxGarmentsPerMarker: 4, MaxMarkers: 1},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			markers, err := Solve(tt.demand, tt.constraints)
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("Solve() error = %v, want %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Solve() error = %v", err)
+			}
+			if len(markers) != tt.wantMarkers {
+				t.Errorf("Solve() returned %d markers, want %d: %+v", len(markers), tt.wantMarkers, markers)
+			}
+			checkPlan(t, tt.demand, tt.constraints, markers)
+		})
+	}
+}
+
+// checkPlan 检查方案覆盖每个 颜色+尺码 的需求，超裁不超过允许的件数，且排版满足约束
+func checkPlan(t *testing.T, demand [][]int, c Constraints, markers []Marker) {
+	t.Helper()
+	if len(markers) > c.MaxMarkers {
+		t.Errorf("%d markers exceed MaxMarkers %d", len(markers), c.MaxMarkers)
+	}
+	cut := make([][]int, len(demand))
+	for ci, row := range demand {
+		cut[ci] = make([]int, len(row))
+	}
+	for _, marker := range markers {
+		if garments := marker.Garments(); garments < 1 || garments > c.MaxGarmentsPerMarker {
+			t.Errorf("marker %v has %d garments, want 1 to %d", marker.Ratio, garments, c.MaxGarmentsPerMarker)
+		}
+		for ci, layers := range marker.Layers {
+			for si, ratio := range marker.Ratio {
+				cut[ci][si] += layers * ratio
+			}
+		}
+	}
+	for ci, row := range demand {
+		for si, quantity := range row {
+			allowed := int(float64(quantity) * c.OvercutPercent / 100)
+			if cut[ci][si] < quantity {
+				t.Errorf("color %d size %d: cut %d, demand %d", ci, si, cut[ci][si], quantity)
+			}
+			if over := cut[ci][si] - quantity; over > allowed {
+				t.Errorf("color %d size %d: overcut %d exceeds allowance %d", ci, si, over, allowed)
+			}
+		}
+	}
+}
+
+func TestSolveRejectsInvalidInput(t *testing.T) {
+	valid := Constraints{MaxPlies: 50, MaxGarmentsPerMarker: 4, MaxMarkers: 5}
+	tests := []struct {
+		name        string
+		demand      [][]int
+		constraints Constraints
+	}{
+		{"zero max plies", [][]int{{1}}, Constraints{MaxGarmentsPerMarker: 4, MaxMarkers: 5}},
+		{"overcut above 100", [][]int{{1}}, Constraints{MaxPlies: 50, MaxGarmentsPerMarker: 4, MaxMarkers: 5, OvercutPercent: 101}},
+		{"ragged demand", [][]int{{1, 2}, {1}}, valid},
+		{"negative demand", [][]int{{1, -1}}, valid},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := Solve(tt.demand, tt.constraints); err == nil {
+				t.Fatal("Solve() error = nil, want an error")
+			}
+		})
+	}
+}
+
+func TestSplitPlies(t *testing.T) {
+	tests := []struct {
+		layers, maxPlies int
+		want             []int
+	}{
+		{0, 50, nil},
+		{30, 50, []int{30}},
+		{50, 50, []int{50}},
+		{100, 50, []int{50, 50}},
+		{101, 50, []int{34, 34, 33}},
+		{11, 5, []int{4, 4, 3}},
+	}
+	for _, tt := range tests {
+		if got := SplitPlies(tt.layers, tt.maxPlies); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("SplitPlies(%d, %d) = %v, want %v", tt.layers, tt.maxPlies, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,207 @@
+package middleware
+
+import (
+	"cutrix-backend/internal/models"
+	"cutrix-backend/pkg/auth"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RouteRule 描述一条路由的访问规则
+type RouteRule struct {
+	// Public 为 true 时该路由无需登录 (例如登录接口本身)
+	Public bool
+	// Authenticated 为 true 时任何已登录的员工都可以访问 (例如修改本人密码)
+	Authenticated bool
+	// Permission 调用该路由所需的权限代码
+	Permission string
+	// Self 为 true 时，路径参数 :id 等于调用者自身 worker_id 的请求同样放行
+	Self bool
+	// Kiosk 为 true 时允许车间终端令牌访问；若路由带 :id 参数，则只能访问本人
+	Kiosk bool
+	// APIScope 非空时，拥有该权限范围的 API 密钥可以访问
+	APIScope string
+}
+
+// Policy 是路由到访问规则的映射，键的格式为 "METHOD /完整/路由/路径"，
+// 例如 "GET /api/workers/:id"
+type Policy map[string]RouteRule
+
+// RouteKey 生成 Policy 中使用的键
+func RouteKey(method, path string) string {
+	return method + " " + path
+}
+
+// PermissionResolver 解析角色当前拥有的权限。角色权限保存在数据库中，
+// 每个请求都会重新解析，管理员修改角色后立即生效。
+type PermissionResolver interface {
+	PermissionsForRole(roleName string) (map[string]bool, error)
+}
+
+// Authorize 按照 Policy 对已认证的请求进行授权检查，必须放在 AuthRequired 之后。
+// 未在 Policy 中登记的路由一律拒绝访问。
+func Authorize(policy Policy, resolver PermissionResolver) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		rule, ok := policy[RouteKey(c.Request.Method, c.FullPath())]
+		if !ok {
+			abortForbidden(c, "route has no access policy")
+			return
+		}
+		if !IsKiosk(c) && !IsAPIKey(c) {
+			permissions, err := resolver.PermissionsForRole(CurrentRole(c))
+			if err != nil {
+				c.AbortWithStatusJSON(http.StatusInternalServerError, models.APIResponse{
+					Success: false,
+					Message: "Failed to resolve permissions",
+					Error:   err.Error(),
+				})
+				return
+			}
+			c.Set("permissions", permissions)
+		}
+		if rule.Public || rule.allows(c) {
+			c.Next()
+			return
+		}
+		if IsKiosk(c) {
+			abortForbidden(c, "kiosk token is not allowed to access this resource")
+			return
+		}
+		if IsAPIKey(c) {
+			abortForbidden(c, "api key lacks the scope required for this resource")
+			return
+		}
+		abortForbidden(c, "permission '"+rule.Permission+"' is required to access this resource")
+	}
+}
+
+func (r RouteRule) allows(c *gin.Context) bool {
+	if IsAPIKey(c) {
+		if r.APIScope == "" {
+			return false
+		}
+		for _, scope := range c.GetStringSlice("api_scopes") {
+			if scope == r.APIScope {
+				return true
+			}
+		}
+		return false
+	}
+
+	if IsKiosk(c) {
+		if !r.Kiosk {
+			return false
+		}
+		idStr := c.Param("id")
+		if idStr == "" {
+			return true
+		}
+		id, err := strconv.Atoi(idStr)
+		return err == nil && id == CurrentWorkerID(c)
+	}
+
+	if r.Authenticated {
+		return true
+	}
+	if r.Permission != "" && HasPermission(c, r.Permission) {
+		return true
+	}
+	if r.Self {
+		id, err := strconv.Atoi(c.Param("id"))
+		return err == nil && id == CurrentWorkerID(c)
+	}
+	return false
+}
+
+// Uncovered 返回已注册但未在 Policy 中登记的 /api 路由，以及 Policy 中
+// 已登记但并未注册的路由。启动时用于保证每条 API 路由都有明确的访问规则。
+func (p Policy) Uncovered(routes gin.RoutesInfo) (missing []string, stale []string) {
+	registered := make(map[string]bool, len(routes))
+	for _, route := range routes {
+		if !strings.HasPrefix(route.Path, "/api/") {
+			continue
+		}
+		key := RouteKey(route.Method, route.Path)
+		registered[key] = true
+		if _, ok := p[key]; !ok {
+			missing = append(missing, key)
+		}
+	}
+	for key := range p {
+		if !registered[key] {
+			stale = append(stale, key)
+		}
+	}
+	sort.Strings(missing)
+	sort.Strings(stale)
+	return missing, stale
+}
+
+// CurrentWorkerID 返回当前请求的已认证员工ID
+func CurrentWorkerID(c *gin.Context) int {
+	return c.GetInt("worker_id")
+}
+
+// CurrentRole 返回当前请求的已认证角色
+func CurrentRole(c *gin.Context) string {
+	return c.GetString("role")
+}
+
+// HasPermission 判断当前请求的调用者是否拥有指定权限 (车间终端令牌和 API 密钥不具备角色权限)
+func HasPermission(c *gin.Context, permission string) bool {
+	return currentPermissions(c)[permission]
+}
+
+func currentPermissions(c *gin.Context) map[string]bool {
+	if value, ok := c.Get("permissions"); ok {
+		if permissions, ok := value.(map[string]bool); ok {
+			return permissions
+		}
+	}
+	return nil
+}
+
+// IsKiosk 判断当前请求是否使用车间终端受限令牌
+func IsKiosk(c *gin.Context) bool {
+	return c.GetString("scope") == auth.ScopeKiosk
+}
+
+// IsAPIKey 判断当前请求是否通过 API 密钥认证
+func IsAPIKey(c *gin.Context) bool {
+	return c.GetString("scope") == auth.ScopeAPIKey
+}
+
+// CurrentAPIKeyID 返回当前请求使用的 API 密钥ID，用户令牌请求返回 nil
+func CurrentAPIKeyID(c *gin.Context) *int {
+	if !IsAPIKey(c) {
+		return nil
+	}
+	id := c.GetInt("api_key_id")
+	return &id
+}
+
+// CurrentActor 返回当前请求的调用者，供服务层记录审计日志
+func CurrentActor(c *gin.Context) models.Actor {
+	actor := models.Actor{
+		Role:        CurrentRole(c),
+		APIKeyID:    CurrentAPIKeyID(c),
+		Permissions: currentPermissions(c),
+		ClientIP:    c.ClientIP(),
+	}
+	if id := CurrentWorkerID(c); id != 0 {
+		actor.WorkerID = &id
+	}
+	return actor
+}
+
+func abortForbidden(c *gin.Context, reason string) {
+	c.AbortWithStatusJSON(http.StatusForbidden, models.APIResponse{
+		Success: false,
+		Message: "权限不足",
+		Error:   reason,
+	})
+}
@@ -1,9 +1,11 @@
 package middleware
 
 import (
+	"cutrix-backend/internal/models"
+	"cutrix-backend/pkg/auth"
 	"fmt"
 	"net/http"
-	"strconv"
+	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
@@ -14,7 +16,7 @@ func CORS() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		c.Header("Access-Control-Allow-Origin", "*")
 		c.Header("Access-Control-Allow-Credentials", "true")
-		c.Header("Access-Control-Allow-Headers", "Content-Type, Content-Length, Accept-Encoding, X-CSRF-Token, Authorization, accept, origin, Cache-Control, X-Requested-With")
+		c.Header("Access-Control-Allow-Headers", "Content-Type, Content-Length, Accept-Encoding, X-CSRF-Token, Authorization, X-API-Key, accept, origin, Cache-Control, X-Requested-With")
 		c.Header("Access-Control-Allow-Methods", "POST, OPTIONS, GET, PUT, DELETE")
 
 		if c.Request.Method == "OPTIONS" {
@@ -54,37 +56,93 @@ func Recovery() gin.HandlerFunc {
 	})
 }
 
-// -- 新增 AuthRequired 中间件 --
-func AuthRequired() gin.HandlerFunc {
+// SessionChecker 用于判断访问令牌所属的会话是否仍然有效
+type SessionChecker interface {
+	IsSessionActive(sessionID int64) (bool, error)
+}
+
+// APIKeyChecker 用于校验系统集成使用的 API 密钥，密钥无效时返回 nil, nil
+type APIKeyChecker interface {
+	AuthenticateAPIKey(key string) (*models.APIKey, error)
+}
+
+// AuthRequired 校验 X-API-Key 头中的 API 密钥，或 Authorization 头中的 Bearer
+// 访问令牌及其会话状态，并将认证结果写入上下文供后续处理器使用
+func AuthRequired(tokens *auth.TokenManager, sessions SessionChecker, apiKeys APIKeyChecker) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		workerIDStr := c.GetHeader("X-Worker-ID")
-		role := c.GetHeader("X-Role")
+		if key := c.GetHeader("X-API-Key"); key != "" {
+			authenticateAPIKey(c, apiKeys, key)
+			return
+		}
 
-		if workerIDStr == "" || role == "" {
-			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Authorization required"})
+		header := c.GetHeader("Authorization")
+		tokenString, found := strings.CutPrefix(header, "Bearer ")
+		if !found || tokenString == "" {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, models.APIResponse{
+				Success: false,
+				Message: "Authorization required",
+				Error:   "missing bearer token",
+			})
+			return
+		}
+
+		claims, err := tokens.Parse(tokenString)
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, models.APIResponse{
+				Success: false,
+				Message: "Authorization required",
+				Error:   err.Error(),
+			})
 			return
 		}
 
-		workerID, err := strconv.Atoi(workerIDStr)
+		active, err := sessions.IsSessionActive(claims.SessionID)
 		if err != nil {
-			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Invalid Worker ID"})
+			c.AbortWithStatusJSON(http.StatusInternalServerError, models.APIResponse{
+				Success: false,
+				Message: "Failed to verify session",
+				Error:   err.Error(),
+			})
+			return
+		}
+		if !active {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, models.APIResponse{
+				Success: false,
+				Message: "Authorization required",
+				Error:   "session revoked or expired",
+			})
 			return
 		}
 
-		c.Set("worker_id", workerID)
-		c.Set("role", role)
+		c.Set("worker_id", claims.WorkerID)
+		c.Set("role", claims.Role)
+		c.Set("session_id", claims.SessionID)
+		c.Set("scope", claims.Scope)
 		c.Next()
 	}
 }
 
-// -- 新增 AdminRequired 中间件 --
-func AdminRequired() gin.HandlerFunc {
-	return func(c *gin.Context) {
-		role, exists := c.Get("role")
-		if !exists || role.(string) != "admin" {
-			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "Admin access required"})
-			return
-		}
-		c.Next()
+func authenticateAPIKey(c *gin.Context, apiKeys APIKeyChecker, key string) {
+	apiKey, err := apiKeys.AuthenticateAPIKey(key)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, models.APIResponse{
+			Success: false,
+			Message: "Failed to verify api key",
+			Error:   err.Error(),
+		})
+		return
 	}
+	if apiKey == nil {
+		c.AbortWithStatusJSON(http.StatusUnauthorized, models.APIResponse{
+			Success: false,
+			Message: "Authorization required",
+			Error:   "invalid, revoked or expired api key",
+		})
+		return
+	}
+
+	c.Set("api_key_id", apiKey.APIKeyID)
+	c.Set("api_scopes", []string(apiKey.Scopes))
+	c.Set("scope", auth.ScopeAPIKey)
+	c.Next()
 }
@@ -0,0 +1,248 @@
+// Package sizes 定义服装的尺码体系 (字母、数字、童装和款号自定义的尺码组)，
+// 把录入的尺码规范为标准写法 (如 "xl" -> "XL"、"2XL" -> "XXL")，并按尺码体系的顺序排序
+package sizes
+
+import (
+	"cmp"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// 尺码体系
+const (
+	Alpha   = "alpha"   // 字母尺码 XXS ~ 6XL
+	Numeric = "numeric" // 数字尺码 (腰围、欧码等)，按数值排序
+	Kids    = "kids"    // 童装身高尺码 73 ~ 170
+	Custom  = "custom"  // 款号自定义的尺码组，按款号给出的顺序排序
+)
+
+// Scales 是全部尺码体系
+var Scales = []string{Alpha, Numeric, Kids, Custom}
+
+var alphaSizes = []string{"XXS", "XS", "S", "M", "L", "XL", "XXL", "3XL", "4XL", "5XL", "6XL"}
+
+// alphaAliases 是字母尺码的其他常见写法
+var alphaAliases = map[string]string{
+	"2XS":     "XXS",
+	"2XL":     "XXL",
+	"XXXL":    "3XL",
+	"XXXXL":   "4XL",
+	"XXXXXL":  "5XL",
+	"XXXXXXL": "6XL",
+}
+
+var kidsSizes = []string{"73", "80", "90", "100", "110", "120", "130", "140", "150", "160", "170"}
+
+var alphaRank = rankOf(alphaSizes)
+
+func rankOf(values []string) map[string]int {
+	rank := make(map[string]int, len(values))
+	for i, value := range values {
+		rank[value] = i
+	}
+	return rank
+}
+
+// IsScale 判断 name 是否为已知的尺码体系
+func IsScale(name string) bool {
+	return slices.Contains(Scales, name)
+}
+
+// Label 返回尺码体系的中文名称
+func Label(scale string) string {
+	switch scale {
+	case Alpha:
+		return "字母尺码"
+	case Numeric:
+		return "数字尺码"
+	case Kids:
+		return "童装尺码"
+	case Custom:
+		return "自定义尺码"
+	}
+	return scale
+}
+
+// Normalize 把尺码规范为标准写法：字母尺码转为大写并统一别名，数字尺码去掉前导零和 "cm" 后缀，
+// 其他尺码只去掉首尾空白
+func Normalize(size string) string {
+	size = strings.TrimSpace(size)
+	upper := strings.ToUpper(size)
+	if alias, ok := alphaAliases[upper]; ok {
+		return alias
+	}
+	if _, ok := alphaRank[upper]; ok {
+		return upper
+	}
+	if number, ok := parseNumber(size); ok {
+		return strconv.FormatFloat(number, 'f', -1, 64)
+	}
+	return size
+}
+
+// parseNumber 解析数字尺码，允许 "cm" 后缀 (童装常写作 110cm)
+func parseNumber(size string) (float64, bool) {
+	size = strings.TrimSuffix(strings.ToLower(strings.TrimSpace(size)), "cm")
+	number, err := strconv.ParseFloat(size, 64)
+	if err != nil || number <= 0 {
+		return 0, false
+	}
+	return number, true
+}
+
+// OnScale 判断规范化后的尺码是否属于尺码体系，自定义尺码体系接受任何尺码
+func OnScale(scale, size string) bool {
+	switch scale {
+	case Alpha:
+		_, ok := alphaRank[size]
+		return ok
+	case Numeric:
+		_, ok := parseNumber(size)
+		return ok
+	case Kids:
+		return slices.Contains(kidsSizes, size)
+	}
+	return true
+}
+
+// Compare 按通用的尺码顺序比较两个尺码：字母尺码在前并按从小到大排列，其次是按数值排列的数字尺码，
+// 其余尺码按字符串排列
+func Compare(a, b string) int {
+	a, b = Normalize(a), Normalize(b)
+	ga, ka := sortKey(a)
+	gb, kb := sortKey(b)
+	if ga != gb {
+		return cmp.Compare(ga, gb)
+	}
+	if c := cmp.Compare(ka, kb); c != 0 {
+		return c
+	}
+	return strings.Compare(a, b)
+}
+
+func sortKey(size string) (group int, key float64) {
+	if rank, ok := alphaRank[size]; ok {
+		return 0, float64(rank)
+	}
+	if number, ok := parseNumber(size); ok {
+		return 1, number
+	}
+	return 2, 0
+}
+
+// Run 是一个款号使用的尺码组：所属的尺码体系和按顺序排列的尺码。
+// 尺码组为空时不限制尺码，只做规范化，并按通用顺序排序
+type Run struct {
+	scale string
+	sizes []string
+	index map[string]int
+}
+
+// NewRun 校验并规范化款号的尺码组。字母、数字和童装尺码组按尺码体系的顺序重新排列，
+// 自定义尺码组保持给出的顺序。scale 为空时视为自定义
+func NewRun(scale string, sizes []string) (*Run, error) {
+	if scale == "" {
+		scale = Custom
+	}
+	if !IsScale(scale) {
+		return nil, fmt.Errorf("未知的尺码体系 %s，只能是 %s", scale, strings.Join(Scales, "、"))
+	}
+	run := &Run{scale: scale, sizes: make([]string, 0, len(sizes))}
+	seen := make(map[string]bool, len(sizes))
+	for _, size := range sizes {
+		normalized := Normalize(size)
+		if normalized == "" {
+			return nil, fmt.Errorf("尺码不能为空")
+		}
+		if !OnScale(scale, normalized) {
+			return nil, fmt.Errorf("尺码 %s 不属于%s", size, Label(scale))
+		}
+		if seen[normalized] {
+			return nil, fmt.Errorf("尺码 %s 重复", normalized)
+		}
+		seen[normalized] = true
+		run.sizes = append(run.sizes, normalized)
+	}
+	if scale != Custom {
+		slices.SortStableFunc(run.sizes, Compare)
+	}
+	run.index = rankOf(run.sizes)
+	return run, nil
+}
+
+// LoadRun 返回款号已保存的尺码组。保存款号时已校验过，无法解析的旧数据按不限制尺码的自定义尺码组处理
+func LoadRun(scale string, sizes []string) *Run {
+	run, err := NewRun(scale, sizes)
+	if err != nil {
+		run, _ = NewRun(Custom, nil)
+	}
+	return run
+}
+
+// Scale 返回尺码组所属的尺码体系
+func (r *Run) Scale() string {
+	return r.scale
+}
+
+// Sizes 返回按顺序排列的尺码
+func (r *Run) Sizes() []string {
+	return slices.Clone(r.sizes)
+}
+
+// Normalize 把尺码规范为尺码组中的写法，尺码不属于尺码组 (或尺码体系) 时返回 false
+func (r *Run) Normalize(size string) (string, bool) {
+	normalized := Normalize(size)
+	if normalized == "" || !OnScale(r.scale, normalized) {
+		return normalized, false
+	}
+	if len(r.sizes) == 0 {
+		return normalized, true
+	}
+	if _, ok := r.index[normalized]; ok {
+		return normalized, true
+	}
+	// 自定义尺码组中的尺码可能不是标准写法，按不区分大小写匹配
+	for _, s := range r.sizes {
+		if strings.EqualFold(s, normalized) {
+			return s, true
+		}
+	}
+	return normalized, false
+}
+
+// Compare 按尺码组的顺序比较两个尺码，不在尺码组中的尺码排在后面并按通用顺序排列
+func (r *Run) Compare(a, b string) int {
+	ia, okA := r.index[Normalize(a)]
+	ib, okB := r.index[Normalize(b)]
+	switch {
+	case okA && okB:
+		return cmp.Compare(ia, ib)
+	case okA:
+		return -1
+	case okB:
+		return 1
+	}
+	return Compare(a, b)
+}
+
+// SortByColor 把明细按颜色首次出现的顺序分组，组内按尺码组的顺序排列，
+// 用于订单、分配、执行报表等 颜色 × 尺码 的表格
+func SortByColor[T any](run *Run, items []T, key func(item T) (color, size string)) {
+	colorOrder := map[string]int{}
+	for _, item := range items {
+		color, _ := key(item)
+		if _, ok := colorOrder[color]; !ok {
+			colorOrder[color] = len(colorOrder)
+		}
+	}
+	slices.SortStableFunc(items, func(a, b T) int {
+		colorA, sizeA := key(a)
+		colorB, sizeB := key(b)
+		if c := cmp.Compare(colorOrder[colorA], colorOrder[colorB]); c != 0 {
+			return c
+		}
+		return run.Compare(sizeA, sizeB)
+	})
+}
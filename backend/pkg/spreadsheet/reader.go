@@ -0,0 +1,111 @@
+// Package spreadsheet 读取客户发来的 CSV / XLSX 表格，统一转换为按行排列的字符串单元格
+package spreadsheet
+
+import (
+	"bytes"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/xuri/excelize/v2"
+	"golang.org/x/text/encoding/simplifiedchinese"
+)
+
+// ErrUnsupportedFormat 表示文件既不是 CSV 也不是 XLSX
+var ErrUnsupportedFormat = errors.New("unsupported file format, only .csv and .xlsx are accepted")
+
+// Read 根据文件扩展名读取 CSV 或 XLSX (只读取第一个工作表)。
+// 返回的单元格已去除首尾空白，末尾的空行会被丢弃。
+func Read(filename string, r io.Reader) ([][]string, error) {
+	var (
+		rows [][]string
+		err  error
+	)
+	switch strings.ToLower(filepath.Ext(filename)) {
+	case ".csv":
+		rows, err = readCSV(r)
+	case ".xlsx":
+		rows, err = readXLSX(r)
+	default:
+		return nil, ErrUnsupportedFormat
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	for i, row := range rows {
+		for j, cell := range row {
+			rows[i][j] = strings.TrimSpace(cell)
+		}
+	}
+	for len(rows) > 0 && IsBlankRow(rows[len(rows)-1]) {
+		rows = rows[:len(rows)-1]
+	}
+	return rows, nil
+}
+
+// readCSV 读取 CSV，兼容 Excel 导出时带的 UTF-8 BOM 以及中文 Windows 下常见的 GBK 编码
+func readCSV(r io.Reader) ([][]string, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read csv: %w", err)
+	}
+	data = bytes.TrimPrefix(data, []byte("\xef\xbb\xbf"))
+	if !utf8.Valid(data) {
+		decoded, err := simplifiedchinese.GB18030.NewDecoder().Bytes(data)
+		if err != nil {
+			return nil, fmt.Errorf("csv is neither UTF-8 nor GB18030 encoded: %w", err)
+		}
+		data = decoded
+	}
+
+	reader := csv.NewReader(bytes.NewReader(data))
+	reader.FieldsPerRecord = -1
+	rows, err := reader.ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse csv: %w", err)
+	}
+	return rows, nil
+}
+
+func readXLSX(r io.Reader) ([][]string, error) {
+	file, err := excelize.OpenReader(r)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open xlsx: %w", err)
+	}
+	defer file.Close()
+
+	sheets := file.GetSheetList()
+	if len(sheets) == 0 {
+		return nil, fmt.Errorf("xlsx contains no worksheets")
+	}
+	rows, err := file.GetRows(sheets[0])
+	if err != nil {
+		return nil, fmt.Errorf("failed to read worksheet %s: %w", sheets[0], err)
+	}
+	return rows, nil
+}
+
+// IsBlankRow 判断一行是否所有单元格都为空
+func IsBlankRow(row []string) bool {
+	for _, cell := range row {
+		if cell != "" {
+			return false
+		}
+	}
+	return true
+}
+
+// ColumnName 把从 0 开始的列序号转换为表格列名，例如 0 -> A，27 -> AB
+func ColumnName(index int) string {
+	name := ""
+	for index >= 0 {
+		name = string(rune('A'+index%26)) + name
+		index = index/26 - 1
+	}
+	return name
+}
@@ -0,0 +1,179 @@
+package spreadsheet
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+
+	"github.com/xuri/excelize/v2"
+)
+
+// 导出文件格式
+const (
+	FormatCSV  = "csv"
+	FormatXLSX = "xlsx"
+)
+
+// csvFlushInterval CSV 每写入多少行刷新一次，让数据尽快发送给客户端
+const csvFlushInterval = 500
+
+// Writer 逐行写出表格。行数据可以是 string、整数、*int、time.Time 或 nil，
+// 调用方写完后必须调用 Close 才会把剩余数据写出。
+// CSV 每 csvFlushInterval 行写出一次；XLSX 是 zip 包，整个文件在 Close 时才写出 (见 xlsxWriter)。
+type Writer interface {
+	WriteRow(cells ...interface{}) error
+	Close() error
+}
+
+// IsSupportedFormat 判断是否为支持的导出格式
+func IsSupportedFormat(format string) bool {
+	return format == FormatCSV || format == FormatXLSX
+}
+
+// ContentType 返回导出格式对应的 HTTP Content-Type
+func ContentType(format string) string {
+	if format == FormatXLSX {
+		return "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet"
+	}
+	return "text/csv; charset=utf-8"
+}
+
+// NewWriter 创建写入 w 的表格，sheetName 只对 XLSX 有效
+func NewWriter(format string, w io.Writer, sheetName string) (Writer, error) {
+	switch format {
+	case FormatCSV:
+		return newCSVWriter(w)
+	case FormatXLSX:
+		return newXLSXWriter(w, sheetName)
+	default:
+		return nil, fmt.Errorf("unsupported export format: %s", format)
+	}
+}
+
+type csvWriter struct {
+	out  *csv.Writer
+	rows int
+}
+
+func newCSVWriter(w io.Writer) (*csvWriter, error) {
+	// 写入 UTF-8 BOM，否则 Excel 打开中文 CSV 会乱码
+	if _, err := w.Write([]byte("\xef\xbb\xbf")); err != nil {
+		return nil, fmt.Errorf("failed to write csv: %w", err)
+	}
+	return &csvWriter{out: csv.NewWriter(w)}, nil
+}
+
+func (w *csvWriter) WriteRow(cells ...interface{}) error {
+	record := make([]string, len(cells))
+	for i, cell := range cells {
+		record[i] = formatCell(cell)
+	}
+	if err := w.out.Write(record); err != nil {
+		return fmt.Errorf("failed to write csv row: %w", err)
+	}
+	w.rows++
+	if w.rows%csvFlushInterval == 0 {
+		w.out.Flush()
+		return w.out.Error()
+	}
+	return nil
+}
+
+func (w *csvWriter) Close() error {
+	w.out.Flush()
+	return w.out.Error()
+}
+
+// xlsxWriter 使用 excelize 的流式写入，超过内存阈值的行会暂存到临时文件，内存占用不随行数增长。
+// 但 StreamWriter 只是在本地缓存工作表，XLSX 的 zip 包要在 Close 时才能生成并写入 out，
+// 因此写完最后一行之前客户端收不到任何数据，数据量大时 (如全部生产记录) 应使用 CSV 导出
+type xlsxWriter struct {
+	out    io.Writer
+	file   *excelize.File
+	stream *excelize.StreamWriter
+	rows   int
+}
+
+func newXLSXWriter(w io.Writer, sheetName string) (*xlsxWriter, error) {
+	file := excelize.NewFile()
+	defaultSheet := file.GetSheetName(0)
+	if sheetName != "" && sheetName != defaultSheet {
+		if err := file.SetSheetName(defaultSheet, sheetName); err != nil {
+			file.Close()
+			return nil, fmt.Errorf("failed to name worksheet: %w", err)
+		}
+	} else {
+		sheetName = defaultSheet
+	}
+	stream, err := file.NewStreamWriter(sheetName)
+	if err != nil {
+		file.Close()
+		return nil, fmt.Errorf("failed to create xlsx stream writer: %w", err)
+	}
+	return &xlsxWriter{out: w, file: file, stream: stream}, nil
+}
+
+func (w *xlsxWriter) WriteRow(cells ...interface{}) error {
+	w.rows++
+	cell, err := excelize.CoordinatesToCellName(1, w.rows)
+	if err != nil {
+		return err
+	}
+	values := make([]interface{}, len(cells))
+	for i, value := range cells {
+		switch v := value.(type) {
+		case int, int64:
+			values[i] = v
+		case *int:
+			if v != nil {
+				values[i] = *v
+			}
+		default:
+			values[i] = formatCell(v)
+		}
+	}
+	if err := w.stream.SetRow(cell, values); err != nil {
+		return fmt.Errorf("failed to write xlsx row: %w", err)
+	}
+	return nil
+}
+
+func (w *xlsxWriter) Close() error {
+	defer w.file.Close()
+	if err := w.stream.Flush(); err != nil {
+		return fmt.Errorf("failed to flush xlsx: %w", err)
+	}
+	if err := w.file.Write(w.out); err != nil {
+		return fmt.Errorf("failed to write xlsx: %w", err)
+	}
+	return nil
+}
+
+func formatCell(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case int:
+		return strconv.Itoa(v)
+	case int64:
+		return strconv.FormatInt(v, 10)
+	case *int:
+		if v == nil {
+			return ""
+		}
+		return strconv.Itoa(*v)
+	case *string:
+		if v == nil {
+			return ""
+		}
+		return *v
+	case time.Time:
+		return v.Format("2006-01-02 15:04:05")
+	default:
+		return fmt.Sprint(v)
+	}
+}
@@ -1,8 +1,17 @@
 import axios, { AxiosInstance, AxiosResponse } from 'axios';
-import { APIResponse, ApiConfig } from '../types';
+import { APIResponse, ApiConfig, LoginResponse } from '../types';
+
+// 清除本地登录状态并回到登录页
+const clearSession = () => {
+  localStorage.removeItem('token');
+  localStorage.removeItem('refresh_token');
+  localStorage.removeItem('user');
+  window.location.href = '/login';
+};
 
 class ApiService {
   private instance: AxiosInstance;
+  private refreshing: Promise<string> | null = null;
 
   constructor(config: ApiConfig) {
     this.instance = axios.create({
@@ -13,9 +22,13 @@ class ApiService {
       },
     });
 
-    // 请求拦截器
+    // 请求拦截器：附加访问令牌
     this.instance.interceptors.request.use(
       (config) => {
+        const token = localStorage.getItem('token');
+        if (token) {
+          config.headers.Authorization = `Bearer ${token}`;
+        }
         return config;
       },
       (error) => {
@@ -28,7 +41,19 @@ class ApiService {
       (response: AxiosResponse<APIResponse>) => {
         return response;
       },
-      (error) => {
+      async (error) => {
+        // 访问令牌失效时先尝试用刷新令牌续期并重试一次，失败则回到登录页
+        const original = error.config;
+        if (error.response?.status === 401 && localStorage.getItem('token') && !original?._retried) {
+          try {
+            const token = await this.refreshToken();
+            original._retried = true;
+            original.headers.Authorization = `Bearer ${token}`;
+            return this.instance(original);
+          } catch {
+            clearSession();
+          }
+        }
         // 优先返回后端自定义的错误信息
         const errorMessage = error.response?.data?.error || error.message || 'Request failed';
         console.error('API Error:', errorMessage);
@@ -37,6 +62,25 @@ class ApiService {
     );
   }
 
+  // 使用刷新令牌换取新的访问令牌，并发请求共用同一次刷新
+  private refreshToken(): Promise<string> {
+    if (!this.refreshing) {
+      const refreshToken = localStorage.getItem('refresh_token');
+      this.refreshing = axios
+        .post<APIResponse<LoginResponse>>(`${this.instance.defaults.baseURL}/auth/refresh`, { refresh_token: refreshToken })
+        .then((response) => {
+          const data = response.data.data as LoginResponse;
+          localStorage.setItem('token', data.token);
+          localStorage.setItem('refresh_token', data.refresh_token);
+          return data.token;
+        })
+        .finally(() => {
+          this.refreshing = null;
+        });
+    }
+    return this.refreshing;
+  }
+
   // 通用GET请求
   async get<T>(url: string): Promise<T> {
     const response = await this.instance.get<APIResponse<T>>(url);
@@ -1,6 +1,8 @@
 import { apiService } from './api';
-import { User, LoginRequest } from '../types';
+import { LoginRequest, LoginResponse } from '../types';
 
 export const authService = {
-  login: (data: LoginRequest) => apiService.post<User>('/auth/login', data),
+  login: (data: LoginRequest) => apiService.post<LoginResponse>('/auth/login', data),
+  logout: (refreshToken: string) => apiService.post('/auth/logout', { refresh_token: refreshToken }),
+  changePassword: (data: { old_password: string; new_password: string }) => apiService.put('/auth/password', data),
 };
\ No newline at end of file
@@ -17,10 +17,12 @@ export const useAuthStore = create<AuthState & AuthActions>((set) => ({
   login: async (data) => {
     set({ loading: true, error: null });
     try {
-      const user = await authService.login(data);
+      const { token, refresh_token, worker: user } = await authService.login(data);
       set({ isAuthenticated: true, user, loading: false });
-      // 将用户信息存储到 localStorage
+      // 将用户信息和令牌存储到 localStorage
       localStorage.setItem('user', JSON.stringify(user));
+      localStorage.setItem('token', token);
+      localStorage.setItem('refresh_token', refresh_token);
     } catch (error) {
       const errorMessage = (error as Error).message;
       set({ error: errorMessage, loading: false, isAuthenticated: false, user: null });
@@ -29,19 +31,28 @@ export const useAuthStore = create<AuthState & AuthActions>((set) => ({
   },
 
   logout: () => {
+    // 通知服务端吊销会话，失败不影响本地注销
+    const refreshToken = localStorage.getItem('refresh_token');
+    if (refreshToken) {
+      authService.logout(refreshToken).catch(() => undefined);
+    }
     set({ isAuthenticated: false, user: null });
     localStorage.removeItem('user');
+    localStorage.removeItem('token');
+    localStorage.removeItem('refresh_token');
   },
 
   checkAuth: () => {
     const userStr = localStorage.getItem('user');
-    if (userStr) {
+    if (userStr && localStorage.getItem('token')) {
       try {
         const user = JSON.parse(userStr) as User;
         set({ isAuthenticated: true, user });
       } catch (e) {
         set({ isAuthenticated: false, user: null });
         localStorage.removeItem('user');
+        localStorage.removeItem('token');
+        localStorage.removeItem('refresh_token');
       }
     }
   },
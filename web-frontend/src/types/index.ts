@@ -25,6 +25,14 @@ export interface LoginRequest {
   password?: string;
 }
 
+export interface LoginResponse {
+  token: string;
+  expires_at: string;
+  refresh_token: string;
+  refresh_expires_at: string;
+  worker: User;
+}
+
 export interface AuthState extends AppState {
   isAuthenticated: boolean;
   user: User | null;